@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+const maxBatchSize = 1000
+
+var (
+	errBatchFailed     = errors.New("batch failed")
+	errBatchRolledBack = errors.New("rolled back as another operation failed")
+)
+
+// BatchOperation is one create, update or delete of a batch. Update and
+// delete act on Id and are conditional on Version unless it is 0.
+type BatchOperation struct {
+	Op      string  `json:"op"`
+	Id      int     `json:"id,omitempty"`
+	Version int     `json:"version,omitempty"`
+	Product Product `json:"product"`
+}
+
+// BatchResult is the outcome of the operation at Index. Product is the
+// product as stored after a create or update.
+type BatchResult struct {
+	Index   int
+	Op      string
+	Product *Product
+	Err     error
+}
+
+func validateBatch(operations []BatchOperation) error {
+	failures := make([]string, 0)
+
+	if len(operations) == 0 {
+		failures = append(failures, "operations should not be empty")
+	}
+	if len(operations) > maxBatchSize {
+		failures = append(failures, fmt.Sprintf("operations should not be more than %d", maxBatchSize))
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// applyBatchOperation runs operation through s, whose repo is bound to the
+// batch transaction.
+func (s *ProductServiceImpl) applyBatchOperation(operation BatchOperation) (*Product, error) {
+	switch operation.Op {
+	case BatchCreate:
+		created, err := s.Create(operation.Product)
+		if err != nil {
+			return nil, err
+		}
+		return &created, nil
+	case BatchUpdate:
+		product := operation.Product
+		product.Id = operation.Id
+		product.Version = operation.Version
+		if err := s.Update(product); err != nil {
+			return nil, err
+		}
+		updated, err := s.GetById(operation.Id)
+		if err != nil {
+			return nil, err
+		}
+		return &updated, nil
+	case BatchDelete:
+		return nil, s.Delete(operation.Id, operation.Version)
+	default:
+		return nil, &validationError{failures: []string{"Op should be one of create, update, delete"}}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "errors"
+
+// eventLogSize is how many events are kept for clients catching up, older
+// ones are dropped and those clients get a snapshot instead.
+const eventLogSize = 1000
+
+var errEventLogTruncated = errors.New("events were dropped from the log")
+
+// EventLog numbers events and keeps the latest eventLogSize of them so a
+// subscriber that reconnects can be sent what it missed. Instances of the
+// server sharing a log share the numbering.
+type EventLog interface {
+	// AppendEvent stores event under the next seq and returns it numbered.
+	AppendEvent(Event) (Event, error)
+	// EventsSince returns the events after seq in order, or
+	// errEventLogTruncated when some of them are no longer kept.
+	EventsSince(seq int64) ([]Event, error)
+	LastEventSeq() (int64, error)
+}
+
+func (r *InMemoryRepo) AppendEvent(event Event) (Event, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	event.Seq = r.lastEventSeq + 1
+	r.events = append(r.events, event)
+	if len(r.events) > eventLogSize {
+		dropped := len(r.events) - eventLogSize
+		r.trimmedEventSeq = r.events[dropped-1].Seq
+		r.events = append([]Event(nil), r.events[dropped:]...)
+	}
+	r.lastEventSeq = event.Seq
+	return event, nil
+}
+
+func (r *InMemoryRepo) EventsSince(seq int64) ([]Event, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	// seqs may skip, so only the highest dropped seq tells whether events
+	// after seq are gone
+	if seq > r.lastEventSeq || seq < r.trimmedEventSeq {
+		return nil, errEventLogTruncated
+	}
+	events := make([]Event, 0)
+	for _, event := range r.events {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *InMemoryRepo) LastEventSeq() (int64, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	return r.lastEventSeq, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func appendTestEvents(t *testing.T, events EventLog, from, to int64) {
+	for seq := from; seq <= to; seq++ {
+		product := Product{Id: int(seq), Brand: "A", Category: "A"}
+		event, err := events.AppendEvent(Event{Type: EventProductCreated, Product: &product})
+		if err != nil {
+			t.Fatal("unable to append event:", err)
+		}
+		if event.Seq != seq {
+			t.Fatalf("expect event to be numbered %d, got %d", seq, event.Seq)
+		}
+	}
+}
+
+func TestInMemoryRepo_EventsSince(t *testing.T) {
+	tests := []struct {
+		name     string
+		appended int64
+		since    int64
+		wantSeqs []int64
+		wantErr  error
+	}{
+		{
+			name:     "all events",
+			appended: 3,
+			since:    0,
+			wantSeqs: []int64{1, 2, 3},
+		},
+		{
+			name:     "events after since",
+			appended: 3,
+			since:    2,
+			wantSeqs: []int64{3},
+		},
+		{
+			name:     "nothing missed",
+			appended: 3,
+			since:    3,
+			wantSeqs: []int64{},
+		},
+		{
+			name:     "since is ahead of the log",
+			appended: 3,
+			since:    5,
+			wantErr:  errEventLogTruncated,
+		},
+		{
+			name:     "oldest events dropped",
+			appended: eventLogSize + 2,
+			since:    1,
+			wantErr:  errEventLogTruncated,
+		},
+		{
+			name:     "events still kept",
+			appended: eventLogSize + 2,
+			since:    eventLogSize,
+			wantSeqs: []int64{eventLogSize + 1, eventLogSize + 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewInMemoryRepo()
+			appendTestEvents(t, repo, 1, tt.appended)
+
+			events, err := repo.EventsSince(tt.since)
+
+			assert.ErrorIs(t, err, tt.wantErr, "error should match")
+			if tt.wantErr == nil {
+				seqs := make([]int64, 0)
+				for _, event := range events {
+					seqs = append(seqs, event.Seq)
+				}
+				assert.Equal(t, tt.wantSeqs, seqs, "expect events in order")
+			}
+			assert.LessOrEqual(t, len(repo.events), eventLogSize, "expect log to stay bounded")
+
+			last, err := repo.LastEventSeq()
+			assert.NoError(t, err, "expect no error")
+			assert.Equal(t, tt.appended, last, "expect last appended seq")
+		})
+	}
+}
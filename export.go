@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+const exportBatchSize = 500
+
+var errUnsupportedExportFormat = errors.New("unsupported export format")
+
+// exportColumns are the columns of CSV and XLSX exports, they are named so the
+// file can be imported again.
+var exportColumns = []string{"id", "sku", "brand", "category", "quantity", "price", "version", "createdAt", "updatedAt"}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	newExporter func(io.Writer) (productExporter, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":   {contentType: "text/csv; charset=utf-8", extension: "csv", newExporter: newCSVExporter},
+	"jsonl": {contentType: "application/x-ndjson", extension: "jsonl", newExporter: newJSONLExporter},
+	"xlsx":  {contentType: xlsxContentType, extension: "xlsx", newExporter: newXLSXExporter},
+}
+
+// productExporter writes products one at a time; close finishes the file.
+type productExporter interface {
+	write(Product) error
+	close() error
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (productExporter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return &csvExporter{writer: writer}, nil
+}
+
+func (e *csvExporter) write(product Product) error {
+	return e.writer.Write([]string{
+		strconv.Itoa(product.Id),
+		product.Sku,
+		product.Brand,
+		product.Category,
+		strconv.Itoa(product.Quantity),
+		strconv.FormatFloat(product.Price, 'f', -1, 64),
+		strconv.Itoa(product.Version),
+		product.CreatedAt.Format(time.RFC3339),
+		product.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (e *csvExporter) close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonlExporter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLExporter(w io.Writer) (productExporter, error) {
+	return &jsonlExporter{encoder: json.NewEncoder(w)}, nil
+}
+
+func (e *jsonlExporter) write(product Product) error {
+	return e.encoder.Encode(product)
+}
+
+func (e *jsonlExporter) close() error {
+	return nil
+}
+
+type xlsxExporter struct {
+	writer *xlsxWriter
+}
+
+func newXLSXExporter(w io.Writer) (productExporter, error) {
+	writer, err := newXLSXWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]interface{}, len(exportColumns))
+	for index, column := range exportColumns {
+		header[index] = column
+	}
+	if err := writer.writeRow(header...); err != nil {
+		return nil, err
+	}
+	return &xlsxExporter{writer: writer}, nil
+}
+
+func (e *xlsxExporter) write(product Product) error {
+	return e.writer.writeRow(
+		product.Id,
+		product.Sku,
+		product.Brand,
+		product.Category,
+		product.Quantity,
+		product.Price,
+		product.Version,
+		product.CreatedAt.Format(time.RFC3339),
+		product.UpdatedAt.Format(time.RFC3339),
+	)
+}
+
+func (e *xlsxExporter) close() error {
+	return e.writer.close()
+}
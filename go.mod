@@ -2,14 +2,16 @@ module bitbucket.org/midaas-telemetry/amritraj
 
 go 1.19
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	github.com/uptrace/bun v1.1.12
+	github.com/uptrace/bun/dialect/pgdialect v1.1.12
+	github.com/uptrace/bun/driver/pgdriver v1.1.12
+)
 
 require (
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/tmthrgd/go-hex v0.0.0-20190904060850-447a3041c3bc // indirect
-	github.com/uptrace/bun v1.1.12 // indirect
-	github.com/uptrace/bun/dialect/pgdialect v1.1.12 // indirect
-	github.com/uptrace/bun/driver/pgdriver v1.1.12 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/crypto v0.6.0 // indirect
@@ -22,7 +24,7 @@ require (
 	github.com/gorilla/websocket v1.5.0
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.1 // indirect
+	github.com/stretchr/testify v1.8.1
 	github.com/uptrace/bun/dbfixture v1.1.12
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
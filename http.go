@@ -1,18 +1,37 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 )
 
+var (
+	errIfMatchRequired = errors.New("if-match header required")
+	errInvalidIfMatch  = errors.New("invalid if-match header")
+	errNoStreaming     = errors.New("response writer cannot stream")
+)
+
 type httpTransport struct {
 	service ProductService
+	// heartbeatInterval is how often an event stream gets a comment and a
+	// websocket a ping, so proxies do not time out the connection
+	heartbeatInterval time.Duration
 }
 
 type ErrorResponse struct {
@@ -35,44 +54,220 @@ func writeError(w http.ResponseWriter, statusCode int, errors ...string) {
 	}
 }
 
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("failed to encode:", err)
+	}
+}
+
 func handleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnsupportedPatch) {
+		w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+	}
+	statusCode, messages := errorStatus(err)
+	writeError(w, statusCode, messages...)
+}
+
+// errorStatus maps an error to the status code and messages it is reported
+// with.
+func errorStatus(err error) (int, []string) {
 	var se *json.SyntaxError
 	if errors.As(err, &se) {
-		writeError(w, http.StatusBadRequest, "invalid json")
-		return
+		return http.StatusBadRequest, []string{"invalid json"}
 	}
 
 	if errors.Is(err, errDuplicateId) {
-		writeError(w, http.StatusConflict, "product exists")
-		return
+		return http.StatusConflict, []string{"product exists"}
+	}
+	if errors.Is(err, errDuplicateSku) {
+		return http.StatusConflict, []string{"sku exists"}
 	}
 	if errors.Is(err, errProductNotFound) {
-		writeError(w, http.StatusNotFound, "product not found")
-		return
+		return http.StatusNotFound, []string{"product not found"}
+	}
+	if errors.Is(err, errVersionConflict) {
+		return http.StatusPreconditionFailed, []string{"product was modified, reload it and retry"}
+	}
+	if errors.Is(err, errIfMatchRequired) {
+		return http.StatusPreconditionRequired, []string{"If-Match header is required"}
+	}
+	if errors.Is(err, errInvalidIfMatch) {
+		return http.StatusBadRequest, []string{"invalid If-Match header"}
+	}
+	if errors.Is(err, errBatchRolledBack) {
+		return http.StatusFailedDependency, []string{"rolled back as another operation failed"}
+	}
+	if errors.Is(err, errPatchTestFailed) {
+		return http.StatusConflict, []string{"patch test failed"}
+	}
+	if errors.Is(err, errUnsupportedPatch) {
+		return http.StatusUnsupportedMediaType, []string{"unsupported patch type"}
+	}
+	if errors.Is(err, errInsufficientStock) {
+		return http.StatusConflict, []string{"insufficient stock"}
+	}
+	if errors.Is(err, errWarehouseNotFound) {
+		return http.StatusNotFound, []string{"warehouse not found"}
+	}
+	if errors.Is(err, errLocationNotFound) {
+		return http.StatusNotFound, []string{"location not found"}
+	}
+	if errors.Is(err, errDuplicateCode) {
+		return http.StatusConflict, []string{"code exists"}
+	}
+	if errors.Is(err, errLotNotFound) {
+		return http.StatusNotFound, []string{"lot not found"}
+	}
+	if errors.Is(err, errDuplicateLot) {
+		return http.StatusConflict, []string{"lot number exists"}
+	}
+	if errors.Is(err, errWebhookNotFound) {
+		return http.StatusNotFound, []string{"webhook not found"}
+	}
+	if errors.Is(err, errDeliveryNotFound) {
+		return http.StatusNotFound, []string{"delivery not found"}
+	}
+	if errors.Is(err, errDeliveryNotDead) {
+		return http.StatusConflict, []string{"only dead deliveries can be retried"}
+	}
+	if errors.Is(err, errReservationNotFound) {
+		return http.StatusNotFound, []string{"reservation not found"}
+	}
+	if errors.Is(err, errReservationNotActive) {
+		return http.StatusConflict, []string{"reservation is no longer active"}
+	}
+	if errors.Is(err, errSupplierNotFound) {
+		return http.StatusNotFound, []string{"supplier not found"}
+	}
+	if errors.Is(err, errPurchaseOrderNotFound) {
+		return http.StatusNotFound, []string{"purchase order not found"}
+	}
+	if errors.Is(err, errSalesOrderNotFound) {
+		return http.StatusNotFound, []string{"sales order not found"}
+	}
+	if errors.Is(err, errTransferOrderNotFound) {
+		return http.StatusNotFound, []string{"transfer order not found"}
+	}
+	if errors.Is(err, errRmaNotFound) {
+		return http.StatusNotFound, []string{"rma not found"}
+	}
+	if errors.Is(err, errRmaLineNotFound) {
+		return http.StatusNotFound, []string{"rma line not found"}
+	}
+	if errors.Is(err, errRmaLineNotQuarantined) {
+		return http.StatusConflict, []string{"rma line is not in quarantine"}
+	}
+	var te *transitionError
+	if errors.As(err, &te) {
+		return http.StatusConflict, []string{te.Error()}
+	}
+	if errors.Is(err, errSearchNotSupported) {
+		return http.StatusNotImplemented, []string{"search not supported"}
+	}
+	if errors.Is(err, errUnsupportedImportType) {
+		return http.StatusUnsupportedMediaType, []string{"unsupported import type, upload csv or xlsx"}
+	}
+	if errors.Is(err, errUnsupportedExportFormat) {
+		return http.StatusBadRequest, []string{"format should be one of csv, jsonl, xlsx"}
+	}
+	if errors.Is(err, errInvalidXLSX) {
+		return http.StatusBadRequest, []string{err.Error()}
+	}
+	var ie *importError
+	if errors.As(err, &ie) {
+		messages := make([]string, 0, len(ie.rows))
+		for _, row := range ie.rows {
+			_, rowMessages := errorStatus(row.Err)
+			if len(rowMessages) == 0 {
+				rowMessages = []string{"internal error"}
+			}
+			for _, message := range rowMessages {
+				messages = append(messages, fmt.Sprintf("row %d: %s", row.Line, message))
+			}
+		}
+		return http.StatusBadRequest, messages
 	}
 	var ve *validationError
 	if errors.As(err, &ve) {
-		writeError(w, http.StatusBadRequest, ve.failures...)
-		return
+		return http.StatusBadRequest, ve.failures
+	}
+
+	return http.StatusInternalServerError, nil
+}
+
+// etag renders a product version as a strong entity tag.
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// listETag is a weak entity tag over the ids and versions of a product list,
+// so it changes whenever any listed product does.
+func listETag(products []Product) string {
+	hash := fnv.New64a()
+	for _, product := range products {
+		fmt.Fprintf(hash, "%d:%d;", product.Id, product.Version)
+	}
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// parseIfMatch returns the version a write is conditional on. "*" matches any
+// version and is returned as 0. Weak tags are accepted as proxies may weaken
+// the tags they pass on.
+func parseIfMatch(r *http.Request) (int, error) {
+	ifMatch := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	if ifMatch == "" {
+		return 0, errIfMatchRequired
+	}
+	if ifMatch == "*" {
+		return 0, nil
 	}
 
-	w.WriteHeader(http.StatusInternalServerError)
+	unquoted, err := strconv.Unquote(ifMatch)
+	if err != nil {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, errInvalidIfMatch
+	}
+	return version, nil
 }
 
 func NewhttpTransport(svc ProductService) *httpTransport {
 	return &httpTransport{
-		service: svc,
+		service:           svc,
+		heartbeatInterval: defaultHeartbeatInterval,
 	}
 }
 
-func buildHttpHandler(t *httpTransport) http.Handler {
+// routeRegistrar is implemented by the transports of the other subsystems so
+// they can be mounted next to the product routes.
+type routeRegistrar interface {
+	registerRoutes(r *mux.Router)
+}
+
+func buildHttpHandler(t *httpTransport, registrars ...routeRegistrar) http.Handler {
 	r := mux.NewRouter()
 	r.HandleFunc("/products", t.Create).Methods("POST")
 	r.HandleFunc("/products/{id}", t.Update).Methods("PUT")
+	r.HandleFunc("/products/{id}", t.Patch).Methods("PATCH")
 	r.HandleFunc("/products", t.GetAll).Methods("GET")
+	r.HandleFunc("/products:batch", t.Batch).Methods("POST")
+	r.HandleFunc("/products/import", t.Import).Methods("POST")
+	r.HandleFunc("/products/search", t.Search).Methods("GET")
+	r.HandleFunc("/products/export", t.Export).Methods("GET")
+	r.HandleFunc("/products/events", t.Events).Methods("GET")
+	r.HandleFunc("/products/low-stock", t.GetLowStock).Methods("GET")
 	r.HandleFunc("/products/{id}", t.GetById).Methods("GET")
+	r.HandleFunc("/products/sku/{sku}", t.GetBySku).Methods("GET")
 	r.HandleFunc("/products/{id}", t.Delete).Methods("DELETE")
+	r.HandleFunc("/products/{id}/movements", t.AddMovement).Methods("POST")
+	r.HandleFunc("/products/{id}/movements", t.GetMovements).Methods("GET")
 	r.HandleFunc("/ws", t.wsEndpoint)
+	for _, registrar := range registrars {
+		registrar.registerRoutes(r)
+	}
 	return r
 }
 
@@ -84,22 +279,17 @@ func (t *httpTransport) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := t.service.Create(product); err != nil {
+	created, err := t.service.Create(product)
+	if err != nil {
 		handleError(w, err)
 		return
 	}
 
+	w.Header().Set("Location", fmt.Sprintf("/products/%d", created.Id))
+	w.Header().Set("ETag", etag(created.Version))
 	w.WriteHeader(http.StatusCreated)
 
-	gotProduct, gotProductErr := t.service.GetById(product.Id)
-
-	if gotProductErr != nil {
-		log.Println("unable to get product:", gotProductErr)
-		handleError(w, gotProductErr)
-		return
-	}
-
-	if err := json.NewEncoder(w).Encode(gotProduct); err != nil {
+	if err := json.NewEncoder(w).Encode(created); err != nil {
 		log.Println("failed to encode:", err)
 		return
 	}
@@ -121,7 +311,14 @@ func (t *httpTransport) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
 	product.Id = id
+	product.Version = version
 	if err := t.service.Update(product); err != nil {
 		handleError(w, err)
 		return
@@ -135,12 +332,222 @@ func (t *httpTransport) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", etag(gotProduct.Version))
 	if err := json.NewEncoder(w).Encode(gotProduct); err != nil {
 		log.Println("failed to encode:", err)
 		return
 	}
 }
 
+// Patch takes a merge patch or a JSON patch, told apart by the Content-Type.
+// Plain application/json is read as a merge patch.
+func (t *httpTransport) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ID")
+		return
+	}
+
+	patchType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		handleError(w, errUnsupportedPatch)
+		return
+	}
+	if patchType == "application/json" {
+		patchType = mergePatchContentType
+	}
+
+	document, err := io.ReadAll(r.Body)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	version, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	patched, err := t.service.Patch(id, version, ProductPatch{Type: patchType, Document: document})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(patched.Version))
+	writeJSON(w, http.StatusOK, patched)
+}
+
+type BatchRequest struct {
+	Partial    bool             `json:"partial"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchItemResponse struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	Status  int      `json:"status"`
+	Product *Product `json:"product,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchItemResponse `json:"results"`
+}
+
+// Batch answers 200 when every operation succeeded and 207 otherwise, the
+// status of each operation is in its result.
+func (t *httpTransport) Batch(w http.ResponseWriter, r *http.Request) {
+	var request BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	results, err := t.service.Batch(request.Operations, request.Partial)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	statusCode := http.StatusOK
+	response := BatchResponse{Results: make([]BatchItemResponse, 0, len(results))}
+	for _, result := range results {
+		item := BatchItemResponse{
+			Index:   result.Index,
+			Op:      result.Op,
+			Status:  http.StatusOK,
+			Product: result.Product,
+		}
+		if result.Op == BatchCreate {
+			item.Status = http.StatusCreated
+		}
+		if result.Err != nil {
+			item.Status, item.Errors = errorStatus(result.Err)
+			statusCode = http.StatusMultiStatus
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	writeJSON(w, statusCode, response)
+}
+
+// Import takes a CSV or XLSX file, either as the request body or as the
+// "file" field of a multipart form. ?mode=upsert updates existing products,
+// ?dryRun=true only reports what would change and ?map=header:field maps a
+// column whose header is not a field name.
+func (t *httpTransport) Import(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := ImportOptions{Mode: query.Get("mode")}
+	if options.Mode == "" {
+		options.Mode = ImportCreate
+	}
+	if dryRun := query.Get("dryRun"); dryRun != "" {
+		var err error
+		if options.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid dryRun")
+			return
+		}
+	}
+	mapping, err := parseImportMapping(query["map"])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var records [][]string
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "file is required")
+			return
+		}
+		defer file.Close()
+		fileType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+		records, err = readImportRecords(file, fileType, header.Filename)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+	} else if records, err = readImportRecords(r.Body, mediaType, ""); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rows, err := parseImportRows(records, mapping)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	summary, err := t.service.Import(rows, options)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// Export streams the products matching the list filters as a csv, jsonl or
+// xlsx download. The response is only started once the first product is
+// read, so errors up to then are still reported as such.
+func (t *httpTransport) Export(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	formatName := values.Get("format")
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, ok := exportFormats[formatName]
+	if !ok {
+		handleError(w, errUnsupportedExportFormat)
+		return
+	}
+
+	query, err := parseProductQuery(values)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	var exporter productExporter
+	started := false
+	start := func() error {
+		started = true
+		filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("20060102"), format.extension)
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		w.WriteHeader(http.StatusOK)
+		exporter, err = format.newExporter(w)
+		return err
+	}
+
+	err = t.service.Export(query, func(product Product) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return exporter.write(product)
+	})
+	if err != nil && !started {
+		handleError(w, err)
+		return
+	}
+	if err == nil && !started {
+		err = start()
+	}
+	if err != nil {
+		log.Println("export aborted:", err)
+		return
+	}
+	if err := exporter.close(); err != nil {
+		log.Println("failed to finish export:", err)
+	}
+}
+
 func (t *httpTransport) GetById(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
@@ -157,6 +564,7 @@ func (t *httpTransport) GetById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", etag(product.Version))
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(product); err != nil {
 		log.Println("failed to encode:", err)
@@ -164,20 +572,76 @@ func (t *httpTransport) GetById(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (t *httpTransport) GetBySku(w http.ResponseWriter, r *http.Request) {
+	product, err := t.service.GetBySku(mux.Vars(r)["sku"])
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(product.Version))
+	writeJSON(w, http.StatusOK, product)
+}
+
+// GetAll lists products one page at a time, defaultProductQueryLimit products
+// unless limit says otherwise. The body stays a plain array so existing clients
+// keep working; paging metadata is sent in the X-Total-Count and X-Next-Cursor
+// headers.
 func (t *httpTransport) GetAll(w http.ResponseWriter, r *http.Request) {
-	products, err := t.service.GetAll()
+	query, err := parseProductQuery(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	page, err := t.service.List(query)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", listETag(page.Products))
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(products); err != nil {
+	if err := json.NewEncoder(w).Encode(page.Products); err != nil {
 		log.Println("failed to encode:", err)
 		return
 	}
 }
 
+func (t *httpTransport) GetLowStock(w http.ResponseWriter, r *http.Request) {
+	products, err := t.service.GetLowStock()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (t *httpTransport) Search(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	results, err := t.service.Search(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
 func (t *httpTransport) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
@@ -189,13 +653,72 @@ func (t *httpTransport) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := t.service.Delete(id); err != nil {
+	version, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if err := t.service.Delete(id, version); err != nil {
 		handleError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
+func (t *httpTransport) AddMovement(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	idstr := vars["id"]
+	id, err := strconv.Atoi(idstr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var movement StockMovement
+	if err := json.NewDecoder(r.Body).Decode(&movement); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	movement.ProductId = id
+	created, err := t.service.AddMovement(movement)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Println("failed to encode:", err)
+		return
+	}
+}
+
+func (t *httpTransport) GetMovements(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	idstr := vars["id"]
+	id, err := strconv.Atoi(idstr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	movements, err := t.service.GetMovements(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(movements); err != nil {
+		log.Println("failed to encode:", err)
+		return
+	}
+}
+
 var upgrader = websocket.Upgrader{ //struct
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -203,6 +726,12 @@ var upgrader = websocket.Upgrader{ //struct
 }
 
 func (t *httpTransport) wsEndpoint(w http.ResponseWriter, r *http.Request) {
+	since, resuming, err := parseSince(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("error while upgrading HTTP to Web Socket:", err)
@@ -218,7 +747,12 @@ func (t *httpTransport) wsEndpoint(w http.ResponseWriter, r *http.Request) {
 	log.Println("client connected", ws.RemoteAddr())
 
 	sub := &subscribeWebSocket{w: ws}
-	if err := t.service.subscribe(sub); err != nil {
+	if resuming {
+		err = t.service.resume(sub, since)
+	} else {
+		err = t.service.subscribe(sub)
+	}
+	if err != nil {
 		log.Println("error while subscribe with web socket:", err)
 		return
 	}
@@ -230,25 +764,297 @@ func (t *httpTransport) wsEndpoint(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	// a client that answers neither pings nor sends anything for two
+	// heartbeats is gone
+	pongWait := 2 * t.heartbeatInterval
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	stopPings := make(chan struct{})
+	defer close(stopPings)
+	go sub.ping(t.heartbeatInterval, stopPings)
+
 	for {
-		if _, _, err := ws.ReadMessage(); err != nil {
+		if err := ws.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+			log.Println("failed to set read deadline", err)
+			return
+		}
+		_, data, err := ws.ReadMessage()
+		if err != nil {
 			log.Println("failed to read", err)
 			return
 		}
+		if err := t.handleSubscriptionMessage(sub, data); err != nil {
+			sub.writeError(err)
+		}
 	}
 }
 
+const (
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+)
+
+// SubscriptionMessage is what a client sends over /ws to only get the events
+// of some products. Filters are named so they can be removed again; a
+// client that never subscribes gets every event.
+type SubscriptionMessage struct {
+	Type   string      `json:"type"`
+	Name   string      `json:"name"`
+	Filter EventFilter `json:"filter"`
+}
+
+// SubscriptionError is sent back over /ws for a message that failed.
+type SubscriptionError struct {
+	Type   string   `json:"type"`
+	Errors []string `json:"errors"`
+}
+
+func (t *httpTransport) handleSubscriptionMessage(sub Subscriber, data []byte) error {
+	var message SubscriptionMessage
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&message); err != nil {
+		return &validationError{failures: []string{"message should be a subscribe or unsubscribe object"}}
+	}
+
+	switch message.Type {
+	case MessageSubscribe:
+		return t.service.setFilter(sub, message.Name, message.Filter)
+	case MessageUnsubscribe:
+		return t.service.removeFilter(sub, message.Name)
+	default:
+		return &validationError{failures: []string{"type should be one of subscribe, unsubscribe"}}
+	}
+}
+
+// parseSince reads the seq of the last event a reconnecting client has seen.
+func parseSince(r *http.Request) (int64, bool, error) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		return 0, false, nil
+	}
+	since, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || since < 0 {
+		return 0, false, &validationError{failures: []string{"since should be a whole number not less than 0"}}
+	}
+	return since, true, nil
+}
+
+// wsWriteWait is how long a write may take before the client is considered
+// gone.
+const wsWriteWait = 10 * time.Second
+
 type subscribeWebSocket struct {
-	w *websocket.Conn
+	// mu serialises writes, the connection allows only one writer at a time
+	mu sync.Mutex
+	w  *websocket.Conn
 }
 
-func (s *subscribeWebSocket) Update(products []Product) {
-	if err := s.w.WriteJSON(products); err != nil {
+func (s *subscribeWebSocket) Update(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.w.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+		log.Println("error while setting write deadline", err)
+		return
+	}
+	if err := s.w.WriteJSON(event); err != nil {
 		log.Println("error while encoding", err)
 		return
 	}
 }
 
+// ping sends a ping every interval until stop is closed.
+func (s *subscribeWebSocket) ping(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.w.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				log.Println("failed to ping", err)
+				return
+			}
+		}
+	}
+}
+
+// Close disconnects the client, which ends wsEndpoint.
+func (s *subscribeWebSocket) Close() error {
+	return s.w.Close()
+}
+
+func (s *subscribeWebSocket) writeError(err error) {
+	_, messages := errorStatus(err)
+	if len(messages) == 0 {
+		messages = []string{err.Error()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.w.WriteJSON(SubscriptionError{Type: "error", Errors: messages}); err != nil {
+		log.Println("error while encoding", err)
+	}
+}
+
 func (s *subscribeWebSocket) Id() string {
 	return s.w.RemoteAddr().String()
 }
+
+const defaultHeartbeatInterval = 15 * time.Second
+
+// eventStreamWriteWait is how long writing an event to a stream may take
+// before the client is considered gone, like wsWriteWait.
+const eventStreamWriteWait = 10 * time.Second
+
+type connContextKey struct{}
+
+// withConn is meant as http.Server.ConnContext. It makes the connection of a
+// request reachable from its handler, which Events needs to time out writes
+// and to close the stream of a slow subscriber; without it event streams have
+// neither.
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// Events streams the same events as /ws as Server-Sent Events, for clients
+// behind proxies that do not pass WebSocket upgrades. A reconnecting
+// EventSource sends the id of the last event it got in Last-Event-ID and
+// resumes from there.
+func (t *httpTransport) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(w, errNoStreaming)
+		return
+	}
+
+	since, resuming, err := parseSince(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		since, err = strconv.ParseInt(lastEventId, 10, 64)
+		if err != nil || since < 0 {
+			handleError(w, &validationError{failures: []string{"Last-Event-ID should be a whole number not less than 0"}})
+			return
+		}
+		resuming = true
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	conn, _ := r.Context().Value(connContextKey{}).(net.Conn)
+	sub := &subscribeEventStream{
+		w:         w,
+		flusher:   flusher,
+		conn:      conn,
+		writeWait: eventStreamWriteWait,
+		id:        r.RemoteAddr,
+		closed:    make(chan struct{}),
+	}
+	if resuming {
+		err = t.service.resume(sub, since)
+	} else {
+		err = t.service.subscribe(sub)
+	}
+	if err != nil {
+		log.Println("error while subscribe with event stream:", err)
+		return
+	}
+
+	defer func() {
+		if err := t.service.unsubscribe(sub); err != nil {
+			log.Println("failed to unsubscribe event stream:", err)
+		}
+	}()
+
+	heartbeat := time.NewTicker(t.heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.closed:
+			return
+		case <-heartbeat.C:
+			if err := sub.write(": heartbeat\n\n"); err != nil {
+				log.Println("failed to write heartbeat:", err)
+				return
+			}
+		}
+	}
+}
+
+// subscribeEventStream writes events as unnamed Server-Sent Events, so
+// EventSource.onmessage gets them all, with the seq as the event id.
+type subscribeEventStream struct {
+	mu      sync.Mutex
+	w       io.Writer
+	flusher http.Flusher
+	// conn is the connection w writes to, nil when the server does not use
+	// withConn
+	conn      net.Conn
+	writeWait time.Duration
+	id        string
+	closed    chan struct{}
+	once      sync.Once
+}
+
+func (s *subscribeEventStream) Update(event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Println("error while encoding", err)
+		return
+	}
+	if err := s.write(fmt.Sprintf("id: %d\ndata: %s\n\n", event.Seq, data)); err != nil {
+		log.Println("error while writing event:", err)
+	}
+}
+
+func (s *subscribeEventStream) Id() string {
+	return s.id
+}
+
+// Close ends the stream, the client reconnects with Last-Event-ID. The
+// connection is closed right away, so a write stuck on a client that stopped
+// reading fails instead of holding up the caller.
+func (s *subscribeEventStream) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.closed)
+		if s.conn != nil {
+			err = s.conn.Close()
+		}
+	})
+	return err
+}
+
+// write sends a message right away; heartbeats and events come from
+// different goroutines. A write taking longer than writeWait fails.
+func (s *subscribeEventStream) write(message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		if err := s.conn.SetWriteDeadline(time.Now().Add(s.writeWait)); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(s.w, message); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	if s.conn != nil {
+		return s.conn.SetWriteDeadline(time.Time{})
+	}
+	return nil
+}
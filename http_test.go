@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -161,6 +166,80 @@ func TestHttpTransport_create(t *testing.T) {
 	}
 }
 
+func TestHttpTransport_CreateGeneratesIdAndSku(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Sku: "PHO-SAM-000001", Brand: "Samsung", Category: "Phones", Quantity: 1, Price: 10},
+	}
+
+	tests := []struct {
+		name           string
+		productJSON    string
+		wantStatusCode int
+		wantId         int
+		wantSku        string
+		wantResponse   string
+	}{
+		{
+			name:           "id and sku generated",
+			productJSON:    `{"brand": "Apple", "category": "Tablets", "quantity": 2, "price": 20}`,
+			wantStatusCode: http.StatusCreated,
+			wantId:         2,
+			wantSku:        "TAB-APP-000002",
+		},
+		{
+			name:           "client sku kept",
+			productJSON:    `{"sku": "IPAD-AIR", "brand": "Apple", "category": "Tablets", "quantity": 2, "price": 20}`,
+			wantStatusCode: http.StatusCreated,
+			wantId:         2,
+			wantSku:        "IPAD-AIR",
+		},
+		{
+			name:           "duplicate sku",
+			productJSON:    `{"sku": "PHO-SAM-000001", "brand": "Samsung", "category": "Phones", "quantity": 2, "price": 20}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["sku exists"]}`,
+		},
+		{
+			name:           "invalid sku",
+			productJSON:    `{"sku": "ipad air", "brand": "Apple", "category": "Tablets", "quantity": 2, "price": 20}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Sku should only contain upper case letters, digits and dashes"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(repo)))
+
+			r := httptest.NewRequest("POST", "/products", strings.NewReader(tt.productJSON))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+
+			if tt.wantStatusCode != http.StatusCreated {
+				assert.JSONEq(t, tt.wantResponse, w.Body.String(), "expect same error response")
+				return
+			}
+
+			var product Product
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &product), "expect no err while conv json to obj type")
+			assert.Equal(t, tt.wantId, product.Id, "expect generated id")
+			assert.Equal(t, tt.wantSku, product.Sku, "expect same sku")
+			assert.Equal(t, fmt.Sprintf("/products/%d", tt.wantId), response.Header.Get("Location"), "expect location of the new product")
+
+			r = httptest.NewRequest("GET", "/products/sku/"+tt.wantSku, nil)
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code, "expect product to be found by sku")
+			assert.Equal(t, etag(product.Version), w.Header().Get("ETag"), "expect etag of the product found by sku")
+		})
+	}
+}
+
 func TestHttpTransport_Update(t *testing.T) {
 	existing := []Product{
 		{
@@ -281,7 +360,8 @@ func TestHttpTransport_Update(t *testing.T) {
 			url := fmt.Sprintf("/products/%d", tt.productID) //this url is used to hit/call update api and by the product id given, it gives the refernce on what product to update
 
 			r := httptest.NewRequest("PUT", url, body) //this creates an http req with url of update api ,its http mthod(PUT),and json of the product to be updated as its req body
-			w := httptest.NewRecorder()                // initailzie a new recorder to record the res of http server
+			r.Header.Set("If-Match", "*")
+			w := httptest.NewRecorder() // initailzie a new recorder to record the res of http server
 
 			start := time.Now()
 
@@ -444,18 +524,26 @@ func TestHttpTransport_GetAll(t *testing.T) {
 			wantResponse: `[
 			{
 				"id": 1,
+				"sku": "",
+				"version": 0,
 				"brand": "A",
 				"category": "A",
 				"quantity": 1,
+				"reserved": 0,
+				"available": 1,
 				"price": 10,
 				"createdAt": "2023-04-26T15:00:00Z",
 				"updatedAt": "2023-04-26T16:00:00Z"
 			},
 			{
 				"id": 2,
+				"sku": "",
+				"version": 0,
 				"brand": "B",
 				"category": "B",
 				"quantity": 2,
+				"reserved": 0,
+				"available": 2,
 				"price": 20,
 				"createdAt": "2023-04-26T17:00:00Z",
 				"updatedAt": "2023-04-26T18:00:00Z"
@@ -492,6 +580,171 @@ func TestHttpTransport_GetAll(t *testing.T) {
 	}
 }
 
+func TestHttpTransport_GetAllPaginated(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Brand: "A", Category: "A", Quantity: 1, Price: 10},
+		{Id: 2, Brand: "B", Category: "A", Quantity: 2, Price: 20},
+		{Id: 3, Brand: "A", Category: "A", Quantity: 3, Price: 30},
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		wantStatusCode  int
+		wantIds         []int
+		wantTotalCount  string
+		wantNextCursor  string
+		wantErrResponse string
+	}{
+		{
+			name:           "first page",
+			url:            "/products?limit=2",
+			wantStatusCode: http.StatusOK,
+			wantIds:        []int{1, 2},
+			wantTotalCount: "3",
+			wantNextCursor: encodeCursor(2),
+		},
+		{
+			name:           "following the cursor",
+			url:            "/products?limit=2&cursor=" + encodeCursor(2),
+			wantStatusCode: http.StatusOK,
+			wantIds:        []int{3},
+			wantTotalCount: "3",
+		},
+		{
+			name:           "filtered and sorted",
+			url:            "/products?brand=A&sort=-quantity",
+			wantStatusCode: http.StatusOK,
+			wantIds:        []int{3, 1},
+			wantTotalCount: "2",
+		},
+		{
+			name:            "invalid parameters",
+			url:             "/products?sort=colour&limit=5000",
+			wantStatusCode:  http.StatusBadRequest,
+			wantErrResponse: `{"errors": ["sort should be one of id, brand, category, quantity, price, createdAt, updatedAt", "limit should be between 0 and 1000"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(repo)))
+
+			r := httptest.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+
+			if tt.wantErrResponse != "" {
+				assert.JSONEq(t, tt.wantErrResponse, w.Body.String(), "expect same error response")
+				return
+			}
+
+			var products []Product
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &products), "expect no err while conv json to obj type")
+			gotIds := make([]int, 0)
+			for _, product := range products {
+				gotIds = append(gotIds, product.Id)
+			}
+			assert.Equal(t, tt.wantIds, gotIds, "expect same products in order")
+			assert.Equal(t, tt.wantTotalCount, response.Header.Get("X-Total-Count"), "expect same total count")
+			assert.Equal(t, tt.wantNextCursor, response.Header.Get("X-Next-Cursor"), "expect same next cursor")
+		})
+	}
+}
+
+func TestHttpTransport_GetAllDefaultLimit(t *testing.T) {
+	existing := make([]Product, 0, defaultProductQueryLimit+5)
+	for id := 1; id <= defaultProductQueryLimit+5; id++ {
+		existing = append(existing, Product{Id: id, Brand: "A", Category: "A", Quantity: 1, Price: 10})
+	}
+	repo := setupInMemoryRepo(existing)
+	handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(repo)))
+
+	r := httptest.NewRequest("GET", "/products", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	response := w.Result()
+	assert.Equal(t, http.StatusOK, response.StatusCode, "expect same status code")
+
+	var products []Product
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &products), "expect no err while conv json to obj type")
+	assert.Len(t, products, defaultProductQueryLimit, "expect one default sized page")
+	assert.Equal(t, strconv.Itoa(len(existing)), response.Header.Get("X-Total-Count"), "expect same total count")
+	assert.Equal(t, encodeCursor(defaultProductQueryLimit), response.Header.Get("X-Next-Cursor"), "expect a cursor to the next page")
+}
+
+func TestHttpTransport_Search(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Brand: "Samsung", Category: "Phones", Quantity: 1, Price: 10},
+		{Id: 2, Brand: "Apple", Category: "Phones", Quantity: 2, Price: 20},
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "ranked results",
+			url:            "/products/search?q=sams",
+			wantStatusCode: http.StatusOK,
+			wantResponse: `[
+				{
+					"product": {
+						"id": 1,
+						"sku": "",
+						"version": 0,
+						"brand": "Samsung",
+						"category": "Phones",
+						"quantity": 1,
+						"reserved": 0,
+						"available": 1,
+						"price": 10,
+						"createdAt": "0001-01-01T00:00:00Z",
+						"updatedAt": "0001-01-01T00:00:00Z"
+					},
+					"rank": 0.5714285714285714
+				}
+			]`,
+		},
+		{
+			name:           "empty query",
+			url:            "/products/search?q=",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["q should not be empty"]}`,
+		},
+		{
+			name:           "invalid limit",
+			url:            "/products/search?q=phone&limit=x",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid limit"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(repo)))
+
+			r := httptest.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code, "expect same status code")
+			assert.JSONEq(t, tt.wantResponse, w.Body.String(), "expect same response")
+		})
+	}
+}
+
 func TestHttpTransport_Delete(t *testing.T) {
 	existing := []Product{
 		{
@@ -543,6 +796,7 @@ func TestHttpTransport_Delete(t *testing.T) {
 
 			url := fmt.Sprintf("/products/%d", tt.productId)
 			r := httptest.NewRequest("DELETE", url, nil)
+			r.Header.Set("If-Match", "*")
 			w := httptest.NewRecorder()
 
 			handler.ServeHTTP(w, r)
@@ -562,98 +816,852 @@ func TestHttpTransport_Delete(t *testing.T) {
 	}
 }
 
-func startHttpServer(t *testing.T, handler http.Handler) string {
-	listner, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		t.Fatal("failed to listen:", err)
+func TestHttpTransport_ConditionalRequests(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 1,
+			Price:    10,
+			Version:  3,
+		},
 	}
+	productJSON := `{"brand": "B", "category": "B", "quantity": 1, "price": 20}`
 
-	url := listner.Addr().String()
-
-	server := &http.Server{
-		Handler: handler,
+	tests := []struct {
+		name           string
+		method         string
+		ifMatch        string
+		wantStatusCode int
+		wantETag       string
+		wantResponse   string
+	}{
+		{
+			name:           "get returns etag",
+			method:         "GET",
+			wantStatusCode: http.StatusOK,
+			wantETag:       `"3"`,
+		},
+		{
+			name:           "update with current version",
+			method:         "PUT",
+			ifMatch:        `"3"`,
+			wantStatusCode: http.StatusOK,
+			wantETag:       `"4"`,
+		},
+		{
+			name:           "update with weak etag",
+			method:         "PUT",
+			ifMatch:        `W/"3"`,
+			wantStatusCode: http.StatusOK,
+			wantETag:       `"4"`,
+		},
+		{
+			name:           "update with stale version",
+			method:         "PUT",
+			ifMatch:        `"2"`,
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantResponse:   `{"errors": ["product was modified, reload it and retry"]}`,
+		},
+		{
+			name:           "update without if-match",
+			method:         "PUT",
+			wantStatusCode: http.StatusPreconditionRequired,
+			wantResponse:   `{"errors": ["If-Match header is required"]}`,
+		},
+		{
+			name:           "update with invalid if-match",
+			method:         "PUT",
+			ifMatch:        "3",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid If-Match header"]}`,
+		},
+		{
+			name:           "delete with current version",
+			method:         "DELETE",
+			ifMatch:        `"3"`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "delete with stale version",
+			method:         "DELETE",
+			ifMatch:        `"4"`,
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantResponse:   `{"errors": ["product was modified, reload it and retry"]}`,
+		},
+		{
+			name:           "delete without if-match",
+			method:         "DELETE",
+			wantStatusCode: http.StatusPreconditionRequired,
+			wantResponse:   `{"errors": ["If-Match header is required"]}`,
+		},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			handler := buildHttpHandler(NewhttpTransport(svc))
 
-	errC := make(chan error)
-	go func() {
-		errC <- server.Serve(listner)
-	}()
-
-	t.Cleanup(func() {
-		if err := server.Close(); err != nil {
-			t.Log("unable to close the server after the completion of test:", err)
-		}
-		t.Log("http server exited:", <-errC)
-	})
-	return url
-}
-
-func TestWebSocket(t *testing.T) {
-
-	t.Run("create", func(t *testing.T) {
-		existing := []Product{
-			{
-				Id:       1,
-				Brand:    "A",
-				Category: "A",
-				Quantity: 1,
-				Price:    10,
-			},
-			{
-				Id:       2,
-				Brand:    "B",
-				Category: "B",
-				Quantity: 2,
-				Price:    20,
-			},
-		}
-
-		repo := setupInMemoryRepo(existing)
-		svc := NewProductServiceImpl(repo)
-		httpTransport := NewhttpTransport(svc)
-		handler := buildHttpHandler(httpTransport)
+			var body io.Reader
+			if tt.method == "PUT" {
+				body = strings.NewReader(productJSON)
+			}
+			r := httptest.NewRequest(tt.method, "/products/1", body)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
 
-		url := startHttpServer(t, handler)
-		t.Log("setup for http server completed")
-		websocketURL := fmt.Sprintf("ws://%s/ws", url)
+			handler.ServeHTTP(w, r)
 
-		conn, httpresponse, err := websocket.DefaultDialer.Dial(websocketURL, nil)
-		if err != nil {
-			t.Fatal("unable to establish websocket connection :", err)
-		}
-		assert.Equal(t, http.StatusSwitchingProtocols, httpresponse.StatusCode, "expect 101 statuscode while switchig from http to websocket")
-		t.Log("websocket connection established")
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+			assert.Equal(t, tt.wantETag, response.Header.Get("ETag"), "expect same etag")
 
-		t.Cleanup(func() {
-			if err := conn.Close(); err != nil {
-				t.Log("err while closing websocket conn:", err)
+			if tt.wantResponse != "" {
+				responseBytes, err := io.ReadAll(response.Body)
+				assert.NoError(t, err, "read response body should succeed")
+				assert.JSONEq(t, tt.wantResponse, string(responseBytes))
 			}
 		})
+	}
+}
 
-		productJSON := `
+func TestHttpTransport_Patch(t *testing.T) {
+	existing := []Product{
 		{
-		"id": 3,
-		"brand": "C",
-		"category": "C",
-		"quantity": 3,
-		"price": 30
-		}
-		`
-		body := strings.NewReader(productJSON)
-		createURL := fmt.Sprintf("http://%s/products", url)
-		req, reqErr := http.NewRequest("POST", createURL, body)
-		if reqErr != nil {
-			t.Fatal("failed to build request:", err)
-		}
-
-		res, resErr := http.DefaultClient.Do(req)
-		if resErr != nil {
-			t.Fatal("err in calling create product api:", resErr)
-		}
-		t.Log("Send request successfully")
+			Id:       1,
+			Sku:      "A-A-000001",
+			Brand:    "A",
+			Category: "A",
+			Quantity: 1,
+			Price:    10,
+			Version:  1,
+		},
+	}
 
-		assert.Equal(t, http.StatusCreated, res.StatusCode, "expect same status code while calling create api")
-		t.Log("got success response")
+	tests := []struct {
+		name           string
+		contentType    string
+		ifMatch        string
+		patch          string
+		wantStatusCode int
+		wantETag       string
+		wantResponse   string
+	}{
+		{
+			name:           "merge patch",
+			contentType:    "application/merge-patch+json",
+			ifMatch:        `"1"`,
+			patch:          `{"price": 12}`,
+			wantStatusCode: http.StatusOK,
+			wantETag:       `"2"`,
+			wantResponse:   `{"id": 1, "sku": "A-A-000001", "brand": "A", "category": "A", "quantity": 1, "reserved": 0, "available": 1, "price": 12, "version": 2}`,
+		},
+		{
+			name:           "plain json is a merge patch",
+			contentType:    "application/json; charset=utf-8",
+			ifMatch:        "*",
+			patch:          `{"brand": "B"}`,
+			wantStatusCode: http.StatusOK,
+			wantETag:       `"2"`,
+			wantResponse:   `{"id": 1, "sku": "A-A-000001", "brand": "B", "category": "A", "quantity": 1, "reserved": 0, "available": 1, "price": 10, "version": 2}`,
+		},
+		{
+			name:           "json patch",
+			contentType:    "application/json-patch+json",
+			ifMatch:        `"1"`,
+			patch:          `[{"op": "test", "path": "/price", "value": 10}, {"op": "replace", "path": "/category", "value": "C"}]`,
+			wantStatusCode: http.StatusOK,
+			wantETag:       `"2"`,
+			wantResponse:   `{"id": 1, "sku": "A-A-000001", "brand": "A", "category": "C", "quantity": 1, "reserved": 0, "available": 1, "price": 10, "version": 2}`,
+		},
+		{
+			name:           "json patch test fails",
+			contentType:    "application/json-patch+json",
+			ifMatch:        `"1"`,
+			patch:          `[{"op": "test", "path": "/price", "value": 11}, {"op": "replace", "path": "/category", "value": "C"}]`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["patch test failed"]}`,
+		},
+		{
+			name:           "validation error",
+			contentType:    "application/merge-patch+json",
+			ifMatch:        `"1"`,
+			patch:          `{"category": ""}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Category should not be empty"]}`,
+		},
+		{
+			name:           "stale version",
+			contentType:    "application/merge-patch+json",
+			ifMatch:        `"2"`,
+			patch:          `{"price": 12}`,
+			wantStatusCode: http.StatusPreconditionFailed,
+			wantResponse:   `{"errors": ["product was modified, reload it and retry"]}`,
+		},
+		{
+			name:           "unsupported content type",
+			contentType:    "text/plain",
+			ifMatch:        `"1"`,
+			patch:          `price=12`,
+			wantStatusCode: http.StatusUnsupportedMediaType,
+			wantResponse:   `{"errors": ["unsupported patch type"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			handler := buildHttpHandler(NewhttpTransport(svc))
+
+			r := httptest.NewRequest("PATCH", "/products/1", strings.NewReader(tt.patch))
+			r.Header.Set("Content-Type", tt.contentType)
+			r.Header.Set("If-Match", tt.ifMatch)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+			assert.Equal(t, tt.wantETag, response.Header.Get("ETag"), "expect same etag")
+
+			var got map[string]interface{}
+			err := json.NewDecoder(response.Body).Decode(&got)
+			assert.NoError(t, err, "decode response should succeed")
+			delete(got, "createdAt")
+			delete(got, "updatedAt")
+			gotBytes, err := json.Marshal(got)
+			assert.NoError(t, err, "encode response should succeed")
+			assert.JSONEq(t, tt.wantResponse, string(gotBytes))
+		})
+	}
+}
+
+func TestHttpTransport_Batch(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Sku: "A-A-000001", Brand: "A", Category: "A", Quantity: 1, Price: 10, Version: 1},
+	}
+
+	tests := []struct {
+		name           string
+		batchJSON      string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name: "all operations succeed",
+			batchJSON: `
+			{
+				"operations": [
+					{"op": "update", "id": 1, "version": 1, "product": {"brand": "A", "category": "A", "quantity": 1, "price": 12}},
+					{"op": "delete", "id": 1, "version": 2}
+				]
+			}`,
+			wantStatusCode: http.StatusOK,
+			wantResponse: `
+			{
+				"results": [
+					{"index": 0, "op": "update", "status": 200, "product": {"id": 1, "sku": "A-A-000001", "brand": "A", "category": "A", "quantity": 1, "reserved": 0, "available": 1, "price": 12, "version": 2}},
+					{"index": 1, "op": "delete", "status": 200}
+				]
+			}`,
+		},
+		{
+			name: "failed batch is rolled back",
+			batchJSON: `
+			{
+				"operations": [
+					{"op": "delete", "id": 1},
+					{"op": "update", "id": 1, "product": {"brand": "", "category": "A"}}
+				]
+			}`,
+			wantStatusCode: http.StatusMultiStatus,
+			wantResponse: `
+			{
+				"results": [
+					{"index": 0, "op": "delete", "status": 424, "errors": ["rolled back as another operation failed"]},
+					{"index": 1, "op": "update", "status": 400, "errors": ["Brand should not be empty"]}
+				]
+			}`,
+		},
+		{
+			name: "partial batch",
+			batchJSON: `
+			{
+				"partial": true,
+				"operations": [
+					{"op": "delete", "id": 7},
+					{"op": "delete", "id": 1, "version": 1}
+				]
+			}`,
+			wantStatusCode: http.StatusMultiStatus,
+			wantResponse: `
+			{
+				"results": [
+					{"index": 0, "op": "delete", "status": 404, "errors": ["product not found"]},
+					{"index": 1, "op": "delete", "status": 200}
+				]
+			}`,
+		},
+		{
+			name:           "empty batch",
+			batchJSON:      `{"operations": []}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["operations should not be empty"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			handler := buildHttpHandler(NewhttpTransport(svc))
+
+			r := httptest.NewRequest("POST", "/products:batch", strings.NewReader(tt.batchJSON))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+
+			var got map[string]interface{}
+			err := json.NewDecoder(response.Body).Decode(&got)
+			assert.NoError(t, err, "decode response should succeed")
+			if results, ok := got["results"].([]interface{}); ok {
+				for _, result := range results {
+					if product, ok := result.(map[string]interface{})["product"].(map[string]interface{}); ok {
+						delete(product, "createdAt")
+						delete(product, "updatedAt")
+					}
+				}
+			}
+			gotBytes, err := json.Marshal(got)
+			assert.NoError(t, err, "encode response should succeed")
+			assert.JSONEq(t, tt.wantResponse, string(gotBytes))
+		})
+	}
+}
+
+func TestHttpTransport_Import(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Sku: "SAM-1", Brand: "Samsung", Category: "Phone", Quantity: 1, Price: 10, Version: 1},
+	}
+
+	multipartBody := func(filename string, content []byte) (string, *bytes.Buffer) {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, err := form.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal("unable to create form file:", err)
+		}
+		if _, err := part.Write(content); err != nil {
+			t.Fatal("unable to write form file:", err)
+		}
+		if err := form.Close(); err != nil {
+			t.Fatal("unable to close form:", err)
+		}
+		return form.FormDataContentType(), body
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		contentType    string
+		body           func() (string, io.Reader)
+		wantStatusCode int
+		wantResponse   string
+		wantCount      int
+	}{
+		{
+			name: "csv body",
+			url:  "/products/import",
+			body: func() (string, io.Reader) {
+				return "text/csv", strings.NewReader("brand,category,quantity,price\nApple,Phone,2,20\nNokia,Phone,1,5\n")
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `{"created": 2, "updated": 0, "dryRun": false}`,
+			wantCount:      3,
+		},
+		{
+			name: "mapped csv upsert",
+			url:  "/products/import?mode=upsert&map=Artikel:sku&map=Preis:price",
+			body: func() (string, io.Reader) {
+				return multipartBody("prices.csv", []byte("Artikel,Preis\nSAM-1,12\n"))
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `{"created": 0, "updated": 1, "dryRun": false}`,
+			wantCount:      1,
+		},
+		{
+			name: "xlsx dry run",
+			url:  "/products/import?dryRun=true",
+			body: func() (string, io.Reader) {
+				parts := make(map[string]string)
+				for name, content := range testWorkbookParts {
+					parts[name] = content
+				}
+				parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"],
+					`<c r="C2">`, `<c r="B2" t="inlineStr"><is><t>Phone</t></is></c><c r="C2">`, 1)
+				return multipartBody("products.xlsx", buildXLSX(t, parts))
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `{"created": 1, "updated": 0, "dryRun": true}`,
+			wantCount:      1,
+		},
+		{
+			name: "xlsx row errors",
+			url:  "/products/import",
+			body: func() (string, io.Reader) {
+				return multipartBody("products.xlsx", buildXLSX(t, testWorkbookParts))
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["row 2: Category should not be empty"]}`,
+			wantCount:      1,
+		},
+		{
+			name: "row errors",
+			url:  "/products/import",
+			body: func() (string, io.Reader) {
+				return "text/csv", strings.NewReader("brand,category,price\n,Phone,1\nApple,Phone,-1\nNokia,Phone,cheap\n")
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse: `{"errors": [
+				"row 4: Price should be a number"
+			]}`,
+			wantCount: 1,
+		},
+		{
+			name: "validation errors per row",
+			url:  "/products/import",
+			body: func() (string, io.Reader) {
+				return "text/csv", strings.NewReader("brand,category,price\n,Phone,1\nApple,Phone,-1\n")
+			},
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse: `{"errors": [
+				"row 2: Brand should not be empty",
+				"row 3: Price should not be less than 0"
+			]}`,
+			wantCount: 1,
+		},
+		{
+			name: "unsupported type",
+			url:  "/products/import",
+			body: func() (string, io.Reader) {
+				return "application/pdf", strings.NewReader("%PDF")
+			},
+			wantStatusCode: http.StatusUnsupportedMediaType,
+			wantResponse:   `{"errors": ["unsupported import type, upload csv or xlsx"]}`,
+			wantCount:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			handler := buildHttpHandler(NewhttpTransport(svc))
+
+			contentType, body := tt.body()
+			r := httptest.NewRequest("POST", tt.url, body)
+			r.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+			responseBytes, err := io.ReadAll(response.Body)
+			assert.NoError(t, err, "read response body should succeed")
+			assert.JSONEq(t, tt.wantResponse, string(responseBytes))
+			assert.Len(t, repo.products, tt.wantCount, "expect same number of products")
+		})
+	}
+}
+
+func TestHttpTransport_Export(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Sku: "PHO-SAM-1", Brand: "Samsung", Category: "Phone", Quantity: 1, Price: 10.5, Version: 1,
+			CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC), UpdatedAt: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC)},
+		{Id: 2, Sku: "TV-SAM-2", Brand: "Samsung", Category: "TV", Quantity: 2, Price: 20, Version: 3,
+			CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC), UpdatedAt: time.Date(2023, 04, 26, 17, 00, 00, 00, time.UTC)},
+		{Id: 3, Sku: "PHO-APP-3", Brand: "Apple, Inc.", Category: "Phone", Quantity: 3, Price: 30, Version: 1,
+			CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC), UpdatedAt: time.Date(2023, 04, 26, 18, 00, 00, 00, time.UTC)},
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		wantStatusCode  int
+		wantContentType string
+		wantExtension   string
+		wantRows        [][]string
+		wantResponse    string
+	}{
+		{
+			name:            "csv",
+			url:             "/products/export?category=Phone&sort=-price",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantExtension:   "csv",
+			wantRows: [][]string{
+				{"id", "sku", "brand", "category", "quantity", "price", "version", "createdAt", "updatedAt"},
+				{"3", "PHO-APP-3", "Apple, Inc.", "Phone", "3", "30", "1", "2023-04-26T15:00:00Z", "2023-04-26T18:00:00Z"},
+				{"1", "PHO-SAM-1", "Samsung", "Phone", "1", "10.5", "1", "2023-04-26T15:00:00Z", "2023-04-26T16:00:00Z"},
+			},
+		},
+		{
+			name:            "csv without matches has a header",
+			url:             "/products/export?brand=Nokia",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantExtension:   "csv",
+			wantRows: [][]string{
+				{"id", "sku", "brand", "category", "quantity", "price", "version", "createdAt", "updatedAt"},
+			},
+		},
+		{
+			name:            "jsonl",
+			url:             "/products/export?format=jsonl&brand=Samsung",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantExtension:   "jsonl",
+			wantResponse: `{"id":1,"sku":"PHO-SAM-1","brand":"Samsung","category":"Phone","quantity":1,"reserved":0,"price":10.5,"version":1,"createdAt":"2023-04-26T15:00:00Z","updatedAt":"2023-04-26T16:00:00Z","available":1}
+{"id":2,"sku":"TV-SAM-2","brand":"Samsung","category":"TV","quantity":2,"reserved":0,"price":20,"version":3,"createdAt":"2023-04-26T15:00:00Z","updatedAt":"2023-04-26T17:00:00Z","available":2}
+`,
+		},
+		{
+			name:            "xlsx",
+			url:             "/products/export?format=xlsx&category=TV",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: xlsxContentType,
+			wantExtension:   "xlsx",
+			wantRows: [][]string{
+				{"id", "sku", "brand", "category", "quantity", "price", "version", "createdAt", "updatedAt"},
+				{"2", "TV-SAM-2", "Samsung", "TV", "2", "20", "3", "2023-04-26T15:00:00Z", "2023-04-26T17:00:00Z"},
+			},
+		},
+		{
+			name:           "unknown format",
+			url:            "/products/export?format=pdf",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["format should be one of csv, jsonl, xlsx"]}`,
+		},
+		{
+			name:           "invalid filter",
+			url:            "/products/export?minPrice=cheap",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["minPrice should be a number"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			handler := buildHttpHandler(NewhttpTransport(svc))
+
+			r := httptest.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+			responseBytes, err := io.ReadAll(response.Body)
+			assert.NoError(t, err, "read response body should succeed")
+
+			if tt.wantStatusCode != http.StatusOK {
+				assert.JSONEq(t, tt.wantResponse, string(responseBytes))
+				return
+			}
+
+			assert.Equal(t, tt.wantContentType, response.Header.Get("Content-Type"), "expect same content type")
+			_, params, err := mime.ParseMediaType(response.Header.Get("Content-Disposition"))
+			assert.NoError(t, err, "expect a valid content disposition")
+			assert.True(t, strings.HasSuffix(params["filename"], "."+tt.wantExtension), "expect a file name with the format extension")
+
+			switch tt.wantExtension {
+			case "csv":
+				rows, err := readCSV(bytes.NewReader(responseBytes))
+				assert.NoError(t, err, "expect valid csv")
+				assert.Equal(t, tt.wantRows, rows)
+			case "xlsx":
+				rows, err := readXLSX(responseBytes)
+				assert.NoError(t, err, "expect valid xlsx")
+				assert.Equal(t, tt.wantRows, rows)
+			default:
+				assert.Equal(t, tt.wantResponse, string(responseBytes))
+			}
+		})
+	}
+}
+
+func TestHttpTransport_AddMovement(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 1,
+			Price:    10,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		movementJSON   string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name: "success",
+			url:  "/products/1/movements",
+			movementJSON: `{
+				"type": "receipt",
+				"quantity": 4,
+				"reason": "delivery",
+				"reference": "PO-7",
+				"actor": "clerk"
+			}`,
+			wantStatusCode: http.StatusCreated,
+			wantResponse: `{
+				"id": 1,
+				"productId": 1,
+				"type": "receipt",
+				"quantity": 4,
+				"reason": "delivery",
+				"reference": "PO-7",
+				"actor": "clerk"
+			}`,
+		},
+		{
+			name: "insufficient stock",
+			url:  "/products/1/movements",
+			movementJSON: `{
+				"type": "issue",
+				"quantity": 2,
+				"reason": "sold"
+			}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["insufficient stock"]}`,
+		},
+		{
+			name: "product not found",
+			url:  "/products/9/movements",
+			movementJSON: `{
+				"type": "receipt",
+				"quantity": 2,
+				"reason": "delivery"
+			}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["product not found"]}`,
+		},
+		{
+			name:           "validation error",
+			url:            "/products/1/movements",
+			movementJSON:   `{"type": "receipt", "quantity": 0, "reason": "delivery"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Quantity should be greater than 0"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			handler := buildHttpHandler(NewhttpTransport(svc))
+
+			r := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.movementJSON))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			response := w.Result()
+			assert.Equal(t, tt.wantStatusCode, response.StatusCode, "expect same status code")
+
+			responseBytes, err := io.ReadAll(response.Body)
+			assert.NoError(t, err, "read response body should succeed")
+
+			if tt.wantStatusCode == http.StatusCreated {
+				var movement StockMovement
+				assert.NoError(t, json.Unmarshal(responseBytes, &movement), "expect no err while conv json to obj type")
+				movement.CreatedAt = time.Time{}
+
+				var wantMovement StockMovement
+				assert.NoError(t, json.Unmarshal([]byte(tt.wantResponse), &wantMovement), "expect no err while conv json to obj type")
+				assert.Equal(t, wantMovement, movement, "expect same movement")
+			} else {
+				assert.JSONEq(t, tt.wantResponse, string(responseBytes), "expect same error response")
+			}
+		})
+	}
+}
+
+func TestHttpTransport_GetMovements(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 3}})
+	repo.movements = []StockMovement{
+		{Id: 1, ProductId: 1, Type: MovementReceipt, Quantity: 3, Reason: "opening stock", CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC)},
+	}
+	handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(repo)))
+
+	r := httptest.NewRequest("GET", "/products/1/movements", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code, "expect same status code")
+	assert.JSONEq(t, `[
+		{
+			"id": 1,
+			"productId": 1,
+			"type": "receipt",
+			"quantity": 3,
+			"reason": "opening stock",
+			"reference": "",
+			"actor": "",
+			"createdAt": "2023-04-26T15:00:00Z"
+		}
+	]`, w.Body.String(), "expect same movements")
+}
+
+func TestHttpTransport_GetLowStock(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{
+		{Id: 1, Brand: "A", Category: "A", Quantity: 3, ReorderPoint: 5, ReorderQuantity: 20, Version: 1},
+		{Id: 2, Brand: "B", Category: "B", Quantity: 8, ReorderPoint: 5, Version: 1},
+		{Id: 3, Brand: "C", Category: "C", Quantity: 0, Version: 1},
+	})
+	handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(repo)))
+
+	r := httptest.NewRequest("GET", "/products/low-stock", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code, "expect same status code")
+	assert.JSONEq(t, `[
+		{
+			"id": 1,
+			"sku": "",
+			"brand": "A",
+			"category": "A",
+			"quantity": 3,
+			"reserved": 0,
+			"available": 3,
+			"price": 0,
+			"reorderPoint": 5,
+			"reorderQuantity": 20,
+			"version": 1,
+			"createdAt": "0001-01-01T00:00:00Z",
+			"updatedAt": "0001-01-01T00:00:00Z"
+		},
+		{
+			"id": 3,
+			"sku": "",
+			"brand": "C",
+			"category": "C",
+			"quantity": 0,
+			"reserved": 0,
+			"available": 0,
+			"price": 0,
+			"version": 1,
+			"createdAt": "0001-01-01T00:00:00Z",
+			"updatedAt": "0001-01-01T00:00:00Z"
+		}
+	]`, w.Body.String(), "expect products at or below their reorder point")
+}
+
+func startHttpServer(t *testing.T, handler http.Handler) string {
+	listner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("failed to listen:", err)
+	}
+
+	url := listner.Addr().String()
+
+	server := &http.Server{
+		Handler:     handler,
+		ConnContext: withConn,
+	}
+
+	errC := make(chan error)
+	go func() {
+		errC <- server.Serve(listner)
+	}()
+
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Log("unable to close the server after the completion of test:", err)
+		}
+		t.Log("http server exited:", <-errC)
+	})
+	return url
+}
+
+func TestWebSocket(t *testing.T) {
+
+	t.Run("create", func(t *testing.T) {
+		existing := []Product{
+			{
+				Id:       1,
+				Brand:    "A",
+				Category: "A",
+				Quantity: 1,
+				Price:    10,
+			},
+			{
+				Id:       2,
+				Brand:    "B",
+				Category: "B",
+				Quantity: 2,
+				Price:    20,
+			},
+		}
+
+		repo := setupInMemoryRepo(existing)
+		svc := NewProductServiceImpl(repo)
+		httpTransport := NewhttpTransport(svc)
+		handler := buildHttpHandler(httpTransport)
+
+		url := startHttpServer(t, handler)
+		t.Log("setup for http server completed")
+		websocketURL := fmt.Sprintf("ws://%s/ws", url)
+
+		conn, httpresponse, err := websocket.DefaultDialer.Dial(websocketURL, nil)
+		if err != nil {
+			t.Fatal("unable to establish websocket connection :", err)
+		}
+		assert.Equal(t, http.StatusSwitchingProtocols, httpresponse.StatusCode, "expect 101 statuscode while switchig from http to websocket")
+		t.Log("websocket connection established")
+
+		t.Cleanup(func() {
+			if err := conn.Close(); err != nil {
+				t.Log("err while closing websocket conn:", err)
+			}
+		})
+
+		productJSON := `
+		{
+		"id": 3,
+		"brand": "C",
+		"category": "C",
+		"quantity": 3,
+		"price": 30
+		}
+		`
+		body := strings.NewReader(productJSON)
+		createURL := fmt.Sprintf("http://%s/products", url)
+		req, reqErr := http.NewRequest("POST", createURL, body)
+		if reqErr != nil {
+			t.Fatal("failed to build request:", err)
+		}
+
+		res, resErr := http.DefaultClient.Do(req)
+		if resErr != nil {
+			t.Fatal("err in calling create product api:", resErr)
+		}
+		t.Log("Send request successfully")
+
+		assert.Equal(t, http.StatusCreated, res.StatusCode, "expect same status code while calling create api")
+		t.Log("got success response")
 
 		wantProductsNotification := []Product{
 			{
@@ -672,6 +1680,7 @@ func TestWebSocket(t *testing.T) {
 			},
 			{
 				Id:       3,
+				Sku:      "C-C-000003",
 				Brand:    "C",
 				Category: "C",
 				Quantity: 3,
@@ -679,11 +1688,14 @@ func TestWebSocket(t *testing.T) {
 			},
 		}
 
-		var gotProductsNotification []Product
-		if err := conn.ReadJSON(&gotProductsNotification); err != nil {
-			t.Fatal("unable to read the msg from websocket:", err)
-		}
-		assert.Equal(t, wantProductsNotification, gotProductsNotification, "expect products notification when create product is successful")
+		snapshot := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventSnapshot, snapshot.Type, "expect a snapshot when connected")
+		assert.Equal(t, existing, snapshot.Products, "expect the catalog in the snapshot")
+
+		event := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventProductCreated, event.Type, "expect created event when create product is successful")
+		assert.Equal(t, snapshot.Seq+1, event.Seq, "expect event to follow the snapshot")
+		assert.Equal(t, wantProductsNotification[2], eventProduct(t, event), "expect the created product")
 	})
 
 	t.Run("update", func(t *testing.T) {
@@ -742,6 +1754,7 @@ func TestWebSocket(t *testing.T) {
 		if reqErr != nil {
 			t.Fatal("unable to build request:", err)
 		}
+		req.Header.Set("If-Match", "*")
 
 		res, resErr := http.DefaultClient.Do(req)
 		if resErr != nil {
@@ -768,11 +1781,10 @@ func TestWebSocket(t *testing.T) {
 				Price:    20,
 			},
 		}
-		var gotProductsNotification []Product
-		if err := conn.ReadJSON(&gotProductsNotification); err != nil {
-			t.Fatal("unable to read the msg from websocket:", err)
-		}
-		assert.Equal(t, wantProductsNotification, gotProductsNotification, "expect same mssg while updating")
+		readWebSocketEvent(t, conn)
+		event := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventProductUpdated, event.Type, "expect updated event")
+		assert.Equal(t, wantProductsNotification[0], eventProduct(t, event), "expect the updated product")
 	})
 
 	t.Run("delete", func(t *testing.T) {
@@ -814,6 +1826,7 @@ func TestWebSocket(t *testing.T) {
 		if reqErr != nil {
 			t.Fatal("failed to build request:", reqErr)
 		}
+		req.Header.Set("If-Match", "*")
 
 		res, resErr := http.DefaultClient.Do(req)
 		if resErr != nil {
@@ -830,21 +1843,10 @@ func TestWebSocket(t *testing.T) {
 			}
 		})
 
-		wantProductsNotification := []Product{
-			{
-				Id:       2,
-				Brand:    "B",
-				Category: "B",
-				Quantity: 2,
-				Price:    20,
-			},
-		}
-
-		var gotProductsNotification []Product
-		if err := conn.ReadJSON(&gotProductsNotification); err != nil {
-			t.Fatal("unable toread mssg from websocket: ", err)
-		}
-		assert.Equal(t, wantProductsNotification, gotProductsNotification, "expect same mssg after delete")
+		readWebSocketEvent(t, conn)
+		event := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventProductDeleted, event.Type, "expect deleted event")
+		assert.Equal(t, existing[0], eventProduct(t, event), "expect the deleted product")
 	})
 
 	t.Run("delete op failed", func(t *testing.T) {
@@ -886,6 +1888,7 @@ func TestWebSocket(t *testing.T) {
 		if reqErr != nil {
 			t.Fatal("failed to builed request:", reqErr)
 		}
+		req.Header.Set("If-Match", "*")
 
 		res, resErr := http.DefaultClient.Do(req)
 		if resErr != nil {
@@ -902,12 +1905,331 @@ func TestWebSocket(t *testing.T) {
 			}
 		})
 
+		readWebSocketEvent(t, conn)
+
 		if readDeadlineErr := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); readDeadlineErr != nil {
 			t.Fatal("err while reading websocket mssg:", readDeadlineErr)
 		}
 
-		var gotProductsNotification []Product
-		readMssgErr := conn.ReadJSON(&gotProductsNotification)
+		var gotEvent Event
+		readMssgErr := conn.ReadJSON(&gotEvent)
 		assert.Error(t, readMssgErr, "expect err while read mssg")
 	})
+
+	t.Run("resume since", func(t *testing.T) {
+		repo := setupInMemoryRepo(nil)
+		svc := NewProductServiceImpl(repo)
+		if err := svc.SetEventLog(repo); err != nil {
+			t.Fatal("unable to set event log:", err)
+		}
+		handler := buildHttpHandler(NewhttpTransport(svc))
+		url := startHttpServer(t, handler)
+
+		missed, err := svc.Create(Product{Brand: "A", Category: "A", Price: 10})
+		if err != nil {
+			t.Fatal("unable to create product:", err)
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws?since=0", url), nil)
+		if err != nil {
+			t.Fatal("unable to establish websocket connection :", err)
+		}
+		t.Cleanup(func() {
+			if err := conn.Close(); err != nil {
+				t.Log("err while closing websocket conn:", err)
+			}
+		})
+
+		event := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventProductCreated, event.Type, "expect the missed event instead of a snapshot")
+		assert.Equal(t, int64(1), event.Seq, "expect the missed event")
+		assert.Equal(t, missed.Id, event.Product.Id, "expect the missed product")
+
+		if _, err := svc.Create(Product{Brand: "B", Category: "B", Price: 20}); err != nil {
+			t.Fatal("unable to create product:", err)
+		}
+		event = readWebSocketEvent(t, conn)
+		assert.Equal(t, int64(2), event.Seq, "expect live events after the missed ones")
+	})
+
+	t.Run("invalid since", func(t *testing.T) {
+		handler := buildHttpHandler(NewhttpTransport(NewProductServiceImpl(setupInMemoryRepo(nil))))
+		url := startHttpServer(t, handler)
+
+		_, res, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws?since=abc", url), nil)
+		assert.Error(t, err, "expect connection to be refused")
+		if assert.NotNil(t, res, "expect a response") {
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expect 400 for invalid since")
+		}
+	})
+
+	t.Run("filter subscription", func(t *testing.T) {
+		existing := []Product{
+			{
+				Id:       1,
+				Brand:    "A",
+				Category: "Phone",
+				Quantity: 1,
+				Price:    10,
+			},
+			{
+				Id:       2,
+				Brand:    "B",
+				Category: "Laptop",
+				Quantity: 2,
+				Price:    20,
+			},
+		}
+		svc := NewProductServiceImpl(setupInMemoryRepo(existing))
+		url := startHttpServer(t, buildHttpHandler(NewhttpTransport(svc)))
+
+		conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", url), nil)
+		if err != nil {
+			t.Fatal("unable to establish websocket connection :", err)
+		}
+		t.Cleanup(func() {
+			if err := conn.Close(); err != nil {
+				t.Log("err while closing websocket conn:", err)
+			}
+		})
+		readWebSocketEvent(t, conn)
+
+		subscribe := `{"type": "subscribe", "name": "phones", "filter": {"category": "Phone"}}`
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(subscribe)); err != nil {
+			t.Fatal("unable to send subscribe message:", err)
+		}
+		snapshot := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventSnapshot, snapshot.Type, "expect a snapshot for the filter")
+		assert.Equal(t, existing[:1], snapshot.Products, "expect only matching products")
+
+		for _, category := range []string{"Laptop", "Phone"} {
+			if _, err := svc.Create(Product{Brand: "C", Category: category, Price: 30}); err != nil {
+				t.Fatal("unable to create product:", err)
+			}
+		}
+		event := readWebSocketEvent(t, conn)
+		assert.Equal(t, EventProductCreated, event.Type, "expect created event")
+		assert.Equal(t, "Phone", event.Product.Category, "expect only the matching product")
+
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "watch"}`)); err != nil {
+			t.Fatal("unable to send message:", err)
+		}
+		var gotError SubscriptionError
+		if err := conn.ReadJSON(&gotError); err != nil {
+			t.Fatal("unable to read the msg from websocket:", err)
+		}
+		assert.Equal(t, SubscriptionError{Type: "error", Errors: []string{"type should be one of subscribe, unsubscribe"}}, gotError)
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		transport := NewhttpTransport(NewProductServiceImpl(setupInMemoryRepo(nil)))
+		transport.heartbeatInterval = 10 * time.Millisecond
+		url := startHttpServer(t, buildHttpHandler(transport))
+
+		conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", url), nil)
+		if err != nil {
+			t.Fatal("unable to establish websocket connection :", err)
+		}
+		t.Cleanup(func() {
+			if err := conn.Close(); err != nil {
+				t.Log("err while closing websocket conn:", err)
+			}
+		})
+
+		pinged := make(chan struct{}, 1)
+		conn.SetPingHandler(func(data string) error {
+			select {
+			case pinged <- struct{}{}:
+			default:
+			}
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		select {
+		case <-pinged:
+		case <-time.After(time.Second):
+			t.Fatal("expect the server to ping")
+		}
+	})
+}
+
+func readWebSocketEvent(t *testing.T, conn *websocket.Conn) Event {
+	var event Event
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal("unable to read the msg from websocket:", err)
+	}
+	return event
+}
+
+// eventProduct returns the product of event without the fields the server
+// sets, so it can be compared with the product that was sent.
+func eventProduct(t *testing.T, event Event) Product {
+	if event.Product == nil {
+		t.Fatal("expect a product in the event")
+	}
+	product := *event.Product
+	product.Version = 0
+	product.CreatedAt = time.Time{}
+	product.UpdatedAt = time.Time{}
+	return product
+}
+
+func TestHttpTransport_Events(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 1,
+			Price:    10,
+		},
+	}
+
+	openStream := func(t *testing.T, url, lastEventId string) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/products/events", url), nil)
+		if err != nil {
+			t.Fatal("failed to build request:", err)
+		}
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("failed to open event stream:", err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res, bufio.NewReader(res.Body)
+	}
+
+	t.Run("snapshot then events", func(t *testing.T) {
+		repo := setupInMemoryRepo(existing)
+		svc := NewProductServiceImpl(repo)
+		url := startHttpServer(t, buildHttpHandler(NewhttpTransport(svc)))
+
+		res, reader := openStream(t, url, "")
+		assert.Equal(t, http.StatusOK, res.StatusCode, "expect 200 for the event stream")
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		id, snapshot := readSSEEvent(t, reader)
+		assert.Equal(t, "0", id, "expect snapshot seq as id")
+		assert.Equal(t, EventSnapshot, snapshot.Type, "expect a snapshot first")
+		assert.Equal(t, existing, snapshot.Products, "expect the catalog in the snapshot")
+
+		if _, err := svc.Create(Product{Brand: "B", Category: "B", Price: 20}); err != nil {
+			t.Fatal("unable to create product:", err)
+		}
+		id, event := readSSEEvent(t, reader)
+		assert.Equal(t, "1", id, "expect seq as id")
+		assert.Equal(t, EventProductCreated, event.Type, "expect created event")
+		assert.Equal(t, "B", event.Product.Brand, "expect the created product")
+	})
+
+	t.Run("resume with Last-Event-ID", func(t *testing.T) {
+		repo := setupInMemoryRepo(existing)
+		svc := NewProductServiceImpl(repo)
+		if err := svc.SetEventLog(repo); err != nil {
+			t.Fatal("unable to set event log:", err)
+		}
+		url := startHttpServer(t, buildHttpHandler(NewhttpTransport(svc)))
+
+		for _, brand := range []string{"B", "C"} {
+			if _, err := svc.Create(Product{Brand: brand, Category: brand, Price: 20}); err != nil {
+				t.Fatal("unable to create product:", err)
+			}
+		}
+
+		_, reader := openStream(t, url, "1")
+		id, event := readSSEEvent(t, reader)
+		assert.Equal(t, "2", id, "expect the missed event instead of a snapshot")
+		assert.Equal(t, "C", event.Product.Brand, "expect the missed product")
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		transport := NewhttpTransport(NewProductServiceImpl(setupInMemoryRepo(nil)))
+		transport.heartbeatInterval = 10 * time.Millisecond
+		url := startHttpServer(t, buildHttpHandler(transport))
+
+		_, reader := openStream(t, url, "")
+		readSSEEvent(t, reader)
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err, "expect a heartbeat")
+		assert.Equal(t, ": heartbeat\n", line, "expect heartbeat comment")
+	})
+
+	t.Run("invalid Last-Event-ID", func(t *testing.T) {
+		url := startHttpServer(t, buildHttpHandler(NewhttpTransport(NewProductServiceImpl(setupInMemoryRepo(nil)))))
+
+		res, _ := openStream(t, url, "abc")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, "expect 400 for invalid Last-Event-ID")
+	})
+}
+
+type nopFlusher struct{}
+
+func (nopFlusher) Flush() {}
+
+func TestSubscribeEventStream_StalledClient(t *testing.T) {
+	newStream := func(t *testing.T, writeWait time.Duration) *subscribeEventStream {
+		// nothing reads the client end, so writes block like on a stalled client
+		conn, client := net.Pipe()
+		t.Cleanup(func() { client.Close() })
+		return &subscribeEventStream{w: conn, flusher: nopFlusher{}, conn: conn, writeWait: writeWait, closed: make(chan struct{})}
+	}
+
+	t.Run("write times out", func(t *testing.T) {
+		sub := newStream(t, 10*time.Millisecond)
+
+		err := sub.write(": heartbeat\n\n")
+		var netErr net.Error
+		if assert.ErrorAs(t, err, &netErr, "expect the write to fail") {
+			assert.True(t, netErr.Timeout(), "expect a timeout")
+		}
+	})
+
+	t.Run("close ends a blocked update", func(t *testing.T) {
+		sub := newStream(t, time.Minute)
+
+		updated := make(chan struct{})
+		go func() {
+			sub.Update(Event{Seq: 1, Type: EventProductCreated})
+			close(updated)
+		}()
+		assert.NoError(t, sub.Close(), "expect close to succeed")
+
+		select {
+		case <-updated:
+		case <-time.After(time.Second):
+			t.Fatal("expect close to end the blocked update")
+		}
+	})
+}
+
+// readSSEEvent reads the next event of an event stream, skipping comments.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) (string, Event) {
+	var id string
+	var event Event
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal("unable to read event stream:", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.Type != "":
+			return id, event
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatal("unable to decode event:", err)
+			}
+		}
+	}
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	ImportCreate = "create"
+	ImportUpsert = "upsert"
+)
+
+const (
+	maxImportRows = 10000
+	maxImportSize = 10 << 20
+)
+
+var (
+	errDryRun                = errors.New("dry run")
+	errUnsupportedImportType = errors.New("unsupported import type")
+)
+
+// tooManyImportRows is the failure reported for files over maxImportRows,
+// whether they are CSV or XLSX.
+var tooManyImportRows = fmt.Sprintf("file should not have more than %d rows", maxImportRows)
+
+// importFields are the product fields a column can be mapped to.
+var importFields = map[string]bool{
+	"id":       true,
+	"sku":      true,
+	"brand":    true,
+	"category": true,
+	"quantity": true,
+	"price":    true,
+}
+
+type ImportOptions struct {
+	Mode   string
+	DryRun bool
+}
+
+type ImportSummary struct {
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	DryRun  bool `json:"dryRun"`
+}
+
+// ImportRow is a product read from line Line of an import file. Fields holds
+// the fields the file has a column for, an upsert leaves the others as they
+// are.
+type ImportRow struct {
+	Line    int
+	Product Product
+	Fields  map[string]bool
+}
+
+// ImportRowError is what went wrong with the row on line Line.
+type ImportRowError struct {
+	Line int
+	Err  error
+}
+
+// importError collects the row errors of an import that was not applied.
+type importError struct {
+	rows []ImportRowError
+}
+
+func (e *importError) Error() string {
+	return fmt.Sprintf("import failed on %d rows", len(e.rows))
+}
+
+func validateImportOptions(options ImportOptions) error {
+	if options.Mode != ImportCreate && options.Mode != ImportUpsert {
+		return &validationError{failures: []string{"mode should be one of create, upsert"}}
+	}
+	return nil
+}
+
+// parseImportMapping reads "header:field" pairs mapping file headers to
+// product fields.
+func parseImportMapping(pairs []string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	failures := make([]string, 0)
+	for _, pair := range pairs {
+		header, field, ok := strings.Cut(pair, ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || strings.TrimSpace(header) == "" || !importFields[field] {
+			failures = append(failures, fmt.Sprintf("map %q should look like header:field with field one of id, sku, brand, category, quantity, price", pair))
+			continue
+		}
+		mapping[strings.ToLower(strings.TrimSpace(header))] = field
+	}
+	if len(failures) > 0 {
+		return nil, &validationError{failures: failures}
+	}
+	return mapping, nil
+}
+
+// readImportRecords reads the records of a file of the given media type,
+// falling back on the extension of filename for generic types.
+func readImportRecords(r io.Reader, mediaType, filename string) ([][]string, error) {
+	switch {
+	case mediaType == "text/csv" || mediaType == "application/csv":
+		return readCSV(r)
+	case mediaType == xlsxContentType:
+	case strings.HasSuffix(strings.ToLower(filename), ".csv"):
+		return readCSV(r)
+	case strings.HasSuffix(strings.ToLower(filename), ".xlsx"):
+	default:
+		return nil, errUnsupportedImportType
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return readXLSX(data)
+}
+
+// readCSV reads all records of a CSV file, rows may have differing lengths.
+// Skipped blank lines are kept as empty records so record n is on line n+1.
+func readCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records := make([][]string, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, &validationError{failures: []string{fmt.Sprintf("invalid csv: %v", err)}}
+		}
+		line, _ := reader.FieldPos(0)
+		for len(records) < line-1 {
+			records = append(records, nil)
+		}
+		records = append(records, record)
+	}
+}
+
+// parseImportRows turns the records of a file into rows, record n being line
+// n+1 of the file. The first record is the header; headers are matched to
+// fields through mapping and otherwise by name. Columns matching no field are
+// ignored.
+func parseImportRows(records [][]string, mapping map[string]string) ([]ImportRow, error) {
+	if len(records) == 0 {
+		return nil, &validationError{failures: []string{"file should have a header row"}}
+	}
+	if len(records)-1 > maxImportRows {
+		return nil, &validationError{failures: []string{tooManyImportRows}}
+	}
+
+	columns := make([]string, len(records[0]))
+	mapped := make(map[string]bool)
+	for index, header := range records[0] {
+		header = strings.ToLower(strings.TrimSpace(header))
+		field, ok := mapping[header]
+		if !ok && importFields[header] {
+			field = header
+		}
+		if field == "" {
+			continue
+		}
+		if mapped[field] {
+			return nil, &validationError{failures: []string{fmt.Sprintf("more than one column is mapped to %s", field)}}
+		}
+		columns[index] = field
+		mapped[field] = true
+	}
+	for header := range mapping {
+		if !containsHeader(records[0], header) {
+			return nil, &validationError{failures: []string{fmt.Sprintf("column %q not found", header)}}
+		}
+	}
+
+	rows := make([]ImportRow, 0, len(records)-1)
+	rowErrors := make([]ImportRowError, 0)
+	for index, record := range records[1:] {
+		line := index + 2
+		if isBlankRecord(record) {
+			continue
+		}
+
+		row := ImportRow{Line: line, Fields: mapped}
+		failures := make([]string, 0)
+		for column, field := range columns {
+			if field == "" {
+				continue
+			}
+			value := ""
+			if column < len(record) {
+				value = strings.TrimSpace(record[column])
+			}
+			if err := setImportField(&row.Product, field, value); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+		if len(failures) > 0 {
+			rowErrors = append(rowErrors, ImportRowError{Line: line, Err: &validationError{failures: failures}})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, &importError{rows: rowErrors}
+	}
+	if len(rows) == 0 {
+		return nil, &validationError{failures: []string{"file should have at least one product row"}}
+	}
+	return rows, nil
+}
+
+func setImportField(product *Product, field, value string) error {
+	var err error
+	switch field {
+	case "id":
+		if value != "" {
+			if product.Id, err = strconv.Atoi(value); err != nil {
+				return errors.New("Id should be a whole number")
+			}
+		}
+	case "sku":
+		product.Sku = value
+	case "brand":
+		product.Brand = value
+	case "category":
+		product.Category = value
+	case "quantity":
+		if value != "" {
+			if product.Quantity, err = strconv.Atoi(value); err != nil {
+				return errors.New("Quantity should be a whole number")
+			}
+		}
+	case "price":
+		if value != "" {
+			if product.Price, err = strconv.ParseFloat(value, 64); err != nil {
+				return errors.New("Price should be a number")
+			}
+		}
+	}
+	return nil
+}
+
+// mergeImportRow overwrites the fields of existing the row has a column for.
+func mergeImportRow(existing Product, row ImportRow) Product {
+	product := existing
+	if row.Fields["brand"] {
+		product.Brand = row.Product.Brand
+	}
+	if row.Fields["category"] {
+		product.Category = row.Product.Category
+	}
+	if row.Fields["quantity"] {
+		product.Quantity = row.Product.Quantity
+	}
+	if row.Fields["price"] {
+		product.Price = row.Product.Price
+	}
+	return product
+}
+
+func containsHeader(headers []string, header string) bool {
+	for _, h := range headers {
+		if strings.ToLower(strings.TrimSpace(h)) == header {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImportRows(t *testing.T) {
+	tests := []struct {
+		name         string
+		csv          string
+		mapping      map[string]string
+		wantRows     []ImportRow
+		wantFailures []string
+		wantRowErrs  []int
+	}{
+		{
+			name: "headers matched by name",
+			csv:  "Brand,Category,Quantity,Price,Colour\nSamsung,Phone,3,99.5,black\n\nApple,Phone,,10,white\n",
+			wantRows: []ImportRow{
+				{
+					Line:    2,
+					Product: Product{Brand: "Samsung", Category: "Phone", Quantity: 3, Price: 99.5},
+					Fields:  map[string]bool{"brand": true, "category": true, "quantity": true, "price": true},
+				},
+				{
+					Line:    4,
+					Product: Product{Brand: "Apple", Category: "Phone", Price: 10},
+					Fields:  map[string]bool{"brand": true, "category": true, "quantity": true, "price": true},
+				},
+			},
+		},
+		{
+			name:    "mapped headers",
+			csv:     "Artikel,Preis\nSAM-1,5\n",
+			mapping: map[string]string{"artikel": "sku", "preis": "price"},
+			wantRows: []ImportRow{
+				{
+					Line:    2,
+					Product: Product{Sku: "SAM-1", Price: 5},
+					Fields:  map[string]bool{"sku": true, "price": true},
+				},
+			},
+		},
+		{
+			name:        "unparsable cells",
+			csv:         "brand,quantity,price\nA,many,1\nB,1,1\nC,2,cheap\n",
+			wantRowErrs: []int{2, 4},
+		},
+		{
+			name:         "mapped header missing",
+			csv:          "brand\nA\n",
+			mapping:      map[string]string{"preis": "price"},
+			wantFailures: []string{`column "preis" not found`},
+		},
+		{
+			name:         "two columns for a field",
+			csv:          "brand,marke\nA,B\n",
+			mapping:      map[string]string{"marke": "brand"},
+			wantFailures: []string{"more than one column is mapped to brand"},
+		},
+		{
+			name:         "no rows",
+			csv:          "brand,category\n",
+			wantFailures: []string{"file should have at least one product row"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := readCSV(strings.NewReader(tt.csv))
+			assert.NoError(t, err, "read csv should succeed")
+
+			rows, err := parseImportRows(records, tt.mapping)
+
+			switch {
+			case len(tt.wantFailures) > 0:
+				var ve *validationError
+				if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+					assert.Equal(t, tt.wantFailures, ve.failures, "expect failures to be same")
+				}
+			case len(tt.wantRowErrs) > 0:
+				var ie *importError
+				if assert.ErrorAs(t, err, &ie, "error should be an import error") {
+					lines := make([]int, 0)
+					for _, row := range ie.rows {
+						lines = append(lines, row.Line)
+					}
+					assert.Equal(t, tt.wantRowErrs, lines, "expect errors for the bad rows")
+				}
+			default:
+				assert.NoError(t, err, "parse should succeed")
+				assert.Equal(t, tt.wantRows, rows)
+			}
+		})
+	}
+}
+
+func TestParseImportMapping(t *testing.T) {
+	mapping, err := parseImportMapping([]string{"Marke:brand", " Preis : Price "})
+	assert.NoError(t, err, "parse should succeed")
+	assert.Equal(t, map[string]string{"marke": "brand", "preis": "price"}, mapping)
+
+	_, err = parseImportMapping([]string{"Marke", "Farbe:colour"})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Len(t, ve.failures, 2, "expect a failure per bad pair")
+	}
+}
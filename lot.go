@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultExpiringWithin is how far ahead the expiring lots report looks when
+// not told otherwise.
+const defaultExpiringWithin = 30 * 24 * time.Hour
+
+// Lot is a batch of a product made and expiring together. Quantity is the
+// part of the product stock held in the lot, it only changes through
+// movements naming the lot.
+type Lot struct {
+	Id             int       `json:"id" bun:"id,pk,autoincrement"`
+	ProductId      int       `json:"productId"`
+	Number         string    `json:"number"`
+	ManufacturedAt time.Time `json:"manufacturedAt,omitempty" bun:",nullzero"`
+	ExpiresAt      time.Time `json:"expiresAt,omitempty" bun:",nullzero"`
+	Quantity       int       `json:"quantity"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// LotQuantity is the part of a movement booked against a lot. Quantity is
+// positive and goes the way the movement does.
+type LotQuantity struct {
+	LotId    int `json:"lotId"`
+	Quantity int `json:"quantity"`
+}
+
+func validateLot(lot Lot) error {
+	failures := make([]string, 0)
+
+	if lot.Number == "" {
+		failures = append(failures, "Number should not be empty")
+	}
+	if !lot.ManufacturedAt.IsZero() && !lot.ExpiresAt.IsZero() && lot.ExpiresAt.Before(lot.ManufacturedAt) {
+		failures = append(failures, "ExpiresAt should not be before ManufacturedAt")
+	}
+	if lot.Quantity < 0 {
+		failures = append(failures, "Quantity should not be less than 0")
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// expired tells whether lot is past its expiry at now. A lot without one
+// never expires.
+func (lot Lot) expired(now time.Time) bool {
+	return !lot.ExpiresAt.IsZero() && !now.Before(lot.ExpiresAt)
+}
+
+// sortFEFO orders lots first expiring first, lots without expiry last.
+func sortFEFO(lots []Lot) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		left, right := lots[i].ExpiresAt, lots[j].ExpiresAt
+		if left.IsZero() || right.IsZero() {
+			return !left.IsZero() && right.IsZero()
+		}
+		if !left.Equal(right) {
+			return left.Before(right)
+		}
+		return lots[i].Id < lots[j].Id
+	})
+}
+
+// allocateFEFO takes quantity out of the lots of a product with onHand in
+// stock, first expired first out. Expired lots are skipped, and what the lots
+// do not cover comes from the stock held outside any lot.
+func allocateFEFO(lots []Lot, onHand, quantity int, now time.Time) ([]LotQuantity, error) {
+	unlotted := onHand
+	for _, lot := range lots {
+		unlotted -= lot.Quantity
+	}
+
+	sorted := append([]Lot{}, lots...)
+	sortFEFO(sorted)
+	allocations := make([]LotQuantity, 0)
+	for _, lot := range sorted {
+		if quantity == 0 {
+			break
+		}
+		if lot.Quantity <= 0 || lot.expired(now) {
+			continue
+		}
+		taken := lot.Quantity
+		if taken > quantity {
+			taken = quantity
+		}
+		allocations = append(allocations, LotQuantity{LotId: lot.Id, Quantity: taken})
+		quantity -= taken
+	}
+	if quantity > unlotted {
+		return nil, errInsufficientStock
+	}
+	return allocations, nil
+}
+
+// prorateLots returns the share of lots in quantity out of total, stock of
+// which lots hold a part and the rest is outside any lot. Every lot gives its
+// share rounded down, so the lots are emptied when all of total is taken.
+func prorateLots(lots []LotQuantity, total, quantity int) []LotQuantity {
+	var taken []LotQuantity
+	for _, lot := range lots {
+		share := lot.Quantity
+		if quantity < total {
+			share = lot.Quantity * quantity / total
+		}
+		if share > 0 {
+			taken = append(taken, LotQuantity{LotId: lot.LotId, Quantity: share})
+		}
+	}
+	return taken
+}
+
+// subtractLots returns what is left of lots once taken is out of them.
+func subtractLots(lots, taken []LotQuantity) []LotQuantity {
+	var left []LotQuantity
+	for _, lot := range lots {
+		for _, out := range taken {
+			if out.LotId == lot.LotId {
+				lot.Quantity -= out.Quantity
+			}
+		}
+		if lot.Quantity > 0 {
+			left = append(left, lot)
+		}
+	}
+	return left
+}
+
+// parseWithin reads the within query parameter of the expiring lots report,
+// a duration like 72h or a number of days like 30d.
+func parseWithin(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultExpiringWithin, nil
+	}
+
+	var within time.Duration
+	var err error
+	if days := strings.TrimSuffix(raw, "d"); days != raw {
+		var n int
+		n, err = strconv.Atoi(days)
+		within = time.Duration(n) * 24 * time.Hour
+	} else {
+		within, err = time.ParseDuration(raw)
+	}
+	if err != nil || within < 0 {
+		return 0, &validationError{failures: []string{fmt.Sprintf("within should be a duration like 72h or 30d, got %q", raw)}}
+	}
+	return within, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type lotTransport struct {
+	service LotService
+}
+
+func NewLotTransport(svc LotService) *lotTransport {
+	return &lotTransport{
+		service: svc,
+	}
+}
+
+func (t *lotTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/products/{id}/lots", t.CreateLot).Methods("POST")
+	r.HandleFunc("/products/{id}/lots", t.GetLots).Methods("GET")
+	r.HandleFunc("/lots/expiring", t.GetExpiringLots).Methods("GET")
+	r.HandleFunc("/lots/{id}", t.GetLotById).Methods("GET")
+}
+
+func (t *lotTransport) CreateLot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var lot Lot
+	if err := json.NewDecoder(r.Body).Decode(&lot); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	lot.ProductId = id
+	created, err := t.service.CreateLot(lot)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *lotTransport) GetLots(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	lots, err := t.service.GetLots(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lots)
+}
+
+func (t *lotTransport) GetExpiringLots(w http.ResponseWriter, r *http.Request) {
+	within, err := parseWithin(r.URL.Query().Get("within"))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	lots, err := t.service.GetExpiringLots(within)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lots)
+}
+
+func (t *lotTransport) GetLotById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	lot, err := t.service.GetLotById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lot)
+}
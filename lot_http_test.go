@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLotTransport(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Version: 1}})
+	products := NewProductServiceImpl(repo)
+	handler := buildHttpHandler(
+		NewhttpTransport(products),
+		NewLotTransport(products),
+	)
+	soon := time.Now().AddDate(0, 0, 5).UTC().Format(time.RFC3339)
+	later := time.Now().AddDate(0, 3, 0).UTC().Format(time.RFC3339)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "invalid lot",
+			method:         "POST",
+			url:            "/products/1/lots",
+			body:           `{"quantity": -1}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Number should not be empty", "Quantity should not be less than 0"]}`,
+		},
+		{
+			name:           "unknown product",
+			method:         "POST",
+			url:            "/products/7/lots",
+			body:           `{"number": "L1"}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["product not found"]}`,
+		},
+		{
+			name:           "create lot",
+			method:         "POST",
+			url:            "/products/1/lots",
+			body:           fmt.Sprintf(`{"number": "L1", "expiresAt": %q, "quantity": 5}`, later),
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "create lot expiring soon",
+			method:         "POST",
+			url:            "/products/1/lots",
+			body:           fmt.Sprintf(`{"number": "L2", "expiresAt": %q, "quantity": 2}`, soon),
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "duplicate lot number",
+			method:         "POST",
+			url:            "/products/1/lots",
+			body:           `{"number": "L1"}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["lot number exists"]}`,
+		},
+		{
+			name:           "issue first expired first out",
+			method:         "POST",
+			url:            "/products/1/movements",
+			body:           `{"type": "issue", "quantity": 3, "reason": "sold"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "issue from an unknown lot",
+			method:         "POST",
+			url:            "/products/1/movements",
+			body:           `{"type": "issue", "quantity": 1, "reason": "sold", "lots": [{"lotId": 7, "quantity": 1}]}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["lot not found"]}`,
+		},
+		{
+			name:           "invalid within",
+			method:         "GET",
+			url:            "/lots/expiring?within=soon",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["within should be a duration like 72h or 30d, got \"soon\""]}`,
+		},
+		{
+			name:           "nothing expiring within a day",
+			method:         "GET",
+			url:            "/lots/expiring?within=1d",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "unknown lot",
+			method:         "GET",
+			url:            "/lots/7",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["lot not found"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1/lots", nil))
+	var lots []Lot
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&lots), "expect lots response")
+	if assert.Len(t, lots, 2) {
+		assert.Equal(t, "L2", lots[0].Number, "expect the first expiring first")
+		assert.Equal(t, []int{0, 4}, []int{lots[0].Quantity, lots[1].Quantity})
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/lots/expiring?within=120d", nil))
+	var expiring []Lot
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&expiring), "expect lots response")
+	if assert.Len(t, expiring, 1, "expect empty lots to be left out") {
+		assert.Equal(t, "L1", expiring[0].Number)
+	}
+}
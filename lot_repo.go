@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errLotNotFound  = errors.New("lot not found")
+	errDuplicateLot = errors.New("found duplicate lot number")
+)
+
+// LotRepo stores the lots of products. Their quantities only change through
+// AddMovement.
+type LotRepo interface {
+	// CreateLot fails with errProductNotFound when the product does not exist
+	// and with errDuplicateLot when it already has a lot with the number.
+	CreateLot(Lot) (Lot, error)
+	GetLotById(id int) (Lot, error)
+	// GetLots returns the lots of a product first expiring first.
+	GetLots(productId int) ([]Lot, error)
+	// LockLots gets the lots like GetLots and makes other transactions
+	// locking them wait until the calling one ends.
+	LockLots(productId int) ([]Lot, error)
+	// GetExpiringLots returns the lots still holding stock that expire
+	// before the given time, expired ones included, first expiring first.
+	GetExpiringLots(before time.Time) ([]Lot, error)
+}
+
+func (r *InMemoryRepo) CreateLot(lot Lot) (Lot, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	if _, err := r.GetById(lot.ProductId); err != nil {
+		return Lot{}, err
+	}
+	for _, currentLot := range r.lots {
+		if currentLot.ProductId == lot.ProductId && currentLot.Number == lot.Number {
+			return Lot{}, errDuplicateLot
+		}
+	}
+
+	r.lastLotId++
+	lot.Id = r.lastLotId
+	r.lots = append(r.lots, lot)
+	return lot, nil
+}
+
+func (r *InMemoryRepo) GetLotById(id int) (Lot, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentLot := range r.lots {
+		if currentLot.Id == id {
+			return currentLot, nil
+		}
+	}
+	return Lot{}, errLotNotFound
+}
+
+func (r *InMemoryRepo) GetLots(productId int) ([]Lot, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	lots := make([]Lot, 0)
+	for _, currentLot := range r.lots {
+		if currentLot.ProductId == productId {
+			lots = append(lots, currentLot)
+		}
+	}
+	sortFEFO(lots)
+	return lots, nil
+}
+
+// LockLots needs no lock of its own, InTx already runs one transaction at a
+// time.
+func (r *InMemoryRepo) LockLots(productId int) ([]Lot, error) {
+	return r.GetLots(productId)
+}
+
+func (r *InMemoryRepo) GetExpiringLots(before time.Time) ([]Lot, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	lots := make([]Lot, 0)
+	for _, currentLot := range r.lots {
+		if currentLot.Quantity > 0 && !currentLot.ExpiresAt.IsZero() && currentLot.ExpiresAt.Before(before) {
+			lots = append(lots, currentLot)
+		}
+	}
+	sortFEFO(lots)
+	return lots, nil
+}
+
+// lotIndex finds the lot with id of the product, -1 when there is none.
+func (r *InMemoryRepo) lotIndex(productId, id int) int {
+	for idx, currentLot := range r.lots {
+		if currentLot.Id == id && currentLot.ProductId == productId {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_Lots(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A"}, {Id: 2, Brand: "B", Category: "B"}})
+	now := time.Date(2024, 1, 22, 12, 0, 0, 0, time.UTC)
+
+	_, err := repo.CreateLot(Lot{ProductId: 7, Number: "L1"})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	late, err := repo.CreateLot(Lot{ProductId: 1, Number: "L1", ExpiresAt: now.AddDate(0, 2, 0)})
+	assert.NoError(t, err, "create lot should succeed")
+	early, err := repo.CreateLot(Lot{ProductId: 1, Number: "L2", ExpiresAt: now.AddDate(0, 1, 0)})
+	assert.NoError(t, err, "create lot should succeed")
+	_, err = repo.CreateLot(Lot{ProductId: 1, Number: "L1"})
+	assert.ErrorIs(t, err, errDuplicateLot)
+	other, err := repo.CreateLot(Lot{ProductId: 2, Number: "L1"})
+	assert.NoError(t, err, "expect lot numbers to be per product")
+
+	_, err = repo.AddMovement(StockMovement{ProductId: 1, Type: MovementReceipt, Quantity: 5, Lots: []LotQuantity{{LotId: late.Id, Quantity: 3}, {LotId: early.Id, Quantity: 2}}})
+	assert.NoError(t, err, "add movement should succeed")
+	_, err = repo.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 3, Lots: []LotQuantity{{LotId: early.Id, Quantity: 3}}})
+	assert.ErrorIs(t, err, errInsufficientStock)
+	_, err = repo.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 1, Lots: []LotQuantity{{LotId: other.Id, Quantity: 1}}})
+	assert.ErrorIs(t, err, errLotNotFound, "expect lots of other products not to be booked")
+	product, _ := repo.GetById(1)
+	assert.Equal(t, 5, product.Quantity, "expect failed movements to leave the stock untouched")
+
+	lots, err := repo.GetLots(1)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, lots, 2) {
+		assert.Equal(t, []int{early.Id, late.Id}, []int{lots[0].Id, lots[1].Id}, "expect the first expiring first")
+		assert.Equal(t, []int{2, 3}, []int{lots[0].Quantity, lots[1].Quantity})
+	}
+	_, err = repo.GetLots(7)
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	expiring, err := repo.GetExpiringLots(now.AddDate(0, 1, 1))
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, []Lot{{Id: early.Id, ProductId: 1, Number: "L2", ExpiresAt: early.ExpiresAt, Quantity: 2}}, expiring)
+
+	assert.NoError(t, repo.Delete(1, 0), "delete should succeed")
+	_, err = repo.GetLotById(late.Id)
+	assert.ErrorIs(t, err, errLotNotFound, "expect deleting a product to drop its lots")
+}
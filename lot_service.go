@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// LotService is implemented by the product service: lots hold the stock of a
+// product, which it books through movements like any other.
+type LotService interface {
+	CreateLot(Lot) (Lot, error)
+	GetLotById(id int) (Lot, error)
+	GetLots(productId int) ([]Lot, error)
+	GetExpiringLots(within time.Duration) ([]Lot, error)
+}
+
+// CreateLot adds a lot to a product. A lot created with a quantity books it
+// as a receipt into the lot.
+func (s *ProductServiceImpl) CreateLot(lot Lot) (Lot, error) {
+	if s.deferred == nil {
+		var created Lot
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			created, err = tx.CreateLot(lot)
+			return err
+		})
+		return created, err
+	}
+
+	if err := validateLot(lot); err != nil {
+		return Lot{}, fmt.Errorf("create lot: %w", err)
+	}
+
+	received := lot.Quantity
+	lot.Quantity = 0
+	lot.CreatedAt = time.Now()
+	created, err := s.repo.CreateLot(lot)
+	if err != nil {
+		return Lot{}, err
+	}
+	if received == 0 {
+		return created, nil
+	}
+
+	_, err = s.AddMovement(StockMovement{
+		ProductId: created.ProductId,
+		Type:      MovementReceipt,
+		Quantity:  received,
+		Lots:      []LotQuantity{{LotId: created.Id, Quantity: received}},
+		Reason:    fmt.Sprintf("lot %s received", created.Number),
+	})
+	if err != nil {
+		return Lot{}, err
+	}
+	return s.repo.GetLotById(created.Id)
+}
+
+func (s *ProductServiceImpl) GetLotById(id int) (Lot, error) {
+	return s.repo.GetLotById(id)
+}
+
+func (s *ProductServiceImpl) GetLots(productId int) ([]Lot, error) {
+	return s.repo.GetLots(productId)
+}
+
+// GetExpiringLots reports the lots still holding stock that expire within
+// the given time from now, the ones already expired included.
+func (s *ProductServiceImpl) GetExpiringLots(within time.Duration) ([]Lot, error) {
+	return s.repo.GetExpiringLots(time.Now().Add(within))
+}
+
+// bookMovement adds movement to the ledger. A movement taking stock out of a
+// product with lots without naming any takes it from the lots first expired
+// first out, see allocateFEFO. It has to run bound to a transaction.
+func (s *ProductServiceImpl) bookMovement(movement StockMovement, current Product) (StockMovement, error) {
+	if movement.delta() < 0 && len(movement.Lots) == 0 {
+		lots, err := s.repo.LockLots(movement.ProductId)
+		if err != nil {
+			return StockMovement{}, err
+		}
+		if len(lots) > 0 {
+			movement.Lots, err = allocateFEFO(lots, current.Quantity, -movement.delta(), movement.CreatedAt)
+			if err != nil {
+				return StockMovement{}, err
+			}
+		}
+	}
+	return s.repo.AddMovement(movement)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductServiceImpl_Lots(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Version: 1}})
+	service := NewProductServiceImpl(repo)
+	now := time.Now()
+
+	_, err := service.CreateLot(Lot{ProductId: 1})
+	var ve *validationError
+	assert.ErrorAs(t, err, &ve, "expect a lot to need a number")
+
+	late, err := service.CreateLot(Lot{ProductId: 1, Number: "L1", ExpiresAt: now.AddDate(0, 2, 0), Quantity: 4})
+	assert.NoError(t, err, "create lot should succeed")
+	assert.Equal(t, 4, late.Quantity, "expect the quantity to be received into the lot")
+	early, err := service.CreateLot(Lot{ProductId: 1, Number: "L2", ExpiresAt: now.AddDate(0, 0, 3), Quantity: 3})
+	assert.NoError(t, err, "create lot should succeed")
+	expired, err := service.CreateLot(Lot{ProductId: 1, Number: "L3", ExpiresAt: now.AddDate(0, 0, -1), Quantity: 2})
+	assert.NoError(t, err, "create lot should succeed")
+	product, _ := service.GetById(1)
+	assert.Equal(t, 9, product.Quantity)
+	movements, _ := service.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		assert.Equal(t, "lot L3 received", movements[len(movements)-1].Reason)
+	}
+
+	issued, err := service.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 5, Reason: "sold"})
+	assert.NoError(t, err, "add movement should succeed")
+	assert.Equal(t, []LotQuantity{{LotId: early.Id, Quantity: 3}, {LotId: late.Id, Quantity: 2}}, issued.Lots, "expect the first expiring lot to go first")
+
+	_, err = service.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 3, Reason: "sold"})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect expired stock not to be issued")
+	scrapped, err := service.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 2, Reason: "expired", Lots: []LotQuantity{{LotId: expired.Id, Quantity: 2}}})
+	assert.NoError(t, err, "expect an expired lot to be issued by name")
+	assert.Len(t, scrapped.Lots, 1)
+
+	lots, err := service.GetLots(1)
+	assert.NoError(t, err, "expect no error")
+	quantities := make(map[int]int)
+	for _, lot := range lots {
+		quantities[lot.Id] = lot.Quantity
+	}
+	assert.Equal(t, map[int]int{early.Id: 0, late.Id: 2, expired.Id: 0}, quantities)
+
+	expiring, err := service.GetExpiringLots(90 * 24 * time.Hour)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, expiring, 1, "expect only lots holding stock") {
+		assert.Equal(t, late.Id, expiring[0].Id)
+	}
+}
+
+func TestProductServiceImpl_ShipFromLots(t *testing.T) {
+	orders, products, order := setupSalesOrders(t, SalesOrderLine{ProductId: 1, Quantity: 3})
+	lot, err := products.CreateLot(Lot{ProductId: 1, Number: "L1", ExpiresAt: time.Now().AddDate(0, 1, 0), Quantity: 2})
+	assert.NoError(t, err, "create lot should succeed")
+
+	for _, step := range []func(int) (SalesOrder, error){orders.AllocateSalesOrder, orders.PickSalesOrder, orders.PackSalesOrder, orders.ShipSalesOrder} {
+		_, err := step(order.Id)
+		assert.NoError(t, err, "expect no error")
+	}
+
+	movements, _ := products.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		assert.Equal(t, []LotQuantity{{LotId: lot.Id, Quantity: 2}}, movements[len(movements)-1].Lots, "expect shipping to empty the lot before the stock outside it")
+	}
+}
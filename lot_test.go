@@ -0,0 +1,199 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLot(t *testing.T) {
+	made := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		lot          Lot
+		wantFailures []string
+	}{
+		{
+			name: "valid lot",
+			lot:  Lot{Number: "L1", ManufacturedAt: made, ExpiresAt: made.AddDate(0, 6, 0), Quantity: 10},
+		},
+		{
+			name: "lot without dates",
+			lot:  Lot{Number: "L1"},
+		},
+		{
+			name:         "invalid lot",
+			lot:          Lot{ManufacturedAt: made, ExpiresAt: made.AddDate(0, 0, -1), Quantity: -1},
+			wantFailures: []string{"Number should not be empty", "ExpiresAt should not be before ManufacturedAt", "Quantity should not be less than 0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLot(tt.lot)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestValidateMovement_Lots(t *testing.T) {
+	tests := []struct {
+		name         string
+		movement     StockMovement
+		wantFailures []string
+	}{
+		{
+			name:     "issue from lots",
+			movement: StockMovement{Type: MovementIssue, Quantity: 5, Reason: "sold", Lots: []LotQuantity{{LotId: 1, Quantity: 2}, {LotId: 2, Quantity: 3}}},
+		},
+		{
+			name:     "negative adjustment of a lot",
+			movement: StockMovement{Type: MovementAdjustment, Quantity: -2, Reason: "count", Lots: []LotQuantity{{LotId: 1, Quantity: 2}}},
+		},
+		{
+			name:         "transfer with lots",
+			movement:     StockMovement{Type: MovementTransfer, Quantity: 1, LocationId: 1, ToLocationId: 2, Reason: "move", Lots: []LotQuantity{{LotId: 1, Quantity: 1}}},
+			wantFailures: []string{"Lots should be empty for a transfer"},
+		},
+		{
+			name:     "receipt partly into a lot",
+			movement: StockMovement{Type: MovementReceipt, Quantity: 5, Reason: "delivery", Lots: []LotQuantity{{LotId: 1, Quantity: 2}}},
+		},
+		{
+			name:     "invalid lots",
+			movement: StockMovement{Type: MovementReceipt, Quantity: 1, Reason: "delivery", Lots: []LotQuantity{{Quantity: 1}, {LotId: 2, Quantity: 1}, {LotId: 2}}},
+			wantFailures: []string{
+				"lot 1: LotId should be greater than 0",
+				"lot 3: LotId 2 is given twice",
+				"lot 3: Quantity should be greater than 0",
+				"Lots should not add up to more than Quantity",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMovement(tt.movement)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestAllocateFEFO(t *testing.T) {
+	now := time.Date(2024, 1, 22, 12, 0, 0, 0, time.UTC)
+	lots := []Lot{
+		{Id: 1, Quantity: 4},
+		{Id: 2, Quantity: 3, ExpiresAt: now.AddDate(0, 0, 10)},
+		{Id: 3, Quantity: 2, ExpiresAt: now.AddDate(0, 0, 5)},
+		{Id: 4, Quantity: 5, ExpiresAt: now.AddDate(0, 0, -1)},
+		{Id: 5, Quantity: 0, ExpiresAt: now.AddDate(0, 0, 1)},
+	}
+
+	tests := []struct {
+		name            string
+		onHand          int
+		quantity        int
+		wantAllocations []LotQuantity
+		wantErr         error
+	}{
+		{
+			name:            "first expiring first",
+			onHand:          14,
+			quantity:        4,
+			wantAllocations: []LotQuantity{{LotId: 3, Quantity: 2}, {LotId: 2, Quantity: 2}},
+		},
+		{
+			name:            "lots without expiry last",
+			onHand:          14,
+			quantity:        7,
+			wantAllocations: []LotQuantity{{LotId: 3, Quantity: 2}, {LotId: 2, Quantity: 3}, {LotId: 1, Quantity: 2}},
+		},
+		{
+			name:            "rest from stock outside lots",
+			onHand:          16,
+			quantity:        11,
+			wantAllocations: []LotQuantity{{LotId: 3, Quantity: 2}, {LotId: 2, Quantity: 3}, {LotId: 1, Quantity: 4}},
+		},
+		{
+			name:     "expired lots are not issued",
+			onHand:   14,
+			quantity: 10,
+			wantErr:  errInsufficientStock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations, err := allocateFEFO(lots, tt.onHand, tt.quantity, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err, "expect no error")
+			assert.Equal(t, tt.wantAllocations, allocations)
+		})
+	}
+	assert.Equal(t, 1, lots[0].Id, "expect the lots given to keep their order")
+}
+
+func TestProrateLots(t *testing.T) {
+	lots := []LotQuantity{{LotId: 1, Quantity: 4}, {LotId: 2, Quantity: 1}}
+
+	tests := []struct {
+		name      string
+		total     int
+		quantity  int
+		wantTaken []LotQuantity
+	}{
+		{name: "share of every lot", total: 10, quantity: 5, wantTaken: []LotQuantity{{LotId: 1, Quantity: 2}}},
+		{name: "all of it", total: 10, quantity: 10, wantTaken: []LotQuantity{{LotId: 1, Quantity: 4}, {LotId: 2, Quantity: 1}}},
+		{name: "too little for any lot", total: 10, quantity: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := prorateLots(lots, tt.total, tt.quantity)
+			assert.Equal(t, tt.wantTaken, taken)
+		})
+	}
+	assert.Equal(t, []LotQuantity{{LotId: 1, Quantity: 2}, {LotId: 2, Quantity: 1}}, subtractLots(lots, []LotQuantity{{LotId: 1, Quantity: 2}}))
+	assert.Nil(t, subtractLots(lots, lots), "expect nothing left of lots taken in full")
+}
+
+func TestParseWithin(t *testing.T) {
+	tests := []struct {
+		raw        string
+		wantWithin time.Duration
+		wantErr    bool
+	}{
+		{raw: "", wantWithin: defaultExpiringWithin},
+		{raw: "72h", wantWithin: 72 * time.Hour},
+		{raw: "7d", wantWithin: 7 * 24 * time.Hour},
+		{raw: "soon", wantErr: true},
+		{raw: "-1d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			within, err := parseWithin(tt.raw)
+			if tt.wantErr {
+				var ve *validationError
+				assert.ErrorAs(t, err, &ve, "error should be of ValidationError type")
+				return
+			}
+			assert.NoError(t, err, "expect no error")
+			assert.Equal(t, tt.wantWithin, within)
+		})
+	}
+}
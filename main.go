@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -178,6 +180,11 @@ func connectPostgres(user, password, address, dbName string) *bun.DB {
 }
 
 func main() {
+	skuPattern := flag.String("sku-pattern", defaultSkuPattern, "pattern for generated SKUs, using {id}, {brand} and {category} with an optional :width")
+	queueSize := flag.Int("subscriber-queue", defaultQueueSize, "events queued per realtime subscriber before the slow consumer policy applies")
+	slowConsumer := flag.String("slow-consumer", SlowConsumerDrop, "what happens to realtime subscribers that fall behind: drop, coalesce or disconnect")
+	flag.Parse()
+
 	db := connectPostgres("postgres", "postgres", "127.0.0.1:5432", "productsdb")
 
 	if _, err := db.Exec("select 1"); err != nil {
@@ -186,10 +193,49 @@ func main() {
 
 	repo := NewPostgresRepo(db)
 	svc := NewProductServiceImpl(repo)
-	transport := NewhttpTransport(svc)
-
-	httpHandler := buildHttpHandler(transport)
+	if err := svc.SetSkuPattern(*skuPattern); err != nil {
+		log.Fatalln("invalid sku pattern:", err)
+	}
+	if err := svc.SetEventLog(repo); err != nil {
+		log.Fatalln("failed to read event log:", err)
+	}
+	if err := svc.SetPublisherOptions(PublisherOptions{QueueSize: *queueSize, SlowConsumer: *slowConsumer}); err != nil {
+		log.Fatalln("invalid publisher options:", err)
+	}
+	relay, err := NewPostgresRelay(db)
+	if err != nil {
+		log.Fatalln("failed to create relay:", err)
+	}
+	svc.SetRelay(relay)
+	go func() {
+		log.Println("relay exiting:", relay.Receive(context.Background(), svc.relayed))
+	}()
+
+	webhookSvc := NewWebhookServiceImpl(repo)
+	svc.SetOutbox(repo, webhookSvc)
+	go func() {
+		log.Println("outbox worker exiting:", svc.RunOutbox(context.Background()))
+	}()
+	go func() {
+		log.Println("webhook worker exiting:", webhookSvc.Run(context.Background()))
+	}()
+	go func() {
+		log.Println("reservation sweeper exiting:", svc.RunReservationSweeper(context.Background()))
+	}()
 
-	err := http.ListenAndServe(":5000", httpHandler)
+	transport := NewhttpTransport(svc)
+	warehouseTransport := NewWarehouseTransport(NewWarehouseServiceImpl(repo))
+	webhookTransport := NewWebhookTransport(webhookSvc)
+	reservationTransport := NewReservationTransport(svc)
+	purchaseOrderTransport := NewPurchaseOrderTransport(NewPurchaseOrderServiceImpl(repo, svc))
+	salesOrderTransport := NewSalesOrderTransport(NewSalesOrderServiceImpl(repo, svc))
+	rmaTransport := NewRmaTransport(NewRmaServiceImpl(repo, svc))
+	transferOrderTransport := NewTransferOrderTransport(NewTransferOrderServiceImpl(repo, svc))
+	lotTransport := NewLotTransport(svc)
+
+	httpHandler := buildHttpHandler(transport, warehouseTransport, webhookTransport, reservationTransport, purchaseOrderTransport, salesOrderTransport, rmaTransport, transferOrderTransport, lotTransport)
+
+	server := &http.Server{Addr: ":5000", Handler: httpHandler, ConnContext: withConn}
+	err = server.ListenAndServe()
 	log.Println("http server exiting:", err)
 }
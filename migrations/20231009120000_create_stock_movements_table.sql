@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE if not exists stock_movements(
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    quantity INT NOT NULL,
+    reason TEXT NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL
+);
+
+CREATE INDEX if not exists stock_movements_product_id_idx ON stock_movements(product_id);
+
+-- book the stock of existing products as an opening movement so the ledger
+-- adds up to products.quantity
+INSERT INTO stock_movements(product_id, type, quantity, reason, created_at)
+SELECT id, CASE WHEN quantity > 0 THEN 'receipt' ELSE 'adjustment' END, quantity, 'opening stock', now()
+FROM products
+WHERE quantity <> 0;
+
+-- +goose Down
+DROP TABLE if exists stock_movements;
@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE TABLE if not exists warehouses(
+    id SERIAL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL
+);
+
+CREATE TABLE if not exists locations(
+    id SERIAL PRIMARY KEY,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    aisle TEXT NOT NULL,
+    rack TEXT NOT NULL DEFAULT '',
+    bin TEXT NOT NULL,
+    created_at timestamptz NOT NULL,
+    UNIQUE (warehouse_id, code)
+);
+
+CREATE TABLE if not exists stock_levels(
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    location_id INT NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+    quantity INT NOT NULL,
+    PRIMARY KEY (product_id, location_id)
+);
+
+CREATE INDEX if not exists stock_levels_location_id_idx ON stock_levels(location_id);
+
+ALTER TABLE stock_movements
+    ADD COLUMN if not exists location_id INT REFERENCES locations(id),
+    ADD COLUMN if not exists to_location_id INT REFERENCES locations(id);
+
+-- +goose Down
+ALTER TABLE stock_movements
+    DROP COLUMN if exists to_location_id,
+    DROP COLUMN if exists location_id;
+
+DROP TABLE if exists stock_levels;
+DROP TABLE if exists locations;
+DROP TABLE if exists warehouses;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE EXTENSION if not exists pg_trgm;
+
+ALTER TABLE products
+    ADD COLUMN if not exists search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', brand || ' ' || category)) STORED;
+
+CREATE INDEX if not exists products_search_vector_idx ON products USING GIN (search_vector);
+CREATE INDEX if not exists products_search_trgm_idx ON products USING GIN ((brand || ' ' || category) gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX if exists products_search_trgm_idx;
+DROP INDEX if exists products_search_vector_idx;
+ALTER TABLE products DROP COLUMN if exists search_vector;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE SEQUENCE if not exists products_id_seq OWNED BY products.id;
+SELECT setval('products_id_seq', COALESCE(MAX(id), 0) + 1, false) FROM products;
+
+ALTER TABLE products ADD COLUMN if not exists sku TEXT;
+UPDATE products SET sku = 'SKU-' || lpad(id::text, 6, '0') WHERE sku IS NULL;
+ALTER TABLE products ADD CONSTRAINT products_sku_key UNIQUE (sku);
+
+-- +goose Down
+ALTER TABLE products DROP CONSTRAINT if exists products_sku_key;
+ALTER TABLE products DROP COLUMN if exists sku;
+DROP SEQUENCE if exists products_id_seq;
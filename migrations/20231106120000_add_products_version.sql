@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE products ADD COLUMN if not exists version INT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE products DROP COLUMN if exists version;
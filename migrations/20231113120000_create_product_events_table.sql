@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE if not exists product_events(
+    seq BIGINT PRIMARY KEY,
+    type TEXT NOT NULL,
+    product JSONB,
+    created_at timestamptz NOT NULL
+);
+
+-- +goose Down
+DROP TABLE if exists product_events;
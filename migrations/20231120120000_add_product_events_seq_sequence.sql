@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE SEQUENCE if not exists product_events_seq OWNED BY product_events.seq;
+SELECT setval('product_events_seq', COALESCE(MAX(seq), 0) + 1, false) FROM product_events;
+ALTER TABLE product_events ALTER COLUMN seq SET DEFAULT nextval('product_events_seq');
+
+-- +goose Down
+ALTER TABLE product_events ALTER COLUMN seq DROP DEFAULT;
+DROP SEQUENCE if exists product_events_seq;
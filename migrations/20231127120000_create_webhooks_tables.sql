@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE if not exists webhooks(
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE TABLE if not exists webhook_deliveries(
+    id SERIAL PRIMARY KEY,
+    webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_seq BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_status_code INT,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL,
+    delivered_at timestamptz,
+    UNIQUE (webhook_id, event_seq)
+);
+
+CREATE INDEX if not exists webhook_deliveries_due_idx ON webhook_deliveries(status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE if exists webhook_deliveries;
+DROP TABLE if exists webhooks;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE if not exists product_outbox(
+    id BIGSERIAL PRIMARY KEY,
+    seq BIGINT,
+    type TEXT NOT NULL,
+    product JSONB,
+    created_at timestamptz NOT NULL,
+    claimed_until timestamptz
+);
+
+-- +goose Down
+DROP TABLE if exists product_outbox;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE products ADD COLUMN if not exists reorder_point INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN if not exists reorder_quantity INT NOT NULL DEFAULT 0;
+
+CREATE INDEX if not exists products_low_stock_idx ON products(id) WHERE quantity <= reorder_point;
+
+-- +goose Down
+DROP INDEX if exists products_low_stock_idx;
+ALTER TABLE products DROP COLUMN if exists reorder_quantity;
+ALTER TABLE products DROP COLUMN if exists reorder_point;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE products ADD COLUMN if not exists reserved INT NOT NULL DEFAULT 0 CHECK (reserved >= 0);
+
+CREATE TABLE if not exists reservations(
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    reference TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX if not exists reservations_product_id_idx ON reservations(product_id);
+CREATE INDEX if not exists reservations_expiry_idx ON reservations(expires_at) WHERE status = 'active';
+
+-- +goose Down
+DROP TABLE if exists reservations;
+ALTER TABLE products DROP COLUMN if exists reserved;
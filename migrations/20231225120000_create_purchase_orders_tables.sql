@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE if not exists suppliers(
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    phone TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE TABLE if not exists purchase_orders(
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    reference TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    expected_at timestamptz,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX if not exists purchase_orders_supplier_id_idx ON purchase_orders(supplier_id);
+CREATE INDEX if not exists purchase_orders_status_idx ON purchase_orders(status);
+
+CREATE TABLE if not exists purchase_order_lines(
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received INT NOT NULL DEFAULT 0 CHECK (received >= 0 AND received <= quantity),
+    unit_cost FLOAT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX if not exists purchase_order_lines_purchase_order_id_idx ON purchase_order_lines(purchase_order_id);
+
+-- +goose Down
+DROP TABLE if exists purchase_order_lines;
+DROP TABLE if exists purchase_orders;
+DROP TABLE if exists suppliers;
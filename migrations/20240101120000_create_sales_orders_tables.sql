@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE reservations ALTER COLUMN expires_at DROP NOT NULL;
+
+CREATE TABLE if not exists sales_orders(
+    id SERIAL PRIMARY KEY,
+    customer TEXT NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL,
+    shipped_at timestamptz,
+    delivered_at timestamptz
+);
+
+CREATE INDEX if not exists sales_orders_status_idx ON sales_orders(status);
+
+CREATE TABLE if not exists sales_order_lines(
+    id SERIAL PRIMARY KEY,
+    sales_order_id INT NOT NULL REFERENCES sales_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price FLOAT NOT NULL DEFAULT 0,
+    reservation_id INT REFERENCES reservations(id) ON DELETE SET NULL
+);
+
+CREATE INDEX if not exists sales_order_lines_sales_order_id_idx ON sales_order_lines(sales_order_id);
+
+-- +goose Down
+DROP TABLE if exists sales_order_lines;
+DROP TABLE if exists sales_orders;
+DELETE FROM reservations WHERE expires_at IS NULL;
+ALTER TABLE reservations ALTER COLUMN expires_at SET NOT NULL;
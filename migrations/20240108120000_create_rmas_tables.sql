@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE if not exists rmas(
+    id SERIAL PRIMARY KEY,
+    sales_order_id INT NOT NULL REFERENCES sales_orders(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL,
+    received_at timestamptz
+);
+
+CREATE INDEX if not exists rmas_sales_order_id_idx ON rmas(sales_order_id);
+
+CREATE TABLE if not exists rma_lines(
+    id SERIAL PRIMARY KEY,
+    rma_id INT NOT NULL REFERENCES rmas(id) ON DELETE CASCADE,
+    sales_order_line_id INT NOT NULL REFERENCES sales_order_lines(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    disposition TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    disposed_at timestamptz
+);
+
+CREATE INDEX if not exists rma_lines_rma_id_idx ON rma_lines(rma_id);
+
+-- +goose Down
+DROP TABLE if exists rma_lines;
+DROP TABLE if exists rmas;
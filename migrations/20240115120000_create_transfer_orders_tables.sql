@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE if not exists transfer_orders(
+    id SERIAL PRIMARY KEY,
+    from_warehouse_id INT NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    to_warehouse_id INT NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    reference TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL,
+    shipped_at timestamptz,
+    closed_at timestamptz,
+    CHECK (from_warehouse_id <> to_warehouse_id)
+);
+
+CREATE INDEX if not exists transfer_orders_status_idx ON transfer_orders(status);
+
+CREATE TABLE if not exists transfer_order_lines(
+    id SERIAL PRIMARY KEY,
+    transfer_order_id INT NOT NULL REFERENCES transfer_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    from_location_id INT NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received INT NOT NULL DEFAULT 0 CHECK (received >= 0),
+    missing INT NOT NULL DEFAULT 0 CHECK (missing >= 0),
+    CHECK (received + missing <= quantity)
+);
+
+CREATE INDEX if not exists transfer_order_lines_transfer_order_id_idx ON transfer_order_lines(transfer_order_id);
+
+-- +goose Down
+DROP TABLE if exists transfer_order_lines;
+DROP TABLE if exists transfer_orders;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE if not exists lots(
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    number TEXT NOT NULL,
+    manufactured_at timestamptz,
+    expires_at timestamptz,
+    quantity INT NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL,
+    UNIQUE (product_id, number)
+);
+
+CREATE INDEX if not exists lots_expires_at_idx ON lots(expires_at) WHERE quantity > 0;
+
+ALTER TABLE stock_movements ADD COLUMN if not exists lots JSONB;
+
+-- +goose Down
+ALTER TABLE stock_movements DROP COLUMN if exists lots;
+DROP TABLE if exists lots;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE if not exists product_event_log(
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    trimmed_seq BIGINT NOT NULL
+);
+
+INSERT INTO product_event_log(trimmed_seq)
+SELECT COALESCE(MIN(seq) - 1, 0) FROM product_events
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+DROP TABLE if exists product_event_log;
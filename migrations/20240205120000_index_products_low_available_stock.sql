@@ -0,0 +1,7 @@
+-- +goose Up
+DROP INDEX if exists products_low_stock_idx;
+CREATE INDEX if not exists products_low_stock_idx ON products(id) WHERE quantity - reserved <= reorder_point;
+
+-- +goose Down
+DROP INDEX if exists products_low_stock_idx;
+CREATE INDEX if not exists products_low_stock_idx ON products(id) WHERE quantity <= reorder_point;
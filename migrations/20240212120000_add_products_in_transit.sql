@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE products ADD COLUMN if not exists in_transit INT NOT NULL DEFAULT 0;
+
+-- stock shipped by transfer orders still open is in transit
+UPDATE products SET in_transit = t.in_transit
+FROM (
+    SELECT l.product_id, SUM(l.quantity - l.received - l.missing) AS in_transit
+    FROM transfer_order_lines l
+    JOIN transfer_orders o ON o.id = l.transfer_order_id
+    WHERE o.status IN ('in_transit', 'partially_received')
+    GROUP BY l.product_id
+) t
+WHERE products.id = t.product_id;
+
+DROP INDEX if exists products_low_stock_idx;
+CREATE INDEX if not exists products_low_stock_idx ON products(id) WHERE quantity - reserved + in_transit <= reorder_point;
+
+-- +goose Down
+DROP INDEX if exists products_low_stock_idx;
+CREATE INDEX if not exists products_low_stock_idx ON products(id) WHERE quantity - reserved <= reorder_point;
+
+ALTER TABLE products DROP COLUMN if exists in_transit;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE transfer_order_lines ADD COLUMN if not exists lots JSONB;
+ALTER TABLE sales_order_lines ADD COLUMN if not exists lots JSONB;
+ALTER TABLE rma_lines ADD COLUMN if not exists lots JSONB;
+
+-- +goose Down
+ALTER TABLE rma_lines DROP COLUMN if exists lots;
+ALTER TABLE sales_order_lines DROP COLUMN if exists lots;
+ALTER TABLE transfer_order_lines DROP COLUMN if exists lots;
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type MovementType string
+
+const (
+	MovementReceipt    MovementType = "receipt"
+	MovementIssue      MovementType = "issue"
+	MovementAdjustment MovementType = "adjustment"
+	MovementTransfer   MovementType = "transfer"
+	// dispatch, arrival and loss are booked by transfer orders only: a
+	// dispatch takes stock out of LocationId into transit, an arrival puts it
+	// back into LocationId and a loss writes off what never arrived.
+	MovementDispatch MovementType = "dispatch"
+	MovementArrival  MovementType = "arrival"
+	MovementLoss     MovementType = "loss"
+)
+
+const movementTypeFailure = "Type should be one of receipt, issue, adjustment, transfer"
+
+// StockMovement is a single entry of the stock ledger. Product.Quantity is the
+// running sum of the deltas of all movements recorded for the product, and
+// Product.InTransit the one of their transit deltas. When LocationId is set
+// the movement also books the stock of that bin; transfers move stock from
+// LocationId to ToLocationId. Lots books the stock of lots, see Lot; what they
+// do not add up to is stock held outside any lot.
+type StockMovement struct {
+	Id           int           `json:"id" bun:"id,pk,autoincrement"`
+	ProductId    int           `json:"productId"`
+	Type         MovementType  `json:"type"`
+	Quantity     int           `json:"quantity"`
+	LocationId   int           `json:"locationId,omitempty" bun:",nullzero"`
+	ToLocationId int           `json:"toLocationId,omitempty" bun:",nullzero"`
+	Lots         []LotQuantity `json:"lots,omitempty" bun:",type:jsonb,nullzero"`
+	Reason       string        `json:"reason"`
+	Reference    string        `json:"reference"`
+	Actor        string        `json:"actor"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// delta returns the signed change the movement applies to the on-hand stock.
+// Receipts and issues carry a positive quantity, adjustments carry the signed
+// change itself and transfers only move stock between bins.
+func (m StockMovement) delta() int {
+	switch m.Type {
+	case MovementIssue, MovementDispatch:
+		return -m.Quantity
+	case MovementTransfer, MovementLoss:
+		return 0
+	}
+	return m.Quantity
+}
+
+// transitDelta returns the signed change the movement applies to the stock in
+// transit between warehouses.
+func (m StockMovement) transitDelta() int {
+	switch m.Type {
+	case MovementDispatch:
+		return m.Quantity
+	case MovementArrival, MovementLoss:
+		return -m.Quantity
+	}
+	return 0
+}
+
+// lotDelta returns the signed change the movement applies to a lot it books
+// quantity against.
+func (m StockMovement) lotDelta(quantity int) int {
+	if m.delta() < 0 {
+		return -quantity
+	}
+	return quantity
+}
+
+func validateMovement(movement StockMovement) error {
+	failures := make([]string, 0)
+
+	switch movement.Type {
+	case MovementReceipt, MovementIssue, MovementDispatch, MovementArrival, MovementLoss:
+		if movement.Quantity <= 0 {
+			failures = append(failures, "Quantity should be greater than 0")
+		}
+	case MovementAdjustment:
+		if movement.Quantity == 0 {
+			failures = append(failures, "Quantity should not be 0")
+		}
+	case MovementTransfer:
+		if movement.Quantity <= 0 {
+			failures = append(failures, "Quantity should be greater than 0")
+		}
+		if movement.LocationId == 0 || movement.ToLocationId == 0 {
+			failures = append(failures, "Transfer needs both LocationId and ToLocationId")
+		} else if movement.LocationId == movement.ToLocationId {
+			failures = append(failures, "ToLocationId should differ from LocationId")
+		}
+	default:
+		failures = append(failures, movementTypeFailure)
+	}
+	if movement.Reason == "" {
+		failures = append(failures, "Reason should not be empty")
+	}
+	failures = append(failures, lotFailures(movement)...)
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// lotFailures checks the lots a movement names. Transfers only move stock
+// between bins and book no lots, other movements book up to their quantity.
+func lotFailures(movement StockMovement) []string {
+	failures := make([]string, 0)
+	if len(movement.Lots) == 0 {
+		return failures
+	}
+	if movement.Type == MovementTransfer {
+		return append(failures, "Lots should be empty for a transfer")
+	}
+
+	seen := make(map[int]bool, len(movement.Lots))
+	total := 0
+	for idx, lot := range movement.Lots {
+		if lot.LotId <= 0 {
+			failures = append(failures, fmt.Sprintf("lot %d: LotId should be greater than 0", idx+1))
+		} else if seen[lot.LotId] {
+			failures = append(failures, fmt.Sprintf("lot %d: LotId %d is given twice", idx+1, lot.LotId))
+		}
+		seen[lot.LotId] = true
+		if lot.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("lot %d: Quantity should be greater than 0", idx+1))
+		}
+		total += lot.Quantity
+	}
+	if quantity := movement.delta(); total > quantity && total > -quantity {
+		failures = append(failures, "Lots should not add up to more than Quantity")
+	}
+	return failures
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// outboxLease is how long a claimed entry is left to the worker that
+	// claimed it before another one may dispatch it again.
+	outboxLease        = 30 * time.Second
+	outboxPollInterval = 5 * time.Second
+	outboxClaimLimit   = 100
+)
+
+var errOutboxEntryNotFound = errors.New("outbox entry not found")
+
+// OutboxEntry is an event of a committed write waiting to be dispatched. Its
+// Event has no seq until it is numbered.
+type OutboxEntry struct {
+	Id           int64
+	Event        Event
+	ClaimedUntil time.Time
+}
+
+// Outbox keeps the events of committed writes until they are dispatched.
+// AddToOutbox is also part of Repo, so events can be added in the
+// transaction of the write they belong to.
+type Outbox interface {
+	AddToOutbox(Event) error
+	// ClaimOutbox returns up to limit entries not claimed at now, oldest
+	// first, and claims them until now plus lease.
+	ClaimOutbox(now time.Time, lease time.Duration, limit int) ([]OutboxEntry, error)
+	// NumberOutboxEntry appends the event of entry to the event log, unless
+	// an earlier attempt already did, and returns the entry with its seq.
+	NumberOutboxEntry(OutboxEntry) (OutboxEntry, error)
+	RemoveFromOutbox(id int64) error
+}
+
+// EventHandler is handed every event dispatched from the outbox. An event
+// whose handler fails stays in the outbox and is handed over again, so
+// handlers have to cope with seeing an event twice.
+type EventHandler interface {
+	HandleEvent(Event) error
+}
+
+func (r *InMemoryRepo) AddToOutbox(event Event) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	r.lastOutboxId++
+	r.outbox = append(r.outbox, OutboxEntry{Id: r.lastOutboxId, Event: event})
+	return nil
+}
+
+func (r *InMemoryRepo) ClaimOutbox(now time.Time, lease time.Duration, limit int) ([]OutboxEntry, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	claimed := make([]OutboxEntry, 0)
+	for idx, entry := range r.outbox {
+		if len(claimed) == limit {
+			break
+		}
+		if entry.ClaimedUntil.After(now) {
+			continue
+		}
+		r.outbox[idx].ClaimedUntil = now.Add(lease)
+		claimed = append(claimed, r.outbox[idx])
+	}
+	return claimed, nil
+}
+
+func (r *InMemoryRepo) NumberOutboxEntry(entry OutboxEntry) (OutboxEntry, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentEntry := range r.outbox {
+		if currentEntry.Id != entry.Id {
+			continue
+		}
+		if currentEntry.Event.Seq == 0 {
+			event, err := r.AppendEvent(currentEntry.Event)
+			if err != nil {
+				return OutboxEntry{}, err
+			}
+			r.outbox[idx].Event = event
+		}
+		return r.outbox[idx], nil
+	}
+	return OutboxEntry{}, errOutboxEntryNotFound
+}
+
+func (r *InMemoryRepo) RemoveFromOutbox(id int64) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, entry := range r.outbox {
+		if entry.Id == id {
+			r.outbox = append(r.outbox[:idx], r.outbox[idx+1:]...)
+			return nil
+		}
+	}
+	return errOutboxEntryNotFound
+}
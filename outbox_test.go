@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testHandler records the events it is handed, failing while err is set.
+type testHandler struct {
+	events []Event
+	err    error
+}
+
+func (h *testHandler) HandleEvent(event Event) error {
+	if h.err != nil {
+		return h.err
+	}
+	h.events = append(h.events, event)
+	return nil
+}
+
+func TestInMemoryRepo_Outbox(t *testing.T) {
+	repo := NewInMemoryRepo()
+	for _, id := range []int{1, 2} {
+		assert.NoError(t, repo.AddToOutbox(Event{Type: EventProductCreated, Product: &Product{Id: id}}), "add should succeed")
+	}
+
+	now := time.Date(2023, 12, 4, 12, 0, 0, 0, time.UTC)
+	entries, err := repo.ClaimOutbox(now, time.Minute, 1)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, entries, 1, "expect claims to respect the limit") {
+		assert.Equal(t, int64(1), entries[0].Id, "expect oldest entry first")
+		assert.Equal(t, int64(0), entries[0].Event.Seq, "expect entry not to be numbered yet")
+	}
+	entries, err = repo.ClaimOutbox(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, entries, 1, "expect claimed entries to be skipped")
+
+	numbered, err := repo.NumberOutboxEntry(OutboxEntry{Id: 1})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(1), numbered.Event.Seq)
+	numbered, err = repo.NumberOutboxEntry(OutboxEntry{Id: 1})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(1), numbered.Event.Seq, "expect an entry to be numbered once")
+	assert.Equal(t, int64(1), repo.lastEventSeq)
+
+	entries, err = repo.ClaimOutbox(now.Add(time.Minute), time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, entries, 2, "expect expired claims to be claimed again")
+
+	assert.NoError(t, repo.RemoveFromOutbox(1), "remove should succeed")
+	assert.ErrorIs(t, repo.RemoveFromOutbox(1), errOutboxEntryNotFound)
+}
+
+func TestProductServiceImpl_outbox(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Price: 10, Version: 1}})
+	svc := NewProductServiceImpl(repo)
+	assert.NoError(t, svc.SetEventLog(repo), "set event log should succeed")
+	handler := &testHandler{err: errors.New("receiver down")}
+	svc.SetOutbox(repo, handler)
+	relay := &testRelay{}
+	svc.SetRelay(relay)
+	subscriber := &testSubscriber{id: "A"}
+	assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+
+	created, err := svc.Create(Product{Brand: "B", Category: "B", Price: 20})
+	assert.NoError(t, err, "create should succeed")
+	assert.ErrorIs(t, svc.Delete(1, 7), errVersionConflict, "expect delete to fail")
+	svc.drain()
+	assert.Equal(t, 0, subscriber.count, "expect events to wait in the outbox")
+	if assert.Len(t, repo.outbox, 1, "expect only committed writes in the outbox") {
+		assert.Equal(t, EventProductCreated, repo.outbox[0].Event.Type)
+	}
+
+	now := time.Now()
+	assert.Error(t, svc.dispatchOutbox(now), "expect failing handler to stop the dispatch")
+	svc.drain()
+	assert.Equal(t, 0, subscriber.count, "expect nothing dispatched while a handler fails")
+	assert.Len(t, repo.outbox, 1, "expect entry to stay in the outbox")
+
+	handler.err = nil
+	assert.NoError(t, svc.dispatchOutbox(now), "expect no error")
+	assert.Empty(t, handler.events, "expect claimed entries to wait for the claim to run out")
+
+	assert.NoError(t, svc.dispatchOutbox(now.Add(outboxLease)), "expect no error")
+	svc.drain()
+	if assert.Len(t, handler.events, 1, "expect handler to get the event") {
+		assert.Equal(t, int64(1), handler.events[0].Seq, "expect seq from the first attempt")
+		assert.Equal(t, created.Id, handler.events[0].Product.Id)
+	}
+	assert.Equal(t, 1, subscriber.count, "expect subscribers to get the event")
+	assert.Len(t, relay.sent, 1, "expect event to be relayed")
+	assert.Empty(t, repo.outbox, "expect dispatched entries to leave the outbox")
+	assert.Equal(t, int64(1), svc.seq)
+
+	_, err = svc.Batch([]BatchOperation{
+		{Op: BatchCreate, Product: Product{Brand: "C", Category: "C", Price: 30}},
+		{Op: BatchDelete, Id: 1},
+	}, false)
+	assert.NoError(t, err, "batch should succeed")
+	assert.Len(t, repo.outbox, 2, "expect batch events in the outbox")
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	errUnsupportedPatch = errors.New("unsupported patch type")
+	errPatchTestFailed  = errors.New("patch test failed")
+)
+
+// ProductPatch is a patch document together with its media type, either an
+// RFC 7396 merge patch or an RFC 6902 JSON patch.
+type ProductPatch struct {
+	Type     string
+	Document []byte
+}
+
+// apply patches the JSON form of product and decodes the result back. The
+// document leaves out available, which is derived and cannot be patched.
+func (p ProductPatch) apply(product Product) (Product, error) {
+	type stored Product
+	doc, err := json.Marshal(stored(product))
+	if err != nil {
+		return Product{}, err
+	}
+
+	switch p.Type {
+	case mergePatchContentType:
+		doc, err = applyMergePatch(doc, p.Document)
+	case jsonPatchContentType:
+		doc, err = applyJSONPatch(doc, p.Document)
+	default:
+		return Product{}, errUnsupportedPatch
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	var patched Product
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return Product{}, &validationError{failures: []string{fmt.Sprintf("patched product is invalid: %v", err)}}
+	}
+	return patched, nil
+}
+
+// validateProductPatch rejects patches touching fields only the server sets.
+func validateProductPatch(current, patched Product) error {
+	failures := make([]string, 0)
+
+	if patched.Id != current.Id {
+		failures = append(failures, "Id should not be changed")
+	}
+	if patched.Sku != current.Sku {
+		failures = append(failures, "Sku should not be changed")
+	}
+	if patched.Version != current.Version {
+		failures = append(failures, "Version should not be changed")
+	}
+	if patched.Reserved != current.Reserved {
+		failures = append(failures, "Reserved should not be changed")
+	}
+	if patched.InTransit != current.InTransit {
+		failures = append(failures, "InTransit should not be changed")
+	}
+	if !patched.CreatedAt.Equal(current.CreatedAt) {
+		failures = append(failures, "CreatedAt should not be changed")
+	}
+	if !patched.UpdatedAt.Equal(current.UpdatedAt) {
+		failures = append(failures, "UpdatedAt should not be changed")
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// changedProductColumns lists the columns of the fields a client may write
+// that differ between current and patched. Quantity is left out, it only
+// changes through movements.
+func changedProductColumns(current, patched Product) []string {
+	columns := make([]string, 0)
+	if patched.Brand != current.Brand {
+		columns = append(columns, "brand")
+	}
+	if patched.Category != current.Category {
+		columns = append(columns, "category")
+	}
+	if patched.Price != current.Price {
+		columns = append(columns, "price")
+	}
+	if patched.ReorderPoint != current.ReorderPoint {
+		columns = append(columns, "reorder_point")
+	}
+	if patched.ReorderQuantity != current.ReorderQuantity {
+		columns = append(columns, "reorder_quantity")
+	}
+	return columns
+}
+
+// applyMergePatch applies an RFC 7396 merge patch to doc.
+func applyMergePatch(doc, patch []byte) ([]byte, error) {
+	var target, patchValue interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, &validationError{failures: []string{"invalid merge patch"}}
+	}
+	return json.Marshal(mergePatch(target, patchValue))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies the operations of an RFC 6902 JSON patch to doc in
+// order. A failing operation fails the whole patch.
+func applyJSONPatch(doc, patch []byte) ([]byte, error) {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, &validationError{failures: []string{"invalid json patch"}}
+	}
+
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	for index, operation := range operations {
+		var err error
+		target, err = applyJSONPatchOperation(target, operation)
+		if errors.Is(err, errPatchTestFailed) {
+			return nil, fmt.Errorf("operation %d: %w", index, err)
+		}
+		if err != nil {
+			return nil, &validationError{failures: []string{fmt.Sprintf("operation %d: %v", index, err)}}
+		}
+	}
+	return json.Marshal(target)
+}
+
+func applyJSONPatchOperation(doc interface{}, operation jsonPatchOperation) (interface{}, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		var value interface{}
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("%s needs a value", operation.Op)
+		}
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, err
+		}
+		switch operation.Op {
+		case "add":
+			return jsonPointerAdd(doc, path, value)
+		case "replace":
+			if doc, err = jsonPointerRemove(doc, path); err != nil {
+				return nil, err
+			}
+			return jsonPointerAdd(doc, path, value)
+		default:
+			current, err := jsonPointerGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, errPatchTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		return jsonPointerRemove(doc, path)
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := jsonPointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			if strings.HasPrefix(operation.Path, operation.From+"/") {
+				return nil, fmt.Errorf("cannot move %s into itself", operation.From)
+			}
+			if doc, err = jsonPointerRemove(doc, from); err != nil {
+				return nil, err
+			}
+		} else if value, err = deepCopyJSON(value); err != nil {
+			return nil, err
+		}
+		return jsonPointerAdd(doc, path, value)
+	default:
+		return nil, fmt.Errorf("unknown op %q", operation.Op)
+	}
+}
+
+// parseJSONPointer splits an RFC 6901 pointer into its unescaped tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q should start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for index, token := range tokens {
+		tokens[index] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func jsonPointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("path member %q does not exist", token)
+		}
+	}
+	return doc, nil
+}
+
+func jsonPointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateJSONParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			index, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to a scalar", token)
+		}
+	})
+}
+
+func jsonPointerRemove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	return updateJSONParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path member %q does not exist", token)
+		}
+	})
+}
+
+// updateJSONParent walks to the container holding the last token of path,
+// lets update change it and stores the changed container back into its own
+// parent, as appending to an array may return a new slice.
+func updateJSONParent(doc interface{}, path []string, update func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return update(doc, path[0])
+	}
+
+	child, err := jsonPointerGet(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = updateJSONParent(child, path[1:], update)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[path[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(path[0], len(node)-1)
+		node[index] = child
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token, allowing indexes up to max.
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+func deepCopyJSON(value interface{}) (interface{}, error) {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	err = json.Unmarshal(bs, &copied)
+	return copied, err
+}
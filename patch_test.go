@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		wantDoc string
+		wantErr bool
+	}{
+		{
+			name:    "replace member",
+			doc:     `{"a": "b"}`,
+			patch:   `{"a": "c"}`,
+			wantDoc: `{"a": "c"}`,
+		},
+		{
+			name:    "add member",
+			doc:     `{"a": "b"}`,
+			patch:   `{"b": "c"}`,
+			wantDoc: `{"a": "b", "b": "c"}`,
+		},
+		{
+			name:    "null removes member",
+			doc:     `{"a": "b", "b": "c"}`,
+			patch:   `{"a": null}`,
+			wantDoc: `{"b": "c"}`,
+		},
+		{
+			name:    "nested objects merge",
+			doc:     `{"a": {"b": "c", "d": "e"}}`,
+			patch:   `{"a": {"b": null, "f": "g"}}`,
+			wantDoc: `{"a": {"d": "e", "f": "g"}}`,
+		},
+		{
+			name:    "arrays are replaced",
+			doc:     `{"a": [1, 2]}`,
+			patch:   `{"a": [3]}`,
+			wantDoc: `{"a": [3]}`,
+		},
+		{
+			name:    "invalid patch",
+			doc:     `{"a": "b"}`,
+			patch:   `{"a":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := applyMergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr {
+				assert.Error(t, err, "expect patch to fail")
+				return
+			}
+			assert.NoError(t, err, "expect patch to apply")
+			assert.JSONEq(t, tt.wantDoc, string(doc))
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		wantDoc string
+		wantErr error
+	}{
+		{
+			name:    "add and replace",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "add", "path": "/b", "value": 2}, {"op": "replace", "path": "/a", "value": 3}]`,
+			wantDoc: `{"a": 3, "b": 2}`,
+		},
+		{
+			name:    "add to array",
+			doc:     `{"a": [1, 3]}`,
+			patch:   `[{"op": "add", "path": "/a/1", "value": 2}, {"op": "add", "path": "/a/-", "value": 4}]`,
+			wantDoc: `{"a": [1, 2, 3, 4]}`,
+		},
+		{
+			name:    "remove",
+			doc:     `{"a": {"b": 1, "c": 2}}`,
+			patch:   `[{"op": "remove", "path": "/a/b"}]`,
+			wantDoc: `{"a": {"c": 2}}`,
+		},
+		{
+			name:    "move and copy",
+			doc:     `{"a": 1, "b": {}}`,
+			patch:   `[{"op": "move", "from": "/a", "path": "/b/a"}, {"op": "copy", "from": "/b", "path": "/c"}]`,
+			wantDoc: `{"b": {"a": 1}, "c": {"a": 1}}`,
+		},
+		{
+			name:    "escaped pointer",
+			doc:     `{"a/b": 1, "m~n": 2}`,
+			patch:   `[{"op": "replace", "path": "/a~1b", "value": 3}, {"op": "remove", "path": "/m~0n"}]`,
+			wantDoc: `{"a/b": 3}`,
+		},
+		{
+			name:    "test passes",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "test", "path": "/a", "value": 1}, {"op": "replace", "path": "/a", "value": 2}]`,
+			wantDoc: `{"a": 2}`,
+		},
+		{
+			name:    "test fails",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "test", "path": "/a", "value": 2}, {"op": "replace", "path": "/a", "value": 3}]`,
+			wantErr: errPatchTestFailed,
+		},
+		{
+			name:    "replace missing member",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "replace", "path": "/b", "value": 2}]`,
+			wantErr: &validationError{failures: []string{`operation 0: path member "b" does not exist`}},
+		},
+		{
+			name:    "unknown op",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "increment", "path": "/a"}]`,
+			wantErr: &validationError{failures: []string{`operation 0: unknown op "increment"`}},
+		},
+		{
+			name:    "not an array",
+			doc:     `{"a": 1}`,
+			patch:   `{"op": "remove", "path": "/a"}`,
+			wantErr: &validationError{failures: []string{"invalid json patch"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := applyJSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if failures, ok := tt.wantErr.(*validationError); ok {
+					assert.Equal(t, failures, err, "expect same validation error")
+				} else {
+					assert.ErrorIs(t, err, tt.wantErr, "error should match")
+				}
+				return
+			}
+			assert.NoError(t, err, "expect patch to apply")
+			assert.JSONEq(t, tt.wantDoc, string(doc))
+		})
+	}
+}
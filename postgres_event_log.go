@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// productEvent is how an Event is stored in the product_events table.
+type productEvent struct {
+	bun.BaseModel `bun:"table:product_events"`
+
+	Seq       int64    `bun:"seq,pk,autoincrement"`
+	Type      string   `bun:"type"`
+	Product   *Product `bun:"product,type:jsonb"`
+	CreatedAt time.Time
+}
+
+// productEventLog is the single row of the product_event_log table.
+// TrimmedSeq is the highest seq dropped from product_events, the low-water
+// mark EventsSince checks; seqs are drawn from a sequence and can have gaps,
+// so the first stored seq says nothing about what was dropped.
+type productEventLog struct {
+	bun.BaseModel `bun:"table:product_event_log"`
+
+	Id         bool  `bun:"id,pk"`
+	TrimmedSeq int64 `bun:"trimmed_seq"`
+}
+
+// AppendEvent draws the seq from product_events_seq while holding the lock on
+// the product_event_log row, which is kept until the transaction commits. So
+// events commit in seq order, even across instances, and a subscriber that
+// resumes after seq n cannot miss an event committed later with a lower seq.
+func (p *PostgresRepo) AppendEvent(event Event) (Event, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().
+			Model(&productEventLog{Id: true}).
+			On("CONFLICT (id) DO UPDATE").
+			Set("trimmed_seq = product_event_log.trimmed_seq").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		stored := productEvent{Type: event.Type, Product: event.Product, CreatedAt: event.CreatedAt}
+		if _, err := tx.NewInsert().Model(&stored).Returning("seq").Exec(ctx); err != nil {
+			return err
+		}
+		event.Seq = stored.Seq
+
+		trimmed := stored.Seq - eventLogSize
+		if trimmed <= 0 {
+			return nil
+		}
+		if _, err := tx.NewDelete().Model((*productEvent)(nil)).Where("seq <= ?", trimmed).Exec(ctx); err != nil {
+			return err
+		}
+		_, err = tx.NewUpdate().
+			Model((*productEventLog)(nil)).
+			Set("trimmed_seq = greatest(trimmed_seq, ?)", trimmed).
+			Where("id").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// EventsSince reads the low-water mark after the events, so events dropped
+// while they were read are reported as truncated rather than skipped.
+func (p *PostgresRepo) EventsSince(seq int64) ([]Event, error) {
+	stored := []productEvent{}
+	if err := p.db.NewSelect().Model(&stored).Where("seq > ?", seq).Order("seq").Scan(context.Background()); err != nil {
+		return nil, err
+	}
+
+	var trimmed int64
+	err := p.db.NewSelect().Model((*productEventLog)(nil)).ColumnExpr("coalesce(max(trimmed_seq), 0)").Scan(context.Background(), &trimmed)
+	if err != nil {
+		return nil, err
+	}
+	if seq < trimmed {
+		return nil, errEventLogTruncated
+	}
+	if len(stored) == 0 {
+		last, err := p.LastEventSeq()
+		if err != nil {
+			return nil, err
+		}
+		if seq > last {
+			return nil, errEventLogTruncated
+		}
+	}
+
+	events := make([]Event, 0, len(stored))
+	for _, current := range stored {
+		events = append(events, Event{Seq: current.Seq, Type: current.Type, Product: current.Product, CreatedAt: current.CreatedAt})
+	}
+	return events, nil
+}
+
+func (p *PostgresRepo) LastEventSeq() (int64, error) {
+	var seq int64
+	err := p.db.NewSelect().Model((*productEvent)(nil)).ColumnExpr("coalesce(max(seq), 0)").Scan(context.Background(), &seq)
+	return seq, err
+}
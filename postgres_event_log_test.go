@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_EventLog(t *testing.T) {
+	db := setupPostgres(t, "emptyData.yaml")
+	for _, model := range []interface{}{(*productEvent)(nil), (*productEventLog)(nil)} {
+		if _, err := db.NewTruncateTable().Model(model).Exec(context.Background()); err != nil {
+			t.Fatal("error while truncating product events:", err)
+		}
+	}
+	repo := NewPostgresRepo(db)
+
+	appendTestEvents(t, repo, 1, 3)
+
+	events, err := repo.EventsSince(1)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, events, 2, "expect events after since") {
+		assert.Equal(t, int64(2), events[0].Seq)
+		assert.Equal(t, EventProductCreated, events[0].Type)
+		assert.Equal(t, 2, events[0].Product.Id, "expect product to be stored")
+	}
+
+	last, err := repo.LastEventSeq()
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(3), last)
+
+	_, err = repo.EventsSince(4)
+	assert.ErrorIs(t, err, errEventLogTruncated, "expect since ahead of the log to need a snapshot")
+
+	// a seq drawn by a transaction that rolled back leaves a gap
+	if _, err := db.Exec("SELECT nextval('product_events_seq')"); err != nil {
+		t.Fatal("error while skipping a seq:", err)
+	}
+	gapped, err := repo.AppendEvent(Event{Type: EventProductCreated, Product: &Product{Id: 5, Brand: "A", Category: "A"}})
+	assert.NoError(t, err, "expect no error")
+	events, err = repo.EventsSince(3)
+	assert.NoError(t, err, "expect a gap in the seqs not to need a snapshot")
+	if assert.Len(t, events, 1, "expect events after since") {
+		assert.Equal(t, gapped.Seq, events[0].Seq)
+	}
+
+	appendTestEvents(t, repo, gapped.Seq+1, gapped.Seq+eventLogSize)
+	_, err = repo.EventsSince(1)
+	assert.ErrorIs(t, err, errEventLogTruncated, "expect dropped events to need a snapshot")
+	_, err = repo.EventsSince(gapped.Seq)
+	assert.NoError(t, err, "expect events after the low-water mark to be kept")
+}
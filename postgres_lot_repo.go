@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func (p *PostgresRepo) CreateLot(lot Lot) (Lot, error) {
+	_, err := p.db.NewInsert().Model(&lot).Returning("id").Exec(context.Background())
+	if err != nil {
+		switch sqlErrorCode(err) {
+		case pgUniqueViolation:
+			return Lot{}, errDuplicateLot
+		case pgForeignKeyViolation:
+			return Lot{}, errProductNotFound
+		}
+		return Lot{}, err
+	}
+	return lot, nil
+}
+
+func (p *PostgresRepo) GetLotById(id int) (Lot, error) {
+	var lot Lot
+	if err := p.db.NewSelect().Model(&lot).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Lot{}, errLotNotFound
+		}
+		return Lot{}, err
+	}
+	return lot, nil
+}
+
+func (p *PostgresRepo) GetLots(productId int) ([]Lot, error) {
+	return p.getLots(productId, false)
+}
+
+// LockLots locks the lot rows, so it only blocks others when p is bound to a
+// transaction.
+func (p *PostgresRepo) LockLots(productId int) ([]Lot, error) {
+	return p.getLots(productId, true)
+}
+
+func (p *PostgresRepo) getLots(productId int, lock bool) ([]Lot, error) {
+	if _, err := p.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	lots := []Lot{}
+	query := p.db.NewSelect().
+		Model(&lots).
+		Where("product_id = ?", productId).
+		OrderExpr("expires_at ASC NULLS LAST, id")
+	if lock {
+		query = query.For("UPDATE")
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+func (p *PostgresRepo) GetExpiringLots(before time.Time) ([]Lot, error) {
+	lots := []Lot{}
+	err := p.db.NewSelect().
+		Model(&lots).
+		Where("quantity > 0").
+		Where("expires_at < ?", before).
+		OrderExpr("expires_at, id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+// adjustLots applies the lots of movement to their quantities, none of which
+// may drop below zero.
+func adjustLots(ctx context.Context, tx bun.Tx, movement StockMovement) error {
+	for _, lotQuantity := range movement.Lots {
+		var quantity int
+		err := tx.NewUpdate().
+			Model((*Lot)(nil)).
+			Set("quantity = quantity + ?", movement.lotDelta(lotQuantity.Quantity)).
+			Where("id = ?", lotQuantity.LotId).
+			Where("product_id = ?", movement.ProductId).
+			Returning("quantity").
+			Scan(ctx, &quantity)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errLotNotFound
+			}
+			return err
+		}
+		if quantity < 0 {
+			return errInsufficientStock
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_Lots(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	if _, err := db.NewTruncateTable().Model((*Lot)(nil)).Cascade().Exec(context.Background()); err != nil {
+		t.Fatal("error while truncating lots:", err)
+	}
+	repo := NewPostgresRepo(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	_, err := repo.CreateLot(Lot{ProductId: 99, Number: "L1", CreatedAt: now})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	lot, err := repo.CreateLot(Lot{ProductId: 10, Number: "L1", ExpiresAt: now.AddDate(0, 0, 5), CreatedAt: now})
+	assert.NoError(t, err, "create lot should succeed")
+	_, err = repo.CreateLot(Lot{ProductId: 10, Number: "L1", CreatedAt: now})
+	assert.ErrorIs(t, err, errDuplicateLot)
+	open, err := repo.CreateLot(Lot{ProductId: 10, Number: "L2", CreatedAt: now})
+	assert.NoError(t, err, "expect a lot without expiry")
+
+	received, err := repo.AddMovement(StockMovement{ProductId: 10, Type: MovementReceipt, Quantity: 3, Reason: "delivery", Lots: []LotQuantity{{LotId: lot.Id, Quantity: 3}}, CreatedAt: now})
+	assert.NoError(t, err, "add movement should succeed")
+	_, err = repo.AddMovement(StockMovement{ProductId: 10, Type: MovementIssue, Quantity: 4, Reason: "sold", Lots: []LotQuantity{{LotId: lot.Id, Quantity: 4}}, CreatedAt: now})
+	assert.ErrorIs(t, err, errInsufficientStock)
+	_, err = repo.AddMovement(StockMovement{ProductId: 10, Type: MovementIssue, Quantity: 1, Reason: "sold", Lots: []LotQuantity{{LotId: lot.Id + 100, Quantity: 1}}, CreatedAt: now})
+	assert.ErrorIs(t, err, errLotNotFound)
+
+	lots, err := repo.LockLots(10)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, lots, 2) {
+		assert.Equal(t, []int{lot.Id, open.Id}, []int{lots[0].Id, lots[1].Id}, "expect lots without expiry last")
+		assert.Equal(t, 3, lots[0].Quantity)
+	}
+
+	movements, err := repo.GetMovements(10)
+	assert.NoError(t, err, "expect no error")
+	for _, movement := range movements {
+		if movement.Id == received.Id {
+			assert.Equal(t, received.Lots, movement.Lots, "expect the movement to keep its lots")
+		}
+	}
+
+	expiring, err := repo.GetExpiringLots(now.AddDate(0, 0, 7))
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, expiring, 1) {
+		assert.Equal(t, lot.Id, expiring[0].Id)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// productOutbox is how an OutboxEntry is stored in the product_outbox table.
+// Seq stays null until the entry is numbered.
+type productOutbox struct {
+	bun.BaseModel `bun:"table:product_outbox"`
+
+	Id           int64    `bun:"id,pk,autoincrement"`
+	Seq          int64    `bun:"seq,nullzero"`
+	Type         string   `bun:"type"`
+	Product      *Product `bun:"product,type:jsonb"`
+	CreatedAt    time.Time
+	ClaimedUntil time.Time `bun:"claimed_until,nullzero"`
+}
+
+func (o productOutbox) entry() OutboxEntry {
+	return OutboxEntry{
+		Id:           o.Id,
+		Event:        Event{Seq: o.Seq, Type: o.Type, Product: o.Product, CreatedAt: o.CreatedAt},
+		ClaimedUntil: o.ClaimedUntil,
+	}
+}
+
+func (p *PostgresRepo) AddToOutbox(event Event) error {
+	stored := productOutbox{Type: event.Type, Product: event.Product, CreatedAt: event.CreatedAt}
+	_, err := p.db.NewInsert().Model(&stored).Exec(context.Background())
+	return err
+}
+
+// ClaimOutbox skips rows another worker has locked, like ClaimDeliveries.
+func (p *PostgresRepo) ClaimOutbox(now time.Time, lease time.Duration, limit int) ([]OutboxEntry, error) {
+	due := p.db.NewSelect().
+		Model((*productOutbox)(nil)).
+		Column("id").
+		Where("claimed_until IS NULL OR claimed_until <= ?", now).
+		Order("id").
+		Limit(limit).
+		For("UPDATE SKIP LOCKED")
+
+	stored := []productOutbox{}
+	err := p.db.NewUpdate().
+		Model((*productOutbox)(nil)).
+		Set("claimed_until = ?", now.Add(lease)).
+		Where("id IN (?)", due).
+		Returning("*").
+		Scan(context.Background(), &stored)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Id < stored[j].Id
+	})
+
+	entries := make([]OutboxEntry, 0, len(stored))
+	for _, current := range stored {
+		entries = append(entries, current.entry())
+	}
+	return entries, nil
+}
+
+// NumberOutboxEntry appends to the event log in the transaction recording the
+// seq, so a crash in between cannot number an entry twice.
+func (p *PostgresRepo) NumberOutboxEntry(entry OutboxEntry) (OutboxEntry, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		var stored productOutbox
+		if err := tx.NewSelect().Model(&stored).Where("id = ?", entry.Id).For("UPDATE").Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errOutboxEntryNotFound
+			}
+			return err
+		}
+		entry = stored.entry()
+		if stored.Seq != 0 {
+			return nil
+		}
+
+		event, err := (&PostgresRepo{db: tx}).AppendEvent(entry.Event)
+		if err != nil {
+			return err
+		}
+		entry.Event = event
+		_, err = tx.NewUpdate().Model((*productOutbox)(nil)).Set("seq = ?", event.Seq).Where("id = ?", entry.Id).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return OutboxEntry{}, err
+	}
+	return entry, nil
+}
+
+func (p *PostgresRepo) RemoveFromOutbox(id int64) error {
+	result, err := p.db.NewDelete().Model((*productOutbox)(nil)).Where("id = ?", id).Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOr(result, errOutboxEntryNotFound)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_Outbox(t *testing.T) {
+	db := setupPostgres(t, "emptyData.yaml")
+	for _, model := range []interface{}{(*productOutbox)(nil), (*productEvent)(nil)} {
+		if _, err := db.NewTruncateTable().Model(model).Exec(context.Background()); err != nil {
+			t.Fatal("error while truncating outbox:", err)
+		}
+	}
+	repo := NewPostgresRepo(db)
+
+	rollback := errors.New("rollback")
+	err := repo.InTx(func(tx Repo) error {
+		if err := tx.AddToOutbox(Event{Type: EventProductDeleted, Product: &Product{Id: 9}, CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+	err = repo.InTx(func(tx Repo) error {
+		return tx.AddToOutbox(Event{Type: EventProductCreated, Product: &Product{Id: 1}, CreatedAt: time.Now()})
+	})
+	assert.NoError(t, err, "expect no error")
+
+	now := time.Now()
+	entries, err := repo.ClaimOutbox(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	if !assert.Len(t, entries, 1, "expect only committed events in the outbox") {
+		return
+	}
+	assert.Equal(t, EventProductCreated, entries[0].Event.Type)
+	claimed, err := repo.ClaimOutbox(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, claimed, "expect claimed entries to be skipped")
+
+	numbered, err := repo.NumberOutboxEntry(entries[0])
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(1), numbered.Event.Seq)
+	numbered, err = repo.NumberOutboxEntry(entries[0])
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(1), numbered.Event.Seq, "expect an entry to be numbered once")
+	last, err := repo.LastEventSeq()
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(1), last)
+
+	assert.NoError(t, repo.RemoveFromOutbox(entries[0].Id), "remove should succeed")
+	assert.ErrorIs(t, repo.RemoveFromOutbox(entries[0].Id), errOutboxEntryNotFound)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+func (p *PostgresRepo) CreateSupplier(supplier Supplier) (Supplier, error) {
+	if _, err := p.db.NewInsert().Model(&supplier).Returning("id").Exec(context.Background()); err != nil {
+		return Supplier{}, err
+	}
+	return supplier, nil
+}
+
+func (p *PostgresRepo) GetSupplierById(id int) (Supplier, error) {
+	var supplier Supplier
+	if err := p.db.NewSelect().Model(&supplier).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Supplier{}, errSupplierNotFound
+		}
+		return Supplier{}, err
+	}
+	return supplier, nil
+}
+
+func (p *PostgresRepo) GetSuppliers() ([]Supplier, error) {
+	suppliers := []Supplier{}
+	if err := p.db.NewSelect().Model(&suppliers).Order("id").Scan(context.Background()); err != nil {
+		return []Supplier{}, err
+	}
+	return suppliers, nil
+}
+
+func (p *PostgresRepo) UpdateSupplier(supplier Supplier) error {
+	result, err := p.db.NewUpdate().
+		Model(&supplier).
+		ExcludeColumn("created_at").
+		WherePK().
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOr(result, errSupplierNotFound)
+}
+
+func (p *PostgresRepo) CreatePurchaseOrder(order PurchaseOrder) (PurchaseOrder, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&order).Returning("id").Exec(ctx); err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errSupplierNotFound
+			}
+			return err
+		}
+		return insertPurchaseOrderLines(ctx, tx, order.Id, order.Lines)
+	})
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PostgresRepo) GetPurchaseOrderById(id int) (PurchaseOrder, error) {
+	return p.getPurchaseOrder(id, false)
+}
+
+// LockPurchaseOrder locks the order row, so it only blocks others when p is
+// bound to a transaction.
+func (p *PostgresRepo) LockPurchaseOrder(id int) (PurchaseOrder, error) {
+	return p.getPurchaseOrder(id, true)
+}
+
+func (p *PostgresRepo) getPurchaseOrder(id int, lock bool) (PurchaseOrder, error) {
+	var order PurchaseOrder
+	query := p.db.NewSelect().Model(&order).Where("id = ?", id)
+	if lock {
+		query = query.For("UPDATE")
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PurchaseOrder{}, errPurchaseOrderNotFound
+		}
+		return PurchaseOrder{}, err
+	}
+
+	order.Lines = []PurchaseOrderLine{}
+	err := p.db.NewSelect().
+		Model(&order.Lines).
+		Where("purchase_order_id = ?", id).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PostgresRepo) GetPurchaseOrders(filter PurchaseOrderFilter) ([]PurchaseOrder, error) {
+	orders := []PurchaseOrder{}
+	query := p.db.NewSelect().
+		Model(&orders).
+		Relation("Lines", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("id")
+		}).
+		Order("purchase_order.id")
+	if filter.SupplierId != 0 {
+		query = query.Where("purchase_order.supplier_id = ?", filter.SupplierId)
+	}
+	if filter.Status != "" {
+		query = query.Where("purchase_order.status = ?", filter.Status)
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		return []PurchaseOrder{}, err
+	}
+	for idx := range orders {
+		if orders[idx].Lines == nil {
+			orders[idx].Lines = []PurchaseOrderLine{}
+		}
+	}
+	return orders, nil
+}
+
+func (p *PostgresRepo) UpdatePurchaseOrder(order PurchaseOrder) error {
+	return p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model(&order).
+			ExcludeColumn("created_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errSupplierNotFound
+			}
+			return err
+		}
+		if err := rowsAffectedOr(result, errPurchaseOrderNotFound); err != nil {
+			return err
+		}
+
+		kept := make([]int, 0, len(order.Lines))
+		for _, line := range order.Lines {
+			if line.Id != 0 {
+				kept = append(kept, line.Id)
+			}
+		}
+		query := tx.NewDelete().Model((*PurchaseOrderLine)(nil)).Where("purchase_order_id = ?", order.Id)
+		if len(kept) > 0 {
+			query = query.Where("id NOT IN (?)", bun.In(kept))
+		}
+		if _, err := query.Exec(ctx); err != nil {
+			return err
+		}
+		return insertPurchaseOrderLines(ctx, tx, order.Id, order.Lines)
+	})
+}
+
+// insertPurchaseOrderLines stores the lines of the order with id, adding the
+// new ones and overwriting the others.
+func insertPurchaseOrderLines(ctx context.Context, tx bun.Tx, id int, lines []PurchaseOrderLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	for idx := range lines {
+		lines[idx].PurchaseOrderId = id
+	}
+	_, err := tx.NewInsert().
+		Model(&lines).
+		On("CONFLICT (id) DO UPDATE").
+		Set("product_id = EXCLUDED.product_id").
+		Set("quantity = EXCLUDED.quantity").
+		Set("received = EXCLUDED.received").
+		Set("unit_cost = EXCLUDED.unit_cost").
+		Returning("id").
+		Exec(ctx)
+	if err != nil {
+		if sqlErrorCode(err) == pgForeignKeyViolation {
+			return errProductNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_PurchaseOrders(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	if _, err := db.NewTruncateTable().Model((*Supplier)(nil)).Cascade().Exec(context.Background()); err != nil {
+		t.Fatal("error while truncating suppliers:", err)
+	}
+	repo := NewPostgresRepo(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	supplier, err := repo.CreateSupplier(Supplier{Name: "Acme", CreatedAt: now, UpdatedAt: now})
+	assert.NoError(t, err, "create supplier should succeed")
+	supplier.Phone = "555-0100"
+	assert.NoError(t, repo.UpdateSupplier(supplier), "update supplier should succeed")
+	got, err := repo.GetSupplierById(supplier.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, "555-0100", got.Phone)
+
+	_, err = repo.CreatePurchaseOrder(PurchaseOrder{SupplierId: supplier.Id + 1, Status: PurchaseOrderDraft, CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errSupplierNotFound)
+	_, err = repo.CreatePurchaseOrder(PurchaseOrder{SupplierId: supplier.Id, Status: PurchaseOrderDraft, Lines: []PurchaseOrderLine{{ProductId: 99, Quantity: 1}}, CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	order, err := repo.CreatePurchaseOrder(PurchaseOrder{
+		SupplierId: supplier.Id,
+		Status:     PurchaseOrderDraft,
+		Lines:      []PurchaseOrderLine{{ProductId: 10, Quantity: 10}, {ProductId: 10, Quantity: 5}},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	assert.NoError(t, err, "create purchase order should succeed")
+	if !assert.Len(t, order.Lines, 2) {
+		return
+	}
+
+	order.Status = PurchaseOrderPartiallyReceived
+	order.Lines = []PurchaseOrderLine{{Id: order.Lines[1].Id, ProductId: 10, Quantity: 5, Received: 2}, {ProductId: 10, Quantity: 1}}
+	assert.NoError(t, repo.UpdatePurchaseOrder(order), "update purchase order should succeed")
+
+	stored, err := repo.LockPurchaseOrder(order.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, PurchaseOrderPartiallyReceived, stored.Status)
+	if assert.Len(t, stored.Lines, 2, "expect the left out line to be removed") {
+		assert.Equal(t, 2, stored.Lines[0].Received)
+		assert.Equal(t, 1, stored.Lines[1].Quantity)
+	}
+
+	orders, err := repo.GetPurchaseOrders(PurchaseOrderFilter{SupplierId: supplier.Id, Status: PurchaseOrderPartiallyReceived})
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, orders, 1) {
+		assert.Len(t, orders[0].Lines, 2)
+	}
+	_, err = repo.GetPurchaseOrderById(order.Id + 1)
+	assert.ErrorIs(t, err, errPurchaseOrderNotFound)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+const productEventsChannel = "product_events"
+
+var errListenerClosed = errors.New("listener closed")
+
+// PostgresRelay passes events between instances with NOTIFY on the
+// product_events channel. Every instance gets its own origin, so it can
+// skip the notifications it sent itself.
+type PostgresRelay struct {
+	db     *bun.DB
+	origin string
+}
+
+// relayMessage is the payload of a notification.
+type relayMessage struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+func NewPostgresRelay(db *bun.DB) (*PostgresRelay, error) {
+	origin := make([]byte, 8)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, err
+	}
+	return &PostgresRelay{db: db, origin: hex.EncodeToString(origin)}, nil
+}
+
+func (p *PostgresRelay) Send(event Event) error {
+	payload, err := json.Marshal(relayMessage{Origin: p.origin, Event: event})
+	if err != nil {
+		return err
+	}
+	return pgdriver.Notify(context.Background(), p.db, productEventsChannel, string(payload))
+}
+
+// Receive listens on its own connection, which pgdriver reconnects when it
+// breaks; events sent in between are not received.
+func (p *PostgresRelay) Receive(ctx context.Context, fn func(Event)) error {
+	listener := pgdriver.NewListener(p.db)
+	defer func() {
+		if err := listener.Close(); err != nil {
+			log.Println("error while closing listener:", err)
+		}
+	}()
+
+	if err := listener.Listen(ctx, productEventsChannel); err != nil {
+		return err
+	}
+
+	notifications := listener.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case notification, ok := <-notifications:
+			if !ok {
+				return errListenerClosed
+			}
+			var message relayMessage
+			if err := json.Unmarshal([]byte(notification.Payload), &message); err != nil {
+				log.Println("error while decoding relayed event:", err)
+				continue
+			}
+			if message.Origin == p.origin {
+				continue
+			}
+			fn(message.Event)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRelay(t *testing.T) {
+	db := setupPostgres(t, "emptyData.yaml")
+
+	sender, err := NewPostgresRelay(db)
+	if err != nil {
+		t.Fatal("unable to create relay:", err)
+	}
+	receiver, err := NewPostgresRelay(db)
+	if err != nil {
+		t.Fatal("unable to create relay:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	received := make(chan Event, 1)
+	echoed := make(chan Event, 1)
+	go func() {
+		_ = receiver.Receive(ctx, func(event Event) { received <- event })
+	}()
+	go func() {
+		_ = sender.Receive(ctx, func(event Event) { echoed <- event })
+	}()
+	// give both listeners time to LISTEN before notifying
+	time.Sleep(100 * time.Millisecond)
+
+	product := Product{Id: 1, Brand: "A", Category: "A"}
+	assert.NoError(t, sender.Send(Event{Seq: 3, Type: EventProductUpdated, Product: &product}), "send should succeed")
+
+	select {
+	case event := <-received:
+		assert.Equal(t, int64(3), event.Seq, "expect same event")
+		assert.Equal(t, EventProductUpdated, event.Type)
+		assert.Equal(t, &product, event.Product)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expect the other instance to receive the event")
+	}
+
+	select {
+	case <-echoed:
+		t.Fatal("expect the sender to skip its own event")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -4,41 +4,108 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/driver/pgdriver"
 )
 
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// sqlErrorCode returns the SQLSTATE of a postgres error, or "" for any other
+// error.
+func sqlErrorCode(err error) string {
+	var pgdriverErr pgdriver.Error
+	if errors.As(err, &pgdriverErr) {
+		return pgdriverErr.Field('C')
+	}
+	return ""
+}
+
+// sqlConstraint returns the name of the constraint a postgres error is about.
+func sqlConstraint(err error) string {
+	var pgdriverErr pgdriver.Error
+	if errors.As(err, &pgdriverErr) {
+		return pgdriverErr.Field('n')
+	}
+	return ""
+}
+
 type PostgresRepo struct {
-	db *bun.DB
+	db bun.IDB
 }
 
 func NewPostgresRepo(db *bun.DB) *PostgresRepo {
 	return &PostgresRepo{db: db}
 }
 
+// InTx runs fn in a transaction, or in a savepoint when p already is bound to
+// one.
+func (p *PostgresRepo) InTx(fn func(Repo) error) error {
+	return p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(&PostgresRepo{db: tx})
+	})
+}
+
 func (p *PostgresRepo) Create(product Product) error {
 	_, err := p.db.NewInsert().Model(&product).Exec(context.Background())
 
 	if err != nil {
-		var pgdriverErr pgdriver.Error
-		if errors.As(err, &pgdriverErr) {
-			sqlErrCode := pgdriverErr.Field('C')
-			if sqlErrCode == "23505" {
-				return errDuplicateId
+		if sqlErrorCode(err) == pgUniqueViolation {
+			if sqlConstraint(err) == "products_sku_key" {
+				return errDuplicateSku
 			}
+			return errDuplicateId
 		}
 		return err
 	}
 	return nil
 }
 
+// NextId draws ids from products_id_seq, skipping ids clients picked
+// themselves.
+func (p *PostgresRepo) NextId() (int, error) {
+	ctx := context.Background()
+	for {
+		var id int
+		if err := p.db.NewRaw("SELECT nextval('products_id_seq')").Scan(ctx, &id); err != nil {
+			return 0, err
+		}
+
+		exists, err := p.db.NewSelect().Model((*Product)(nil)).Where("id = ?", id).Exists(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return id, nil
+		}
+	}
+}
+
 func (p *PostgresRepo) Update(product Product) error {
-	result, err := p.db.NewUpdate().
-		Model(&product).
-		Column("id", "brand", "category", "quantity", "price", "updated_at").
+	return p.UpdateColumns(product, []string{"brand", "category", "price", "reorder_point", "reorder_quantity"})
+}
+
+func (p *PostgresRepo) UpdateColumns(product Product, columns []string) error {
+	query := p.db.NewUpdate().Model(&product)
+	for _, column := range columns {
+		switch column {
+		case "brand", "category", "price", "reorder_point", "reorder_quantity":
+			query = query.Set(column + " = ?" + column)
+		default:
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+	}
+	result, err := query.
+		Set("updated_at = ?updated_at").
+		Set("version = version + 1").
 		Where("id = ?", product.Id).
+		Where("(? = 0 OR version = ?)", product.Version, product.Version).
 		Exec(context.Background())
 
 	if err != nil {
@@ -52,11 +119,24 @@ func (p *PostgresRepo) Update(product Product) error {
 		return rowsErr
 	}
 	if rowsAffect == 0 {
-		return errProductNotFound
+		return p.notFoundOrConflict(product.Id)
 	}
 	return nil
 }
 
+// notFoundOrConflict tells apart the two reasons a versioned write can match
+// no row.
+func (p *PostgresRepo) notFoundOrConflict(id int) error {
+	exists, err := p.db.NewSelect().Model((*Product)(nil)).Where("id = ?", id).Exists(context.Background())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errVersionConflict
+	}
+	return errProductNotFound
+}
+
 func (p *PostgresRepo) GetById(id int) (Product, error) {
 	var product Product
 	if err := p.db.NewSelect().Model(&product).Where("id = ?", id).Scan(context.Background()); err != nil {
@@ -69,6 +149,18 @@ func (p *PostgresRepo) GetById(id int) (Product, error) {
 	return product, nil
 }
 
+func (p *PostgresRepo) GetBySku(sku string) (Product, error) {
+	var product Product
+	if err := p.db.NewSelect().Model(&product).Where("sku = ?", sku).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Product{}, errProductNotFound
+		}
+		return Product{}, err
+	}
+
+	return product, nil
+}
+
 func (p *PostgresRepo) GetAll() ([]Product, error) {
 	products := []Product{}
 
@@ -80,9 +172,155 @@ func (p *PostgresRepo) GetAll() ([]Product, error) {
 	return products, nil
 }
 
-func (p *PostgresRepo) Delete(id int) error {
+func (p *PostgresRepo) GetLowStock() ([]Product, error) {
+	products := []Product{}
+	err := p.db.NewSelect().
+		Model(&products).
+		Where("quantity - reserved + in_transit <= reorder_point").
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (p *PostgresRepo) List(query ProductQuery) (ProductPage, error) {
+	products := []Product{}
+	q := applyProductQuery(p.db.NewSelect().Model(&products), query)
+
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		q = q.Offset(query.Offset)
+	}
+
+	total, err := q.ScanAndCount(context.Background())
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	return ProductPage{
+		Products:   products,
+		Total:      total,
+		NextCursor: nextCursor(query, total),
+	}, nil
+}
+
+// Export reads the matching products through a server side cursor, so only
+// exportBatchSize of them are in memory at a time.
+func (p *PostgresRepo) Export(query ProductQuery, fn func(Product) error) error {
+	return p.db.RunInTx(context.Background(), &sql.TxOptions{ReadOnly: true}, func(ctx context.Context, tx bun.Tx) error {
+		q := applyProductQuery(tx.NewSelect().Model((*Product)(nil)), query)
+		if _, err := tx.ExecContext(ctx, "DECLARE product_export NO SCROLL CURSOR FOR ?", q); err != nil {
+			return err
+		}
+
+		for {
+			var products []Product
+			if err := tx.NewRaw("FETCH ? FROM product_export", exportBatchSize).Scan(ctx, &products); err != nil {
+				return err
+			}
+			if len(products) == 0 {
+				break
+			}
+			for _, product := range products {
+				if err := fn(product); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, "CLOSE product_export")
+		return err
+	})
+}
+
+// applyProductQuery adds the filters and the order of query to q.
+func applyProductQuery(q *bun.SelectQuery, query ProductQuery) *bun.SelectQuery {
+	if query.Brand != "" {
+		q = q.Where("brand = ?", query.Brand)
+	}
+	if query.Category != "" {
+		q = q.Where("category = ?", query.Category)
+	}
+	if query.MinPrice != nil {
+		q = q.Where("price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		q = q.Where("price <= ?", *query.MaxPrice)
+	}
+	if query.MinQuantity != nil {
+		q = q.Where("quantity >= ?", *query.MinQuantity)
+	}
+	if query.MaxQuantity != nil {
+		q = q.Where("quantity <= ?", *query.MaxQuantity)
+	}
+	if !query.UpdatedSince.IsZero() {
+		q = q.Where("updated_at >= ?", query.UpdatedSince)
+	}
+
+	direction := "ASC"
+	if query.Desc {
+		direction = "DESC"
+	}
+	if column, ok := productSortColumns[query.SortBy]; ok && column != "id" {
+		q = q.OrderExpr("? "+direction, bun.Ident(column))
+	}
+	return q.OrderExpr("id " + direction)
+}
+
+// productSearchRow is a product together with its rank for a search query.
+type productSearchRow struct {
+	Product `bun:",extend"`
+	Rank    float64 `bun:"rank,scanonly"`
+}
+
+// Search matches every query term as a prefix against the search_vector
+// column and falls back to trigram similarity so misspelt terms still find
+// something. Both indexes come from the search migration.
+func (p *PostgresRepo) Search(query string, limit int) ([]SearchResult, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	prefixes := make([]string, 0, len(terms))
+	for _, term := range terms {
+		prefixes = append(prefixes, term+":*")
+	}
+	tsQuery := strings.Join(prefixes, " & ")
+	text := strings.Join(terms, " ")
+
+	rows := []productSearchRow{}
+	err := p.db.NewSelect().
+		Model(&rows).
+		ColumnExpr("?TableColumns").
+		ColumnExpr("ts_rank(product.search_vector, to_tsquery('simple', ?)) + similarity(product.brand || ' ' || product.category, ?) AS rank", tsQuery, text).
+		Where("product.search_vector @@ to_tsquery('simple', ?)", tsQuery).
+		WhereOr("(product.brand || ' ' || product.category) % ?", text).
+		OrderExpr("rank DESC").
+		OrderExpr("product.id ASC").
+		Limit(limit).
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, SearchResult{Product: row.Product, Rank: row.Rank})
+	}
+	return results, nil
+}
+
+func (p *PostgresRepo) Delete(id, version int) error {
 	var product Product
-	result, err := p.db.NewDelete().Model(&product).Where("id = ?", id).Exec(context.Background())
+	result, err := p.db.NewDelete().
+		Model(&product).
+		Where("id = ?", id).
+		Where("(? = 0 OR version = ?)", version, version).
+		Exec(context.Background())
 	if err != nil {
 		log.Println("err while deleting Product: ", err)
 		return err
@@ -94,7 +332,104 @@ func (p *PostgresRepo) Delete(id int) error {
 		return rowsErr
 	}
 	if rowsAffected == 0 {
-		return errProductNotFound
+		return p.notFoundOrConflict(id)
 	}
 	return nil
 }
+
+func (p *PostgresRepo) AddMovement(movement StockMovement) (StockMovement, error) {
+	ctx := context.Background()
+	err := p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var quantity, reserved, inTransit int
+		err := tx.NewUpdate().
+			Model((*Product)(nil)).
+			Set("quantity = quantity + ?", movement.delta()).
+			Set("in_transit = in_transit + ?", movement.transitDelta()).
+			Set("updated_at = ?", movement.CreatedAt).
+			Set("version = version + 1").
+			Where("id = ?", movement.ProductId).
+			Returning("quantity, reserved, in_transit").
+			Scan(ctx, &quantity, &reserved, &inTransit)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errProductNotFound
+			}
+			return err
+		}
+		// stock held by reservations cannot be taken out
+		if quantity < 0 || (movement.delta() < 0 && quantity < reserved) || inTransit < 0 {
+			return errInsufficientStock
+		}
+
+		locationDelta := movement.delta()
+		if movement.Type == MovementTransfer {
+			locationDelta = -movement.Quantity
+		}
+		if movement.LocationId != 0 {
+			if err := adjustStockLevel(ctx, tx, movement.ProductId, movement.LocationId, locationDelta); err != nil {
+				return err
+			}
+		}
+		if movement.ToLocationId != 0 {
+			if err := adjustStockLevel(ctx, tx, movement.ProductId, movement.ToLocationId, movement.Quantity); err != nil {
+				return err
+			}
+		}
+		if err := adjustLots(ctx, tx, movement); err != nil {
+			return err
+		}
+
+		_, err = tx.NewInsert().Model(&movement).Returning("id").Exec(ctx)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, errProductNotFound) && !errors.Is(err, errInsufficientStock) && !errors.Is(err, errLocationNotFound) && !errors.Is(err, errLotNotFound) {
+			log.Println("error while adding stock movement in postgres:", err)
+		}
+		return StockMovement{}, err
+	}
+	return movement, nil
+}
+
+// adjustStockLevel applies delta to the stock of a product in a bin and drops
+// the level once it reaches zero.
+func adjustStockLevel(ctx context.Context, tx bun.Tx, productId, locationId, delta int) error {
+	stockLevel := StockLevel{ProductId: productId, LocationId: locationId, Quantity: delta}
+	_, err := tx.NewInsert().
+		Model(&stockLevel).
+		On("CONFLICT (product_id, location_id) DO UPDATE").
+		Set("quantity = stock_level.quantity + EXCLUDED.quantity").
+		Returning("quantity").
+		Exec(ctx)
+	if err != nil {
+		if sqlErrorCode(err) == pgForeignKeyViolation {
+			return errLocationNotFound
+		}
+		return err
+	}
+	if stockLevel.Quantity < 0 {
+		return errInsufficientStock
+	}
+	if stockLevel.Quantity == 0 {
+		_, err = tx.NewDelete().Model(&stockLevel).WherePK().Exec(ctx)
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresRepo) GetMovements(productId int) ([]StockMovement, error) {
+	if _, err := p.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	movements := []StockMovement{}
+	err := p.db.NewSelect().
+		Model(&movements).
+		Where("product_id = ?", productId).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -13,7 +14,7 @@ import (
 
 func setupPostgres(t *testing.T, fixtureFileName string) *bun.DB {
 	db := connectPostgres("postgres", "postgres", "127.0.0.1:5432", "productsdb")
-	db.RegisterModel((*Product)(nil))
+	db.RegisterModel((*Product)(nil), (*StockMovement)(nil), (*Warehouse)(nil), (*Location)(nil), (*StockLevel)(nil))
 	t.Cleanup(func() {
 		t.Log("closing db", db.Close())
 	})
@@ -165,8 +166,9 @@ func TestPostgresRepo_Update(t *testing.T) {
 					Id:        10,
 					Brand:     "A",
 					Category:  "A",
-					Quantity:  1,
+					Quantity:  10,
 					Price:     10,
+					Version:   1,
 					CreatedAt: time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC),
 					UpdatedAt: time.Date(2023, 04, 28, 11, 00, 00, 00, time.UTC),
 				},
@@ -206,6 +208,39 @@ func TestPostgresRepo_Update(t *testing.T) {
 			},
 			wantErr: errProductNotFound,
 		},
+		{
+			name: "stale version",
+			args: args{
+				product: Product{
+					Id:       10,
+					Brand:    "C",
+					Category: "C",
+					Price:    90,
+					Version:  4,
+				},
+			},
+			wantProducts: []Product{
+				{
+					Id:        10,
+					Brand:     "J",
+					Category:  "J",
+					Quantity:  10,
+					Price:     100,
+					CreatedAt: time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC),
+					UpdatedAt: time.Date(2023, 04, 28, 11, 00, 00, 00, time.UTC),
+				},
+				{
+					Id:        20,
+					Brand:     "K",
+					Category:  "K",
+					Quantity:  20,
+					Price:     200,
+					CreatedAt: time.Date(2023, 04, 28, 12, 00, 00, 00, time.UTC),
+					UpdatedAt: time.Date(2023, 04, 28, 13, 00, 00, 00, time.UTC),
+				},
+			},
+			wantErr: errVersionConflict,
+		},
 		{
 			name: "created at should not change on update",
 			args: args{
@@ -235,6 +270,7 @@ func TestPostgresRepo_Update(t *testing.T) {
 					Category:  "J",
 					Quantity:  10,
 					Price:     100,
+					Version:   1,
 					CreatedAt: time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC),
 					UpdatedAt: time.Date(2023, 04, 28, 20, 00, 00, 00, time.UTC),
 				},
@@ -261,6 +297,40 @@ func TestPostgresRepo_Update(t *testing.T) {
 	}
 }
 
+func TestPostgresRepo_UpdateColumns(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	repo := NewPostgresRepo(db)
+
+	err := repo.UpdateColumns(Product{
+		Id:        10,
+		Brand:     "Z",
+		Category:  "Z",
+		Quantity:  99,
+		Price:     150,
+		UpdatedAt: time.Date(2023, 04, 28, 20, 00, 00, 00, time.UTC),
+	}, []string{"price"})
+	assert.NoError(t, err, "update columns should succeed")
+
+	product, err := repo.GetById(10)
+	assert.NoError(t, err, "get should succeed")
+	assert.Equal(t, Product{
+		Id:        10,
+		Brand:     "J",
+		Category:  "J",
+		Quantity:  10,
+		Price:     150,
+		Version:   1,
+		CreatedAt: time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC),
+		UpdatedAt: time.Date(2023, 04, 28, 20, 00, 00, 00, time.UTC),
+	}, product, "expect only price, updated at and version to change")
+
+	err = repo.UpdateColumns(Product{Id: 10, Price: 1, Version: 5}, []string{"price"})
+	assert.ErrorIs(t, err, errVersionConflict, "expect stale version to conflict")
+
+	err = repo.UpdateColumns(Product{Id: 10, Quantity: 1}, []string{"quantity"})
+	assert.EqualError(t, err, `column "quantity" cannot be updated`)
+}
+
 func TestPostgresRepo_GetById(t *testing.T) {
 	type args struct {
 		id int
@@ -360,6 +430,24 @@ func TestPostgresRepo_GetAll(t *testing.T) {
 	}
 }
 
+func TestPostgresRepo_GetLowStock(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	repo := NewPostgresRepo(db)
+
+	lowStock, err := repo.GetLowStock()
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, lowStock, "expect no product below a reorder point")
+
+	err = repo.UpdateColumns(Product{Id: 10, ReorderPoint: 10, ReorderQuantity: 50}, []string{"reorder_point", "reorder_quantity"})
+	assert.NoError(t, err, "expect no error")
+	lowStock, err = repo.GetLowStock()
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, lowStock, 1, "expect product at its reorder point") {
+		assert.Equal(t, 10, lowStock[0].Id)
+		assert.Equal(t, 50, lowStock[0].ReorderQuantity)
+	}
+}
+
 func TestPostgresRepo_Delete(t *testing.T) {
 	type args struct {
 		id int
@@ -421,7 +509,7 @@ func TestPostgresRepo_Delete(t *testing.T) {
 			db := setupPostgres(t, "existingData.yaml")
 			repo := NewPostgresRepo(db)
 
-			err := repo.Delete(tt.args.id)
+			err := repo.Delete(tt.args.id, 0)
 
 			assert.ErrorIs(t, err, tt.wantErr, "error while geting products should match the expected error")
 
@@ -433,3 +521,330 @@ func TestPostgresRepo_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestPostgresRepo_AddMovement(t *testing.T) {
+	type args struct {
+		movement StockMovement
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantQuantity  int
+		wantInTransit int
+		wantMovements []StockMovement
+		wantErr       error
+	}{
+		{
+			name: "receipt increments quantity",
+			args: args{
+				movement: StockMovement{
+					ProductId: 10,
+					Type:      MovementReceipt,
+					Quantity:  5,
+					Reason:    "delivery",
+					Reference: "PO-1",
+					Actor:     "clerk",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+			wantQuantity: 15,
+			wantMovements: []StockMovement{
+				{
+					ProductId: 10,
+					Type:      MovementReceipt,
+					Quantity:  5,
+					Reason:    "delivery",
+					Reference: "PO-1",
+					Actor:     "clerk",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "issue more than on hand",
+			args: args{
+				movement: StockMovement{
+					ProductId: 10,
+					Type:      MovementIssue,
+					Quantity:  11,
+					Reason:    "sold",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+			wantQuantity:  10,
+			wantMovements: []StockMovement{},
+			wantErr:       errInsufficientStock,
+		},
+		{
+			name: "dispatch moves stock into transit",
+			args: args{
+				movement: StockMovement{
+					ProductId: 10,
+					Type:      MovementDispatch,
+					Quantity:  3,
+					Reason:    "transfer order 1 shipped",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+			wantQuantity:  7,
+			wantInTransit: 3,
+			wantMovements: []StockMovement{
+				{
+					ProductId: 10,
+					Type:      MovementDispatch,
+					Quantity:  3,
+					Reason:    "transfer order 1 shipped",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+		},
+		{
+			name: "loss more than in transit",
+			args: args{
+				movement: StockMovement{
+					ProductId: 10,
+					Type:      MovementLoss,
+					Quantity:  1,
+					Reason:    "transfer order 1 closed with stock missing",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+			wantQuantity:  10,
+			wantMovements: []StockMovement{},
+			wantErr:       errInsufficientStock,
+		},
+		{
+			name: "product not found",
+			args: args{
+				movement: StockMovement{
+					ProductId: 99,
+					Type:      MovementReceipt,
+					Quantity:  1,
+					Reason:    "delivery",
+					CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+				},
+			},
+			wantQuantity:  10,
+			wantMovements: []StockMovement{},
+			wantErr:       errProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupPostgres(t, "existingData.yaml")
+			_, truncateErr := db.NewTruncateTable().Model((*StockMovement)(nil)).Exec(context.Background())
+			assert.NoError(t, truncateErr, "expect no error while truncating movements")
+			repo := NewPostgresRepo(db)
+
+			_, err := repo.AddMovement(tt.args.movement)
+			assert.ErrorIs(t, err, tt.wantErr, "error while adding movement should match the expected error")
+
+			product, getErr := repo.GetById(10)
+			assert.NoError(t, getErr, "expect no error while getting product")
+			assert.Equal(t, tt.wantQuantity, product.Quantity, "expect quantity derived from movements")
+			assert.Equal(t, tt.wantInTransit, product.InTransit, "expect stock in transit derived from movements")
+
+			gotMovements, gotErr := repo.GetMovements(10)
+			assert.NoError(t, gotErr, "expect no error while getting movements")
+			for idx := range gotMovements {
+				gotMovements[idx].Id = 0
+			}
+			assert.Equal(t, tt.wantMovements, gotMovements, "expect same movements")
+		})
+	}
+}
+
+func TestPostgresRepo_List(t *testing.T) {
+	minPrice := 150.0
+
+	tests := []struct {
+		name           string
+		query          ProductQuery
+		wantIds        []int
+		wantTotal      int
+		wantNextCursor string
+	}{
+		{
+			name:      "all products by id",
+			query:     ProductQuery{},
+			wantIds:   []int{10, 20},
+			wantTotal: 2,
+		},
+		{
+			name:      "filter by brand",
+			query:     ProductQuery{Brand: "K"},
+			wantIds:   []int{20},
+			wantTotal: 1,
+		},
+		{
+			name:      "filter by min price",
+			query:     ProductQuery{MinPrice: &minPrice},
+			wantIds:   []int{20},
+			wantTotal: 1,
+		},
+		{
+			name:      "updated since",
+			query:     ProductQuery{UpdatedSince: time.Date(2023, 04, 28, 12, 00, 00, 00, time.UTC)},
+			wantIds:   []int{20},
+			wantTotal: 1,
+		},
+		{
+			name:           "first page sorted by quantity descending",
+			query:          ProductQuery{SortBy: "quantity", Desc: true, Limit: 1},
+			wantIds:        []int{20},
+			wantTotal:      2,
+			wantNextCursor: encodeCursor(1),
+		},
+		{
+			name:      "second page",
+			query:     ProductQuery{SortBy: "quantity", Desc: true, Limit: 1, Offset: 1},
+			wantIds:   []int{10},
+			wantTotal: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupPostgres(t, "existingData.yaml")
+			repo := NewPostgresRepo(db)
+
+			page, err := repo.List(tt.query)
+			assert.NoError(t, err, "expect no error while listing products")
+
+			gotIds := make([]int, 0)
+			for _, product := range page.Products {
+				gotIds = append(gotIds, product.Id)
+			}
+			assert.Equal(t, tt.wantIds, gotIds, "expect same products in order")
+			assert.Equal(t, tt.wantTotal, page.Total, "expect same total")
+			assert.Equal(t, tt.wantNextCursor, page.NextCursor, "expect same next cursor")
+		})
+	}
+}
+
+func TestPostgresRepo_Search(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantIds []int
+	}{
+		{
+			name:    "prefix of brand",
+			query:   "j",
+			wantIds: []int{10},
+		},
+		{
+			name:    "brand and category",
+			query:   "k k",
+			wantIds: []int{20},
+		},
+		{
+			name:    "no match",
+			query:   "zzz",
+			wantIds: []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupPostgres(t, "existingData.yaml")
+			repo := NewPostgresRepo(db)
+
+			results, err := repo.Search(tt.query, 10)
+			assert.NoError(t, err, "expect no error while searching products")
+
+			gotIds := make([]int, 0)
+			for _, result := range results {
+				gotIds = append(gotIds, result.Product.Id)
+			}
+			assert.Equal(t, tt.wantIds, gotIds, "expect same products by rank")
+		})
+	}
+}
+
+func TestPostgresRepo_NextIdAndSku(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	repo := NewPostgresRepo(db)
+
+	id, err := repo.NextId()
+	assert.NoError(t, err, "expect no error while drawing the next id")
+	assert.NotContains(t, []int{0, 10, 20}, id, "expect an id no product uses")
+
+	product := Product{
+		Id:        id,
+		Sku:       "K-K-NEW",
+		Brand:     "K",
+		Category:  "K",
+		CreatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+		UpdatedAt: time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+	}
+	assert.NoError(t, repo.Create(product), "expect no error while creating product")
+
+	gotProduct, err := repo.GetBySku("K-K-NEW")
+	assert.NoError(t, err, "expect no error while getting product by sku")
+	assert.Equal(t, product, gotProduct, "expect same product")
+
+	product.Id = id + 1000
+	assert.ErrorIs(t, repo.Create(product), errDuplicateSku, "expect duplicate sku")
+
+	_, err = repo.GetBySku("MISSING")
+	assert.ErrorIs(t, err, errProductNotFound, "expect product not found")
+}
+
+func TestPostgresRepo_InTx(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	repo := NewPostgresRepo(db)
+	errAbort := errors.New("abort")
+
+	err := repo.InTx(func(tx Repo) error {
+		if err := tx.Create(Product{Id: 30, Brand: "L", Category: "L", Price: 300}); err != nil {
+			return err
+		}
+		innerErr := tx.InTx(func(tx Repo) error {
+			if err := tx.Delete(10, 0); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		assert.ErrorIs(t, innerErr, errAbort, "expect inner error")
+		return nil
+	})
+	assert.NoError(t, err, "transaction should commit")
+
+	err = repo.InTx(func(tx Repo) error {
+		if err := tx.Delete(20, 0); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort, "expect transaction to be rolled back")
+
+	var ids []int
+	scanErr := db.NewSelect().Model((*Product)(nil)).Column("id").Order("id").Scan(context.Background(), &ids)
+	assert.NoError(t, scanErr, "expect no error while getting products")
+	assert.Equal(t, []int{10, 20, 30}, ids, "expect only committed writes")
+}
+
+func TestPostgresRepo_Export(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	repo := NewPostgresRepo(db)
+
+	var ids []int
+	err := repo.Export(ProductQuery{SortBy: "price", Desc: true, Limit: 1}, func(product Product) error {
+		ids = append(ids, product.Id)
+		return nil
+	})
+	assert.NoError(t, err, "export should succeed")
+	assert.Equal(t, []int{20, 10}, ids, "expect every product in query order")
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = repo.Export(ProductQuery{}, func(product Product) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop, "expect the callback error")
+	assert.Equal(t, 1, calls, "expect export to stop at the first error")
+}
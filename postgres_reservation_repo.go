@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// CreateReservation takes the stock with a conditional update of the product
+// row, which concurrent reservations for the product wait on.
+func (p *PostgresRepo) CreateReservation(reservation Reservation) (Reservation, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model((*Product)(nil)).
+			Set("reserved = reserved + ?", reservation.Quantity).
+			Set("updated_at = ?", reservation.CreatedAt).
+			Set("version = version + 1").
+			Where("id = ?", reservation.ProductId).
+			Where("quantity - reserved >= ?", reservation.Quantity).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			exists, err := tx.NewSelect().Model((*Product)(nil)).Where("id = ?", reservation.ProductId).Exists(ctx)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return errProductNotFound
+			}
+			return errInsufficientStock
+		}
+
+		_, err = tx.NewInsert().Model(&reservation).Returning("id").Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reservation, nil
+}
+
+func (p *PostgresRepo) GetReservationById(id int) (Reservation, error) {
+	var reservation Reservation
+	if err := p.db.NewSelect().Model(&reservation).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Reservation{}, errReservationNotFound
+		}
+		return Reservation{}, err
+	}
+	return reservation, nil
+}
+
+func (p *PostgresRepo) GetReservations(productId int) ([]Reservation, error) {
+	if _, err := p.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	reservations := []Reservation{}
+	err := p.db.NewSelect().
+		Model(&reservations).
+		Where("product_id = ?", productId).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// EndReservation only updates a reservation that is still active, so of two
+// concurrent attempts to end it one fails with errReservationNotActive.
+func (p *PostgresRepo) EndReservation(id int, status string, at time.Time) (Reservation, error) {
+	var reservation Reservation
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewUpdate().
+			Model(&reservation).
+			Set("status = ?", status).
+			Set("updated_at = ?", at).
+			Where("id = ?", id).
+			Where("status = ?", ReservationActive).
+			Returning("*").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := (&PostgresRepo{db: tx}).GetReservationById(id); err != nil {
+				return err
+			}
+			return errReservationNotActive
+		}
+		if err != nil {
+			return err
+		}
+		return unreserve(ctx, tx, reservation, at)
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reservation, nil
+}
+
+func (p *PostgresRepo) ExpireReservations(now time.Time) ([]Reservation, error) {
+	reservations := []Reservation{}
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewUpdate().
+			Model((*Reservation)(nil)).
+			Set("status = ?", ReservationExpired).
+			Set("updated_at = ?", now).
+			Where("status = ?", ReservationActive).
+			Where("expires_at <= ?", now).
+			Returning("*").
+			Scan(ctx, &reservations)
+		if err != nil {
+			return err
+		}
+		for _, reservation := range reservations {
+			if err := unreserve(ctx, tx, reservation, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// unreserve gives the stock of an ended reservation back.
+func unreserve(ctx context.Context, tx bun.Tx, reservation Reservation, at time.Time) error {
+	_, err := tx.NewUpdate().
+		Model((*Product)(nil)).
+		Set("reserved = reserved - ?", reservation.Quantity).
+		Set("updated_at = ?", at).
+		Set("version = version + 1").
+		Where("id = ?", reservation.ProductId).
+		Exec(ctx)
+	return err
+}
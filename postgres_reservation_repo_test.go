@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_Reservations(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	repo := NewPostgresRepo(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	first, err := repo.CreateReservation(Reservation{ProductId: 10, Quantity: 4, Reference: "order-1", Status: ReservationActive, ExpiresAt: now.Add(time.Minute), CreatedAt: now, UpdatedAt: now})
+	assert.NoError(t, err, "create reservation should succeed")
+	_, err = repo.CreateReservation(Reservation{ProductId: 10, Quantity: 7, Status: ReservationActive, ExpiresAt: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect reservations not to hold more than is on hand")
+	_, err = repo.CreateReservation(Reservation{ProductId: 99, Quantity: 1, Status: ReservationActive, ExpiresAt: now, CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	got, err := repo.GetReservationById(first.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, "order-1", got.Reference)
+	_, err = repo.AddMovement(StockMovement{ProductId: 10, Type: MovementIssue, Quantity: 7, Reason: "sale", CreatedAt: now})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect reserved stock not to be issued")
+
+	expired, err := repo.ExpireReservations(now.Add(time.Minute))
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, ReservationExpired, expired[0].Status)
+	}
+	_, err = repo.EndReservation(first.Id, ReservationConfirmed, now)
+	assert.ErrorIs(t, err, errReservationNotActive, "expect an expired reservation not to be confirmed")
+	_, err = repo.EndReservation(9999, ReservationReleased, now)
+	assert.ErrorIs(t, err, errReservationNotFound)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 15; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CreateReservation(Reservation{ProductId: 10, Quantity: 1, Status: ReservationActive, ExpiresAt: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now})
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, reserved, "expect no more reservations than stock")
+
+	product, err := repo.GetById(10)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, 10, product.Reserved)
+
+	reservations, err := repo.GetReservations(10)
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, reservations, 11)
+}
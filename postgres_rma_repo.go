@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+func (p *PostgresRepo) CreateRma(rma Rma) (Rma, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&rma).Returning("id").Exec(ctx); err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errSalesOrderNotFound
+			}
+			return err
+		}
+		if len(rma.Lines) == 0 {
+			return nil
+		}
+		for idx := range rma.Lines {
+			rma.Lines[idx].RmaId = rma.Id
+		}
+		if _, err := tx.NewInsert().Model(&rma.Lines).Returning("id").Exec(ctx); err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errProductNotFound
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return Rma{}, err
+	}
+	return rma, nil
+}
+
+func (p *PostgresRepo) GetRmaById(id int) (Rma, error) {
+	return p.getRma(id, false)
+}
+
+// LockRma locks the rma row, so it only blocks others when p is bound to a
+// transaction.
+func (p *PostgresRepo) LockRma(id int) (Rma, error) {
+	return p.getRma(id, true)
+}
+
+func (p *PostgresRepo) getRma(id int, lock bool) (Rma, error) {
+	var rma Rma
+	query := p.db.NewSelect().Model(&rma).Where("id = ?", id)
+	if lock {
+		query = query.For("UPDATE")
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rma{}, errRmaNotFound
+		}
+		return Rma{}, err
+	}
+
+	rma.Lines = []RmaLine{}
+	err := p.db.NewSelect().
+		Model(&rma.Lines).
+		Where("rma_id = ?", id).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return Rma{}, err
+	}
+	return rma, nil
+}
+
+func (p *PostgresRepo) GetRmas(filter RmaFilter) ([]Rma, error) {
+	rmas := []Rma{}
+	query := p.db.NewSelect().
+		Model(&rmas).
+		Relation("Lines", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("id")
+		}).
+		Order("rma.id")
+	if filter.SalesOrderId != 0 {
+		query = query.Where("rma.sales_order_id = ?", filter.SalesOrderId)
+	}
+	if filter.Status != "" {
+		query = query.Where("rma.status = ?", filter.Status)
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		return []Rma{}, err
+	}
+	for idx := range rmas {
+		if rmas[idx].Lines == nil {
+			rmas[idx].Lines = []RmaLine{}
+		}
+	}
+	return rmas, nil
+}
+
+func (p *PostgresRepo) UpdateRma(rma Rma) error {
+	return p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model(&rma).
+			Column("status", "updated_at", "received_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if err := rowsAffectedOr(result, errRmaNotFound); err != nil {
+			return err
+		}
+
+		for _, line := range rma.Lines {
+			_, err := tx.NewUpdate().
+				Model(&line).
+				Column("disposition", "note", "disposed_at").
+				Where("id = ?", line.Id).
+				Where("rma_id = ?", rma.Id).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_Rmas(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	if _, err := db.NewTruncateTable().Model((*SalesOrder)(nil)).Cascade().Exec(context.Background()); err != nil {
+		t.Fatal("error while truncating sales orders:", err)
+	}
+	repo := NewPostgresRepo(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	order, err := repo.CreateSalesOrder(SalesOrder{
+		Customer:  "Jane",
+		Status:    SalesOrderShipped,
+		Lines:     []SalesOrderLine{{ProductId: 10, Quantity: 2}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	assert.NoError(t, err, "create sales order should succeed")
+	if !assert.Len(t, order.Lines, 1) {
+		return
+	}
+
+	_, err = repo.CreateRma(Rma{SalesOrderId: order.Id + 1, Reason: "damaged", Status: RmaOpen, CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errSalesOrderNotFound)
+
+	rma, err := repo.CreateRma(Rma{
+		SalesOrderId: order.Id,
+		Reason:       "damaged",
+		Status:       RmaOpen,
+		Lines:        []RmaLine{{SalesOrderLineId: order.Lines[0].Id, ProductId: 10, Quantity: 2}},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	assert.NoError(t, err, "create rma should succeed")
+	if !assert.Len(t, rma.Lines, 1) {
+		return
+	}
+
+	rma.Status = RmaReceived
+	rma.ReceivedAt = now
+	rma.Lines[0].Disposition = DispositionQuarantine
+	rma.Lines[0].Note = "seal broken"
+	rma.Lines[0].DisposedAt = now
+	assert.NoError(t, repo.UpdateRma(rma), "update rma should succeed")
+
+	stored, err := repo.LockRma(rma.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, RmaReceived, stored.Status)
+	assert.Equal(t, now, stored.ReceivedAt.UTC())
+	assert.Equal(t, DispositionQuarantine, stored.Lines[0].Disposition)
+	assert.Equal(t, "seal broken", stored.Lines[0].Note)
+
+	rmas, err := repo.GetRmas(RmaFilter{SalesOrderId: order.Id, Status: RmaReceived})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, rmas, 1)
+	_, err = repo.GetRmaById(rma.Id + 1)
+	assert.ErrorIs(t, err, errRmaNotFound)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+func (p *PostgresRepo) CreateSalesOrder(order SalesOrder) (SalesOrder, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&order).Returning("id").Exec(ctx); err != nil {
+			return err
+		}
+		if len(order.Lines) == 0 {
+			return nil
+		}
+		for idx := range order.Lines {
+			order.Lines[idx].SalesOrderId = order.Id
+		}
+		if _, err := tx.NewInsert().Model(&order.Lines).Returning("id").Exec(ctx); err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errProductNotFound
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return SalesOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PostgresRepo) GetSalesOrderById(id int) (SalesOrder, error) {
+	return p.getSalesOrder(id, false)
+}
+
+// LockSalesOrder locks the order row, so it only blocks others when p is
+// bound to a transaction.
+func (p *PostgresRepo) LockSalesOrder(id int) (SalesOrder, error) {
+	return p.getSalesOrder(id, true)
+}
+
+func (p *PostgresRepo) getSalesOrder(id int, lock bool) (SalesOrder, error) {
+	var order SalesOrder
+	query := p.db.NewSelect().Model(&order).Where("id = ?", id)
+	if lock {
+		query = query.For("UPDATE")
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SalesOrder{}, errSalesOrderNotFound
+		}
+		return SalesOrder{}, err
+	}
+
+	order.Lines = []SalesOrderLine{}
+	err := p.db.NewSelect().
+		Model(&order.Lines).
+		Where("sales_order_id = ?", id).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return SalesOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PostgresRepo) GetSalesOrders(filter SalesOrderFilter) ([]SalesOrder, error) {
+	orders := []SalesOrder{}
+	query := p.db.NewSelect().
+		Model(&orders).
+		Relation("Lines", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("id")
+		}).
+		Order("sales_order.id")
+	if filter.Customer != "" {
+		query = query.Where("sales_order.customer = ?", filter.Customer)
+	}
+	if filter.Status != "" {
+		query = query.Where("sales_order.status = ?", filter.Status)
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		return []SalesOrder{}, err
+	}
+	for idx := range orders {
+		if orders[idx].Lines == nil {
+			orders[idx].Lines = []SalesOrderLine{}
+		}
+	}
+	return orders, nil
+}
+
+func (p *PostgresRepo) UpdateSalesOrder(order SalesOrder) error {
+	return p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model(&order).
+			Column("status", "updated_at", "shipped_at", "delivered_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if err := rowsAffectedOr(result, errSalesOrderNotFound); err != nil {
+			return err
+		}
+
+		for _, line := range order.Lines {
+			_, err := tx.NewUpdate().
+				Model(&line).
+				Column("reservation_id", "lots").
+				Where("id = ?", line.Id).
+				Where("sales_order_id = ?", order.Id).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
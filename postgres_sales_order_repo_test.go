@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_SalesOrders(t *testing.T) {
+	db := setupPostgres(t, "existingData.yaml")
+	if _, err := db.NewTruncateTable().Model((*SalesOrder)(nil)).Cascade().Exec(context.Background()); err != nil {
+		t.Fatal("error while truncating sales orders:", err)
+	}
+	repo := NewPostgresRepo(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	_, err := repo.CreateSalesOrder(SalesOrder{Customer: "Jane", Status: SalesOrderCreated, Lines: []SalesOrderLine{{ProductId: 99, Quantity: 1}}, CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	order, err := repo.CreateSalesOrder(SalesOrder{
+		Customer:  "Jane",
+		Status:    SalesOrderCreated,
+		Lines:     []SalesOrderLine{{ProductId: 10, Quantity: 2, UnitPrice: 9.99}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	assert.NoError(t, err, "create sales order should succeed")
+	if !assert.Len(t, order.Lines, 1) {
+		return
+	}
+
+	reservation, err := repo.CreateReservation(Reservation{ProductId: 10, Quantity: 2, Status: ReservationActive, CreatedAt: now, UpdatedAt: now})
+	assert.NoError(t, err, "expect a reservation without expiry to be stored")
+	order.Status = SalesOrderAllocated
+	order.Lines[0].ReservationId = reservation.Id
+	assert.NoError(t, repo.UpdateSalesOrder(order), "update sales order should succeed")
+
+	expired, err := repo.ExpireReservations(now.Add(24 * time.Hour))
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, expired, "expect a reservation without expiry not to expire")
+
+	stored, err := repo.LockSalesOrder(order.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, SalesOrderAllocated, stored.Status)
+	assert.Equal(t, reservation.Id, stored.Lines[0].ReservationId)
+
+	orders, err := repo.GetSalesOrders(SalesOrderFilter{Customer: "Jane", Status: SalesOrderAllocated})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, orders, 1)
+	_, err = repo.GetSalesOrderById(order.Id + 1)
+	assert.ErrorIs(t, err, errSalesOrderNotFound)
+}
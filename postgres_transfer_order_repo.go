@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+func (p *PostgresRepo) CreateTransferOrder(order TransferOrder) (TransferOrder, error) {
+	err := p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&order).Returning("id").Exec(ctx); err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errWarehouseNotFound
+			}
+			return err
+		}
+		if len(order.Lines) == 0 {
+			return nil
+		}
+		for idx := range order.Lines {
+			order.Lines[idx].TransferOrderId = order.Id
+		}
+		if _, err := tx.NewInsert().Model(&order.Lines).Returning("id").Exec(ctx); err != nil {
+			if sqlErrorCode(err) == pgForeignKeyViolation {
+				return errProductNotFound
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PostgresRepo) GetTransferOrderById(id int) (TransferOrder, error) {
+	return p.getTransferOrder(id, false)
+}
+
+// LockTransferOrder locks the order row, so it only blocks others when p is
+// bound to a transaction.
+func (p *PostgresRepo) LockTransferOrder(id int) (TransferOrder, error) {
+	return p.getTransferOrder(id, true)
+}
+
+func (p *PostgresRepo) getTransferOrder(id int, lock bool) (TransferOrder, error) {
+	var order TransferOrder
+	query := p.db.NewSelect().Model(&order).Where("id = ?", id)
+	if lock {
+		query = query.For("UPDATE")
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return TransferOrder{}, errTransferOrderNotFound
+		}
+		return TransferOrder{}, err
+	}
+
+	order.Lines = []TransferOrderLine{}
+	err := p.db.NewSelect().
+		Model(&order.Lines).
+		Where("transfer_order_id = ?", id).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return order, nil
+}
+
+func (p *PostgresRepo) GetTransferOrders(filter TransferOrderFilter) ([]TransferOrder, error) {
+	orders := []TransferOrder{}
+	query := p.db.NewSelect().
+		Model(&orders).
+		Relation("Lines", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("id")
+		}).
+		Order("transfer_order.id")
+	if filter.FromWarehouseId != 0 {
+		query = query.Where("transfer_order.from_warehouse_id = ?", filter.FromWarehouseId)
+	}
+	if filter.ToWarehouseId != 0 {
+		query = query.Where("transfer_order.to_warehouse_id = ?", filter.ToWarehouseId)
+	}
+	if filter.Status != "" {
+		query = query.Where("transfer_order.status = ?", filter.Status)
+	}
+	if err := query.Scan(context.Background()); err != nil {
+		return []TransferOrder{}, err
+	}
+	for idx := range orders {
+		if orders[idx].Lines == nil {
+			orders[idx].Lines = []TransferOrderLine{}
+		}
+	}
+	return orders, nil
+}
+
+func (p *PostgresRepo) UpdateTransferOrder(order TransferOrder) error {
+	return p.db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model(&order).
+			Column("status", "updated_at", "shipped_at", "closed_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if err := rowsAffectedOr(result, errTransferOrderNotFound); err != nil {
+			return err
+		}
+
+		for _, line := range order.Lines {
+			_, err := tx.NewUpdate().
+				Model(&line).
+				Column("received", "missing", "lots").
+				Where("id = ?", line.Id).
+				Where("transfer_order_id = ?", order.Id).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
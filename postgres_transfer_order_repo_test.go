@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_TransferOrders(t *testing.T) {
+	db, location := setupPostgresWarehouse(t)
+	repo := NewPostgresRepo(db)
+	destination, err := repo.CreateWarehouse(Warehouse{Code: "DEL", Name: "Delhi", CreatedAt: time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC)})
+	if err != nil {
+		t.Fatal("error while creating warehouse:", err)
+	}
+	if _, err := db.NewTruncateTable().Model((*TransferOrder)(nil)).Cascade().Exec(context.Background()); err != nil {
+		t.Fatal("error while truncating transfer orders:", err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	_, err = repo.CreateTransferOrder(TransferOrder{FromWarehouseId: location.WarehouseId, ToWarehouseId: destination.Id + 1, Status: TransferOrderDraft, CreatedAt: now, UpdatedAt: now})
+	assert.ErrorIs(t, err, errWarehouseNotFound)
+
+	order, err := repo.CreateTransferOrder(TransferOrder{
+		FromWarehouseId: location.WarehouseId,
+		ToWarehouseId:   destination.Id,
+		Status:          TransferOrderDraft,
+		Lines:           []TransferOrderLine{{ProductId: 10, FromLocationId: location.Id, Quantity: 3}},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	assert.NoError(t, err, "create transfer order should succeed")
+	if !assert.Len(t, order.Lines, 1) {
+		return
+	}
+
+	order.Status = TransferOrderClosed
+	order.ShippedAt = now
+	order.ClosedAt = now
+	order.Lines[0].Received = 2
+	order.Lines[0].Missing = 1
+	order.Lines[0].Lots = []LotQuantity{{LotId: 1, Quantity: 1}}
+	assert.NoError(t, repo.UpdateTransferOrder(order), "update transfer order should succeed")
+
+	stored, err := repo.LockTransferOrder(order.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, TransferOrderClosed, stored.Status)
+	assert.Equal(t, now, stored.ClosedAt.UTC())
+	assert.Equal(t, 2, stored.Lines[0].Received)
+	assert.Equal(t, 1, stored.Lines[0].Missing)
+	assert.Equal(t, []LotQuantity{{LotId: 1, Quantity: 1}}, stored.Lines[0].Lots)
+
+	orders, err := repo.GetTransferOrders(TransferOrderFilter{FromWarehouseId: location.WarehouseId, ToWarehouseId: destination.Id, Status: TransferOrderClosed})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, orders, 1)
+	_, err = repo.GetTransferOrderById(order.Id + 1)
+	assert.ErrorIs(t, err, errTransferOrderNotFound)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+func (p *PostgresRepo) CreateWarehouse(warehouse Warehouse) (Warehouse, error) {
+	_, err := p.db.NewInsert().Model(&warehouse).Returning("id").Exec(context.Background())
+	if err != nil {
+		if sqlErrorCode(err) == pgUniqueViolation {
+			return Warehouse{}, errDuplicateCode
+		}
+		return Warehouse{}, err
+	}
+	return warehouse, nil
+}
+
+func (p *PostgresRepo) GetWarehouseById(id int) (Warehouse, error) {
+	var warehouse Warehouse
+	if err := p.db.NewSelect().Model(&warehouse).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Warehouse{}, errWarehouseNotFound
+		}
+		return Warehouse{}, err
+	}
+	return warehouse, nil
+}
+
+func (p *PostgresRepo) GetWarehouses() ([]Warehouse, error) {
+	warehouses := []Warehouse{}
+	if err := p.db.NewSelect().Model(&warehouses).Order("id").Scan(context.Background()); err != nil {
+		return []Warehouse{}, err
+	}
+	return warehouses, nil
+}
+
+func (p *PostgresRepo) CreateLocation(location Location) (Location, error) {
+	_, err := p.db.NewInsert().Model(&location).Returning("id").Exec(context.Background())
+	if err != nil {
+		switch sqlErrorCode(err) {
+		case pgUniqueViolation:
+			return Location{}, errDuplicateCode
+		case pgForeignKeyViolation:
+			return Location{}, errWarehouseNotFound
+		}
+		return Location{}, err
+	}
+	return location, nil
+}
+
+func (p *PostgresRepo) GetLocationById(id int) (Location, error) {
+	var location Location
+	if err := p.db.NewSelect().Model(&location).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Location{}, errLocationNotFound
+		}
+		return Location{}, err
+	}
+	return location, nil
+}
+
+func (p *PostgresRepo) GetLocations(warehouseId int) ([]Location, error) {
+	if _, err := p.GetWarehouseById(warehouseId); err != nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	err := p.db.NewSelect().
+		Model(&locations).
+		Where("warehouse_id = ?", warehouseId).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func (p *PostgresRepo) GetStockByWarehouse(warehouseId int) ([]StockLevel, error) {
+	if _, err := p.GetWarehouseById(warehouseId); err != nil {
+		return nil, err
+	}
+
+	stockLevels := []StockLevel{}
+	err := p.db.NewSelect().
+		Model(&stockLevels).
+		Join("JOIN locations AS location ON location.id = stock_level.location_id").
+		Where("location.warehouse_id = ?", warehouseId).
+		Order("stock_level.location_id", "stock_level.product_id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return stockLevels, nil
+}
+
+func (p *PostgresRepo) GetStockByLocation(locationId int) ([]StockLevel, error) {
+	if _, err := p.GetLocationById(locationId); err != nil {
+		return nil, err
+	}
+
+	stockLevels := []StockLevel{}
+	err := p.db.NewSelect().
+		Model(&stockLevels).
+		Where("location_id = ?", locationId).
+		Order("product_id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return stockLevels, nil
+}
+
+func (p *PostgresRepo) GetStockByProduct(productId int) ([]StockLevel, error) {
+	if _, err := p.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	stockLevels := []StockLevel{}
+	err := p.db.NewSelect().
+		Model(&stockLevels).
+		Where("product_id = ?", productId).
+		Order("location_id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return stockLevels, nil
+}
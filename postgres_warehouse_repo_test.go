@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func setupPostgresWarehouse(t *testing.T) (*bun.DB, Location) {
+	db := setupPostgres(t, "existingData.yaml")
+	_, err := db.NewTruncateTable().Model((*Warehouse)(nil)).Cascade().Exec(context.Background())
+	if err != nil {
+		t.Fatal("error while truncating warehouses:", err)
+	}
+
+	repo := NewPostgresRepo(db)
+	warehouse, err := repo.CreateWarehouse(Warehouse{
+		Code:      "BLR",
+		Name:      "Bangalore",
+		CreatedAt: time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC),
+	})
+	if err != nil {
+		t.Fatal("error while creating warehouse:", err)
+	}
+	location, err := repo.CreateLocation(Location{
+		WarehouseId: warehouse.Id,
+		Code:        "A-01",
+		Aisle:       "A",
+		Bin:         "01",
+		CreatedAt:   time.Date(2023, 04, 28, 10, 00, 00, 00, time.UTC),
+	})
+	if err != nil {
+		t.Fatal("error while creating location:", err)
+	}
+	return db, location
+}
+
+func TestPostgresRepo_CreateLocation(t *testing.T) {
+	db, location := setupPostgresWarehouse(t)
+	repo := NewPostgresRepo(db)
+
+	_, err := repo.CreateLocation(Location{WarehouseId: location.WarehouseId, Code: "A-01", Aisle: "A", Bin: "02"})
+	assert.ErrorIs(t, err, errDuplicateCode, "expect duplicate code in the same warehouse")
+
+	_, err = repo.CreateLocation(Location{WarehouseId: location.WarehouseId + 100, Code: "A-02", Aisle: "A", Bin: "02"})
+	assert.ErrorIs(t, err, errWarehouseNotFound, "expect unknown warehouse")
+
+	locations, err := repo.GetLocations(location.WarehouseId)
+	assert.NoError(t, err, "expect no error while getting locations")
+	assert.Equal(t, []Location{location}, locations, "expect only the created location")
+}
+
+func TestPostgresRepo_AddMovementWithLocation(t *testing.T) {
+	db, location := setupPostgresWarehouse(t)
+	repo := NewPostgresRepo(db)
+
+	_, err := repo.AddMovement(StockMovement{
+		ProductId:  10,
+		Type:       MovementReceipt,
+		Quantity:   4,
+		LocationId: location.Id,
+		Reason:     "delivery",
+		CreatedAt:  time.Date(2023, 04, 29, 10, 00, 00, 00, time.UTC),
+	})
+	assert.NoError(t, err, "expect no error while receiving into bin")
+
+	_, err = repo.AddMovement(StockMovement{
+		ProductId:  10,
+		Type:       MovementIssue,
+		Quantity:   5,
+		LocationId: location.Id,
+		Reason:     "picked",
+		CreatedAt:  time.Date(2023, 04, 29, 11, 00, 00, 00, time.UTC),
+	})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect bin to run short")
+
+	stockLevels, err := repo.GetStockByLocation(location.Id)
+	assert.NoError(t, err, "expect no error while getting bin contents")
+	assert.Equal(t, []StockLevel{{ProductId: 10, LocationId: location.Id, Quantity: 4}}, stockLevels, "expect same bin contents")
+
+	stockLevels, err = repo.GetStockByWarehouse(location.WarehouseId)
+	assert.NoError(t, err, "expect no error while getting warehouse stock")
+	assert.Equal(t, []StockLevel{{ProductId: 10, LocationId: location.Id, Quantity: 4}}, stockLevels, "expect same warehouse stock")
+
+	product, err := repo.GetById(10)
+	assert.NoError(t, err, "expect no error while getting product")
+	assert.Equal(t, 14, product.Quantity, "expect total quantity to include the bin")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+func (p *PostgresRepo) CreateWebhook(webhook Webhook) (Webhook, error) {
+	if _, err := p.db.NewInsert().Model(&webhook).Returning("id").Exec(context.Background()); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (p *PostgresRepo) GetWebhookById(id int) (Webhook, error) {
+	var webhook Webhook
+	if err := p.db.NewSelect().Model(&webhook).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, errWebhookNotFound
+		}
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (p *PostgresRepo) GetWebhooks() ([]Webhook, error) {
+	webhooks := []Webhook{}
+	if err := p.db.NewSelect().Model(&webhooks).Order("id").Scan(context.Background()); err != nil {
+		return []Webhook{}, err
+	}
+	return webhooks, nil
+}
+
+func (p *PostgresRepo) UpdateWebhook(webhook Webhook) error {
+	result, err := p.db.NewUpdate().Model(&webhook).WherePK().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOr(result, errWebhookNotFound)
+}
+
+func (p *PostgresRepo) DeleteWebhook(id int) error {
+	result, err := p.db.NewDelete().Model((*Webhook)(nil)).Where("id = ?", id).Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOr(result, errWebhookNotFound)
+}
+
+func (p *PostgresRepo) CreateDelivery(delivery WebhookDelivery) (WebhookDelivery, error) {
+	if _, err := p.db.NewInsert().Model(&delivery).Returning("id").Exec(context.Background()); err != nil {
+		switch sqlErrorCode(err) {
+		case pgUniqueViolation:
+			return WebhookDelivery{}, errDuplicateDelivery
+		case pgForeignKeyViolation:
+			return WebhookDelivery{}, errWebhookNotFound
+		}
+		return WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// ClaimDeliveries skips rows another worker has locked, so instances sharing
+// the table each claim their own deliveries.
+func (p *PostgresRepo) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error) {
+	due := p.db.NewSelect().
+		Model((*WebhookDelivery)(nil)).
+		Column("id").
+		Where("status = ?", DeliveryPending).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at", "id").
+		Limit(limit).
+		For("UPDATE SKIP LOCKED")
+
+	deliveries := []WebhookDelivery{}
+	err := p.db.NewUpdate().
+		Model((*WebhookDelivery)(nil)).
+		Set("next_attempt_at = ?", now.Add(lease)).
+		Where("id IN (?)", due).
+		Returning("*").
+		Scan(context.Background(), &deliveries)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	return deliveries, nil
+}
+
+func (p *PostgresRepo) UpdateDelivery(delivery WebhookDelivery) error {
+	result, err := p.db.NewUpdate().Model(&delivery).WherePK().Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOr(result, errDeliveryNotFound)
+}
+
+func (p *PostgresRepo) GetDeliveryById(id int) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := p.db.NewSelect().Model(&delivery).Where("id = ?", id).Scan(context.Background()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WebhookDelivery{}, errDeliveryNotFound
+		}
+		return WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+func (p *PostgresRepo) GetDeliveries(webhookId int) ([]WebhookDelivery, error) {
+	if _, err := p.GetWebhookById(webhookId); err != nil {
+		return nil, err
+	}
+
+	deliveries := []WebhookDelivery{}
+	err := p.db.NewSelect().
+		Model(&deliveries).
+		Where("webhook_id = ?", webhookId).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (p *PostgresRepo) GetDeadDeliveries() ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	err := p.db.NewSelect().
+		Model(&deliveries).
+		Where("status = ?", DeliveryDead).
+		Order("id").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// rowsAffectedOr returns notFound when a write matched no rows.
+func rowsAffectedOr(result sql.Result, notFound error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
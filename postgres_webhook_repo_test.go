@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresRepo_Webhooks(t *testing.T) {
+	db := setupPostgres(t, "emptyData.yaml")
+	if _, err := db.NewTruncateTable().Model((*Webhook)(nil)).Cascade().Exec(context.Background()); err != nil {
+		t.Fatal("error while truncating webhooks:", err)
+	}
+	repo := NewPostgresRepo(db)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	webhook, err := repo.CreateWebhook(Webhook{Url: "https://example.com", Secret: "secret", Events: []string{EventProductCreated}, CreatedAt: now, UpdatedAt: now})
+	assert.NoError(t, err, "create webhook should succeed")
+
+	webhook.Url = "https://example.com/products"
+	assert.NoError(t, repo.UpdateWebhook(webhook), "update webhook should succeed")
+	got, err := repo.GetWebhookById(webhook.Id)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, "https://example.com/products", got.Url)
+	assert.Equal(t, []string{EventProductCreated}, got.Events)
+
+	for seq, at := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(time.Minute)} {
+		_, err := repo.CreateDelivery(WebhookDelivery{
+			WebhookId:     webhook.Id,
+			EventSeq:      int64(seq + 1),
+			EventType:     EventProductCreated,
+			Payload:       []byte(`{"seq": 1}`),
+			Status:        DeliveryPending,
+			NextAttemptAt: at,
+			CreatedAt:     now,
+		})
+		assert.NoError(t, err, "create delivery should succeed")
+	}
+	_, err = repo.CreateDelivery(WebhookDelivery{WebhookId: webhook.Id, EventSeq: 1, Payload: []byte(`{}`), Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now})
+	assert.ErrorIs(t, err, errDuplicateDelivery, "expect an event to be queued once per webhook")
+
+	claimed, err := repo.ClaimDeliveries(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, claimed, 2, "expect only due deliveries")
+	claimed, err = repo.ClaimDeliveries(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, claimed, "expect leased deliveries not to be claimed again")
+
+	delivery, err := repo.GetDeliveryById(1)
+	assert.NoError(t, err, "expect no error")
+	delivery.Status = DeliveryDead
+	delivery.Attempts = 8
+	delivery.LastStatusCode = 500
+	assert.NoError(t, repo.UpdateDelivery(delivery), "update delivery should succeed")
+	dead, err := repo.GetDeadDeliveries()
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, dead, 1)
+
+	assert.NoError(t, repo.DeleteWebhook(webhook.Id), "delete webhook should succeed")
+	_, err = repo.GetDeliveries(webhook.Id)
+	assert.ErrorIs(t, err, errWebhookNotFound)
+	_, err = repo.GetDeliveryById(1)
+	assert.ErrorIs(t, err, errDeliveryNotFound, "expect deliveries to be deleted with the webhook")
+}
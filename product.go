@@ -1,18 +1,47 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
 
+// Product is a catalog entry. Quantity is the stock on hand, Reserved the
+// part of it held for pending orders; what is left to sell is reported as
+// available. InTransit is stock shipped from one warehouse to another and not
+// received yet, it is no longer on hand but not gone either.
+//
+// Available stock and stock in transit at or below ReorderPoint are low and
+// should be topped up by ReorderQuantity.
 type Product struct {
-	Id        int       `json:"id"`
-	Brand     string    `json:"brand"`
-	Category  string    `json:"category"`
-	Quantity  int       `json:"quantity"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	Id              int       `json:"id"`
+	Sku             string    `json:"sku" bun:",nullzero"`
+	Brand           string    `json:"brand"`
+	Category        string    `json:"category"`
+	Quantity        int       `json:"quantity"`
+	Reserved        int       `json:"reserved"`
+	InTransit       int       `json:"inTransit,omitempty"`
+	Price           float64   `json:"price"`
+	ReorderPoint    int       `json:"reorderPoint,omitempty"`
+	ReorderQuantity int       `json:"reorderQuantity,omitempty"`
+	Version         int       `json:"version"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// MarshalJSON adds available, which is derived from the stock and neither
+// stored nor read back.
+func (p Product) MarshalJSON() ([]byte, error) {
+	type product Product
+	return json.Marshal(struct {
+		product
+		Available int `json:"available"`
+	}{product(p), p.available()})
+}
+
+// available is the part of the stock on hand that is not reserved.
+func (p Product) available() int {
+	return p.Quantity - p.Reserved
 }
 
 type validationError struct {
@@ -29,6 +58,9 @@ func validateProduct(product Product) error {
 	if product.Id < 0 {
 		failures = append(failures, "Id should not be less than 0")
 	}
+	if product.Sku != "" && !validSku.MatchString(product.Sku) {
+		failures = append(failures, "Sku should only contain upper case letters, digits and dashes")
+	}
 	if product.Brand == "" {
 		failures = append(failures, "Brand should not be empty")
 	}
@@ -41,6 +73,12 @@ func validateProduct(product Product) error {
 	if product.Price < 0 {
 		failures = append(failures, "Price should not be less than 0")
 	}
+	if product.ReorderPoint < 0 {
+		failures = append(failures, "ReorderPoint should not be less than 0")
+	}
+	if product.ReorderQuantity < 0 {
+		failures = append(failures, "ReorderQuantity should not be less than 0")
+	}
 
 	if len(failures) == 0 {
 		return nil
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProductQueryLimit = 100
+	maxProductQueryLimit     = 1000
+)
+
+// ProductQuery filters, sorts and pages the product list. Zero values mean
+// "no filter". A zero Limit returns every matching product from the repo, but
+// the service pages it at defaultProductQueryLimit so no request reads the
+// whole catalog.
+type ProductQuery struct {
+	Brand        string
+	Category     string
+	MinPrice     *float64
+	MaxPrice     *float64
+	MinQuantity  *int
+	MaxQuantity  *int
+	UpdatedSince time.Time
+	SortBy       string
+	Desc         bool
+	Limit        int
+	Offset       int
+}
+
+// ProductPage is one page of the product list. NextCursor is empty on the
+// last page.
+type ProductPage struct {
+	Products   []Product
+	Total      int
+	NextCursor string
+}
+
+// productSortColumns maps the sort keys accepted by the API to the columns of
+// the products table.
+var productSortColumns = map[string]string{
+	"id":        "id",
+	"brand":     "brand",
+	"category":  "category",
+	"quantity":  "quantity",
+	"price":     "price",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+// parseProductQuery reads the list parameters of GET /products:
+// brand, category, minPrice, maxPrice, minQuantity, maxQuantity,
+// updatedSince (RFC 3339), sort (a key, prefixed with "-" for descending),
+// limit and cursor.
+func parseProductQuery(values url.Values) (ProductQuery, error) {
+	failures := make([]string, 0)
+	query := ProductQuery{
+		Brand:    values.Get("brand"),
+		Category: values.Get("category"),
+	}
+
+	parseFloat := func(name string) *float64 {
+		raw := values.Get(name)
+		if raw == "" {
+			return nil
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s should be a number", name))
+			return nil
+		}
+		return &value
+	}
+	parseInt := func(name string) *int {
+		raw := values.Get(name)
+		if raw == "" {
+			return nil
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s should be an integer", name))
+			return nil
+		}
+		return &value
+	}
+
+	query.MinPrice = parseFloat("minPrice")
+	query.MaxPrice = parseFloat("maxPrice")
+	query.MinQuantity = parseInt("minQuantity")
+	query.MaxQuantity = parseInt("maxQuantity")
+
+	if raw := values.Get("updatedSince"); raw != "" {
+		updatedSince, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			failures = append(failures, "updatedSince should be an RFC 3339 timestamp")
+		}
+		query.UpdatedSince = updatedSince
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		query.Desc = strings.HasPrefix(raw, "-")
+		query.SortBy = strings.TrimPrefix(raw, "-")
+	}
+	if limit := parseInt("limit"); limit != nil {
+		query.Limit = *limit
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			failures = append(failures, "cursor is invalid")
+		}
+		query.Offset = offset
+	}
+
+	if len(failures) > 0 {
+		return ProductQuery{}, &validationError{failures: failures}
+	}
+	return query, nil
+}
+
+func validateProductQuery(query ProductQuery) error {
+	failures := make([]string, 0)
+
+	if query.SortBy != "" {
+		if _, ok := productSortColumns[query.SortBy]; !ok {
+			failures = append(failures, "sort should be one of id, brand, category, quantity, price, createdAt, updatedAt")
+		}
+	}
+	if query.Limit < 0 || query.Limit > maxProductQueryLimit {
+		failures = append(failures, fmt.Sprintf("limit should be between 0 and %d", maxProductQueryLimit))
+	}
+	if query.Offset < 0 {
+		failures = append(failures, "cursor is invalid")
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		failures = append(failures, "minPrice should not be greater than maxPrice")
+	}
+	if query.MinQuantity != nil && query.MaxQuantity != nil && *query.MinQuantity > *query.MaxQuantity {
+		failures = append(failures, "minQuantity should not be greater than maxQuantity")
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// encodeCursor and decodeCursor keep the cursor opaque to clients, even
+// though it currently is a plain offset.
+func encodeCursor(offset int) string {
+	return strconv.FormatInt(int64(offset), 36)
+}
+
+func decodeCursor(cursor string) (int, error) {
+	offset, err := strconv.ParseInt(cursor, 36, 64)
+	return int(offset), err
+}
+
+// nextCursor returns the cursor of the page after the one starting at offset,
+// or "" when that page was the last.
+func nextCursor(query ProductQuery, total int) string {
+	if query.Limit == 0 || query.Offset+query.Limit >= total {
+		return ""
+	}
+	return encodeCursor(query.Offset + query.Limit)
+}
+
+func (q ProductQuery) matches(product Product) bool {
+	if q.Brand != "" && product.Brand != q.Brand {
+		return false
+	}
+	if q.Category != "" && product.Category != q.Category {
+		return false
+	}
+	if q.MinPrice != nil && product.Price < *q.MinPrice {
+		return false
+	}
+	if q.MaxPrice != nil && product.Price > *q.MaxPrice {
+		return false
+	}
+	if q.MinQuantity != nil && product.Quantity < *q.MinQuantity {
+		return false
+	}
+	if q.MaxQuantity != nil && product.Quantity > *q.MaxQuantity {
+		return false
+	}
+	if !q.UpdatedSince.IsZero() && product.UpdatedAt.Before(q.UpdatedSince) {
+		return false
+	}
+	return true
+}
+
+// sortProducts orders products the same way PostgresRepo does: by the sort
+// key, ties broken by id.
+func (q ProductQuery) sortProducts(products []Product) {
+	compareTime := func(a, b time.Time) int {
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	}
+	compare := func(a, b Product) int {
+		switch q.SortBy {
+		case "brand":
+			return strings.Compare(a.Brand, b.Brand)
+		case "category":
+			return strings.Compare(a.Category, b.Category)
+		case "quantity":
+			return a.Quantity - b.Quantity
+		case "price":
+			switch {
+			case a.Price < b.Price:
+				return -1
+			case a.Price > b.Price:
+				return 1
+			}
+			return 0
+		case "createdAt":
+			return compareTime(a.CreatedAt, b.CreatedAt)
+		case "updatedAt":
+			return compareTime(a.UpdatedAt, b.UpdatedAt)
+		}
+		return 0
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		cmp := compare(products[i], products[j])
+		if cmp == 0 {
+			cmp = products[i].Id - products[j].Id
+		}
+		if q.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProductQuery(t *testing.T) {
+	minPrice := 10.5
+	maxQuantity := 20
+
+	tests := []struct {
+		name         string
+		rawQuery     string
+		wantQuery    ProductQuery
+		wantFailures []string
+	}{
+		{
+			name:      "no parameters",
+			rawQuery:  "",
+			wantQuery: ProductQuery{},
+		},
+		{
+			name:     "all parameters",
+			rawQuery: "brand=A&category=B&minPrice=10.5&maxQuantity=20&updatedSince=2023-04-26T15:00:00Z&sort=-price&limit=5&cursor=a",
+			wantQuery: ProductQuery{
+				Brand:        "A",
+				Category:     "B",
+				MinPrice:     &minPrice,
+				MaxQuantity:  &maxQuantity,
+				UpdatedSince: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC),
+				SortBy:       "price",
+				Desc:         true,
+				Limit:        5,
+				Offset:       10,
+			},
+		},
+		{
+			name:     "malformed parameters",
+			rawQuery: "minPrice=x&limit=y&updatedSince=yesterday&cursor=!",
+			wantFailures: []string{
+				"minPrice should be a number",
+				"updatedSince should be an RFC 3339 timestamp",
+				"limit should be an integer",
+				"cursor is invalid",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.rawQuery)
+			assert.NoError(t, err, "expect valid raw query")
+
+			query, err := parseProductQuery(values)
+
+			if len(tt.wantFailures) > 0 {
+				var ve *validationError
+				assert.ErrorAs(t, err, &ve, "error should be of ValidationError type")
+				assert.Equal(t, tt.wantFailures, ve.failures, "expect failures to be same")
+				return
+			}
+			assert.NoError(t, err, "expect no error")
+			assert.Equal(t, tt.wantQuery, query, "expect same query")
+		})
+	}
+}
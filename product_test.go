@@ -23,10 +23,12 @@ func TestProduct_Marshal(t *testing.T) {
 			args: args{
 				product: Product{
 					Id:        1,
+					Sku:       "A-A-000001",
 					Brand:     "A",
 					Category:  "A",
 					Quantity:  1,
 					Price:     10,
+					Version:   2,
 					CreatedAt: time.Date(2023, 04, 25, 1, 00, 00, 00, time.UTC),
 					UpdatedAt: time.Date(2023, 04, 25, 1, 00, 00, 00, time.UTC),
 				},
@@ -34,10 +36,47 @@ func TestProduct_Marshal(t *testing.T) {
 			wantJSON: `
 			{
 				"id": 1,
+				"sku": "A-A-000001",
 				"brand": "A",
 				"category": "A",
 				"quantity": 1,
 				"price": 10,
+				"version": 2,
+				"reserved": 0,
+				"available": 1,
+				"createdAt":"2023-04-25T01:00:00Z",
+				"updatedAt":"2023-04-25T01:00:00Z"
+			}
+			`,
+			wantErr: nil,
+		},
+		{
+			name: "product marshal with reserved stock",
+			args: args{
+				product: Product{
+					Id:        1,
+					Sku:       "A-A-000001",
+					Brand:     "A",
+					Category:  "A",
+					Quantity:  5,
+					Reserved:  2,
+					Price:     10,
+					Version:   2,
+					CreatedAt: time.Date(2023, 04, 25, 1, 00, 00, 00, time.UTC),
+					UpdatedAt: time.Date(2023, 04, 25, 1, 00, 00, 00, time.UTC),
+				},
+			},
+			wantJSON: `
+			{
+				"id": 1,
+				"sku": "A-A-000001",
+				"brand": "A",
+				"category": "A",
+				"quantity": 5,
+				"price": 10,
+				"version": 2,
+				"reserved": 2,
+				"available": 3,
 				"createdAt":"2023-04-25T01:00:00Z",
 				"updatedAt":"2023-04-25T01:00:00Z"
 			}
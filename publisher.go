@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// What happens to a subscriber whose queue is full, because it reads events
+// slower than they are published.
+const (
+	// SlowConsumerDrop skips the events that do not fit, the subscriber
+	// notices the gap in seq and can resume.
+	SlowConsumerDrop = "drop"
+	// SlowConsumerCoalesce replaces everything queued by a snapshot of the
+	// catalog as of the newest event.
+	SlowConsumerCoalesce = "coalesce"
+	// SlowConsumerDisconnect closes the subscriber, it has to reconnect.
+	SlowConsumerDisconnect = "disconnect"
+)
+
+const defaultQueueSize = 256
+
+// PublisherOptions configure how events are queued for subscribers.
+type PublisherOptions struct {
+	QueueSize    int
+	SlowConsumer string
+}
+
+func validatePublisherOptions(options PublisherOptions) error {
+	failures := make([]string, 0)
+	if options.QueueSize <= 0 {
+		failures = append(failures, "QueueSize should be greater than 0")
+	}
+	switch options.SlowConsumer {
+	case SlowConsumerDrop, SlowConsumerCoalesce, SlowConsumerDisconnect:
+	default:
+		failures = append(failures, fmt.Sprintf("SlowConsumer should be one of %s, %s, %s", SlowConsumerDrop, SlowConsumerCoalesce, SlowConsumerDisconnect))
+	}
+	if len(failures) > 0 {
+		return &validationError{failures: failures}
+	}
+	return nil
+}
+
+// subscription queues the events of one subscriber and sends them from its
+// own goroutine, so publishing never waits for a subscriber.
+type subscription struct {
+	subscriber Subscriber
+	// filter is nil for subscribers that get every event
+	filter *subscriptionFilter
+	size   int
+	// snapshot builds the snapshot sent in place of the queue once it was
+	// coalesced
+	snapshot func(*subscription) (Event, error)
+
+	mu sync.Mutex
+	// changed is signalled whenever queue, stale, sending or stopped change
+	changed *sync.Cond
+	queue   []Event
+	// stale is set when the queue was coalesced, the next thing sent is a
+	// snapshot
+	stale   bool
+	sending bool
+	stopped bool
+	done    chan struct{}
+}
+
+func newSubscription(subscriber Subscriber, size int, snapshot func(*subscription) (Event, error)) *subscription {
+	sub := &subscription{subscriber: subscriber, size: size, snapshot: snapshot, done: make(chan struct{})}
+	sub.changed = sync.NewCond(&sub.mu)
+	go sub.run()
+	return sub
+}
+
+func (s *subscription) run() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.stale && !s.stopped {
+			s.changed.Wait()
+		}
+		if s.stopped {
+			s.queue = nil
+			s.changed.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+		stale := s.stale
+		events := s.queue
+		s.stale = false
+		s.queue = nil
+		s.sending = true
+		s.mu.Unlock()
+
+		if stale {
+			events = s.coalesce(events)
+		}
+		for _, event := range events {
+			s.subscriber.Update(event)
+		}
+
+		s.mu.Lock()
+		s.sending = false
+		s.changed.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// enqueue queues event unless the queue is full.
+func (s *subscription) enqueue(event Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) >= s.size {
+		return false
+	}
+	s.queue = append(s.queue, event)
+	s.changed.Broadcast()
+	return true
+}
+
+// push queues events however many are queued already; it is used for what a
+// subscriber is sent when it (re)subscribes.
+func (s *subscription) push(events ...Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = append(s.queue, events...)
+	s.changed.Broadcast()
+}
+
+// markStale drops whatever is queued, a snapshot is sent in its place. The
+// snapshot is built by the send goroutine, so publishing does not wait for
+// the catalog to be read.
+func (s *subscription) markStale() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = nil
+	s.stale = true
+	s.changed.Broadcast()
+}
+
+// coalesce returns a snapshot followed by the events queued after the queue
+// was marked stale that the snapshot does not cover yet. Without a snapshot
+// only those events are sent, the subscriber notices the gap in seq.
+func (s *subscription) coalesce(events []Event) []Event {
+	snapshot, err := s.snapshot(s)
+	if err != nil {
+		log.Println("error while coalescing events for", s.subscriber.Id(), ":", err)
+		return events
+	}
+	coalesced := []Event{snapshot}
+	for _, event := range events {
+		if event.Seq > snapshot.Seq {
+			coalesced = append(coalesced, event)
+		}
+	}
+	return coalesced
+}
+
+// drain waits until everything queued has been sent.
+func (s *subscription) drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for (len(s.queue) > 0 || s.stale || s.sending) && !s.stopped {
+		s.changed.Wait()
+	}
+}
+
+// stop discards what is queued and waits for the send goroutine to finish,
+// after which the subscriber is not called anymore.
+func (s *subscription) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.changed.Broadcast()
+	s.mu.Unlock()
+
+	<-s.done
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingSubscriber blocks in its first Update until released, like a client
+// that stopped reading.
+type blockingSubscriber struct {
+	*testSubscriber
+	entered chan struct{}
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newBlockingSubscriber(id string) *blockingSubscriber {
+	return &blockingSubscriber{
+		testSubscriber: &testSubscriber{id: id},
+		entered:        make(chan struct{}),
+		release:        make(chan struct{}),
+		closed:         make(chan struct{}),
+	}
+}
+
+func (b *blockingSubscriber) Update(event Event) {
+	select {
+	case <-b.entered:
+	default:
+		close(b.entered)
+	}
+	<-b.release
+	b.testSubscriber.Update(event)
+}
+
+func (b *blockingSubscriber) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestValidatePublisherOptions(t *testing.T) {
+	assert.NoError(t, validatePublisherOptions(PublisherOptions{QueueSize: 1, SlowConsumer: SlowConsumerCoalesce}))
+
+	err := validatePublisherOptions(PublisherOptions{SlowConsumer: "block"})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{
+			"QueueSize should be greater than 0",
+			"SlowConsumer should be one of drop, coalesce, disconnect",
+		}, ve.failures)
+	}
+}
+
+func TestProductServiceImpl_SlowConsumer(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 10,
+			Price:    10,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		policy         string
+		wantSeqs       []int64
+		wantSnapshot   int64
+		wantDisconnect bool
+	}{
+		{
+			name:     "drop",
+			policy:   SlowConsumerDrop,
+			wantSeqs: []int64{1},
+		},
+		{
+			name:         "coalesce",
+			policy:       SlowConsumerCoalesce,
+			wantSnapshot: 3,
+		},
+		{
+			name:           "disconnect",
+			policy:         SlowConsumerDisconnect,
+			wantDisconnect: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewProductServiceImpl(setupInMemoryRepo(existing))
+			assert.NoError(t, svc.SetPublisherOptions(PublisherOptions{QueueSize: 1, SlowConsumer: tt.policy}))
+
+			slow := newBlockingSubscriber("slow")
+			assert.NoError(t, svc.subscribe(slow), "subscribe should succeed")
+			<-slow.entered
+
+			// publishing carries on while the subscriber is stuck
+			for i := 0; i < 3; i++ {
+				svc.publish(EventProductUpdated, existing[0])
+			}
+			close(slow.release)
+
+			if tt.wantDisconnect {
+				select {
+				case <-slow.closed:
+				case <-time.After(time.Second):
+					t.Fatal("expect slow subscriber to be closed")
+				}
+				assert.NotContains(t, svc.subscriptions, "slow", "expect slow subscriber to be removed")
+				return
+			}
+			svc.drain()
+
+			var seqs []int64
+			for _, event := range slow.events {
+				seqs = append(seqs, event.Seq)
+			}
+			assert.Equal(t, tt.wantSeqs, seqs, "expect events the slow subscriber got")
+			assert.Equal(t, tt.wantSnapshot, slow.snapshot.Seq, "expect the last snapshot the slow subscriber got")
+			assert.Equal(t, existing, slow.products, "expect replica to match the catalog")
+		})
+	}
+}
+
+// gatedRepo blocks GetAll once armed until gate is closed, like a catalog
+// read that takes long.
+type gatedRepo struct {
+	*InMemoryRepo
+	armed atomic.Bool
+	gate  chan struct{}
+}
+
+func (g *gatedRepo) GetAll() ([]Product, error) {
+	if g.armed.Load() {
+		<-g.gate
+	}
+	return g.InMemoryRepo.GetAll()
+}
+
+// gatedEventLog blocks AppendEvent until gate is closed, like a slow
+// database round trip.
+type gatedEventLog struct {
+	*InMemoryRepo
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g *gatedEventLog) AppendEvent(event Event) (Event, error) {
+	close(g.entered)
+	<-g.gate
+	return g.InMemoryRepo.AppendEvent(event)
+}
+
+func TestProductServiceImpl_PublishDoesNotWait(t *testing.T) {
+	existing := []Product{{Id: 1, Brand: "A", Category: "A", Quantity: 10, Price: 10}}
+
+	t.Run("for the event log", func(t *testing.T) {
+		repo := setupInMemoryRepo(existing)
+		events := &gatedEventLog{InMemoryRepo: NewInMemoryRepo(), entered: make(chan struct{}), gate: make(chan struct{})}
+		svc := NewProductServiceImpl(repo)
+		assert.NoError(t, svc.SetEventLog(events), "set event log should succeed")
+
+		go svc.publish(EventProductUpdated, existing[0])
+		<-events.entered
+
+		subscribed := make(chan error)
+		subscriber := &testSubscriber{id: "new"}
+		go func() { subscribed <- svc.subscribe(subscriber) }()
+		select {
+		case err := <-subscribed:
+			assert.NoError(t, err, "subscribe should succeed")
+		case <-time.After(time.Second):
+			t.Fatal("expect subscribe not to wait for the event log")
+		}
+
+		close(events.gate)
+		assert.Eventually(t, func() bool {
+			svc.drain()
+			svc.mu.Lock()
+			defer svc.mu.Unlock()
+			return svc.seq == 1
+		}, time.Second, 10*time.Millisecond, "expect the event to be published")
+	})
+
+	t.Run("for a coalesced snapshot", func(t *testing.T) {
+		repo := &gatedRepo{InMemoryRepo: setupInMemoryRepo(existing), gate: make(chan struct{})}
+		svc := NewProductServiceImpl(repo)
+		assert.NoError(t, svc.SetPublisherOptions(PublisherOptions{QueueSize: 1, SlowConsumer: SlowConsumerCoalesce}))
+
+		slow := newBlockingSubscriber("slow")
+		assert.NoError(t, svc.subscribe(slow), "subscribe should succeed")
+		<-slow.entered
+
+		repo.armed.Store(true)
+		for i := 0; i < 3; i++ {
+			svc.publish(EventProductUpdated, existing[0])
+		}
+		close(slow.release)
+
+		published := make(chan struct{})
+		go func() {
+			svc.publish(EventProductUpdated, existing[0])
+			close(published)
+		}()
+		select {
+		case <-published:
+		case <-time.After(time.Second):
+			t.Fatal("expect publish not to wait for the catalog to be read")
+		}
+
+		close(repo.gate)
+		svc.drain()
+		lastSeq := slow.snapshot.Seq
+		if len(slow.events) > 0 {
+			lastSeq = slow.events[len(slow.events)-1].Seq
+		}
+		assert.Equal(t, int64(4), lastSeq, "expect the slow subscriber to catch up")
+		assert.Equal(t, existing, slow.products, "expect replica to match the catalog")
+	})
+}
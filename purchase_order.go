@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Statuses of a purchase order. A draft can still be edited; once sent it is
+// received in one or more goes and closed when everything arrived, or closed
+// early when the rest is not coming.
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderClosed            = "closed"
+)
+
+type Supplier struct {
+	Id        int       `json:"id" bun:"id,pk,autoincrement"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PurchaseOrder is stock ordered from a supplier, expected to arrive by
+// ExpectedAt.
+type PurchaseOrder struct {
+	Id         int                 `json:"id" bun:"id,pk,autoincrement"`
+	SupplierId int                 `json:"supplierId"`
+	Reference  string              `json:"reference"`
+	Status     string              `json:"status"`
+	ExpectedAt time.Time           `json:"expectedAt" bun:",nullzero"`
+	Lines      []PurchaseOrderLine `json:"lines" bun:"rel:has-many,join:id=purchase_order_id"`
+	CreatedAt  time.Time           `json:"createdAt"`
+	UpdatedAt  time.Time           `json:"updatedAt"`
+}
+
+// PurchaseOrderLine orders Quantity of a product, of which Received arrived
+// so far.
+type PurchaseOrderLine struct {
+	Id              int     `json:"id" bun:"id,pk,autoincrement"`
+	PurchaseOrderId int     `json:"-"`
+	ProductId       int     `json:"productId"`
+	Quantity        int     `json:"quantity"`
+	Received        int     `json:"received"`
+	UnitCost        float64 `json:"unitCost"`
+}
+
+// PurchaseOrderReceipt books goods arriving for the lines of a purchase
+// order, into LocationId when it is set.
+type PurchaseOrderReceipt struct {
+	LocationId int                        `json:"locationId,omitempty"`
+	Actor      string                     `json:"actor"`
+	Lines      []PurchaseOrderReceiptLine `json:"lines"`
+}
+
+type PurchaseOrderReceiptLine struct {
+	LineId   int `json:"lineId"`
+	Quantity int `json:"quantity"`
+}
+
+// PurchaseOrderFilter narrows down GetPurchaseOrders, zero fields match
+// everything.
+type PurchaseOrderFilter struct {
+	SupplierId int
+	Status     string
+}
+
+// parsePurchaseOrderFilter reads the supplierId and status query parameters.
+func parsePurchaseOrderFilter(values url.Values) (PurchaseOrderFilter, error) {
+	failures := make([]string, 0)
+	filter := PurchaseOrderFilter{Status: values.Get("status")}
+
+	if raw := values.Get("supplierId"); raw != "" {
+		supplierId, err := strconv.Atoi(raw)
+		if err != nil {
+			failures = append(failures, "supplierId should be an integer")
+		}
+		filter.SupplierId = supplierId
+	}
+	switch filter.Status {
+	case "", PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived, PurchaseOrderClosed:
+	default:
+		failures = append(failures, fmt.Sprintf("status should be one of %s, %s, %s, %s", PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived, PurchaseOrderClosed))
+	}
+
+	if len(failures) == 0 {
+		return filter, nil
+	}
+	return PurchaseOrderFilter{}, &validationError{failures: failures}
+}
+
+func validateSupplier(supplier Supplier) error {
+	failures := make([]string, 0)
+
+	if supplier.Name == "" {
+		failures = append(failures, "Name should not be empty")
+	}
+	if supplier.Email != "" {
+		if _, err := mail.ParseAddress(supplier.Email); err != nil {
+			failures = append(failures, "Email should be a valid email address")
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+func validatePurchaseOrder(order PurchaseOrder) error {
+	failures := make([]string, 0)
+
+	if order.SupplierId <= 0 {
+		failures = append(failures, "SupplierId should be greater than 0")
+	}
+	if len(order.Lines) == 0 {
+		failures = append(failures, "Lines should not be empty")
+	}
+	for idx, line := range order.Lines {
+		if line.ProductId <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: ProductId should be greater than 0", idx+1))
+		}
+		if line.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should be greater than 0", idx+1))
+		}
+		if line.UnitCost < 0 {
+			failures = append(failures, fmt.Sprintf("line %d: UnitCost should not be less than 0", idx+1))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// validateReceipt checks receipt against the lines of order, which cannot
+// receive more than is still outstanding.
+func validateReceipt(order PurchaseOrder, receipt PurchaseOrderReceipt) error {
+	failures := make([]string, 0)
+
+	if len(receipt.Lines) == 0 {
+		failures = append(failures, "Lines should not be empty")
+	}
+	outstanding := make(map[int]int, len(order.Lines))
+	for _, line := range order.Lines {
+		outstanding[line.Id] = line.Quantity - line.Received
+	}
+	for idx, line := range receipt.Lines {
+		left, ok := outstanding[line.LineId]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("line %d: LineId %d is not a line of the purchase order", idx+1, line.LineId))
+			continue
+		}
+		if line.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should be greater than 0", idx+1))
+			continue
+		}
+		if line.Quantity > left {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should not be more than the %d outstanding", idx+1, left))
+			continue
+		}
+		outstanding[line.LineId] = left - line.Quantity
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// received tells whether every line of order arrived in full.
+func (order PurchaseOrder) received() bool {
+	for _, line := range order.Lines {
+		if line.Received < line.Quantity {
+			return false
+		}
+	}
+	return true
+}
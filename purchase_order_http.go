@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type purchaseOrderTransport struct {
+	service PurchaseOrderService
+}
+
+func NewPurchaseOrderTransport(svc PurchaseOrderService) *purchaseOrderTransport {
+	return &purchaseOrderTransport{
+		service: svc,
+	}
+}
+
+func (t *purchaseOrderTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/suppliers", t.CreateSupplier).Methods("POST")
+	r.HandleFunc("/suppliers", t.GetSuppliers).Methods("GET")
+	r.HandleFunc("/suppliers/{id}", t.GetSupplierById).Methods("GET")
+	r.HandleFunc("/suppliers/{id}", t.UpdateSupplier).Methods("PUT")
+	r.HandleFunc("/purchase-orders", t.CreatePurchaseOrder).Methods("POST")
+	r.HandleFunc("/purchase-orders", t.GetPurchaseOrders).Methods("GET")
+	r.HandleFunc("/purchase-orders/{id}", t.GetPurchaseOrderById).Methods("GET")
+	r.HandleFunc("/purchase-orders/{id}", t.UpdatePurchaseOrder).Methods("PUT")
+	r.HandleFunc("/purchase-orders/{id}/send", t.SendPurchaseOrder).Methods("POST")
+	r.HandleFunc("/purchase-orders/{id}/receive", t.ReceivePurchaseOrder).Methods("POST")
+	r.HandleFunc("/purchase-orders/{id}/close", t.ClosePurchaseOrder).Methods("POST")
+}
+
+func (t *purchaseOrderTransport) CreateSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier Supplier
+	if err := json.NewDecoder(r.Body).Decode(&supplier); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreateSupplier(supplier)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *purchaseOrderTransport) GetSuppliers(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := t.service.GetSuppliers()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, suppliers)
+}
+
+func (t *purchaseOrderTransport) GetSupplierById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	supplier, err := t.service.GetSupplierById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, supplier)
+}
+
+func (t *purchaseOrderTransport) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var supplier Supplier
+	if err := json.NewDecoder(r.Body).Decode(&supplier); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	supplier.Id = id
+	updated, err := t.service.UpdateSupplier(supplier)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (t *purchaseOrderTransport) CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var order PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreatePurchaseOrder(order)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *purchaseOrderTransport) GetPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parsePurchaseOrderFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	orders, err := t.service.GetPurchaseOrders(filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (t *purchaseOrderTransport) GetPurchaseOrderById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := t.service.GetPurchaseOrderById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (t *purchaseOrderTransport) UpdatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var order PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	order.Id = id
+	updated, err := t.service.UpdatePurchaseOrder(order)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (t *purchaseOrderTransport) SendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := t.service.SendPurchaseOrder(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (t *purchaseOrderTransport) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var receipt PurchaseOrderReceipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	order, err := t.service.ReceivePurchaseOrder(id, receipt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (t *purchaseOrderTransport) ClosePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := t.service.ClosePurchaseOrder(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurchaseOrderTransport(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 1, Version: 1}})
+	products := NewProductServiceImpl(repo)
+	handler := buildHttpHandler(
+		NewhttpTransport(products),
+		NewPurchaseOrderTransport(NewPurchaseOrderServiceImpl(repo, products)),
+	)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "invalid supplier",
+			method:         "POST",
+			url:            "/suppliers",
+			body:           `{"email": "nobody"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Name should not be empty", "Email should be a valid email address"]}`,
+		},
+		{
+			name:           "create supplier",
+			method:         "POST",
+			url:            "/suppliers",
+			body:           `{"name": "Acme", "email": "orders@acme.example"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "order from unknown supplier",
+			method:         "POST",
+			url:            "/purchase-orders",
+			body:           `{"supplierId": 7, "lines": [{"productId": 1, "quantity": 10}]}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["supplier not found"]}`,
+		},
+		{
+			name:           "create purchase order",
+			method:         "POST",
+			url:            "/purchase-orders",
+			body:           `{"supplierId": 1, "reference": "PO-1", "lines": [{"productId": 1, "quantity": 10, "unitCost": 2.5}]}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "receive a draft",
+			method:         "POST",
+			url:            "/purchase-orders/1/receive",
+			body:           `{"lines": [{"lineId": 1, "quantity": 4}]}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["cannot receive a purchase order that is draft"]}`,
+		},
+		{
+			name:           "send",
+			method:         "POST",
+			url:            "/purchase-orders/1/send",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "receive more than ordered",
+			method:         "POST",
+			url:            "/purchase-orders/1/receive",
+			body:           `{"lines": [{"lineId": 1, "quantity": 11}]}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["line 1: Quantity should not be more than the 10 outstanding"]}`,
+		},
+		{
+			name:           "receive part",
+			method:         "POST",
+			url:            "/purchase-orders/1/receive",
+			body:           `{"lines": [{"lineId": 1, "quantity": 4}]}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "filter by status",
+			method:         "GET",
+			url:            "/purchase-orders?status=sent",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "invalid filter",
+			method:         "GET",
+			url:            "/purchase-orders?supplierId=x",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["supplierId should be an integer"]}`,
+		},
+		{
+			name:           "unknown purchase order",
+			method:         "POST",
+			url:            "/purchase-orders/7/close",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["purchase order not found"]}`,
+		},
+		{
+			name:           "invalid id",
+			method:         "GET",
+			url:            "/suppliers/abc",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid id"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/purchase-orders/1", nil))
+	var order PurchaseOrder
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&order), "expect purchase order response")
+	assert.Equal(t, PurchaseOrderPartiallyReceived, order.Status)
+	if assert.Len(t, order.Lines, 1) {
+		assert.Equal(t, 4, order.Lines[0].Received)
+		assert.Equal(t, 2.5, order.Lines[0].UnitCost)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+	var product Product
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&product), "expect product response")
+	assert.Equal(t, 5, product.Quantity, "expect the receipt to add to the stock")
+}
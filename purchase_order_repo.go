@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	errSupplierNotFound      = errors.New("supplier not found")
+	errPurchaseOrderNotFound = errors.New("purchase order not found")
+)
+
+// PurchaseOrderRepo stores suppliers and the orders placed with them. Orders
+// are stored and returned with their lines, ordered by id.
+type PurchaseOrderRepo interface {
+	CreateSupplier(Supplier) (Supplier, error)
+	GetSupplierById(id int) (Supplier, error)
+	GetSuppliers() ([]Supplier, error)
+	UpdateSupplier(Supplier) error
+	// CreatePurchaseOrder fails with errSupplierNotFound or
+	// errProductNotFound when the order refers to one that does not exist.
+	CreatePurchaseOrder(PurchaseOrder) (PurchaseOrder, error)
+	GetPurchaseOrderById(id int) (PurchaseOrder, error)
+	// LockPurchaseOrder gets the order like GetPurchaseOrderById and makes
+	// other transactions locking it wait until the calling one ends.
+	LockPurchaseOrder(id int) (PurchaseOrder, error)
+	GetPurchaseOrders(PurchaseOrderFilter) ([]PurchaseOrder, error)
+	// UpdatePurchaseOrder replaces the order and its lines; lines without an
+	// id are added and lines left out are removed.
+	UpdatePurchaseOrder(PurchaseOrder) error
+}
+
+func (r *InMemoryRepo) CreateSupplier(supplier Supplier) (Supplier, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	r.lastSupplierId++
+	supplier.Id = r.lastSupplierId
+	r.suppliers = append(r.suppliers, supplier)
+	return supplier, nil
+}
+
+func (r *InMemoryRepo) GetSupplierById(id int) (Supplier, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentSupplier := range r.suppliers {
+		if currentSupplier.Id == id {
+			return currentSupplier, nil
+		}
+	}
+	return Supplier{}, errSupplierNotFound
+}
+
+func (r *InMemoryRepo) GetSuppliers() ([]Supplier, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	return append([]Supplier{}, r.suppliers...), nil
+}
+
+func (r *InMemoryRepo) UpdateSupplier(supplier Supplier) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentSupplier := range r.suppliers {
+		if currentSupplier.Id == supplier.Id {
+			supplier.CreatedAt = currentSupplier.CreatedAt
+			r.suppliers[idx] = supplier
+			return nil
+		}
+	}
+	return errSupplierNotFound
+}
+
+func (r *InMemoryRepo) CreatePurchaseOrder(order PurchaseOrder) (PurchaseOrder, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	if err := r.checkPurchaseOrder(order); err != nil {
+		return PurchaseOrder{}, err
+	}
+
+	r.lastPurchaseOrderId++
+	order.Id = r.lastPurchaseOrderId
+	order.Lines = r.numberPurchaseOrderLines(order.Id, order.Lines)
+	r.purchaseOrders = append(r.purchaseOrders, order)
+	return copyPurchaseOrder(order), nil
+}
+
+func (r *InMemoryRepo) GetPurchaseOrderById(id int) (PurchaseOrder, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentOrder := range r.purchaseOrders {
+		if currentOrder.Id == id {
+			return copyPurchaseOrder(currentOrder), nil
+		}
+	}
+	return PurchaseOrder{}, errPurchaseOrderNotFound
+}
+
+// LockPurchaseOrder needs no lock of its own, InTx already runs one
+// transaction at a time.
+func (r *InMemoryRepo) LockPurchaseOrder(id int) (PurchaseOrder, error) {
+	return r.GetPurchaseOrderById(id)
+}
+
+func (r *InMemoryRepo) GetPurchaseOrders(filter PurchaseOrderFilter) ([]PurchaseOrder, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	orders := make([]PurchaseOrder, 0)
+	for _, currentOrder := range r.purchaseOrders {
+		if filter.SupplierId != 0 && currentOrder.SupplierId != filter.SupplierId {
+			continue
+		}
+		if filter.Status != "" && currentOrder.Status != filter.Status {
+			continue
+		}
+		orders = append(orders, copyPurchaseOrder(currentOrder))
+	}
+	return orders, nil
+}
+
+func (r *InMemoryRepo) UpdatePurchaseOrder(order PurchaseOrder) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentOrder := range r.purchaseOrders {
+		if currentOrder.Id == order.Id {
+			if err := r.checkPurchaseOrder(order); err != nil {
+				return err
+			}
+			order.CreatedAt = currentOrder.CreatedAt
+			order.Lines = r.numberPurchaseOrderLines(order.Id, order.Lines)
+			r.purchaseOrders[idx] = order
+			return nil
+		}
+	}
+	return errPurchaseOrderNotFound
+}
+
+// checkPurchaseOrder makes sure the supplier and the products of order
+// exist, as the foreign keys do in postgres.
+func (r *InMemoryRepo) checkPurchaseOrder(order PurchaseOrder) error {
+	if _, err := r.GetSupplierById(order.SupplierId); err != nil {
+		return err
+	}
+	for _, line := range order.Lines {
+		if _, err := r.GetById(line.ProductId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// numberPurchaseOrderLines returns a copy of lines belonging to the order
+// with id, the new ones numbered.
+func (r *InMemoryRepo) numberPurchaseOrderLines(id int, lines []PurchaseOrderLine) []PurchaseOrderLine {
+	numbered := make([]PurchaseOrderLine, len(lines))
+	for idx, line := range lines {
+		if line.Id == 0 {
+			r.lastPurchaseOrderLineId++
+			line.Id = r.lastPurchaseOrderLineId
+		}
+		line.PurchaseOrderId = id
+		numbered[idx] = line
+	}
+	sort.Slice(numbered, func(i, j int) bool { return numbered[i].Id < numbered[j].Id })
+	return numbered
+}
+
+// copyPurchaseOrder keeps callers from changing the stored lines.
+func copyPurchaseOrder(order PurchaseOrder) PurchaseOrder {
+	order.Lines = append([]PurchaseOrderLine{}, order.Lines...)
+	return order
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_PurchaseOrders(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A"}, {Id: 2, Brand: "B", Category: "B"}})
+	supplier, err := repo.CreateSupplier(Supplier{Name: "Acme"})
+	assert.NoError(t, err, "create supplier should succeed")
+
+	_, err = repo.CreatePurchaseOrder(PurchaseOrder{SupplierId: 7, Lines: []PurchaseOrderLine{{ProductId: 1, Quantity: 1}}})
+	assert.ErrorIs(t, err, errSupplierNotFound)
+	_, err = repo.CreatePurchaseOrder(PurchaseOrder{SupplierId: supplier.Id, Lines: []PurchaseOrderLine{{ProductId: 7, Quantity: 1}}})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	order, err := repo.CreatePurchaseOrder(PurchaseOrder{
+		SupplierId: supplier.Id,
+		Status:     PurchaseOrderDraft,
+		Lines:      []PurchaseOrderLine{{ProductId: 1, Quantity: 10}, {ProductId: 2, Quantity: 5}},
+	})
+	assert.NoError(t, err, "create purchase order should succeed")
+	assert.Equal(t, 1, order.Id)
+	assert.Equal(t, []int{1, 2}, []int{order.Lines[0].Id, order.Lines[1].Id}, "expect lines to be numbered")
+
+	order.Lines[0].Received = 100
+	stored, _ := repo.GetPurchaseOrderById(order.Id)
+	assert.Equal(t, 0, stored.Lines[0].Received, "expect returned lines not to share the stored ones")
+
+	order.Lines = []PurchaseOrderLine{{Id: 2, ProductId: 2, Quantity: 6}, {ProductId: 1, Quantity: 3}}
+	order.Status = PurchaseOrderSent
+	assert.NoError(t, repo.UpdatePurchaseOrder(order), "update purchase order should succeed")
+	stored, _ = repo.GetPurchaseOrderById(order.Id)
+	if assert.Len(t, stored.Lines, 2, "expect the left out line to be removed") {
+		assert.Equal(t, PurchaseOrderLine{Id: 2, PurchaseOrderId: 1, ProductId: 2, Quantity: 6}, stored.Lines[0])
+		assert.Equal(t, 3, stored.Lines[1].Id, "expect the new line to be numbered")
+	}
+	assert.ErrorIs(t, repo.UpdatePurchaseOrder(PurchaseOrder{Id: 7, SupplierId: supplier.Id}), errPurchaseOrderNotFound)
+
+	orders, err := repo.GetPurchaseOrders(PurchaseOrderFilter{Status: PurchaseOrderDraft})
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, orders, "expect the status filter to apply")
+	orders, err = repo.GetPurchaseOrders(PurchaseOrderFilter{SupplierId: supplier.Id, Status: PurchaseOrderSent})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, orders, 1)
+
+	assert.NoError(t, repo.Delete(1, 0), "delete should succeed")
+	stored, _ = repo.GetPurchaseOrderById(order.Id)
+	assert.Len(t, stored.Lines, 1, "expect the lines of a deleted product to go with it")
+}
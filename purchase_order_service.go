@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type PurchaseOrderService interface {
+	CreateSupplier(Supplier) (Supplier, error)
+	GetSupplierById(id int) (Supplier, error)
+	GetSuppliers() ([]Supplier, error)
+	UpdateSupplier(Supplier) (Supplier, error)
+	CreatePurchaseOrder(PurchaseOrder) (PurchaseOrder, error)
+	GetPurchaseOrderById(id int) (PurchaseOrder, error)
+	GetPurchaseOrders(PurchaseOrderFilter) ([]PurchaseOrder, error)
+	UpdatePurchaseOrder(PurchaseOrder) (PurchaseOrder, error)
+	SendPurchaseOrder(id int) (PurchaseOrder, error)
+	ReceivePurchaseOrder(id int, receipt PurchaseOrderReceipt) (PurchaseOrder, error)
+	ClosePurchaseOrder(id int) (PurchaseOrder, error)
+}
+
+// PurchaseOrderServiceImpl books received goods through the product service,
+// so they land in the stock ledger and are published like any other
+// movement.
+type PurchaseOrderServiceImpl struct {
+	repo     Repo
+	products *ProductServiceImpl
+}
+
+func NewPurchaseOrderServiceImpl(repo Repo, products *ProductServiceImpl) *PurchaseOrderServiceImpl {
+	return &PurchaseOrderServiceImpl{
+		repo:     repo,
+		products: products,
+	}
+}
+
+func (s *PurchaseOrderServiceImpl) CreateSupplier(supplier Supplier) (Supplier, error) {
+	if err := validateSupplier(supplier); err != nil {
+		return Supplier{}, fmt.Errorf("create supplier: %w", err)
+	}
+
+	timeNow := time.Now()
+	supplier.CreatedAt = timeNow
+	supplier.UpdatedAt = timeNow
+	return s.repo.CreateSupplier(supplier)
+}
+
+func (s *PurchaseOrderServiceImpl) GetSupplierById(id int) (Supplier, error) {
+	return s.repo.GetSupplierById(id)
+}
+
+func (s *PurchaseOrderServiceImpl) GetSuppliers() ([]Supplier, error) {
+	return s.repo.GetSuppliers()
+}
+
+func (s *PurchaseOrderServiceImpl) UpdateSupplier(supplier Supplier) (Supplier, error) {
+	if err := validateSupplier(supplier); err != nil {
+		return Supplier{}, fmt.Errorf("update supplier: %w", err)
+	}
+
+	supplier.UpdatedAt = time.Now()
+	if err := s.repo.UpdateSupplier(supplier); err != nil {
+		return Supplier{}, err
+	}
+	return s.repo.GetSupplierById(supplier.Id)
+}
+
+func (s *PurchaseOrderServiceImpl) CreatePurchaseOrder(order PurchaseOrder) (PurchaseOrder, error) {
+	if err := validatePurchaseOrder(order); err != nil {
+		return PurchaseOrder{}, fmt.Errorf("create purchase order: %w", err)
+	}
+
+	timeNow := time.Now()
+	order.Status = PurchaseOrderDraft
+	order.CreatedAt = timeNow
+	order.UpdatedAt = timeNow
+	lines := make([]PurchaseOrderLine, len(order.Lines))
+	for idx, line := range order.Lines {
+		lines[idx] = PurchaseOrderLine{ProductId: line.ProductId, Quantity: line.Quantity, UnitCost: line.UnitCost}
+	}
+	order.Lines = lines
+	return s.repo.CreatePurchaseOrder(order)
+}
+
+func (s *PurchaseOrderServiceImpl) GetPurchaseOrderById(id int) (PurchaseOrder, error) {
+	return s.repo.GetPurchaseOrderById(id)
+}
+
+func (s *PurchaseOrderServiceImpl) GetPurchaseOrders(filter PurchaseOrderFilter) ([]PurchaseOrder, error) {
+	return s.repo.GetPurchaseOrders(filter)
+}
+
+// UpdatePurchaseOrder replaces the supplier, reference, expected date and
+// lines of a draft. Lines are kept by id, lines without one are added.
+func (s *PurchaseOrderServiceImpl) UpdatePurchaseOrder(order PurchaseOrder) (PurchaseOrder, error) {
+	if err := validatePurchaseOrder(order); err != nil {
+		return PurchaseOrder{}, fmt.Errorf("update purchase order: %w", err)
+	}
+
+	var updated PurchaseOrder
+	err := s.repo.InTx(func(tx Repo) error {
+		current, err := tx.LockPurchaseOrder(order.Id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("purchase order", "edit", current.Status, PurchaseOrderDraft); err != nil {
+			return err
+		}
+
+		existing := make(map[int]bool, len(current.Lines))
+		for _, line := range current.Lines {
+			existing[line.Id] = true
+		}
+		failures := make([]string, 0)
+		for idx, line := range order.Lines {
+			if line.Id != 0 && !existing[line.Id] {
+				failures = append(failures, fmt.Sprintf("line %d: Id %d is not a line of the purchase order", idx+1, line.Id))
+			}
+			order.Lines[idx].Received = 0
+		}
+		if len(failures) > 0 {
+			return fmt.Errorf("update purchase order: %w", &validationError{failures: failures})
+		}
+
+		order.Status = current.Status
+		order.CreatedAt = current.CreatedAt
+		order.UpdatedAt = time.Now()
+		if err := tx.UpdatePurchaseOrder(order); err != nil {
+			return err
+		}
+		updated, err = tx.GetPurchaseOrderById(order.Id)
+		return err
+	})
+	return updated, err
+}
+
+// SendPurchaseOrder marks a draft as sent to the supplier, after which it
+// can be received but no longer edited.
+func (s *PurchaseOrderServiceImpl) SendPurchaseOrder(id int) (PurchaseOrder, error) {
+	return s.transition(id, "send", PurchaseOrderSent, PurchaseOrderDraft)
+}
+
+// ClosePurchaseOrder closes an order whose remaining lines are not coming.
+func (s *PurchaseOrderServiceImpl) ClosePurchaseOrder(id int) (PurchaseOrder, error) {
+	return s.transition(id, "close", PurchaseOrderClosed, PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived)
+}
+
+// transition moves the order with id to status when it is in one of from.
+func (s *PurchaseOrderServiceImpl) transition(id int, action, status string, from ...string) (PurchaseOrder, error) {
+	var order PurchaseOrder
+	err := s.repo.InTx(func(tx Repo) (err error) {
+		order, err = tx.LockPurchaseOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("purchase order", action, order.Status, from...); err != nil {
+			return err
+		}
+
+		order.Status = status
+		order.UpdatedAt = time.Now()
+		return tx.UpdatePurchaseOrder(order)
+	})
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	return order, nil
+}
+
+// ReceivePurchaseOrder adds the received quantities to the lines and books a
+// receipt movement for each, all in one transaction. The order is closed
+// once every line arrived in full.
+func (s *PurchaseOrderServiceImpl) ReceivePurchaseOrder(id int, receipt PurchaseOrderReceipt) (PurchaseOrder, error) {
+	var order PurchaseOrder
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		order, err = tx.repo.LockPurchaseOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("purchase order", "receive", order.Status, PurchaseOrderSent, PurchaseOrderPartiallyReceived); err != nil {
+			return err
+		}
+		if err := validateReceipt(order, receipt); err != nil {
+			return fmt.Errorf("receive purchase order: %w", err)
+		}
+
+		for _, received := range receipt.Lines {
+			for idx, line := range order.Lines {
+				if line.Id != received.LineId {
+					continue
+				}
+				order.Lines[idx].Received += received.Quantity
+				_, err := tx.AddMovement(StockMovement{
+					ProductId:  line.ProductId,
+					Type:       MovementReceipt,
+					Quantity:   received.Quantity,
+					LocationId: receipt.LocationId,
+					Reason:     fmt.Sprintf("purchase order %d received", order.Id),
+					Reference:  order.Reference,
+					Actor:      receipt.Actor,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		order.Status = PurchaseOrderPartiallyReceived
+		if order.received() {
+			order.Status = PurchaseOrderClosed
+		}
+		order.UpdatedAt = time.Now()
+		return tx.repo.UpdatePurchaseOrder(order)
+	})
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	return order, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPurchaseOrders(t *testing.T) (*PurchaseOrderServiceImpl, *ProductServiceImpl, PurchaseOrder) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 1, Version: 1}, {Id: 2, Brand: "B", Category: "B", Version: 1}})
+	products := NewProductServiceImpl(repo)
+	service := NewPurchaseOrderServiceImpl(repo, products)
+
+	supplier, err := service.CreateSupplier(Supplier{Name: "Acme", Email: "orders@acme.example"})
+	assert.NoError(t, err, "create supplier should succeed")
+	order, err := service.CreatePurchaseOrder(PurchaseOrder{
+		SupplierId: supplier.Id,
+		Reference:  "PO-1",
+		Lines:      []PurchaseOrderLine{{ProductId: 1, Quantity: 10, Received: 10}, {ProductId: 2, Quantity: 5}},
+	})
+	assert.NoError(t, err, "create purchase order should succeed")
+	return service, products, order
+}
+
+func TestPurchaseOrderServiceImpl_Receive(t *testing.T) {
+	service, products, order := setupPurchaseOrders(t)
+	assert.Equal(t, PurchaseOrderDraft, order.Status)
+	assert.Equal(t, 0, order.Lines[0].Received, "expect a new order to have received nothing")
+
+	receipt := PurchaseOrderReceipt{Lines: []PurchaseOrderReceiptLine{{LineId: order.Lines[0].Id, Quantity: 4}}}
+	_, err := service.ReceivePurchaseOrder(order.Id, receipt)
+	var te *transitionError
+	assert.ErrorAs(t, err, &te, "expect a draft not to be received")
+
+	sent, err := service.SendPurchaseOrder(order.Id)
+	assert.NoError(t, err, "send should succeed")
+	assert.Equal(t, PurchaseOrderSent, sent.Status)
+	_, err = service.SendPurchaseOrder(order.Id)
+	assert.ErrorAs(t, err, &te, "expect an order to be sent once")
+
+	received, err := service.ReceivePurchaseOrder(order.Id, receipt)
+	assert.NoError(t, err, "receive should succeed")
+	assert.Equal(t, PurchaseOrderPartiallyReceived, received.Status)
+	assert.Equal(t, 4, received.Lines[0].Received)
+
+	product, _ := products.GetById(1)
+	assert.Equal(t, 5, product.Quantity, "expect the received quantity to be added")
+	movements, _ := products.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		movement := movements[len(movements)-1]
+		assert.Equal(t, MovementReceipt, movement.Type)
+		assert.Equal(t, "PO-1", movement.Reference, "expect the movement to carry the order reference")
+	}
+
+	_, err = service.ReceivePurchaseOrder(order.Id, PurchaseOrderReceipt{Lines: []PurchaseOrderReceiptLine{{LineId: order.Lines[1].Id, Quantity: 6}}})
+	var ve *validationError
+	assert.ErrorAs(t, err, &ve, "expect over-receipt to fail validation")
+
+	received, err = service.ReceivePurchaseOrder(order.Id, PurchaseOrderReceipt{Lines: []PurchaseOrderReceiptLine{
+		{LineId: order.Lines[0].Id, Quantity: 6},
+		{LineId: order.Lines[1].Id, Quantity: 5},
+	}})
+	assert.NoError(t, err, "receive should succeed")
+	assert.Equal(t, PurchaseOrderClosed, received.Status, "expect a fully received order to close")
+
+	product, _ = products.GetById(2)
+	assert.Equal(t, 5, product.Quantity)
+	_, err = service.ClosePurchaseOrder(order.Id)
+	assert.ErrorAs(t, err, &te, "expect a closed order not to be closed again")
+}
+
+func TestPurchaseOrderServiceImpl_ReceiveRollsBack(t *testing.T) {
+	service, products, order := setupPurchaseOrders(t)
+	_, err := service.SendPurchaseOrder(order.Id)
+	assert.NoError(t, err, "send should succeed")
+
+	_, err = service.ReceivePurchaseOrder(order.Id, PurchaseOrderReceipt{
+		LocationId: 7,
+		Lines:      []PurchaseOrderReceiptLine{{LineId: order.Lines[0].Id, Quantity: 1}},
+	})
+	assert.ErrorIs(t, err, errLocationNotFound)
+
+	stored, _ := service.GetPurchaseOrderById(order.Id)
+	assert.Equal(t, PurchaseOrderSent, stored.Status, "expect a failed receipt to leave the order")
+	assert.Equal(t, 0, stored.Lines[0].Received)
+	product, _ := products.GetById(1)
+	assert.Equal(t, 1, product.Quantity, "expect a failed receipt to leave the stock")
+}
+
+func TestPurchaseOrderServiceImpl_Update(t *testing.T) {
+	service, _, order := setupPurchaseOrders(t)
+
+	order.Reference = "PO-1b"
+	order.Lines = []PurchaseOrderLine{{Id: order.Lines[1].Id, ProductId: 2, Quantity: 8}, {ProductId: 1, Quantity: 2}}
+	updated, err := service.UpdatePurchaseOrder(order)
+	assert.NoError(t, err, "update should succeed")
+	assert.Equal(t, "PO-1b", updated.Reference)
+	assert.Equal(t, PurchaseOrderDraft, updated.Status)
+	assert.Len(t, updated.Lines, 2)
+
+	order.Lines = []PurchaseOrderLine{{Id: 99, ProductId: 2, Quantity: 1}}
+	_, err = service.UpdatePurchaseOrder(order)
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "expect a foreign line to fail validation") {
+		assert.Equal(t, []string{"line 1: Id 99 is not a line of the purchase order"}, ve.failures)
+	}
+
+	_, err = service.ClosePurchaseOrder(order.Id)
+	assert.NoError(t, err, "close should succeed")
+	order.Lines = []PurchaseOrderLine{{ProductId: 2, Quantity: 1}}
+	_, err = service.UpdatePurchaseOrder(order)
+	var te *transitionError
+	assert.ErrorAs(t, err, &te, "expect only drafts to be edited")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePurchaseOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		order        PurchaseOrder
+		wantFailures []string
+	}{
+		{
+			name:  "valid order",
+			order: PurchaseOrder{SupplierId: 1, Lines: []PurchaseOrderLine{{ProductId: 1, Quantity: 10, UnitCost: 2.5}}},
+		},
+		{
+			name:         "empty order",
+			order:        PurchaseOrder{},
+			wantFailures: []string{"SupplierId should be greater than 0", "Lines should not be empty"},
+		},
+		{
+			name:  "invalid lines",
+			order: PurchaseOrder{SupplierId: 1, Lines: []PurchaseOrderLine{{ProductId: 1, Quantity: 1}, {Quantity: 0, UnitCost: -1}}},
+			wantFailures: []string{
+				"line 2: ProductId should be greater than 0",
+				"line 2: Quantity should be greater than 0",
+				"line 2: UnitCost should not be less than 0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePurchaseOrder(tt.order)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestValidateReceipt(t *testing.T) {
+	order := PurchaseOrder{Lines: []PurchaseOrderLine{{Id: 1, Quantity: 10, Received: 4}, {Id: 2, Quantity: 5}}}
+	tests := []struct {
+		name         string
+		receipt      PurchaseOrderReceipt
+		wantFailures []string
+	}{
+		{
+			name:    "outstanding quantities",
+			receipt: PurchaseOrderReceipt{Lines: []PurchaseOrderReceiptLine{{LineId: 1, Quantity: 6}, {LineId: 2, Quantity: 1}}},
+		},
+		{
+			name:         "no lines",
+			receipt:      PurchaseOrderReceipt{},
+			wantFailures: []string{"Lines should not be empty"},
+		},
+		{
+			name:         "unknown line",
+			receipt:      PurchaseOrderReceipt{Lines: []PurchaseOrderReceiptLine{{LineId: 3, Quantity: 1}, {LineId: 2, Quantity: 0}}},
+			wantFailures: []string{"line 1: LineId 3 is not a line of the purchase order", "line 2: Quantity should be greater than 0"},
+		},
+		{
+			name:         "more than outstanding over several entries",
+			receipt:      PurchaseOrderReceipt{Lines: []PurchaseOrderReceiptLine{{LineId: 1, Quantity: 5}, {LineId: 1, Quantity: 2}}},
+			wantFailures: []string{"line 2: Quantity should not be more than the 1 outstanding"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReceipt(order, tt.receipt)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestParsePurchaseOrderFilter(t *testing.T) {
+	filter, err := parsePurchaseOrderFilter(url.Values{"supplierId": {"3"}, "status": {PurchaseOrderSent}})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, PurchaseOrderFilter{SupplierId: 3, Status: PurchaseOrderSent}, filter)
+
+	_, err = parsePurchaseOrderFilter(url.Values{"supplierId": {"x"}, "status": {"open"}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{
+			"supplierId should be an integer",
+			"status should be one of draft, sent, partially_received, closed",
+		}, ve.failures)
+	}
+}
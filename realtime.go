@@ -1,12 +1,163 @@
 package main
 
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	EventSnapshot       = "snapshot"
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+	EventStockLow       = "stock.low"
+	EventStockOut       = "stock.out"
+)
+
+// Event is a change to the catalog. Seq increases with every change but may
+// skip numbers, so a gap does not mean an event was missed; a snapshot
+// carries the whole catalog as of Seq, later events apply on top of it. Stock
+// alerts follow the product.updated event of the change that set them off and
+// leave the catalog as it is.
+type Event struct {
+	Seq       int64     `json:"seq"`
+	Type      string    `json:"type"`
+	Product   *Product  `json:"product,omitempty"`
+	Products  []Product `json:"products,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type Publisher interface {
 	subscribe(Subscriber) error
+	resume(subscriber Subscriber, since int64) error
+	setFilter(subscriber Subscriber, name string, filter EventFilter) error
+	removeFilter(subscriber Subscriber, name string) error
 	unsubscribe(Subscriber) error
-	notify()
+	publish(eventType string, product Product)
+}
+
+// Relay passes events between the instances of the server, so subscribers
+// of one instance hear about changes made through another.
+type Relay interface {
+	// Send passes an event published by this instance to the others.
+	Send(Event) error
+	// Receive calls fn with every event another instance sent until ctx is
+	// done. Events this instance sent are not passed back.
+	Receive(ctx context.Context, fn func(Event)) error
 }
 
+// Subscriber receives a snapshot when it subscribes and every event after it.
 type Subscriber interface {
-	Update([]Product)
+	Update(Event)
 	Id() string
 }
+
+// EventFilter picks the products a subscriber wants events for. Zero values
+// mean "no filter", like in ProductQuery.
+type EventFilter struct {
+	Category    string `json:"category,omitempty"`
+	Brand       string `json:"brand,omitempty"`
+	Ids         []int  `json:"ids,omitempty"`
+	MinQuantity *int   `json:"minQuantity,omitempty"`
+	MaxQuantity *int   `json:"maxQuantity,omitempty"`
+}
+
+func validateEventFilter(filter EventFilter) error {
+	failures := make([]string, 0)
+	for _, id := range filter.Ids {
+		if id <= 0 {
+			failures = append(failures, fmt.Sprintf("ids should be greater than 0, got %d", id))
+		}
+	}
+	if filter.MinQuantity != nil && filter.MaxQuantity != nil && *filter.MinQuantity > *filter.MaxQuantity {
+		failures = append(failures, "minQuantity should not be greater than maxQuantity")
+	}
+	if len(failures) > 0 {
+		return &validationError{failures: failures}
+	}
+	return nil
+}
+
+func (f EventFilter) matches(product Product) bool {
+	if f.Category != "" && product.Category != f.Category {
+		return false
+	}
+	if f.Brand != "" && product.Brand != f.Brand {
+		return false
+	}
+	if len(f.Ids) > 0 && !containsId(f.Ids, product.Id) {
+		return false
+	}
+	if f.MinQuantity != nil && product.Quantity < *f.MinQuantity {
+		return false
+	}
+	if f.MaxQuantity != nil && product.Quantity > *f.MaxQuantity {
+		return false
+	}
+	return true
+}
+
+func containsId(ids []int, id int) bool {
+	for _, current := range ids {
+		if current == id {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionFilter holds the named filters of one subscriber, which gets
+// the events of products matching any of them. It remembers which products
+// the subscriber has, so an update that moves a product out of the filters
+// still reaches the subscriber, and later events for it no longer do.
+type subscriptionFilter struct {
+	filters map[string]EventFilter
+	visible map[int]bool
+}
+
+func (f *subscriptionFilter) matches(product Product) bool {
+	for _, filter := range f.filters {
+		if filter.matches(product) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns the matching products and starts tracking them.
+func (f *subscriptionFilter) snapshot(products []Product) []Product {
+	f.visible = make(map[int]bool)
+	matching := make([]Product, 0)
+	for _, product := range products {
+		if f.matches(product) {
+			f.visible[product.Id] = true
+			matching = append(matching, product)
+		}
+	}
+	return matching
+}
+
+// admit reports whether event should be sent to the subscriber.
+func (f *subscriptionFilter) admit(event Event) bool {
+	if event.Product == nil {
+		return false
+	}
+	id := event.Product.Id
+	wasVisible := f.visible[id]
+	switch event.Type {
+	case EventProductCreated, EventProductUpdated:
+		if f.matches(*event.Product) {
+			f.visible[id] = true
+			return true
+		}
+		delete(f.visible, id)
+		return wasVisible
+	case EventProductDeleted:
+		delete(f.visible, id)
+		return wasVisible
+	case EventStockLow, EventStockOut:
+		return wasVisible
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFilter_matches(t *testing.T) {
+	product := Product{Id: 2, Brand: "Samsung", Category: "Phone", Quantity: 5}
+	one, ten := 1, 10
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   bool
+	}{
+		{name: "empty filter", filter: EventFilter{}, want: true},
+		{name: "category", filter: EventFilter{Category: "Phone"}, want: true},
+		{name: "other category", filter: EventFilter{Category: "Laptop"}, want: false},
+		{name: "brand and category", filter: EventFilter{Brand: "Samsung", Category: "Phone"}, want: true},
+		{name: "ids", filter: EventFilter{Ids: []int{1, 2}}, want: true},
+		{name: "other ids", filter: EventFilter{Ids: []int{1, 3}}, want: false},
+		{name: "quantity in range", filter: EventFilter{MinQuantity: &one, MaxQuantity: &ten}, want: true},
+		{name: "quantity above max", filter: EventFilter{MaxQuantity: &one}, want: false},
+		{name: "quantity below min", filter: EventFilter{MinQuantity: &ten}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.matches(product))
+		})
+	}
+}
+
+func TestValidateEventFilter(t *testing.T) {
+	one, ten := 1, 10
+
+	err := validateEventFilter(EventFilter{Ids: []int{0}, MinQuantity: &ten, MaxQuantity: &one})
+
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{
+			"ids should be greater than 0, got 0",
+			"minQuantity should not be greater than maxQuantity",
+		}, ve.failures)
+	}
+	assert.NoError(t, validateEventFilter(EventFilter{Category: "Phone", MinQuantity: &one}))
+}
+
+func TestSubscriptionFilter_admit(t *testing.T) {
+	filter := &subscriptionFilter{filters: map[string]EventFilter{
+		"phones": {Category: "Phone"},
+		"watch":  {Ids: []int{9}},
+	}}
+	snapshot := filter.snapshot([]Product{
+		{Id: 1, Category: "Phone"},
+		{Id: 2, Category: "Laptop"},
+		{Id: 9, Category: "Watch"},
+	})
+	assert.Equal(t, []Product{{Id: 1, Category: "Phone"}, {Id: 9, Category: "Watch"}}, snapshot, "expect products matching any filter")
+
+	event := func(eventType string, product Product) Event {
+		return Event{Type: eventType, Product: &product}
+	}
+	steps := []struct {
+		name  string
+		event Event
+		want  bool
+	}{
+		{name: "matching product created", event: event(EventProductCreated, Product{Id: 3, Category: "Phone"}), want: true},
+		{name: "other product created", event: event(EventProductCreated, Product{Id: 4, Category: "Laptop"}), want: false},
+		{name: "other product updated", event: event(EventProductUpdated, Product{Id: 2, Category: "Laptop"}), want: false},
+		{name: "product moved into the filter", event: event(EventProductUpdated, Product{Id: 2, Category: "Phone"}), want: true},
+		{name: "product moved out of the filter", event: event(EventProductUpdated, Product{Id: 1, Category: "Laptop"}), want: true},
+		{name: "product no longer tracked", event: event(EventProductUpdated, Product{Id: 1, Category: "Tablet"}), want: false},
+		{name: "untracked product deleted", event: event(EventProductDeleted, Product{Id: 1, Category: "Tablet"}), want: false},
+		{name: "tracked product deleted", event: event(EventProductDeleted, Product{Id: 9, Category: "Watch"}), want: true},
+		{name: "tracked product low", event: event(EventStockLow, Product{Id: 2, Category: "Phone"}), want: true},
+		{name: "untracked product out", event: event(EventStockOut, Product{Id: 4, Category: "Laptop"}), want: false},
+	}
+	for _, step := range steps {
+		assert.Equal(t, step.want, filter.admit(step.event), step.name)
+	}
+}
@@ -2,47 +2,218 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
 )
 
 var (
-	errProductNotFound = errors.New("product not found")
-	errDuplicateId     = errors.New("found duplicate id")
-	errEmptyId         = errors.New("id should not be empty")
+	errProductNotFound   = errors.New("product not found")
+	errDuplicateId       = errors.New("found duplicate id")
+	errDuplicateSku      = errors.New("found duplicate sku")
+	errVersionConflict   = errors.New("product was modified concurrently")
+	errEmptyId           = errors.New("id should not be empty")
+	errInsufficientStock = errors.New("insufficient stock")
 )
 
+// Repo stores products. Update, UpdateColumns and Delete reject a product
+// whose version is not the stored one with errVersionConflict; a zero version
+// skips the check.
 type Repo interface {
+	NextId() (int, error)
 	Create(Product) error
 	Update(Product) error
+	// UpdateColumns only writes the given columns, out of brand, category,
+	// price, reorder_point and reorder_quantity, together with updated_at and
+	// the version.
+	UpdateColumns(product Product, columns []string) error
 	GetById(id int) (Product, error)
+	GetBySku(sku string) (Product, error)
 	GetAll() ([]Product, error)
-	Delete(id int) error
+	// GetLowStock returns the products whose stock position is at or below
+	// their reorder point, out of stock ones included, by id.
+	GetLowStock() ([]Product, error)
+	List(ProductQuery) (ProductPage, error)
+	// Export calls fn with every product matching the filters of query, in
+	// its order, stopping at the first error. Paging is ignored.
+	Export(query ProductQuery, fn func(Product) error) error
+	Delete(id, version int) error
+	AddMovement(StockMovement) (StockMovement, error)
+	GetMovements(productId int) ([]StockMovement, error)
+	// InTx runs fn against a repo whose writes are kept only when fn returns
+	// nil. InTx may be nested, an inner call only rolls back its own writes.
+	InTx(fn func(Repo) error) error
+	// AddToOutbox stores an event in the outbox, see Outbox.
+	AddToOutbox(Event) error
+	WarehouseRepo
+	ReservationRepo
+	PurchaseOrderRepo
+	SalesOrderRepo
+	RmaRepo
+	TransferOrderRepo
+	LotRepo
 }
 
+// InMemoryRepo takes mu in every exported method: for reading in the getters
+// and for writing everywhere else, see read and write. A transaction holds it
+// for writing until it is done, see InTx.
 type InMemoryRepo struct {
-	products []Product
+	mu *sync.RWMutex
+	// held is set on the views of the repo handed out while mu is held, their
+	// methods do not take it again
+	held bool
+	*inMemoryState
+}
+
+// inMemoryState is what the in-memory repo stores, kept apart from mu so a
+// failed transaction can put it back as a whole.
+type inMemoryState struct {
+	products            []Product
+	lastId              int
+	movements           []StockMovement
+	lastMovementId      int
+	warehouses          []Warehouse
+	lastWarehouseId     int
+	locations           []Location
+	lastLocationId      int
+	stockLevels         []StockLevel
+	searchIndex         *invertedIndex
+	events              []Event
+	lastEventSeq        int64
+	trimmedEventSeq     int64
+	webhooks            []Webhook
+	lastWebhookId       int
+	deliveries          []WebhookDelivery
+	lastDeliveryId      int
+	outbox              []OutboxEntry
+	lastOutboxId        int64
+	reservations        []Reservation
+	lastReservationId   int
+	suppliers           []Supplier
+	lastSupplierId      int
+	purchaseOrders      []PurchaseOrder
+	lastPurchaseOrderId int
+	salesOrders         []SalesOrder
+	lastSalesOrderId    int
+	rmas                []Rma
+	lastRmaId           int
+	transferOrders      []TransferOrder
+	lastTransferOrderId int
+	lots                []Lot
+	lastLotId           int
+	// lines are numbered across orders, like their serial column in postgres
+	lastPurchaseOrderLineId int
+	lastSalesOrderLineId    int
+	lastRmaLineId           int
+	lastTransferOrderLineId int
 }
 
 func NewInMemoryRepo() *InMemoryRepo {
 	return &InMemoryRepo{
-		products: make([]Product, 0),
+		mu: &sync.RWMutex{},
+		inMemoryState: &inMemoryState{
+			products:       make([]Product, 0),
+			movements:      make([]StockMovement, 0),
+			warehouses:     make([]Warehouse, 0),
+			locations:      make([]Location, 0),
+			stockLevels:    make([]StockLevel, 0),
+			webhooks:       make([]Webhook, 0),
+			deliveries:     make([]WebhookDelivery, 0),
+			outbox:         make([]OutboxEntry, 0),
+			reservations:   make([]Reservation, 0),
+			suppliers:      make([]Supplier, 0),
+			purchaseOrders: make([]PurchaseOrder, 0),
+			salesOrders:    make([]SalesOrder, 0),
+			rmas:           make([]Rma, 0),
+			transferOrders: make([]TransferOrder, 0),
+			lots:           make([]Lot, 0),
+			searchIndex:    newInvertedIndex(),
+		},
+	}
+}
+
+// NextId hands out ids from a counter, skipping ids clients picked themselves.
+func (r *InMemoryRepo) NextId() (int, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for {
+		r.lastId++
+		if _, err := r.GetById(r.lastId); errors.Is(err, errProductNotFound) {
+			return r.lastId, nil
+		}
 	}
 }
 
 func (r *InMemoryRepo) Create(product Product) error {
+	r, unlock := r.write()
+	defer unlock()
+
 	for _, currentProduct := range r.products {
 		if currentProduct.Id == product.Id {
 			return errDuplicateId
 		}
+		if product.Sku != "" && currentProduct.Sku == product.Sku {
+			return errDuplicateSku
+		}
 	}
 	r.products = append(r.products, product)
+	r.searchIndex.add(product.Id, searchableText(product))
 	return nil
 }
 
 func (r *InMemoryRepo) Update(product Product) error {
+	r, unlock := r.write()
+	defer unlock()
+
 	for idx, currentProduct := range r.products {
 		if currentProduct.Id == product.Id {
+			if product.Version != 0 && product.Version != currentProduct.Version {
+				return errVersionConflict
+			}
 			product.CreatedAt = currentProduct.CreatedAt
+			product.Quantity = currentProduct.Quantity
+			product.Reserved = currentProduct.Reserved
+			product.InTransit = currentProduct.InTransit
+			product.Sku = currentProduct.Sku
+			product.Version = currentProduct.Version + 1
 			r.products[idx] = product
+			r.searchIndex.add(product.Id, searchableText(product))
+			return nil
+		}
+	}
+	return errProductNotFound
+}
+
+func (r *InMemoryRepo) UpdateColumns(product Product, columns []string) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentProduct := range r.products {
+		if currentProduct.Id == product.Id {
+			if product.Version != 0 && product.Version != currentProduct.Version {
+				return errVersionConflict
+			}
+			for _, column := range columns {
+				switch column {
+				case "brand":
+					currentProduct.Brand = product.Brand
+				case "category":
+					currentProduct.Category = product.Category
+				case "price":
+					currentProduct.Price = product.Price
+				case "reorder_point":
+					currentProduct.ReorderPoint = product.ReorderPoint
+				case "reorder_quantity":
+					currentProduct.ReorderQuantity = product.ReorderQuantity
+				default:
+					return fmt.Errorf("column %q cannot be updated", column)
+				}
+			}
+			currentProduct.UpdatedAt = product.UpdatedAt
+			currentProduct.Version++
+			r.products[idx] = currentProduct
+			r.searchIndex.add(currentProduct.Id, searchableText(currentProduct))
 			return nil
 		}
 	}
@@ -50,6 +221,9 @@ func (r *InMemoryRepo) Update(product Product) error {
 }
 
 func (r *InMemoryRepo) GetById(id int) (Product, error) {
+	r, unlock := r.read()
+	defer unlock()
+
 	for _, currentProduct := range r.products {
 		if currentProduct.Id == id {
 			return currentProduct, nil
@@ -58,18 +232,351 @@ func (r *InMemoryRepo) GetById(id int) (Product, error) {
 	return Product{}, errProductNotFound
 }
 
+func (r *InMemoryRepo) GetBySku(sku string) (Product, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentProduct := range r.products {
+		if currentProduct.Sku == sku {
+			return currentProduct, nil
+		}
+	}
+	return Product{}, errProductNotFound
+}
+
 func (r *InMemoryRepo) GetAll() ([]Product, error) {
+	r, unlock := r.read()
+	defer unlock()
+
 	products := make([]Product, len(r.products))
 	copy(products, r.products)
 	return products, nil
 }
 
-func (r *InMemoryRepo) Delete(id int) error {
+func (r *InMemoryRepo) GetLowStock() ([]Product, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	products := make([]Product, 0)
+	for _, currentProduct := range r.products {
+		if isLowStock(currentProduct) {
+			products = append(products, currentProduct)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Id < products[j].Id
+	})
+	return products, nil
+}
+
+func (r *InMemoryRepo) List(query ProductQuery) (ProductPage, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	matching := make([]Product, 0)
+	for _, currentProduct := range r.products {
+		if query.matches(currentProduct) {
+			matching = append(matching, currentProduct)
+		}
+	}
+	query.sortProducts(matching)
+
+	page := ProductPage{
+		Products:   make([]Product, 0),
+		Total:      len(matching),
+		NextCursor: nextCursor(query, len(matching)),
+	}
+	if query.Offset < len(matching) {
+		end := len(matching)
+		if query.Limit > 0 && query.Offset+query.Limit < end {
+			end = query.Offset + query.Limit
+		}
+		page.Products = append(page.Products, matching[query.Offset:end]...)
+	}
+	return page, nil
+}
+
+func (r *InMemoryRepo) Search(query string, limit int) ([]SearchResult, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	ranks := r.searchIndex.search(tokenize(query))
+
+	results := make([]SearchResult, 0, len(ranks))
+	for _, currentProduct := range r.products {
+		if rank, ok := ranks[currentProduct.Id]; ok {
+			results = append(results, SearchResult{Product: currentProduct, Rank: rank})
+		}
+	}
+	sortSearchResults(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Export calls fn for every product matching query, in query order. The in
+// memory repo has nothing to stream from, so it reads one unpaged List.
+func (r *InMemoryRepo) Export(query ProductQuery, fn func(Product) error) error {
+	query.Limit, query.Offset = 0, 0
+	page, err := r.List(query)
+	if err != nil {
+		return err
+	}
+	for _, product := range page.Products {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InTx runs one transaction at a time and keeps readers out while it runs,
+// so the checks a transaction makes hold until it is done and nobody sees
+// its changes before that. fn gets a view of the repo that works with mu
+// held, InTx on it nests instead of waiting for the running transaction.
+func (r *InMemoryRepo) InTx(fn func(Repo) error) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	snapshot := r.snapshot()
+	if err := fn(r); err != nil {
+		*r.inMemoryState = snapshot
+		r.reindex()
+		return err
+	}
+	return nil
+}
+
+// read takes mu for reading, unless it is held already, and returns a view
+// of the repo along with the matching unlock. Methods shadow r with the
+// view, so the methods they call through it do not take mu again.
+func (r *InMemoryRepo) read() (*InMemoryRepo, func()) {
+	if r.held {
+		return r, func() {}
+	}
+	r.mu.RLock()
+	return &InMemoryRepo{mu: r.mu, held: true, inMemoryState: r.inMemoryState}, r.mu.RUnlock
+}
+
+// write is read for methods that change the state.
+func (r *InMemoryRepo) write() (*InMemoryRepo, func()) {
+	if r.held {
+		return r, func() {}
+	}
+	r.mu.Lock()
+	return &InMemoryRepo{mu: r.mu, held: true, inMemoryState: r.inMemoryState}, r.mu.Unlock
+}
+
+// snapshot copies the repo state so a failed InTx can put it back.
+func (r *InMemoryRepo) snapshot() inMemoryState {
+	snapshot := *r.inMemoryState
+	snapshot.products = append([]Product(nil), r.products...)
+	snapshot.movements = append([]StockMovement(nil), r.movements...)
+	snapshot.warehouses = append([]Warehouse(nil), r.warehouses...)
+	snapshot.locations = append([]Location(nil), r.locations...)
+	snapshot.stockLevels = append([]StockLevel(nil), r.stockLevels...)
+	snapshot.events = append([]Event(nil), r.events...)
+	snapshot.webhooks = append([]Webhook(nil), r.webhooks...)
+	snapshot.deliveries = append([]WebhookDelivery(nil), r.deliveries...)
+	snapshot.outbox = append([]OutboxEntry(nil), r.outbox...)
+	snapshot.reservations = append([]Reservation(nil), r.reservations...)
+	snapshot.suppliers = append([]Supplier(nil), r.suppliers...)
+	snapshot.purchaseOrders = append([]PurchaseOrder(nil), r.purchaseOrders...)
+	snapshot.salesOrders = append([]SalesOrder(nil), r.salesOrders...)
+	snapshot.rmas = append([]Rma(nil), r.rmas...)
+	snapshot.transferOrders = append([]TransferOrder(nil), r.transferOrders...)
+	snapshot.lots = append([]Lot(nil), r.lots...)
+	return snapshot
+}
+
+// reindex rebuilds the search index from the stored products.
+func (r *InMemoryRepo) reindex() {
+	r.searchIndex = newInvertedIndex()
+	for _, currentProduct := range r.products {
+		r.searchIndex.add(currentProduct.Id, searchableText(currentProduct))
+	}
+}
+
+func (r *InMemoryRepo) Delete(id, version int) error {
+	r, unlock := r.write()
+	defer unlock()
+
 	for idx, currentProduct := range r.products {
 		if currentProduct.Id == id {
+			if version != 0 && version != currentProduct.Version {
+				return errVersionConflict
+			}
 			r.products = append(r.products[:idx], r.products[idx+1:]...)
+			r.deleteProductStock(id)
+			r.searchIndex.remove(id)
 			return nil
 		}
 	}
 	return errProductNotFound
 }
+
+func (r *InMemoryRepo) AddMovement(movement StockMovement) (StockMovement, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentProduct := range r.products {
+		if currentProduct.Id == movement.ProductId {
+			quantity := currentProduct.Quantity + movement.delta()
+			// stock held by reservations cannot be taken out
+			if quantity < 0 || (movement.delta() < 0 && quantity < currentProduct.Reserved) {
+				return StockMovement{}, errInsufficientStock
+			}
+			inTransit := currentProduct.InTransit + movement.transitDelta()
+			if inTransit < 0 {
+				return StockMovement{}, errInsufficientStock
+			}
+
+			// every check happens before anything is written so a failed
+			// movement leaves product and bins untouched
+			locationDelta := movement.delta()
+			if movement.Type == MovementTransfer {
+				locationDelta = -movement.Quantity
+			}
+			if movement.LocationId != 0 {
+				if _, err := r.GetLocationById(movement.LocationId); err != nil {
+					return StockMovement{}, err
+				}
+				if r.stockLevel(movement.ProductId, movement.LocationId)+locationDelta < 0 {
+					return StockMovement{}, errInsufficientStock
+				}
+			}
+			if movement.ToLocationId != 0 {
+				if _, err := r.GetLocationById(movement.ToLocationId); err != nil {
+					return StockMovement{}, err
+				}
+			}
+			lotIdxs := make([]int, len(movement.Lots))
+			for idx, lot := range movement.Lots {
+				lotIdx := r.lotIndex(movement.ProductId, lot.LotId)
+				if lotIdx < 0 {
+					return StockMovement{}, errLotNotFound
+				}
+				if r.lots[lotIdx].Quantity+movement.lotDelta(lot.Quantity) < 0 {
+					return StockMovement{}, errInsufficientStock
+				}
+				lotIdxs[idx] = lotIdx
+			}
+
+			r.products[idx].Quantity = quantity
+			r.products[idx].InTransit = inTransit
+			r.products[idx].UpdatedAt = movement.CreatedAt
+			r.products[idx].Version++
+			if movement.LocationId != 0 {
+				r.adjustStockLevel(movement.ProductId, movement.LocationId, locationDelta)
+			}
+			if movement.ToLocationId != 0 {
+				r.adjustStockLevel(movement.ProductId, movement.ToLocationId, movement.Quantity)
+			}
+			for idx, lot := range movement.Lots {
+				r.lots[lotIdxs[idx]].Quantity += movement.lotDelta(lot.Quantity)
+			}
+			movement.Lots = append([]LotQuantity(nil), movement.Lots...)
+
+			r.lastMovementId++
+			movement.Id = r.lastMovementId
+			r.movements = append(r.movements, movement)
+			return movement, nil
+		}
+	}
+	return StockMovement{}, errProductNotFound
+}
+
+func (r *InMemoryRepo) GetMovements(productId int) ([]StockMovement, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	movements := make([]StockMovement, 0)
+	for _, movement := range r.movements {
+		if movement.ProductId == productId {
+			movements = append(movements, movement)
+		}
+	}
+	return movements, nil
+}
+
+// deleteProductStock drops the ledger, bin levels, reservations and order
+// lines of a deleted product, the same way the foreign keys cascade in postgres.
+func (r *InMemoryRepo) deleteProductStock(productId int) {
+	movements := make([]StockMovement, 0, len(r.movements))
+	for _, movement := range r.movements {
+		if movement.ProductId != productId {
+			movements = append(movements, movement)
+		}
+	}
+	r.movements = movements
+
+	stockLevels := make([]StockLevel, 0, len(r.stockLevels))
+	for _, stockLevel := range r.stockLevels {
+		if stockLevel.ProductId != productId {
+			stockLevels = append(stockLevels, stockLevel)
+		}
+	}
+	r.stockLevels = stockLevels
+
+	lots := make([]Lot, 0, len(r.lots))
+	for _, lot := range r.lots {
+		if lot.ProductId != productId {
+			lots = append(lots, lot)
+		}
+	}
+	r.lots = lots
+
+	reservations := make([]Reservation, 0, len(r.reservations))
+	for _, reservation := range r.reservations {
+		if reservation.ProductId != productId {
+			reservations = append(reservations, reservation)
+		}
+	}
+	r.reservations = reservations
+
+	for idx, order := range r.purchaseOrders {
+		lines := make([]PurchaseOrderLine, 0, len(order.Lines))
+		for _, line := range order.Lines {
+			if line.ProductId != productId {
+				lines = append(lines, line)
+			}
+		}
+		r.purchaseOrders[idx].Lines = lines
+	}
+
+	for idx, order := range r.salesOrders {
+		lines := make([]SalesOrderLine, 0, len(order.Lines))
+		for _, line := range order.Lines {
+			if line.ProductId != productId {
+				lines = append(lines, line)
+			}
+		}
+		r.salesOrders[idx].Lines = lines
+	}
+
+	for idx, rma := range r.rmas {
+		lines := make([]RmaLine, 0, len(rma.Lines))
+		for _, line := range rma.Lines {
+			if line.ProductId != productId {
+				lines = append(lines, line)
+			}
+		}
+		r.rmas[idx].Lines = lines
+	}
+
+	for idx, order := range r.transferOrders {
+		lines := make([]TransferOrderLine, 0, len(order.Lines))
+		for _, line := range order.Lines {
+			if line.ProductId != productId {
+				lines = append(lines, line)
+			}
+		}
+		r.transferOrders[idx].Lines = lines
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 func setupInMemoryRepo(existing []Product) *InMemoryRepo {
 	repo := NewInMemoryRepo()
 	repo.products = append(repo.products, existing...)
+	repo.reindex()
 	return repo
 }
 
@@ -148,7 +150,7 @@ func TestInMemoryRepo_Update(t *testing.T) {
 		wantErr      error
 	}{
 		{
-			name: "product updated",
+			name: "product updated, quantity is kept",
 			args: args{
 				product: Product{
 					Id:        2,
@@ -174,8 +176,9 @@ func TestInMemoryRepo_Update(t *testing.T) {
 					Id:        2,
 					Brand:     "B",
 					Category:  "B",
-					Quantity:  2,
+					Quantity:  1,
 					Price:     20,
+					Version:   1,
 					CreatedAt: time.Date(2023, 04, 26, 17, 00, 00, 00, time.UTC),
 					UpdatedAt: time.Date(2023, 04, 26, 19, 00, 00, 00, time.UTC),
 				},
@@ -235,6 +238,7 @@ func TestInMemoryRepo_Update(t *testing.T) {
 					Category:  "B",
 					Quantity:  1,
 					Price:     10,
+					Version:   1,
 					CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC),
 					UpdatedAt: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC),
 				},
@@ -250,6 +254,39 @@ func TestInMemoryRepo_Update(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "stale version",
+			args: args{
+				product: Product{
+					Id:       1,
+					Brand:    "C",
+					Category: "C",
+					Price:    30,
+					Version:  3,
+				},
+			},
+			wantProducts: []Product{
+				{
+					Id:        1,
+					Brand:     "A",
+					Category:  "B",
+					Quantity:  1,
+					Price:     10,
+					CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC),
+					UpdatedAt: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC),
+				},
+				{
+					Id:        2,
+					Brand:     "A",
+					Category:  "B",
+					Quantity:  1,
+					Price:     10,
+					CreatedAt: time.Date(2023, 04, 26, 17, 00, 00, 00, time.UTC),
+					UpdatedAt: time.Date(2023, 04, 26, 18, 00, 00, 00, time.UTC),
+				},
+			},
+			wantErr: errVersionConflict,
+		},
 	}
 
 	for _, tt := range tests {
@@ -264,6 +301,85 @@ func TestInMemoryRepo_Update(t *testing.T) {
 	}
 }
 
+func TestInMemoryRepo_UpdateColumns(t *testing.T) {
+	existing := []Product{
+		{
+			Id:        1,
+			Brand:     "A",
+			Category:  "A",
+			Quantity:  1,
+			Price:     10,
+			Version:   1,
+			UpdatedAt: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC),
+		},
+	}
+	patched := Product{
+		Id:        1,
+		Brand:     "B",
+		Category:  "B",
+		Quantity:  9,
+		Price:     20,
+		Version:   1,
+		UpdatedAt: time.Date(2023, 04, 26, 19, 00, 00, 00, time.UTC),
+	}
+
+	tests := []struct {
+		name        string
+		product     Product
+		columns     []string
+		wantProduct Product
+		wantErr     string
+	}{
+		{
+			name:    "only listed columns are written",
+			product: patched,
+			columns: []string{"price"},
+			wantProduct: Product{
+				Id:        1,
+				Brand:     "A",
+				Category:  "A",
+				Quantity:  1,
+				Price:     20,
+				Version:   2,
+				UpdatedAt: time.Date(2023, 04, 26, 19, 00, 00, 00, time.UTC),
+			},
+		},
+		{
+			name:    "unknown column",
+			product: patched,
+			columns: []string{"quantity"},
+			wantErr: `column "quantity" cannot be updated`,
+		},
+		{
+			name:    "stale version",
+			product: Product{Id: 1, Brand: "B", Version: 3},
+			columns: []string{"brand"},
+			wantErr: errVersionConflict.Error(),
+		},
+		{
+			name:    "product not found",
+			product: Product{Id: 2, Brand: "B"},
+			columns: []string{"brand"},
+			wantErr: errProductNotFound.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+
+			err := repo.UpdateColumns(tt.product, tt.columns)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr, "error should match")
+				assert.Equal(t, existing, repo.products, "expect products to be unchanged")
+				return
+			}
+			assert.NoError(t, err, "update should succeed")
+			assert.Equal(t, []Product{tt.wantProduct}, repo.products, "expect only listed columns to change")
+		})
+	}
+}
+
 func TestInMemoryRepo_Delete(t *testing.T) {
 	existing := []Product{
 		{
@@ -290,7 +406,8 @@ func TestInMemoryRepo_Delete(t *testing.T) {
 	}
 
 	type args struct {
-		id int
+		id      int
+		version int
 	}
 
 	tests := []struct {
@@ -352,12 +469,43 @@ func TestInMemoryRepo_Delete(t *testing.T) {
 			},
 			wantErr: errProductNotFound,
 		},
+		{
+			name: "stale version",
+			args: args{
+				id:      2,
+				version: 5,
+			},
+			wantProducts: []Product{
+				{
+					Id:       1,
+					Brand:    "A",
+					Category: "A",
+					Quantity: 1,
+					Price:    10,
+				},
+				{
+					Id:       2,
+					Brand:    "B",
+					Category: "B",
+					Quantity: 2,
+					Price:    20,
+				},
+				{
+					Id:       3,
+					Brand:    "C",
+					Category: "C",
+					Quantity: 3,
+					Price:    30,
+				},
+			},
+			wantErr: errVersionConflict,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			repo := setupInMemoryRepo(existing)
 
-			err := repo.Delete(tt.args.id)
+			err := repo.Delete(tt.args.id, tt.args.version)
 
 			assert.ErrorIs(t, err, tt.wantErr, "error should match")
 			assert.Equal(t, tt.wantProducts, repo.products, "products should be equal")
@@ -493,3 +641,288 @@ func TestInMemoryRepo_GetAll(t *testing.T) {
 	}
 
 }
+
+func TestInMemoryRepo_AddMovement(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 5,
+			Price:    10,
+		},
+	}
+
+	type args struct {
+		movement StockMovement
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantQuantity  int
+		wantMovements []StockMovement
+		wantErr       error
+	}{
+		{
+			name: "receipt increments quantity",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: MovementReceipt, Quantity: 3, Reason: "delivery"},
+			},
+			wantQuantity: 8,
+			wantMovements: []StockMovement{
+				{Id: 1, ProductId: 1, Type: MovementReceipt, Quantity: 3, Reason: "delivery"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "issue decrements quantity",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 5, Reason: "sold"},
+			},
+			wantQuantity: 0,
+			wantMovements: []StockMovement{
+				{Id: 1, ProductId: 1, Type: MovementIssue, Quantity: 5, Reason: "sold"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "negative adjustment below zero",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: MovementAdjustment, Quantity: -6, Reason: "stocktake"},
+			},
+			wantQuantity:  5,
+			wantMovements: []StockMovement{},
+			wantErr:       errInsufficientStock,
+		},
+		{
+			name: "product not found",
+			args: args{
+				movement: StockMovement{ProductId: 2, Type: MovementReceipt, Quantity: 1, Reason: "delivery"},
+			},
+			wantQuantity:  5,
+			wantMovements: []StockMovement{},
+			wantErr:       errProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+
+			_, err := repo.AddMovement(tt.args.movement)
+
+			assert.ErrorIs(t, err, tt.wantErr, "error should match")
+			assert.Equal(t, tt.wantQuantity, repo.products[0].Quantity, "expect quantity derived from movements")
+			assert.Equal(t, tt.wantMovements, repo.movements, "expect same movements")
+		})
+	}
+}
+
+func TestInMemoryRepo_GetMovements(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{
+		{Id: 1, Brand: "A", Category: "A"},
+		{Id: 2, Brand: "B", Category: "B"},
+	})
+	repo.movements = []StockMovement{
+		{Id: 1, ProductId: 1, Type: MovementReceipt, Quantity: 3, Reason: "delivery"},
+		{Id: 2, ProductId: 2, Type: MovementReceipt, Quantity: 4, Reason: "delivery"},
+		{Id: 3, ProductId: 1, Type: MovementIssue, Quantity: 1, Reason: "sold"},
+	}
+
+	movements, err := repo.GetMovements(1)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, []StockMovement{
+		{Id: 1, ProductId: 1, Type: MovementReceipt, Quantity: 3, Reason: "delivery"},
+		{Id: 3, ProductId: 1, Type: MovementIssue, Quantity: 1, Reason: "sold"},
+	}, movements, "expect movements of product 1 only")
+
+	_, err = repo.GetMovements(3)
+	assert.ErrorIs(t, err, errProductNotFound, "expect not found for unknown product")
+}
+
+func TestInMemoryRepo_List(t *testing.T) {
+	existing := []Product{
+		{
+			Id:        3,
+			Brand:     "A",
+			Category:  "X",
+			Quantity:  30,
+			Price:     30,
+			UpdatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC),
+		},
+		{
+			Id:        1,
+			Brand:     "B",
+			Category:  "X",
+			Quantity:  10,
+			Price:     10,
+			UpdatedAt: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC),
+		},
+		{
+			Id:        2,
+			Brand:     "A",
+			Category:  "Y",
+			Quantity:  20,
+			Price:     20,
+			UpdatedAt: time.Date(2023, 04, 26, 17, 00, 00, 00, time.UTC),
+		},
+	}
+	minPrice := 15.0
+	maxQuantity := 25
+
+	tests := []struct {
+		name           string
+		query          ProductQuery
+		wantIds        []int
+		wantTotal      int
+		wantNextCursor string
+	}{
+		{
+			name:      "default order is by id",
+			query:     ProductQuery{},
+			wantIds:   []int{1, 2, 3},
+			wantTotal: 3,
+		},
+		{
+			name:      "filter by brand",
+			query:     ProductQuery{Brand: "A"},
+			wantIds:   []int{2, 3},
+			wantTotal: 2,
+		},
+		{
+			name:      "price and quantity range",
+			query:     ProductQuery{MinPrice: &minPrice, MaxQuantity: &maxQuantity},
+			wantIds:   []int{2},
+			wantTotal: 1,
+		},
+		{
+			name:      "updated since",
+			query:     ProductQuery{UpdatedSince: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC)},
+			wantIds:   []int{1, 2},
+			wantTotal: 2,
+		},
+		{
+			name:           "first page sorted by price descending",
+			query:          ProductQuery{SortBy: "price", Desc: true, Limit: 2},
+			wantIds:        []int{3, 2},
+			wantTotal:      3,
+			wantNextCursor: encodeCursor(2),
+		},
+		{
+			name:      "last page",
+			query:     ProductQuery{SortBy: "price", Desc: true, Limit: 2, Offset: 2},
+			wantIds:   []int{1},
+			wantTotal: 3,
+		},
+		{
+			name:      "offset past the end",
+			query:     ProductQuery{Limit: 2, Offset: 10},
+			wantIds:   []int{},
+			wantTotal: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+
+			page, err := repo.List(tt.query)
+			assert.NoError(t, err, "expect no error")
+
+			gotIds := make([]int, 0)
+			for _, product := range page.Products {
+				gotIds = append(gotIds, product.Id)
+			}
+			assert.Equal(t, tt.wantIds, gotIds, "expect same products in order")
+			assert.Equal(t, tt.wantTotal, page.Total, "expect same total")
+			assert.Equal(t, tt.wantNextCursor, page.NextCursor, "expect same next cursor")
+			assert.Equal(t, existing, repo.products, "expect stored order untouched")
+		})
+	}
+}
+
+func TestInMemoryRepo_InTx(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Brand: "Samsung", Category: "Phone", Quantity: 1, Price: 10},
+	}
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name          string
+		fn            func(Repo) error
+		wantErr       error
+		wantIds       []int
+		wantMovements int
+		wantSearch    []int
+	}{
+		{
+			name: "writes are kept",
+			fn: func(repo Repo) error {
+				if err := repo.Create(Product{Id: 2, Brand: "Apple", Category: "Phone"}); err != nil {
+					return err
+				}
+				_, err := repo.AddMovement(StockMovement{ProductId: 2, Type: MovementReceipt, Quantity: 2, Reason: "r"})
+				return err
+			},
+			wantIds:       []int{1, 2},
+			wantMovements: 1,
+			wantSearch:    []int{2},
+		},
+		{
+			name: "writes are rolled back",
+			fn: func(repo Repo) error {
+				if err := repo.Create(Product{Id: 2, Brand: "Apple", Category: "Phone"}); err != nil {
+					return err
+				}
+				if _, err := repo.AddMovement(StockMovement{ProductId: 2, Type: MovementReceipt, Quantity: 2, Reason: "r"}); err != nil {
+					return err
+				}
+				return errAbort
+			},
+			wantErr:    errAbort,
+			wantIds:    []int{1},
+			wantSearch: []int{},
+		},
+		{
+			name: "nested rollback only undoes its own writes",
+			fn: func(repo Repo) error {
+				if err := repo.Create(Product{Id: 2, Brand: "Apple", Category: "Phone"}); err != nil {
+					return err
+				}
+				innerErr := repo.InTx(func(repo Repo) error {
+					if err := repo.Delete(1, 0); err != nil {
+						return err
+					}
+					return errAbort
+				})
+				assert.ErrorIs(t, innerErr, errAbort, "expect inner error")
+				return nil
+			},
+			wantIds:    []int{1, 2},
+			wantSearch: []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+
+			err := repo.InTx(tt.fn)
+
+			assert.ErrorIs(t, err, tt.wantErr, "error should match")
+			ids := make([]int, 0)
+			for _, product := range repo.products {
+				ids = append(ids, product.Id)
+			}
+			assert.Equal(t, tt.wantIds, ids, "expect same products")
+			assert.Len(t, repo.movements, tt.wantMovements, "expect same movements")
+
+			found := make([]int, 0)
+			results, searchErr := repo.Search("apple", 10)
+			assert.NoError(t, searchErr, "search should succeed")
+			for _, result := range results {
+				found = append(found, result.Product.Id)
+			}
+			assert.Equal(t, tt.wantSearch, found, "expect search index to follow the products")
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+)
+
+// Statuses of a reservation. Only an active reservation holds stock; it ends
+// confirmed when the stock is taken out, released when it is given back and
+// expired when nobody did either in time.
+const (
+	ReservationActive    = "active"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// defaultReservationTTL is how long a reservation created without ExpiresAt
+// holds its stock.
+const defaultReservationTTL = 15 * time.Minute
+
+// Reservation holds Quantity of a product for a pending order, named by
+// Reference, without taking it out of stock. A reservation without ExpiresAt
+// holds the stock until it is confirmed or released.
+type Reservation struct {
+	Id        int       `json:"id" bun:"id,pk,autoincrement"`
+	ProductId int       `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	Reference string    `json:"reference"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt" bun:",nullzero"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// expired tells whether reservation ran out at now.
+func (r Reservation) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !r.ExpiresAt.After(now)
+}
+
+func validateReservation(reservation Reservation, now time.Time) error {
+	failures := make([]string, 0)
+
+	if reservation.ProductId <= 0 {
+		failures = append(failures, "ProductId should be greater than 0")
+	}
+	if reservation.Quantity <= 0 {
+		failures = append(failures, "Quantity should be greater than 0")
+	}
+	if !reservation.ExpiresAt.IsZero() && !reservation.ExpiresAt.After(now) {
+		failures = append(failures, "ExpiresAt should be in the future")
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type reservationTransport struct {
+	service ReservationService
+}
+
+func NewReservationTransport(svc ReservationService) *reservationTransport {
+	return &reservationTransport{
+		service: svc,
+	}
+}
+
+func (t *reservationTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/reservations", t.Reserve).Methods("POST")
+	r.HandleFunc("/reservations/{id}", t.GetReservationById).Methods("GET")
+	r.HandleFunc("/reservations/{id}/confirm", t.ConfirmReservation).Methods("POST")
+	r.HandleFunc("/reservations/{id}/release", t.ReleaseReservation).Methods("POST")
+	r.HandleFunc("/products/{id}/reservations", t.GetReservations).Methods("GET")
+}
+
+func (t *reservationTransport) Reserve(w http.ResponseWriter, r *http.Request) {
+	var reservation Reservation
+	if err := json.NewDecoder(r.Body).Decode(&reservation); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.Reserve(reservation)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *reservationTransport) GetReservationById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	reservation, err := t.service.GetReservationById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (t *reservationTransport) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	reservation, err := t.service.ConfirmReservation(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (t *reservationTransport) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	reservation, err := t.service.ReleaseReservation(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (t *reservationTransport) GetReservations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	reservations, err := t.service.GetReservations(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservations)
+}
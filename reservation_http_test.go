@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservationTransport(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}})
+	service := NewProductServiceImpl(repo)
+	handler := buildHttpHandler(NewhttpTransport(service), NewReservationTransport(service))
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "invalid reservation",
+			method:         "POST",
+			url:            "/reservations",
+			body:           `{"productId": 1}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Quantity should be greater than 0"]}`,
+		},
+		{
+			name:           "reserve",
+			method:         "POST",
+			url:            "/reservations",
+			body:           `{"productId": 1, "quantity": 2, "reference": "order-1"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "reserve more than available",
+			method:         "POST",
+			url:            "/reservations",
+			body:           `{"productId": 1, "quantity": 4}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["insufficient stock"]}`,
+		},
+		{
+			name:           "reserve unknown product",
+			method:         "POST",
+			url:            "/reservations",
+			body:           `{"productId": 7, "quantity": 1}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["product not found"]}`,
+		},
+		{
+			name:           "reserve again",
+			method:         "POST",
+			url:            "/reservations",
+			body:           `{"productId": 1, "quantity": 3}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "confirm",
+			method:         "POST",
+			url:            "/reservations/1/confirm",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "confirm twice",
+			method:         "POST",
+			url:            "/reservations/1/confirm",
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["reservation is no longer active"]}`,
+		},
+		{
+			name:           "release",
+			method:         "POST",
+			url:            "/reservations/2/release",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "release unknown reservation",
+			method:         "POST",
+			url:            "/reservations/7/release",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["reservation not found"]}`,
+		},
+		{
+			name:           "invalid id",
+			method:         "GET",
+			url:            "/reservations/abc",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid id"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+	var product map[string]interface{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&product), "expect product response")
+	assert.Equal(t, float64(3), product["quantity"], "expect confirmed stock to be taken out")
+	assert.Equal(t, float64(0), product["reserved"], "expect ended reservations to hold nothing")
+	assert.Equal(t, float64(3), product["available"])
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1/reservations", nil))
+	var reservations []Reservation
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&reservations), "expect reservations response")
+	if assert.Len(t, reservations, 2) {
+		assert.Equal(t, ReservationConfirmed, reservations[0].Status)
+		assert.Equal(t, "order-1", reservations[0].Reference)
+		assert.Equal(t, ReservationReleased, reservations[1].Status)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errReservationNotFound  = errors.New("reservation not found")
+	errReservationNotActive = errors.New("reservation is no longer active")
+)
+
+// ReservationRepo stores reservations and keeps Product.Reserved in step with
+// the active ones. Reserving checks and takes the available stock in one
+// step, so concurrent reservations cannot hold more than there is.
+type ReservationRepo interface {
+	// CreateReservation fails with errInsufficientStock when less than the
+	// reserved quantity is available.
+	CreateReservation(Reservation) (Reservation, error)
+	GetReservationById(id int) (Reservation, error)
+	GetReservations(productId int) ([]Reservation, error)
+	// EndReservation moves an active reservation to status at the given time
+	// and gives its stock back, or fails with errReservationNotActive.
+	EndReservation(id int, status string, at time.Time) (Reservation, error)
+	// ExpireReservations ends the active reservations expired at now and
+	// returns them.
+	ExpireReservations(now time.Time) ([]Reservation, error)
+}
+
+func (r *InMemoryRepo) CreateReservation(reservation Reservation) (Reservation, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentProduct := range r.products {
+		if currentProduct.Id == reservation.ProductId {
+			if currentProduct.Quantity-currentProduct.Reserved < reservation.Quantity {
+				return Reservation{}, errInsufficientStock
+			}
+			r.products[idx].Reserved += reservation.Quantity
+			r.products[idx].UpdatedAt = reservation.CreatedAt
+			r.products[idx].Version++
+
+			r.lastReservationId++
+			reservation.Id = r.lastReservationId
+			r.reservations = append(r.reservations, reservation)
+			return reservation, nil
+		}
+	}
+	return Reservation{}, errProductNotFound
+}
+
+func (r *InMemoryRepo) GetReservationById(id int) (Reservation, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentReservation := range r.reservations {
+		if currentReservation.Id == id {
+			return currentReservation, nil
+		}
+	}
+	return Reservation{}, errReservationNotFound
+}
+
+func (r *InMemoryRepo) GetReservations(productId int) ([]Reservation, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	reservations := make([]Reservation, 0)
+	for _, currentReservation := range r.reservations {
+		if currentReservation.ProductId == productId {
+			reservations = append(reservations, currentReservation)
+		}
+	}
+	return reservations, nil
+}
+
+func (r *InMemoryRepo) EndReservation(id int, status string, at time.Time) (Reservation, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentReservation := range r.reservations {
+		if currentReservation.Id == id {
+			if currentReservation.Status != ReservationActive {
+				return Reservation{}, errReservationNotActive
+			}
+			r.reservations[idx].Status = status
+			r.reservations[idx].UpdatedAt = at
+			r.unreserve(currentReservation.ProductId, currentReservation.Quantity, at)
+			return r.reservations[idx], nil
+		}
+	}
+	return Reservation{}, errReservationNotFound
+}
+
+func (r *InMemoryRepo) ExpireReservations(now time.Time) ([]Reservation, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	expired := make([]Reservation, 0)
+	for idx, currentReservation := range r.reservations {
+		if currentReservation.Status == ReservationActive && currentReservation.expired(now) {
+			r.reservations[idx].Status = ReservationExpired
+			r.reservations[idx].UpdatedAt = now
+			r.unreserve(currentReservation.ProductId, currentReservation.Quantity, now)
+			expired = append(expired, r.reservations[idx])
+		}
+	}
+	return expired, nil
+}
+
+func (r *InMemoryRepo) unreserve(productId, quantity int, at time.Time) {
+	for idx, currentProduct := range r.products {
+		if currentProduct.Id == productId {
+			r.products[idx].Reserved -= quantity
+			r.products[idx].UpdatedAt = at
+			r.products[idx].Version++
+			return
+		}
+	}
+}
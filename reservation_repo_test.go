@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_Reservations(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}})
+	now := time.Date(2023, 12, 18, 12, 0, 0, 0, time.UTC)
+
+	first, err := repo.CreateReservation(Reservation{ProductId: 1, Quantity: 3, Status: ReservationActive, ExpiresAt: now.Add(time.Minute), CreatedAt: now})
+	assert.NoError(t, err, "create reservation should succeed")
+	assert.Equal(t, 1, first.Id)
+	_, err = repo.CreateReservation(Reservation{ProductId: 1, Quantity: 3, Status: ReservationActive, ExpiresAt: now.Add(time.Hour), CreatedAt: now})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect reservations not to hold more than is on hand")
+	second, err := repo.CreateReservation(Reservation{ProductId: 1, Quantity: 2, Status: ReservationActive, ExpiresAt: now.Add(time.Hour), CreatedAt: now})
+	assert.NoError(t, err, "create reservation should succeed")
+	_, err = repo.CreateReservation(Reservation{ProductId: 7, Quantity: 1})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	product, _ := repo.GetById(1)
+	assert.Equal(t, 5, product.Reserved, "expect both reservations to hold stock")
+	assert.Equal(t, 3, product.Version, "expect every reservation to bump the version")
+	_, err = repo.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 1, Reason: "sale"})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect reserved stock not to be issued")
+
+	expired, err := repo.ExpireReservations(now.Add(time.Minute))
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, expired, 1, "expect only the due reservation to expire") {
+		assert.Equal(t, first.Id, expired[0].Id)
+		assert.Equal(t, ReservationExpired, expired[0].Status)
+	}
+	_, err = repo.EndReservation(first.Id, ReservationReleased, now)
+	assert.ErrorIs(t, err, errReservationNotActive, "expect an expired reservation not to be released")
+
+	released, err := repo.EndReservation(second.Id, ReservationReleased, now)
+	assert.NoError(t, err, "release should succeed")
+	assert.Equal(t, ReservationReleased, released.Status)
+	_, err = repo.EndReservation(7, ReservationReleased, now)
+	assert.ErrorIs(t, err, errReservationNotFound)
+
+	product, _ = repo.GetById(1)
+	assert.Equal(t, 0, product.Reserved, "expect ended reservations to give their stock back")
+
+	reservations, err := repo.GetReservations(1)
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, reservations, 2)
+	_, err = repo.GetReservations(7)
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	assert.NoError(t, repo.Delete(1, 0), "delete should succeed")
+	_, err = repo.GetReservationById(first.Id)
+	assert.ErrorIs(t, err, errReservationNotFound, "expect reservations to be deleted with the product")
+}
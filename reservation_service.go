@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// reservationSweepInterval is how often RunReservationSweeper looks for
+// expired reservations.
+const reservationSweepInterval = 30 * time.Second
+
+// ReservationService is implemented by the product service: reservations
+// change the available stock of a product, which it publishes with every
+// change.
+type ReservationService interface {
+	Reserve(Reservation) (Reservation, error)
+	ConfirmReservation(id int) (Reservation, error)
+	ReleaseReservation(id int) (Reservation, error)
+	GetReservationById(id int) (Reservation, error)
+	GetReservations(productId int) ([]Reservation, error)
+}
+
+func (s *ProductServiceImpl) Reserve(reservation Reservation) (Reservation, error) {
+	if s.deferred == nil {
+		var created Reservation
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			created, err = tx.Reserve(reservation)
+			return err
+		})
+		return created, err
+	}
+
+	now := time.Now()
+	if err := validateReservation(reservation, now); err != nil {
+		return Reservation{}, fmt.Errorf("create reservation: %w", err)
+	}
+
+	if reservation.ExpiresAt.IsZero() {
+		reservation.ExpiresAt = now.Add(defaultReservationTTL)
+	}
+	return s.hold(reservation, now)
+}
+
+// hold stores reservation as active from now. It has to run bound to a
+// transaction.
+func (s *ProductServiceImpl) hold(reservation Reservation, now time.Time) (Reservation, error) {
+	reservation.Status = ReservationActive
+	reservation.CreatedAt = now
+	reservation.UpdatedAt = now
+
+	current, err := s.repo.GetById(reservation.ProductId)
+	if err != nil {
+		return Reservation{}, err
+	}
+	created, err := s.repo.CreateReservation(reservation)
+	if err != nil {
+		return Reservation{}, err
+	}
+	s.publishStored(EventProductUpdated, current)
+	return created, nil
+}
+
+// ConfirmReservation takes the reserved stock out with an issue movement. A
+// reservation past its expiry can no longer be confirmed, even when the
+// sweeper did not get to it yet.
+func (s *ProductServiceImpl) ConfirmReservation(id int) (Reservation, error) {
+	if s.deferred == nil {
+		var confirmed Reservation
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			confirmed, err = tx.ConfirmReservation(id)
+			return err
+		})
+		return confirmed, err
+	}
+
+	reservation, err := s.repo.GetReservationById(id)
+	if err != nil {
+		return Reservation{}, err
+	}
+	confirmed, _, err := s.takeReserved(reservation, "reservation "+strconv.Itoa(reservation.Id)+" confirmed")
+	return confirmed, err
+}
+
+// takeReserved confirms reservation and issues its stock for reason,
+// returning the issue movement too. It has to run bound to a transaction.
+func (s *ProductServiceImpl) takeReserved(reservation Reservation, reason string) (Reservation, StockMovement, error) {
+	now := time.Now()
+	if reservation.Status != ReservationActive || reservation.expired(now) {
+		return Reservation{}, StockMovement{}, errReservationNotActive
+	}
+
+	current, err := s.repo.GetById(reservation.ProductId)
+	if err != nil {
+		return Reservation{}, StockMovement{}, err
+	}
+	confirmed, err := s.repo.EndReservation(reservation.Id, ReservationConfirmed, now)
+	if err != nil {
+		return Reservation{}, StockMovement{}, err
+	}
+	issued, err := s.bookMovement(StockMovement{
+		ProductId: confirmed.ProductId,
+		Type:      MovementIssue,
+		Quantity:  confirmed.Quantity,
+		Reason:    reason,
+		Reference: confirmed.Reference,
+		CreatedAt: now,
+	}, current)
+	if err != nil {
+		return Reservation{}, StockMovement{}, err
+	}
+	s.publishStored(EventProductUpdated, current)
+	return confirmed, issued, nil
+}
+
+func (s *ProductServiceImpl) ReleaseReservation(id int) (Reservation, error) {
+	if s.deferred == nil {
+		var released Reservation
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			released, err = tx.ReleaseReservation(id)
+			return err
+		})
+		return released, err
+	}
+
+	reservation, err := s.repo.GetReservationById(id)
+	if err != nil {
+		return Reservation{}, err
+	}
+	current, err := s.repo.GetById(reservation.ProductId)
+	if err != nil {
+		return Reservation{}, err
+	}
+	released, err := s.repo.EndReservation(id, ReservationReleased, time.Now())
+	if err != nil {
+		return Reservation{}, err
+	}
+	s.publishStored(EventProductUpdated, current)
+	return released, nil
+}
+
+func (s *ProductServiceImpl) GetReservationById(id int) (Reservation, error) {
+	return s.repo.GetReservationById(id)
+}
+
+func (s *ProductServiceImpl) GetReservations(productId int) ([]Reservation, error) {
+	return s.repo.GetReservations(productId)
+}
+
+// ExpireReservations ends the reservations expired at now, gives their stock
+// back and returns how many there were.
+func (s *ProductServiceImpl) ExpireReservations(now time.Time) (int, error) {
+	var expired []Reservation
+	err := s.write(func(tx *ProductServiceImpl) (err error) {
+		expired, err = tx.repo.ExpireReservations(now)
+		if err != nil {
+			return err
+		}
+		published := make(map[int]bool)
+		for _, reservation := range expired {
+			if published[reservation.ProductId] {
+				continue
+			}
+			published[reservation.ProductId] = true
+			product, err := tx.repo.GetById(reservation.ProductId)
+			if err != nil {
+				return err
+			}
+			tx.publish(EventProductUpdated, product)
+		}
+		return nil
+	})
+	return len(expired), err
+}
+
+// RunReservationSweeper expires reservations every reservationSweepInterval
+// until ctx is done.
+func (s *ProductServiceImpl) RunReservationSweeper(ctx context.Context) error {
+	ticker := time.NewTicker(reservationSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ExpireReservations(time.Now()); err != nil {
+			log.Println("error while expiring reservations:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductServiceImpl_Reservations(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}})
+	service := NewProductServiceImpl(repo)
+	subscriber := &testSubscriber{id: "test"}
+	assert.NoError(t, service.subscribe(subscriber), "subscribe should succeed")
+
+	reservation, err := service.Reserve(Reservation{ProductId: 1, Quantity: 2, Reference: "order-1"})
+	assert.NoError(t, err, "reserve should succeed")
+	assert.Equal(t, ReservationActive, reservation.Status)
+	assert.WithinDuration(t, time.Now().Add(defaultReservationTTL), reservation.ExpiresAt, time.Minute, "expect the default expiry")
+
+	_, err = service.Reserve(Reservation{ProductId: 1, Quantity: 4})
+	assert.ErrorIs(t, err, errInsufficientStock, "expect only available stock to be reserved")
+	_, err = service.Reserve(Reservation{})
+	var ve *validationError
+	assert.ErrorAs(t, err, &ve, "expect invalid reservation to fail validation")
+
+	confirmed, err := service.ConfirmReservation(reservation.Id)
+	assert.NoError(t, err, "confirm should succeed")
+	assert.Equal(t, ReservationConfirmed, confirmed.Status)
+	_, err = service.ConfirmReservation(reservation.Id)
+	assert.ErrorIs(t, err, errReservationNotActive, "expect a reservation to be confirmed once")
+
+	product, _ := service.GetById(1)
+	assert.Equal(t, 3, product.Quantity, "expect confirm to take the stock out")
+	assert.Equal(t, 0, product.Reserved)
+	movements, _ := service.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		issued := movements[len(movements)-1]
+		assert.Equal(t, MovementIssue, issued.Type)
+		assert.Equal(t, 2, issued.Quantity)
+		assert.Equal(t, "order-1", issued.Reference, "expect the movement to carry the order reference")
+	}
+
+	released, err := service.Reserve(Reservation{ProductId: 1, Quantity: 3})
+	assert.NoError(t, err, "reserve should succeed")
+	released, err = service.ReleaseReservation(released.Id)
+	assert.NoError(t, err, "release should succeed")
+	assert.Equal(t, ReservationReleased, released.Status)
+	_, err = service.ReleaseReservation(7)
+	assert.ErrorIs(t, err, errReservationNotFound)
+
+	product, _ = service.GetById(1)
+	assert.Equal(t, 3, product.Quantity, "expect release to leave the stock on hand")
+	assert.Equal(t, 0, product.Reserved)
+
+	service.drain()
+	types := make([]string, 0, len(subscriber.events))
+	for _, event := range subscriber.events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{
+		EventProductUpdated,
+		EventProductUpdated,
+		EventProductUpdated, EventStockOut,
+		EventProductUpdated,
+	}, types, "expect an update for every change and an alert once nothing is left to sell")
+}
+
+func TestProductServiceImpl_ExpireReservations(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}})
+	service := NewProductServiceImpl(repo)
+
+	reservation, err := service.Reserve(Reservation{ProductId: 1, Quantity: 2, ExpiresAt: time.Now().Add(time.Minute)})
+	assert.NoError(t, err, "reserve should succeed")
+	_, err = service.Reserve(Reservation{ProductId: 1, Quantity: 2, ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err, "reserve should succeed")
+
+	expired, err := service.ExpireReservations(time.Now().Add(2 * time.Minute))
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, 1, expired, "expect only the due reservation to expire")
+
+	got, _ := service.GetReservationById(reservation.Id)
+	assert.Equal(t, ReservationExpired, got.Status)
+	product, _ := service.GetById(1)
+	assert.Equal(t, 2, product.Reserved, "expect the expired reservation to give its stock back")
+}
+
+func TestProductServiceImpl_ReserveConcurrently(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 10, Version: 1}})
+	service := NewProductServiceImpl(repo)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Reserve(Reservation{ProductId: 1, Quantity: 1}); err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, reserved, "expect no more reservations than stock")
+	product, _ := service.GetById(1)
+	assert.Equal(t, 10, product.Reserved)
+}
+
+// TestProductServiceImpl_SweepWhileReserving is meant for -race: readers run
+// next to the transactions reserving and expiring stock.
+func TestProductServiceImpl_SweepWhileReserving(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 1000, Version: 1}})
+	service := NewProductServiceImpl(repo)
+
+	var wg sync.WaitGroup
+	var started sync.WaitGroup
+	done := make(chan struct{})
+	// loop calls fn until done, signalling started after the first call
+	loop := func(fn func()) {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+			started.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					fn()
+				}
+			}
+		}()
+	}
+
+	loop(func() {
+		_, err := service.ExpireReservations(time.Now().Add(time.Hour))
+		assert.NoError(t, err, "sweep should succeed")
+	})
+	loop(func() {
+		product, err := service.GetById(1)
+		assert.NoError(t, err, "get should succeed")
+		assert.True(t, product.Reserved >= 0 && product.Reserved <= product.Quantity, "expect reserved stock within the quantity")
+		_, err = service.GetReservations(1)
+		assert.NoError(t, err, "get reservations should succeed")
+		_, err = service.List(ProductQuery{})
+		assert.NoError(t, err, "list should succeed")
+	})
+	started.Wait()
+
+	for i := 0; i < 200; i++ {
+		_, err := service.Reserve(Reservation{ProductId: 1, Quantity: 1, ExpiresAt: time.Now().Add(time.Minute)})
+		assert.NoError(t, err, "reserve should succeed")
+	}
+	close(done)
+	wg.Wait()
+
+	_, err := service.ExpireReservations(time.Now().Add(time.Hour))
+	assert.NoError(t, err, "sweep should succeed")
+	product, _ := service.GetById(1)
+	assert.Equal(t, 0, product.Reserved, "expect every reservation to have given its stock back")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReservation(t *testing.T) {
+	now := time.Date(2023, 12, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		reservation  Reservation
+		wantFailures []string
+	}{
+		{
+			name:        "valid reservation",
+			reservation: Reservation{ProductId: 1, Quantity: 2, Reference: "order-1"},
+		},
+		{
+			name:        "valid reservation with expiry",
+			reservation: Reservation{ProductId: 1, Quantity: 2, ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			name:         "empty reservation",
+			reservation:  Reservation{},
+			wantFailures: []string{"ProductId should be greater than 0", "Quantity should be greater than 0"},
+		},
+		{
+			name:         "expired reservation",
+			reservation:  Reservation{ProductId: 1, Quantity: -1, ExpiresAt: now},
+			wantFailures: []string{"Quantity should be greater than 0", "ExpiresAt should be in the future"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReservation(tt.reservation, now)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
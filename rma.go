@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of an rma. An open rma waits for the goods; once they are
+// received every line has a disposition, and the rma closes when no line is
+// left in quarantine.
+const (
+	RmaOpen      = "open"
+	RmaReceived  = "received"
+	RmaClosed    = "closed"
+	RmaCancelled = "cancelled"
+)
+
+// Dispositions of a returned line. Restocked goods go back into stock,
+// quarantined ones are held outside it until they are restocked or scrapped
+// and scrapped ones are written off.
+const (
+	DispositionRestock    = "restock"
+	DispositionQuarantine = "quarantine"
+	DispositionScrap      = "scrap"
+)
+
+// Rma authorises the customer of a shipped sales order to return goods,
+// for Reason.
+type Rma struct {
+	Id           int       `json:"id" bun:"id,pk,autoincrement"`
+	SalesOrderId int       `json:"salesOrderId"`
+	Reason       string    `json:"reason"`
+	Status       string    `json:"status"`
+	Lines        []RmaLine `json:"lines" bun:"rel:has-many,join:id=rma_id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	ReceivedAt   time.Time `json:"receivedAt,omitempty" bun:",nullzero"`
+}
+
+// RmaLine returns Quantity of a sales order line. Lots are the lots the
+// returned goods were shipped from, their share of the lots of the sales
+// order line. Note says why the goods got their disposition.
+type RmaLine struct {
+	Id               int           `json:"id" bun:"id,pk,autoincrement"`
+	RmaId            int           `json:"-"`
+	SalesOrderLineId int           `json:"salesOrderLineId"`
+	ProductId        int           `json:"productId"`
+	Quantity         int           `json:"quantity"`
+	Lots             []LotQuantity `json:"lots,omitempty" bun:",type:jsonb,nullzero"`
+	Disposition      string        `json:"disposition,omitempty"`
+	Note             string        `json:"note,omitempty"`
+	DisposedAt       time.Time     `json:"disposedAt,omitempty" bun:",nullzero"`
+}
+
+// RmaFilter narrows down GetRmas, zero fields match everything.
+type RmaFilter struct {
+	SalesOrderId int
+	Status       string
+}
+
+// RmaReceipt gives every line of an rma its disposition when the goods
+// arrive. Restocked goods go into LocationId when it is set.
+type RmaReceipt struct {
+	LocationId int                  `json:"locationId,omitempty"`
+	Actor      string               `json:"actor"`
+	Lines      []RmaDispositionLine `json:"lines"`
+}
+
+type RmaDispositionLine struct {
+	LineId      int    `json:"lineId"`
+	Disposition string `json:"disposition"`
+	Note        string `json:"note"`
+}
+
+// RmaDisposition settles a quarantined line, which is restocked or scrapped.
+type RmaDisposition struct {
+	LocationId  int    `json:"locationId,omitempty"`
+	Actor       string `json:"actor"`
+	Disposition string `json:"disposition"`
+	Note        string `json:"note"`
+}
+
+func validateRma(rma Rma) error {
+	failures := make([]string, 0)
+
+	if rma.SalesOrderId <= 0 {
+		failures = append(failures, "SalesOrderId should be greater than 0")
+	}
+	if rma.Reason == "" {
+		failures = append(failures, "Reason should not be empty")
+	}
+	if len(rma.Lines) == 0 {
+		failures = append(failures, "Lines should not be empty")
+	}
+	for idx, line := range rma.Lines {
+		if line.SalesOrderLineId <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: SalesOrderLineId should be greater than 0", idx+1))
+		}
+		if line.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should be greater than 0", idx+1))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// parseRmaFilter reads the salesOrderId and status query parameters.
+func parseRmaFilter(values url.Values) (RmaFilter, error) {
+	failures := make([]string, 0)
+	filter := RmaFilter{Status: values.Get("status")}
+
+	if raw := values.Get("salesOrderId"); raw != "" {
+		salesOrderId, err := strconv.Atoi(raw)
+		if err != nil {
+			failures = append(failures, "salesOrderId should be an integer")
+		}
+		filter.SalesOrderId = salesOrderId
+	}
+	switch filter.Status {
+	case "", RmaOpen, RmaReceived, RmaClosed, RmaCancelled:
+	default:
+		failures = append(failures, fmt.Sprintf("status should be one of %s, %s, %s, %s", RmaOpen, RmaReceived, RmaClosed, RmaCancelled))
+	}
+
+	if len(failures) == 0 {
+		return filter, nil
+	}
+	return RmaFilter{}, &validationError{failures: failures}
+}
+
+// validateReturnable checks the lines of rma against order, of which
+// returned was already returned per sales order line.
+func validateReturnable(rma Rma, order SalesOrder, returned map[int]int) error {
+	failures := make([]string, 0)
+
+	shipped := make(map[int]int, len(order.Lines))
+	for _, line := range order.Lines {
+		shipped[line.Id] = line.Quantity
+	}
+	returning := make(map[int]int, len(rma.Lines))
+	for idx, line := range rma.Lines {
+		quantity, ok := shipped[line.SalesOrderLineId]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("line %d: SalesOrderLineId %d is not a line of the sales order", idx+1, line.SalesOrderLineId))
+			continue
+		}
+		left := quantity - returned[line.SalesOrderLineId] - returning[line.SalesOrderLineId]
+		if line.Quantity > left {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should not be more than the %d left to return", idx+1, left))
+			continue
+		}
+		returning[line.SalesOrderLineId] += line.Quantity
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// validateRmaReceipt checks that receipt gives every line of rma exactly one
+// disposition.
+func validateRmaReceipt(rma Rma, receipt RmaReceipt) error {
+	failures := make([]string, 0)
+
+	pending := make(map[int]bool, len(rma.Lines))
+	for _, line := range rma.Lines {
+		pending[line.Id] = true
+	}
+	for idx, line := range receipt.Lines {
+		if !pending[line.LineId] {
+			failures = append(failures, fmt.Sprintf("line %d: LineId %d is not a line of the rma or was given twice", idx+1, line.LineId))
+			continue
+		}
+		delete(pending, line.LineId)
+		failures = append(failures, dispositionFailures(fmt.Sprintf("line %d: ", idx+1), line.Disposition, line.Note, DispositionRestock, DispositionQuarantine, DispositionScrap)...)
+	}
+	for _, line := range rma.Lines {
+		if pending[line.Id] {
+			failures = append(failures, fmt.Sprintf("line %d of the rma needs a disposition", line.Id))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+func validateRmaDisposition(disposition RmaDisposition) error {
+	failures := dispositionFailures("", disposition.Disposition, disposition.Note, DispositionRestock, DispositionScrap)
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// dispositionFailures checks that disposition is one of allowed and that
+// goods not going back into stock come with a note saying why.
+func dispositionFailures(prefix, disposition, note string, allowed ...string) []string {
+	failures := make([]string, 0)
+	if !containsString(allowed, disposition) {
+		failures = append(failures, fmt.Sprintf("%sDisposition should be one of %s", prefix, strings.Join(allowed, ", ")))
+	} else if disposition != DispositionRestock && note == "" {
+		failures = append(failures, prefix+"Note should say why the goods are not restocked")
+	}
+	return failures
+}
+
+// quarantined tells whether a line of rma is still held in quarantine.
+func (rma Rma) quarantined() bool {
+	for _, line := range rma.Lines {
+		if line.Disposition == DispositionQuarantine {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type rmaTransport struct {
+	service RmaService
+}
+
+func NewRmaTransport(svc RmaService) *rmaTransport {
+	return &rmaTransport{
+		service: svc,
+	}
+}
+
+func (t *rmaTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/rmas", t.CreateRma).Methods("POST")
+	r.HandleFunc("/rmas", t.GetRmas).Methods("GET")
+	r.HandleFunc("/rmas/{id}", t.GetRmaById).Methods("GET")
+	r.HandleFunc("/rmas/{id}/receive", t.ReceiveRma).Methods("POST")
+	r.HandleFunc("/rmas/{id}/lines/{lineId}/dispose", t.DisposeRmaLine).Methods("POST")
+	r.HandleFunc("/rmas/{id}/cancel", t.CancelRma).Methods("POST")
+}
+
+func (t *rmaTransport) CreateRma(w http.ResponseWriter, r *http.Request) {
+	var rma Rma
+	if err := json.NewDecoder(r.Body).Decode(&rma); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreateRma(rma)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *rmaTransport) GetRmas(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseRmaFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rmas, err := t.service.GetRmas(filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rmas)
+}
+
+func (t *rmaTransport) GetRmaById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	rma, err := t.service.GetRmaById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rma)
+}
+
+func (t *rmaTransport) ReceiveRma(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var receipt RmaReceipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rma, err := t.service.ReceiveRma(id, receipt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rma)
+}
+
+func (t *rmaTransport) DisposeRmaLine(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	lineId, err := strconv.Atoi(mux.Vars(r)["lineId"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid line id")
+		return
+	}
+
+	var disposition RmaDisposition
+	if err := json.NewDecoder(r.Body).Decode(&disposition); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rma, err := t.service.DisposeRmaLine(id, lineId, disposition)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rma)
+}
+
+func (t *rmaTransport) CancelRma(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	rma, err := t.service.CancelRma(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rma)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRmaTransport(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}})
+	products := NewProductServiceImpl(repo)
+	handler := buildHttpHandler(
+		NewhttpTransport(products),
+		NewSalesOrderTransport(NewSalesOrderServiceImpl(repo, products)),
+		NewRmaTransport(NewRmaServiceImpl(repo, products)),
+	)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "create sales order",
+			method:         "POST",
+			url:            "/sales-orders",
+			body:           `{"customer": "Jane", "lines": [{"productId": 1, "quantity": 3}]}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "return before shipping",
+			method:         "POST",
+			url:            "/rmas",
+			body:           `{"salesOrderId": 1, "reason": "damaged", "lines": [{"salesOrderLineId": 1, "quantity": 1}]}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["cannot return a sales order that is created"]}`,
+		},
+		{name: "allocate", method: "POST", url: "/sales-orders/1/allocate", wantStatusCode: http.StatusOK},
+		{name: "pick", method: "POST", url: "/sales-orders/1/pick", wantStatusCode: http.StatusOK},
+		{name: "pack", method: "POST", url: "/sales-orders/1/pack", wantStatusCode: http.StatusOK},
+		{name: "ship", method: "POST", url: "/sales-orders/1/ship", wantStatusCode: http.StatusOK},
+		{
+			name:           "invalid rma",
+			method:         "POST",
+			url:            "/rmas",
+			body:           `{"salesOrderId": 1, "lines": []}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Reason should not be empty", "Lines should not be empty"]}`,
+		},
+		{
+			name:           "unknown sales order",
+			method:         "POST",
+			url:            "/rmas",
+			body:           `{"salesOrderId": 7, "reason": "damaged", "lines": [{"salesOrderLineId": 1, "quantity": 1}]}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["sales order not found"]}`,
+		},
+		{
+			name:           "create rma",
+			method:         "POST",
+			url:            "/rmas",
+			body:           `{"salesOrderId": 1, "reason": "damaged", "lines": [{"salesOrderLineId": 1, "quantity": 2}]}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "dispose before receiving",
+			method:         "POST",
+			url:            "/rmas/1/lines/1/dispose",
+			body:           `{"disposition": "restock"}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["cannot dispose of a return that is open"]}`,
+		},
+		{
+			name:           "receive into quarantine",
+			method:         "POST",
+			url:            "/rmas/1/receive",
+			body:           `{"actor": "dock", "lines": [{"lineId": 1, "disposition": "quarantine", "note": "needs testing"}]}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "filter by status",
+			method:         "GET",
+			url:            "/rmas?salesOrderId=1&status=closed",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "unknown line",
+			method:         "POST",
+			url:            "/rmas/1/lines/7/dispose",
+			body:           `{"disposition": "restock"}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["rma line not found"]}`,
+		},
+		{
+			name:           "restock after testing",
+			method:         "POST",
+			url:            "/rmas/1/lines/1/dispose",
+			body:           `{"actor": "qa", "disposition": "restock", "note": "works fine"}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "unknown rma",
+			method:         "GET",
+			url:            "/rmas/7",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["rma not found"]}`,
+		},
+		{
+			name:           "invalid line id",
+			method:         "POST",
+			url:            "/rmas/1/lines/abc/dispose",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid line id"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/rmas/1", nil))
+	var rma Rma
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&rma), "expect rma response")
+	assert.Equal(t, RmaClosed, rma.Status)
+	assert.Equal(t, "works fine", rma.Lines[0].Note)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+	var product Product
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&product), "expect product response")
+	assert.Equal(t, 4, product.Quantity, "expect the restocked return back in stock")
+}
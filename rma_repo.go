@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+)
+
+var errRmaNotFound = errors.New("rma not found")
+
+// RmaRepo stores rmas with their lines, ordered by id. The lines are fixed
+// once the rma is created, only their dispositions change.
+type RmaRepo interface {
+	CreateRma(Rma) (Rma, error)
+	GetRmaById(id int) (Rma, error)
+	// LockRma gets the rma like GetRmaById and makes other transactions
+	// locking it wait until the calling one ends.
+	LockRma(id int) (Rma, error)
+	GetRmas(RmaFilter) ([]Rma, error)
+	// UpdateRma writes the status and timestamps of the rma and the
+	// dispositions of its lines.
+	UpdateRma(Rma) error
+}
+
+func (r *InMemoryRepo) CreateRma(rma Rma) (Rma, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	if _, err := r.GetSalesOrderById(rma.SalesOrderId); err != nil {
+		return Rma{}, err
+	}
+
+	r.lastRmaId++
+	rma.Id = r.lastRmaId
+	lines := make([]RmaLine, len(rma.Lines))
+	for idx, line := range rma.Lines {
+		r.lastRmaLineId++
+		line.Id = r.lastRmaLineId
+		line.RmaId = rma.Id
+		lines[idx] = line
+	}
+	rma.Lines = lines
+	r.rmas = append(r.rmas, rma)
+	return copyRma(rma), nil
+}
+
+func (r *InMemoryRepo) GetRmaById(id int) (Rma, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentRma := range r.rmas {
+		if currentRma.Id == id {
+			return copyRma(currentRma), nil
+		}
+	}
+	return Rma{}, errRmaNotFound
+}
+
+// LockRma needs no lock of its own, InTx already runs one transaction at a
+// time.
+func (r *InMemoryRepo) LockRma(id int) (Rma, error) {
+	return r.GetRmaById(id)
+}
+
+func (r *InMemoryRepo) GetRmas(filter RmaFilter) ([]Rma, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	rmas := make([]Rma, 0)
+	for _, currentRma := range r.rmas {
+		if filter.SalesOrderId != 0 && currentRma.SalesOrderId != filter.SalesOrderId {
+			continue
+		}
+		if filter.Status != "" && currentRma.Status != filter.Status {
+			continue
+		}
+		rmas = append(rmas, copyRma(currentRma))
+	}
+	return rmas, nil
+}
+
+func (r *InMemoryRepo) UpdateRma(rma Rma) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentRma := range r.rmas {
+		if currentRma.Id == rma.Id {
+			updated := copyRma(currentRma)
+			updated.Status = rma.Status
+			updated.UpdatedAt = rma.UpdatedAt
+			updated.ReceivedAt = rma.ReceivedAt
+			for lineIdx, line := range updated.Lines {
+				for _, changed := range rma.Lines {
+					if changed.Id == line.Id {
+						updated.Lines[lineIdx].Disposition = changed.Disposition
+						updated.Lines[lineIdx].Note = changed.Note
+						updated.Lines[lineIdx].DisposedAt = changed.DisposedAt
+					}
+				}
+			}
+			r.rmas[idx] = updated
+			return nil
+		}
+	}
+	return errRmaNotFound
+}
+
+// copyRma keeps callers from changing the stored lines.
+func copyRma(rma Rma) Rma {
+	rma.Lines = append([]RmaLine{}, rma.Lines...)
+	return rma
+}
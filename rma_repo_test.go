@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_Rmas(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A"}})
+	order, err := repo.CreateSalesOrder(SalesOrder{Customer: "Jane", Status: SalesOrderShipped, Lines: []SalesOrderLine{{ProductId: 1, Quantity: 2}}})
+	assert.NoError(t, err, "create sales order should succeed")
+
+	_, err = repo.CreateRma(Rma{SalesOrderId: 7, Reason: "damaged"})
+	assert.ErrorIs(t, err, errSalesOrderNotFound)
+
+	rma, err := repo.CreateRma(Rma{
+		SalesOrderId: order.Id,
+		Reason:       "damaged",
+		Status:       RmaOpen,
+		Lines:        []RmaLine{{SalesOrderLineId: order.Lines[0].Id, ProductId: 1, Quantity: 2}},
+	})
+	assert.NoError(t, err, "create rma should succeed")
+	assert.Equal(t, 1, rma.Lines[0].Id, "expect lines to be numbered")
+
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	rma.Status = RmaReceived
+	rma.ReceivedAt = now
+	rma.Reason = "changed"
+	rma.Lines[0].Disposition = DispositionQuarantine
+	rma.Lines[0].Note = "seal broken"
+	rma.Lines[0].Quantity = 9
+	assert.NoError(t, repo.UpdateRma(rma), "update rma should succeed")
+
+	stored, _ := repo.GetRmaById(rma.Id)
+	assert.Equal(t, RmaReceived, stored.Status)
+	assert.Equal(t, now, stored.ReceivedAt)
+	assert.Equal(t, "damaged", stored.Reason, "expect only the status and timestamps to be written")
+	assert.Equal(t, DispositionQuarantine, stored.Lines[0].Disposition)
+	assert.Equal(t, "seal broken", stored.Lines[0].Note)
+	assert.Equal(t, 2, stored.Lines[0].Quantity, "expect the lines to be fixed")
+	assert.ErrorIs(t, repo.UpdateRma(Rma{Id: 7}), errRmaNotFound)
+
+	rmas, err := repo.GetRmas(RmaFilter{SalesOrderId: order.Id, Status: RmaOpen})
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, rmas, "expect the status filter to apply")
+	rmas, err = repo.GetRmas(RmaFilter{SalesOrderId: order.Id})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, rmas, 1)
+
+	assert.NoError(t, repo.Delete(1, 0), "delete should succeed")
+	stored, _ = repo.GetRmaById(rma.Id)
+	assert.Empty(t, stored.Lines, "expect deleting a product to drop its returned lines")
+}
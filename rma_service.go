@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	errRmaLineNotFound       = errors.New("rma line not found")
+	errRmaLineNotQuarantined = errors.New("rma line is not in quarantine")
+)
+
+type RmaService interface {
+	CreateRma(Rma) (Rma, error)
+	GetRmaById(id int) (Rma, error)
+	GetRmas(RmaFilter) ([]Rma, error)
+	ReceiveRma(id int, receipt RmaReceipt) (Rma, error)
+	DisposeRmaLine(id, lineId int, disposition RmaDisposition) (Rma, error)
+	CancelRma(id int) (Rma, error)
+}
+
+// RmaServiceImpl books restocked returns through the product service, so
+// they land in the stock ledger and are published like any other movement.
+// Quarantined and scrapped goods stay out of stock, their trail is the rma
+// line.
+type RmaServiceImpl struct {
+	repo     Repo
+	products *ProductServiceImpl
+}
+
+func NewRmaServiceImpl(repo Repo, products *ProductServiceImpl) *RmaServiceImpl {
+	return &RmaServiceImpl{
+		repo:     repo,
+		products: products,
+	}
+}
+
+// CreateRma authorises returning lines of a shipped sales order, up to what
+// shipped less what earlier rmas not cancelled return. Every line gets its
+// share of the lots the sales order line shipped from that earlier rmas do
+// not return.
+func (s *RmaServiceImpl) CreateRma(rma Rma) (Rma, error) {
+	if err := validateRma(rma); err != nil {
+		return Rma{}, fmt.Errorf("create rma: %w", err)
+	}
+
+	var created Rma
+	err := s.repo.InTx(func(tx Repo) error {
+		// locking the order keeps two rmas from returning the same goods
+		order, err := tx.LockSalesOrder(rma.SalesOrderId)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("sales order", "return", order.Status, SalesOrderShipped, SalesOrderDelivered); err != nil {
+			return err
+		}
+		earlier, err := tx.GetRmas(RmaFilter{SalesOrderId: order.Id})
+		if err != nil {
+			return err
+		}
+		returned := make(map[int]int)
+		lots := make(map[int][]LotQuantity, len(order.Lines))
+		for _, line := range order.Lines {
+			lots[line.Id] = line.Lots
+		}
+		for _, current := range earlier {
+			if current.Status == RmaCancelled {
+				continue
+			}
+			for _, line := range current.Lines {
+				returned[line.SalesOrderLineId] += line.Quantity
+				lots[line.SalesOrderLineId] = subtractLots(lots[line.SalesOrderLineId], line.Lots)
+			}
+		}
+		if err := validateReturnable(rma, order, returned); err != nil {
+			return fmt.Errorf("create rma: %w", err)
+		}
+
+		products := make(map[int]int, len(order.Lines))
+		shipped := make(map[int]int, len(order.Lines))
+		for _, line := range order.Lines {
+			products[line.Id] = line.ProductId
+			shipped[line.Id] = line.Quantity
+		}
+		timeNow := time.Now()
+		rma.Status = RmaOpen
+		rma.CreatedAt = timeNow
+		rma.UpdatedAt = timeNow
+		rma.ReceivedAt = time.Time{}
+		lines := make([]RmaLine, len(rma.Lines))
+		for idx, line := range rma.Lines {
+			id := line.SalesOrderLineId
+			returnedLots := prorateLots(lots[id], shipped[id]-returned[id], line.Quantity)
+			lots[id] = subtractLots(lots[id], returnedLots)
+			returned[id] += line.Quantity
+			lines[idx] = RmaLine{SalesOrderLineId: id, ProductId: products[id], Quantity: line.Quantity, Lots: returnedLots}
+		}
+		rma.Lines = lines
+		created, err = tx.CreateRma(rma)
+		return err
+	})
+	if err != nil {
+		return Rma{}, err
+	}
+	return created, nil
+}
+
+func (s *RmaServiceImpl) GetRmaById(id int) (Rma, error) {
+	return s.repo.GetRmaById(id)
+}
+
+func (s *RmaServiceImpl) GetRmas(filter RmaFilter) ([]Rma, error) {
+	return s.repo.GetRmas(filter)
+}
+
+// ReceiveRma books the returned goods with a disposition for every line.
+// The rma closes unless some of them went into quarantine.
+func (s *RmaServiceImpl) ReceiveRma(id int, receipt RmaReceipt) (Rma, error) {
+	var rma Rma
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		rma, err = tx.repo.LockRma(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("return", "receive", rma.Status, RmaOpen); err != nil {
+			return err
+		}
+		if err := validateRmaReceipt(rma, receipt); err != nil {
+			return fmt.Errorf("receive rma: %w", err)
+		}
+
+		now := time.Now()
+		for _, received := range receipt.Lines {
+			for idx, line := range rma.Lines {
+				if line.Id != received.LineId {
+					continue
+				}
+				disposition := RmaDisposition{LocationId: receipt.LocationId, Actor: receipt.Actor, Disposition: received.Disposition, Note: received.Note}
+				if err := s.dispose(tx, rma, &rma.Lines[idx], disposition, now); err != nil {
+					return err
+				}
+			}
+		}
+
+		rma.Status = RmaClosed
+		if rma.quarantined() {
+			rma.Status = RmaReceived
+		}
+		rma.ReceivedAt = now
+		rma.UpdatedAt = now
+		return tx.repo.UpdateRma(rma)
+	})
+	if err != nil {
+		return Rma{}, err
+	}
+	return rma, nil
+}
+
+// DisposeRmaLine restocks or scraps a quarantined line once it has been
+// inspected, closing the rma with its last one.
+func (s *RmaServiceImpl) DisposeRmaLine(id, lineId int, disposition RmaDisposition) (Rma, error) {
+	var rma Rma
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		rma, err = tx.repo.LockRma(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("return", "dispose of", rma.Status, RmaReceived); err != nil {
+			return err
+		}
+		if err := validateRmaDisposition(disposition); err != nil {
+			return fmt.Errorf("dispose rma line: %w", err)
+		}
+
+		now := time.Now()
+		found := false
+		for idx, line := range rma.Lines {
+			if line.Id != lineId {
+				continue
+			}
+			found = true
+			if line.Disposition != DispositionQuarantine {
+				return errRmaLineNotQuarantined
+			}
+			if err := s.dispose(tx, rma, &rma.Lines[idx], disposition, now); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return errRmaLineNotFound
+		}
+
+		if !rma.quarantined() {
+			rma.Status = RmaClosed
+		}
+		rma.UpdatedAt = now
+		return tx.repo.UpdateRma(rma)
+	})
+	if err != nil {
+		return Rma{}, err
+	}
+	return rma, nil
+}
+
+// CancelRma drops an rma whose goods never came back, so its quantities can
+// be returned by another one.
+func (s *RmaServiceImpl) CancelRma(id int) (Rma, error) {
+	var rma Rma
+	err := s.repo.InTx(func(tx Repo) (err error) {
+		rma, err = tx.LockRma(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("return", "cancel", rma.Status, RmaOpen); err != nil {
+			return err
+		}
+
+		rma.Status = RmaCancelled
+		rma.UpdatedAt = time.Now()
+		return tx.UpdateRma(rma)
+	})
+	if err != nil {
+		return Rma{}, err
+	}
+	return rma, nil
+}
+
+// dispose gives line its disposition, putting restocked goods back into the
+// lots they shipped from with a receipt movement saying why they came back.
+func (s *RmaServiceImpl) dispose(tx *ProductServiceImpl, rma Rma, line *RmaLine, disposition RmaDisposition, now time.Time) error {
+	line.Disposition = disposition.Disposition
+	line.Note = disposition.Note
+	line.DisposedAt = now
+	if disposition.Disposition != DispositionRestock {
+		return nil
+	}
+
+	why := disposition.Note
+	if why == "" {
+		why = rma.Reason
+	}
+	_, err := tx.AddMovement(StockMovement{
+		ProductId:  line.ProductId,
+		Type:       MovementReceipt,
+		Quantity:   line.Quantity,
+		LocationId: disposition.LocationId,
+		Lots:       line.Lots,
+		Reason:     fmt.Sprintf("rma %d restocked: %s", rma.Id, why),
+		Reference:  fmt.Sprintf("rma %d", rma.Id),
+		Actor:      disposition.Actor,
+	})
+	return err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRmas ships a sales order of 3 of product 1 and 1 of product 2, out of
+// 5 and 1 in stock.
+func setupRmas(t *testing.T) (*RmaServiceImpl, *ProductServiceImpl, SalesOrder) {
+	orders, products, order := setupSalesOrders(t, SalesOrderLine{ProductId: 1, Quantity: 3}, SalesOrderLine{ProductId: 2, Quantity: 1})
+	for _, step := range []func(int) (SalesOrder, error){orders.AllocateSalesOrder, orders.PickSalesOrder, orders.PackSalesOrder, orders.ShipSalesOrder} {
+		var err error
+		order, err = step(order.Id)
+		assert.NoError(t, err, "expect no error")
+	}
+	return NewRmaServiceImpl(orders.repo, products), products, order
+}
+
+func TestRmaServiceImpl_Create(t *testing.T) {
+	service, _, order := setupRmas(t)
+
+	rma, err := service.CreateRma(Rma{SalesOrderId: order.Id, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: order.Lines[0].Id, Quantity: 2}}})
+	assert.NoError(t, err, "create rma should succeed")
+	assert.Equal(t, RmaOpen, rma.Status)
+	assert.Equal(t, 1, rma.Lines[0].ProductId, "expect the product of the sales order line")
+
+	_, err = service.CreateRma(Rma{SalesOrderId: order.Id, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: order.Lines[0].Id, Quantity: 2}}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "expect goods not to be returned twice") {
+		assert.Equal(t, []string{"line 1: Quantity should not be more than the 1 left to return"}, ve.failures)
+	}
+
+	_, err = service.CancelRma(rma.Id)
+	assert.NoError(t, err, "cancel should succeed")
+	_, err = service.CreateRma(Rma{SalesOrderId: order.Id, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: order.Lines[0].Id, Quantity: 3}}})
+	assert.NoError(t, err, "expect a cancelled rma to return nothing")
+
+	unshipped, err := NewSalesOrderServiceImpl(service.repo, service.products).CreateSalesOrder(SalesOrder{Customer: "Jane", Lines: []SalesOrderLine{{ProductId: 1, Quantity: 1}}})
+	assert.NoError(t, err, "create sales order should succeed")
+	_, err = service.CreateRma(Rma{SalesOrderId: unshipped.Id, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: unshipped.Lines[0].Id, Quantity: 1}}})
+	var te *transitionError
+	if assert.ErrorAs(t, err, &te, "expect only shipped orders to be returned") {
+		assert.Equal(t, "cannot return a sales order that is created", te.Error())
+	}
+}
+
+func TestRmaServiceImpl_Receive(t *testing.T) {
+	service, products, order := setupRmas(t)
+	rma, err := service.CreateRma(Rma{
+		SalesOrderId: order.Id,
+		Reason:       "wrong size",
+		Lines:        []RmaLine{{SalesOrderLineId: order.Lines[0].Id, Quantity: 2}, {SalesOrderLineId: order.Lines[1].Id, Quantity: 1}},
+	})
+	assert.NoError(t, err, "create rma should succeed")
+
+	_, err = service.ReceiveRma(rma.Id, RmaReceipt{Lines: []RmaDispositionLine{{LineId: rma.Lines[0].Id, Disposition: DispositionRestock}}})
+	var ve *validationError
+	assert.ErrorAs(t, err, &ve, "expect every line to need a disposition")
+
+	received, err := service.ReceiveRma(rma.Id, RmaReceipt{Actor: "dock", Lines: []RmaDispositionLine{
+		{LineId: rma.Lines[0].Id, Disposition: DispositionRestock},
+		{LineId: rma.Lines[1].Id, Disposition: DispositionQuarantine, Note: "seal broken"},
+	}})
+	assert.NoError(t, err, "receive should succeed")
+	assert.Equal(t, RmaReceived, received.Status, "expect a quarantined line to keep the rma open")
+	assert.False(t, received.ReceivedAt.IsZero(), "expect the receiving time")
+
+	product, _ := products.GetById(1)
+	assert.Equal(t, 4, product.Quantity, "expect restocked goods back in stock")
+	movements, _ := products.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		movement := movements[len(movements)-1]
+		assert.Equal(t, MovementReceipt, movement.Type)
+		assert.Equal(t, "rma 1 restocked: wrong size", movement.Reason)
+		assert.Equal(t, "dock", movement.Actor)
+	}
+	product, _ = products.GetById(2)
+	assert.Equal(t, 0, product.Quantity, "expect quarantined goods to stay out of stock")
+
+	_, err = service.DisposeRmaLine(rma.Id, rma.Lines[0].Id, RmaDisposition{Disposition: DispositionScrap, Note: "torn"})
+	assert.ErrorIs(t, err, errRmaLineNotQuarantined)
+	_, err = service.DisposeRmaLine(rma.Id, 7, RmaDisposition{Disposition: DispositionScrap, Note: "torn"})
+	assert.ErrorIs(t, err, errRmaLineNotFound)
+
+	closed, err := service.DisposeRmaLine(rma.Id, rma.Lines[1].Id, RmaDisposition{Disposition: DispositionScrap, Note: "contents spoiled"})
+	assert.NoError(t, err, "dispose should succeed")
+	assert.Equal(t, RmaClosed, closed.Status)
+	assert.Equal(t, DispositionScrap, closed.Lines[1].Disposition)
+	assert.Equal(t, "contents spoiled", closed.Lines[1].Note)
+	product, _ = products.GetById(2)
+	assert.Equal(t, 0, product.Quantity, "expect scrapped goods to be written off")
+
+	_, err = service.CancelRma(rma.Id)
+	var te *transitionError
+	if assert.ErrorAs(t, err, &te, "expect a closed rma not to be cancelled") {
+		assert.Equal(t, "cannot cancel a return that is closed", te.Error())
+	}
+}
+
+func TestRmaServiceImpl_RestockLots(t *testing.T) {
+	orders, products, order := setupSalesOrders(t, SalesOrderLine{ProductId: 1, Quantity: 4})
+	lot, err := products.CreateLot(Lot{ProductId: 1, Number: "L1", ExpiresAt: time.Now().AddDate(0, 1, 0), Quantity: 2})
+	assert.NoError(t, err, "create lot should succeed")
+	for _, step := range []func(int) (SalesOrder, error){orders.AllocateSalesOrder, orders.PickSalesOrder, orders.PackSalesOrder, orders.ShipSalesOrder} {
+		order, err = step(order.Id)
+		assert.NoError(t, err, "expect no error")
+	}
+	assert.Equal(t, []LotQuantity{{LotId: lot.Id, Quantity: 2}}, order.Lines[0].Lots, "expect the line to keep the lots it shipped from")
+
+	service := NewRmaServiceImpl(orders.repo, products)
+	lineId := order.Lines[0].Id
+	first, err := service.CreateRma(Rma{SalesOrderId: order.Id, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: lineId, Quantity: 2}}})
+	assert.NoError(t, err, "create rma should succeed")
+	assert.Equal(t, []LotQuantity{{LotId: lot.Id, Quantity: 1}}, first.Lines[0].Lots, "expect a share of the lots shipped")
+	second, err := service.CreateRma(Rma{SalesOrderId: order.Id, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: lineId, Quantity: 2}}})
+	assert.NoError(t, err, "create rma should succeed")
+	assert.Equal(t, []LotQuantity{{LotId: lot.Id, Quantity: 1}}, second.Lines[0].Lots, "expect the rest of the lots shipped")
+
+	for _, rma := range []Rma{first, second} {
+		_, err = service.ReceiveRma(rma.Id, RmaReceipt{Lines: []RmaDispositionLine{{LineId: rma.Lines[0].Id, Disposition: DispositionRestock}}})
+		assert.NoError(t, err, "receive should succeed")
+	}
+	stored, _ := products.GetLotById(lot.Id)
+	assert.Equal(t, 2, stored.Quantity, "expect restocked goods back in their lot")
+	product, _ := products.GetById(1)
+	assert.Equal(t, 7, product.Quantity, "expect all the goods back in stock")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRma(t *testing.T) {
+	tests := []struct {
+		name         string
+		rma          Rma
+		wantFailures []string
+	}{
+		{
+			name: "valid rma",
+			rma:  Rma{SalesOrderId: 1, Reason: "damaged", Lines: []RmaLine{{SalesOrderLineId: 1, Quantity: 1}}},
+		},
+		{
+			name:         "empty rma",
+			rma:          Rma{},
+			wantFailures: []string{"SalesOrderId should be greater than 0", "Reason should not be empty", "Lines should not be empty"},
+		},
+		{
+			name: "invalid line",
+			rma:  Rma{SalesOrderId: 1, Reason: "damaged", Lines: []RmaLine{{}}},
+			wantFailures: []string{
+				"line 1: SalesOrderLineId should be greater than 0",
+				"line 1: Quantity should be greater than 0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRma(tt.rma)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestValidateReturnable(t *testing.T) {
+	order := SalesOrder{Id: 1, Lines: []SalesOrderLine{{Id: 1, ProductId: 1, Quantity: 3}, {Id: 2, ProductId: 2, Quantity: 1}}}
+
+	tests := []struct {
+		name         string
+		lines        []RmaLine
+		returned     map[int]int
+		wantFailures []string
+	}{
+		{
+			name:  "everything shipped",
+			lines: []RmaLine{{SalesOrderLineId: 1, Quantity: 3}, {SalesOrderLineId: 2, Quantity: 1}},
+		},
+		{
+			name:         "foreign line",
+			lines:        []RmaLine{{SalesOrderLineId: 7, Quantity: 1}},
+			wantFailures: []string{"line 1: SalesOrderLineId 7 is not a line of the sales order"},
+		},
+		{
+			name:         "already returned",
+			lines:        []RmaLine{{SalesOrderLineId: 1, Quantity: 2}},
+			returned:     map[int]int{1: 2},
+			wantFailures: []string{"line 1: Quantity should not be more than the 1 left to return"},
+		},
+		{
+			name:         "line given twice",
+			lines:        []RmaLine{{SalesOrderLineId: 1, Quantity: 2}, {SalesOrderLineId: 1, Quantity: 2}},
+			wantFailures: []string{"line 2: Quantity should not be more than the 1 left to return"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReturnable(Rma{Lines: tt.lines}, order, tt.returned)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestValidateRmaReceipt(t *testing.T) {
+	rma := Rma{Lines: []RmaLine{{Id: 1}, {Id: 2}}}
+
+	tests := []struct {
+		name         string
+		lines        []RmaDispositionLine
+		wantFailures []string
+	}{
+		{
+			name: "every line disposed",
+			lines: []RmaDispositionLine{
+				{LineId: 1, Disposition: DispositionRestock},
+				{LineId: 2, Disposition: DispositionQuarantine, Note: "seal broken"},
+			},
+		},
+		{
+			name:         "missing line",
+			lines:        []RmaDispositionLine{{LineId: 1, Disposition: DispositionRestock}},
+			wantFailures: []string{"line 2 of the rma needs a disposition"},
+		},
+		{
+			name: "invalid dispositions",
+			lines: []RmaDispositionLine{
+				{LineId: 1, Disposition: "resell"},
+				{LineId: 2, Disposition: DispositionScrap},
+				{LineId: 2, Disposition: DispositionRestock},
+			},
+			wantFailures: []string{
+				"line 1: Disposition should be one of restock, quarantine, scrap",
+				"line 2: Note should say why the goods are not restocked",
+				"line 3: LineId 2 is not a line of the rma or was given twice",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRmaReceipt(rma, RmaReceipt{Lines: tt.lines})
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestValidateRmaDisposition(t *testing.T) {
+	assert.NoError(t, validateRmaDisposition(RmaDisposition{Disposition: DispositionScrap, Note: "mouldy"}), "expect no error")
+
+	err := validateRmaDisposition(RmaDisposition{Disposition: DispositionQuarantine, Note: "again"})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{"Disposition should be one of restock, scrap"}, ve.failures)
+	}
+}
+
+func TestParseRmaFilter(t *testing.T) {
+	filter, err := parseRmaFilter(url.Values{"salesOrderId": {"3"}, "status": {RmaReceived}})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, RmaFilter{SalesOrderId: 3, Status: RmaReceived}, filter)
+
+	_, err = parseRmaFilter(url.Values{"salesOrderId": {"x"}, "status": {"lost"}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{"salesOrderId should be an integer", "status should be one of open, received, closed, cancelled"}, ve.failures)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Statuses of a sales order. Allocating reserves the stock of every line,
+// which only leaves the stock when the order ships. Until then the order can
+// be cancelled, giving the stock back.
+const (
+	SalesOrderCreated   = "created"
+	SalesOrderAllocated = "allocated"
+	SalesOrderPicked    = "picked"
+	SalesOrderPacked    = "packed"
+	SalesOrderShipped   = "shipped"
+	SalesOrderDelivered = "delivered"
+	SalesOrderCancelled = "cancelled"
+)
+
+// SalesOrder is stock sold to Customer.
+type SalesOrder struct {
+	Id          int              `json:"id" bun:"id,pk,autoincrement"`
+	Customer    string           `json:"customer"`
+	Reference   string           `json:"reference"`
+	Status      string           `json:"status"`
+	Lines       []SalesOrderLine `json:"lines" bun:"rel:has-many,join:id=sales_order_id"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+	ShippedAt   time.Time        `json:"shippedAt,omitempty" bun:",nullzero"`
+	DeliveredAt time.Time        `json:"deliveredAt,omitempty" bun:",nullzero"`
+}
+
+// SalesOrderLine sells Quantity of a product. ReservationId is the
+// reservation holding its stock once the order is allocated, Lots the lots
+// the stock was taken from once it shipped.
+type SalesOrderLine struct {
+	Id            int           `json:"id" bun:"id,pk,autoincrement"`
+	SalesOrderId  int           `json:"-"`
+	ProductId     int           `json:"productId"`
+	Quantity      int           `json:"quantity"`
+	UnitPrice     float64       `json:"unitPrice"`
+	ReservationId int           `json:"reservationId,omitempty" bun:",nullzero"`
+	Lots          []LotQuantity `json:"lots,omitempty" bun:",type:jsonb,nullzero"`
+}
+
+// SalesOrderFilter narrows down GetSalesOrders, zero fields match
+// everything.
+type SalesOrderFilter struct {
+	Customer string
+	Status   string
+}
+
+func validateSalesOrder(order SalesOrder) error {
+	failures := make([]string, 0)
+
+	if order.Customer == "" {
+		failures = append(failures, "Customer should not be empty")
+	}
+	if len(order.Lines) == 0 {
+		failures = append(failures, "Lines should not be empty")
+	}
+	for idx, line := range order.Lines {
+		if line.ProductId <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: ProductId should be greater than 0", idx+1))
+		}
+		if line.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should be greater than 0", idx+1))
+		}
+		if line.UnitPrice < 0 {
+			failures = append(failures, fmt.Sprintf("line %d: UnitPrice should not be less than 0", idx+1))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// parseSalesOrderFilter reads the customer and status query parameters.
+func parseSalesOrderFilter(values url.Values) (SalesOrderFilter, error) {
+	filter := SalesOrderFilter{Customer: values.Get("customer"), Status: values.Get("status")}
+
+	switch filter.Status {
+	case "", SalesOrderCreated, SalesOrderAllocated, SalesOrderPicked, SalesOrderPacked, SalesOrderShipped, SalesOrderDelivered, SalesOrderCancelled:
+		return filter, nil
+	}
+	return SalesOrderFilter{}, &validationError{failures: []string{
+		fmt.Sprintf("status should be one of %s, %s, %s, %s, %s, %s, %s", SalesOrderCreated, SalesOrderAllocated, SalesOrderPicked, SalesOrderPacked, SalesOrderShipped, SalesOrderDelivered, SalesOrderCancelled),
+	}}
+}
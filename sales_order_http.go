@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type salesOrderTransport struct {
+	service SalesOrderService
+}
+
+func NewSalesOrderTransport(svc SalesOrderService) *salesOrderTransport {
+	return &salesOrderTransport{
+		service: svc,
+	}
+}
+
+func (t *salesOrderTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/sales-orders", t.CreateSalesOrder).Methods("POST")
+	r.HandleFunc("/sales-orders", t.GetSalesOrders).Methods("GET")
+	r.HandleFunc("/sales-orders/{id}", t.GetSalesOrderById).Methods("GET")
+	r.HandleFunc("/sales-orders/{id}/allocate", t.AllocateSalesOrder).Methods("POST")
+	r.HandleFunc("/sales-orders/{id}/pick", t.PickSalesOrder).Methods("POST")
+	r.HandleFunc("/sales-orders/{id}/pack", t.PackSalesOrder).Methods("POST")
+	r.HandleFunc("/sales-orders/{id}/ship", t.ShipSalesOrder).Methods("POST")
+	r.HandleFunc("/sales-orders/{id}/deliver", t.DeliverSalesOrder).Methods("POST")
+	r.HandleFunc("/sales-orders/{id}/cancel", t.CancelSalesOrder).Methods("POST")
+}
+
+func (t *salesOrderTransport) CreateSalesOrder(w http.ResponseWriter, r *http.Request) {
+	var order SalesOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreateSalesOrder(order)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *salesOrderTransport) GetSalesOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSalesOrderFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	orders, err := t.service.GetSalesOrders(filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (t *salesOrderTransport) GetSalesOrderById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := t.service.GetSalesOrderById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (t *salesOrderTransport) AllocateSalesOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.AllocateSalesOrder)
+}
+
+func (t *salesOrderTransport) PickSalesOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.PickSalesOrder)
+}
+
+func (t *salesOrderTransport) PackSalesOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.PackSalesOrder)
+}
+
+func (t *salesOrderTransport) ShipSalesOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.ShipSalesOrder)
+}
+
+func (t *salesOrderTransport) DeliverSalesOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.DeliverSalesOrder)
+}
+
+func (t *salesOrderTransport) CancelSalesOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.CancelSalesOrder)
+}
+
+// transition runs one step of the order named in the path.
+func (t *salesOrderTransport) transition(w http.ResponseWriter, r *http.Request, step func(id int) (SalesOrder, error)) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := step(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSalesOrderTransport(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}})
+	products := NewProductServiceImpl(repo)
+	handler := buildHttpHandler(
+		NewhttpTransport(products),
+		NewSalesOrderTransport(NewSalesOrderServiceImpl(repo, products)),
+	)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "invalid sales order",
+			method:         "POST",
+			url:            "/sales-orders",
+			body:           `{"lines": []}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Customer should not be empty", "Lines should not be empty"]}`,
+		},
+		{
+			name:           "unknown product",
+			method:         "POST",
+			url:            "/sales-orders",
+			body:           `{"customer": "Jane", "lines": [{"productId": 7, "quantity": 1}]}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["product not found"]}`,
+		},
+		{
+			name:           "create sales order",
+			method:         "POST",
+			url:            "/sales-orders",
+			body:           `{"customer": "Jane", "reference": "SO-1", "lines": [{"productId": 1, "quantity": 2, "unitPrice": 9.99}]}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "pick before allocating",
+			method:         "POST",
+			url:            "/sales-orders/1/pick",
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["cannot pick a sales order that is created"]}`,
+		},
+		{
+			name:           "allocate",
+			method:         "POST",
+			url:            "/sales-orders/1/allocate",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "pick",
+			method:         "POST",
+			url:            "/sales-orders/1/pick",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "pack",
+			method:         "POST",
+			url:            "/sales-orders/1/pack",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "ship",
+			method:         "POST",
+			url:            "/sales-orders/1/ship",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "cancel after shipping",
+			method:         "POST",
+			url:            "/sales-orders/1/cancel",
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["cannot cancel a sales order that is shipped"]}`,
+		},
+		{
+			name:           "deliver",
+			method:         "POST",
+			url:            "/sales-orders/1/deliver",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "filter by status",
+			method:         "GET",
+			url:            "/sales-orders?status=shipped",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "unknown sales order",
+			method:         "POST",
+			url:            "/sales-orders/7/allocate",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["sales order not found"]}`,
+		},
+		{
+			name:           "invalid id",
+			method:         "POST",
+			url:            "/sales-orders/abc/ship",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid id"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/sales-orders/1", nil))
+	var order SalesOrder
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&order), "expect sales order response")
+	assert.Equal(t, SalesOrderDelivered, order.Status)
+	assert.False(t, order.DeliveredAt.IsZero(), "expect the delivery time")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+	var product Product
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&product), "expect product response")
+	assert.Equal(t, 3, product.Quantity, "expect the shipment to take the stock out")
+}
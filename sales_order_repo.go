@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+)
+
+var errSalesOrderNotFound = errors.New("sales order not found")
+
+// SalesOrderRepo stores sales orders with their lines, ordered by id. The
+// lines are fixed once the order is created, only their reservations change.
+type SalesOrderRepo interface {
+	// CreateSalesOrder fails with errProductNotFound when a line refers to a
+	// product that does not exist.
+	CreateSalesOrder(SalesOrder) (SalesOrder, error)
+	GetSalesOrderById(id int) (SalesOrder, error)
+	// LockSalesOrder gets the order like GetSalesOrderById and makes other
+	// transactions locking it wait until the calling one ends.
+	LockSalesOrder(id int) (SalesOrder, error)
+	GetSalesOrders(SalesOrderFilter) ([]SalesOrder, error)
+	// UpdateSalesOrder writes the status and timestamps of the order and the
+	// reservations of its lines.
+	UpdateSalesOrder(SalesOrder) error
+}
+
+func (r *InMemoryRepo) CreateSalesOrder(order SalesOrder) (SalesOrder, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for _, line := range order.Lines {
+		if _, err := r.GetById(line.ProductId); err != nil {
+			return SalesOrder{}, err
+		}
+	}
+
+	r.lastSalesOrderId++
+	order.Id = r.lastSalesOrderId
+	lines := make([]SalesOrderLine, len(order.Lines))
+	for idx, line := range order.Lines {
+		r.lastSalesOrderLineId++
+		line.Id = r.lastSalesOrderLineId
+		line.SalesOrderId = order.Id
+		lines[idx] = line
+	}
+	order.Lines = lines
+	r.salesOrders = append(r.salesOrders, order)
+	return copySalesOrder(order), nil
+}
+
+func (r *InMemoryRepo) GetSalesOrderById(id int) (SalesOrder, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentOrder := range r.salesOrders {
+		if currentOrder.Id == id {
+			return copySalesOrder(currentOrder), nil
+		}
+	}
+	return SalesOrder{}, errSalesOrderNotFound
+}
+
+// LockSalesOrder needs no lock of its own, InTx already runs one transaction
+// at a time.
+func (r *InMemoryRepo) LockSalesOrder(id int) (SalesOrder, error) {
+	return r.GetSalesOrderById(id)
+}
+
+func (r *InMemoryRepo) GetSalesOrders(filter SalesOrderFilter) ([]SalesOrder, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	orders := make([]SalesOrder, 0)
+	for _, currentOrder := range r.salesOrders {
+		if filter.Customer != "" && currentOrder.Customer != filter.Customer {
+			continue
+		}
+		if filter.Status != "" && currentOrder.Status != filter.Status {
+			continue
+		}
+		orders = append(orders, copySalesOrder(currentOrder))
+	}
+	return orders, nil
+}
+
+func (r *InMemoryRepo) UpdateSalesOrder(order SalesOrder) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentOrder := range r.salesOrders {
+		if currentOrder.Id == order.Id {
+			updated := copySalesOrder(currentOrder)
+			updated.Status = order.Status
+			updated.UpdatedAt = order.UpdatedAt
+			updated.ShippedAt = order.ShippedAt
+			updated.DeliveredAt = order.DeliveredAt
+			for lineIdx, line := range updated.Lines {
+				for _, changed := range order.Lines {
+					if changed.Id == line.Id {
+						updated.Lines[lineIdx].ReservationId = changed.ReservationId
+						updated.Lines[lineIdx].Lots = changed.Lots
+					}
+				}
+			}
+			r.salesOrders[idx] = updated
+			return nil
+		}
+	}
+	return errSalesOrderNotFound
+}
+
+// copySalesOrder keeps callers from changing the stored lines.
+func copySalesOrder(order SalesOrder) SalesOrder {
+	order.Lines = append([]SalesOrderLine{}, order.Lines...)
+	return order
+}
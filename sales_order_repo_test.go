@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_SalesOrders(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A"}, {Id: 2, Brand: "B", Category: "B"}})
+
+	_, err := repo.CreateSalesOrder(SalesOrder{Customer: "Jane", Lines: []SalesOrderLine{{ProductId: 7, Quantity: 1}}})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	order, err := repo.CreateSalesOrder(SalesOrder{
+		Customer: "Jane",
+		Status:   SalesOrderCreated,
+		Lines:    []SalesOrderLine{{ProductId: 1, Quantity: 1}, {ProductId: 2, Quantity: 2}},
+	})
+	assert.NoError(t, err, "create sales order should succeed")
+	assert.Equal(t, []int{1, 2}, []int{order.Lines[0].Id, order.Lines[1].Id}, "expect lines to be numbered")
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	order.Status = SalesOrderShipped
+	order.ShippedAt = now
+	order.Customer = "John"
+	order.Lines[1].ReservationId = 3
+	order.Lines[1].Quantity = 9
+	assert.NoError(t, repo.UpdateSalesOrder(order), "update sales order should succeed")
+
+	stored, _ := repo.GetSalesOrderById(order.Id)
+	assert.Equal(t, SalesOrderShipped, stored.Status)
+	assert.Equal(t, now, stored.ShippedAt)
+	assert.Equal(t, "Jane", stored.Customer, "expect only the status and timestamps to be written")
+	assert.Equal(t, 3, stored.Lines[1].ReservationId)
+	assert.Equal(t, 2, stored.Lines[1].Quantity, "expect the lines to be fixed")
+	assert.ErrorIs(t, repo.UpdateSalesOrder(SalesOrder{Id: 7}), errSalesOrderNotFound)
+
+	orders, err := repo.GetSalesOrders(SalesOrderFilter{Customer: "Jane", Status: SalesOrderCreated})
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, orders, "expect the status filter to apply")
+	orders, err = repo.GetSalesOrders(SalesOrderFilter{Customer: "Jane"})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, orders, 1)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SalesOrderService interface {
+	CreateSalesOrder(SalesOrder) (SalesOrder, error)
+	GetSalesOrderById(id int) (SalesOrder, error)
+	GetSalesOrders(SalesOrderFilter) ([]SalesOrder, error)
+	AllocateSalesOrder(id int) (SalesOrder, error)
+	PickSalesOrder(id int) (SalesOrder, error)
+	PackSalesOrder(id int) (SalesOrder, error)
+	ShipSalesOrder(id int) (SalesOrder, error)
+	DeliverSalesOrder(id int) (SalesOrder, error)
+	CancelSalesOrder(id int) (SalesOrder, error)
+}
+
+// SalesOrderServiceImpl holds and takes the stock of orders through the
+// reservations of the product service, so every change is in the stock
+// ledger and published.
+type SalesOrderServiceImpl struct {
+	repo     Repo
+	products *ProductServiceImpl
+}
+
+func NewSalesOrderServiceImpl(repo Repo, products *ProductServiceImpl) *SalesOrderServiceImpl {
+	return &SalesOrderServiceImpl{
+		repo:     repo,
+		products: products,
+	}
+}
+
+func (s *SalesOrderServiceImpl) CreateSalesOrder(order SalesOrder) (SalesOrder, error) {
+	if err := validateSalesOrder(order); err != nil {
+		return SalesOrder{}, fmt.Errorf("create sales order: %w", err)
+	}
+
+	timeNow := time.Now()
+	order.Status = SalesOrderCreated
+	order.CreatedAt = timeNow
+	order.UpdatedAt = timeNow
+	order.ShippedAt = time.Time{}
+	order.DeliveredAt = time.Time{}
+	lines := make([]SalesOrderLine, len(order.Lines))
+	for idx, line := range order.Lines {
+		lines[idx] = SalesOrderLine{ProductId: line.ProductId, Quantity: line.Quantity, UnitPrice: line.UnitPrice}
+	}
+	order.Lines = lines
+	return s.repo.CreateSalesOrder(order)
+}
+
+func (s *SalesOrderServiceImpl) GetSalesOrderById(id int) (SalesOrder, error) {
+	return s.repo.GetSalesOrderById(id)
+}
+
+func (s *SalesOrderServiceImpl) GetSalesOrders(filter SalesOrderFilter) ([]SalesOrder, error) {
+	return s.repo.GetSalesOrders(filter)
+}
+
+// AllocateSalesOrder reserves the stock of every line, or of none when one
+// of them is short.
+func (s *SalesOrderServiceImpl) AllocateSalesOrder(id int) (SalesOrder, error) {
+	return s.transition(id, "allocate", SalesOrderAllocated, []string{SalesOrderCreated}, func(tx *ProductServiceImpl, order *SalesOrder, now time.Time) error {
+		for idx, line := range order.Lines {
+			reservation, err := tx.hold(Reservation{ProductId: line.ProductId, Quantity: line.Quantity, Reference: order.Reference}, now)
+			if err != nil {
+				return err
+			}
+			order.Lines[idx].ReservationId = reservation.Id
+		}
+		return nil
+	})
+}
+
+func (s *SalesOrderServiceImpl) PickSalesOrder(id int) (SalesOrder, error) {
+	return s.transition(id, "pick", SalesOrderPicked, []string{SalesOrderAllocated}, nil)
+}
+
+func (s *SalesOrderServiceImpl) PackSalesOrder(id int) (SalesOrder, error) {
+	return s.transition(id, "pack", SalesOrderPacked, []string{SalesOrderPicked}, nil)
+}
+
+// ShipSalesOrder takes the reserved stock of every line out with an issue
+// movement, keeping the lots it came from on the line.
+func (s *SalesOrderServiceImpl) ShipSalesOrder(id int) (SalesOrder, error) {
+	return s.transition(id, "ship", SalesOrderShipped, []string{SalesOrderPacked}, func(tx *ProductServiceImpl, order *SalesOrder, now time.Time) error {
+		reason := fmt.Sprintf("sales order %d shipped", order.Id)
+		for idx, line := range order.Lines {
+			reservation, err := tx.repo.GetReservationById(line.ReservationId)
+			if err != nil {
+				return err
+			}
+			_, issued, err := tx.takeReserved(reservation, reason)
+			if err != nil {
+				return err
+			}
+			order.Lines[idx].Lots = issued.Lots
+		}
+		order.ShippedAt = now
+		return nil
+	})
+}
+
+func (s *SalesOrderServiceImpl) DeliverSalesOrder(id int) (SalesOrder, error) {
+	return s.transition(id, "deliver", SalesOrderDelivered, []string{SalesOrderShipped}, func(tx *ProductServiceImpl, order *SalesOrder, now time.Time) error {
+		order.DeliveredAt = now
+		return nil
+	})
+}
+
+// CancelSalesOrder gives back the stock an order that has not shipped yet
+// holds.
+func (s *SalesOrderServiceImpl) CancelSalesOrder(id int) (SalesOrder, error) {
+	from := []string{SalesOrderCreated, SalesOrderAllocated, SalesOrderPicked, SalesOrderPacked}
+	return s.transition(id, "cancel", SalesOrderCancelled, from, func(tx *ProductServiceImpl, order *SalesOrder, now time.Time) error {
+		for _, line := range order.Lines {
+			if line.ReservationId == 0 {
+				continue
+			}
+			// a reservation ended by hand holds nothing to give back
+			if _, err := tx.ReleaseReservation(line.ReservationId); err != nil && !errors.Is(err, errReservationNotActive) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transition moves the order with id from one of from to status, running fn
+// in the same transaction to do what the step takes.
+func (s *SalesOrderServiceImpl) transition(id int, action, status string, from []string, fn func(tx *ProductServiceImpl, order *SalesOrder, now time.Time) error) (SalesOrder, error) {
+	var order SalesOrder
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		order, err = tx.repo.LockSalesOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("sales order", action, order.Status, from...); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if fn != nil {
+			if err := fn(tx, &order, now); err != nil {
+				return err
+			}
+		}
+		order.Status = status
+		order.UpdatedAt = now
+		return tx.repo.UpdateSalesOrder(order)
+	})
+	if err != nil {
+		return SalesOrder{}, err
+	}
+	return order, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSalesOrders(t *testing.T, lines ...SalesOrderLine) (*SalesOrderServiceImpl, *ProductServiceImpl, SalesOrder) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Quantity: 5, Version: 1}, {Id: 2, Brand: "B", Category: "B", Quantity: 1, Version: 1}})
+	products := NewProductServiceImpl(repo)
+	service := NewSalesOrderServiceImpl(repo, products)
+
+	order, err := service.CreateSalesOrder(SalesOrder{Customer: "Jane", Reference: "SO-1", Lines: lines})
+	assert.NoError(t, err, "create sales order should succeed")
+	return service, products, order
+}
+
+func TestSalesOrderServiceImpl_Fulfil(t *testing.T) {
+	service, products, order := setupSalesOrders(t, SalesOrderLine{ProductId: 1, Quantity: 3}, SalesOrderLine{ProductId: 2, Quantity: 1})
+	assert.Equal(t, SalesOrderCreated, order.Status)
+
+	_, err := service.ShipSalesOrder(order.Id)
+	var te *transitionError
+	if assert.ErrorAs(t, err, &te, "expect an order to be packed before it ships") {
+		assert.Equal(t, "cannot ship a sales order that is created", te.Error())
+	}
+
+	allocated, err := service.AllocateSalesOrder(order.Id)
+	assert.NoError(t, err, "allocate should succeed")
+	assert.NotZero(t, allocated.Lines[0].ReservationId, "expect every line to be reserved")
+	product, _ := products.GetById(1)
+	assert.Equal(t, 3, product.Reserved)
+	assert.Equal(t, 5, product.Quantity, "expect allocation to leave the stock on hand")
+
+	_, err = products.ExpireReservations(time.Now().Add(24 * time.Hour))
+	assert.NoError(t, err, "expect no error")
+	product, _ = products.GetById(1)
+	assert.Equal(t, 3, product.Reserved, "expect allocations not to expire")
+
+	for _, step := range []func(int) (SalesOrder, error){service.PickSalesOrder, service.PackSalesOrder} {
+		_, err := step(order.Id)
+		assert.NoError(t, err, "expect no error")
+	}
+	shipped, err := service.ShipSalesOrder(order.Id)
+	assert.NoError(t, err, "ship should succeed")
+	assert.Equal(t, SalesOrderShipped, shipped.Status)
+	assert.False(t, shipped.ShippedAt.IsZero(), "expect the shipping time")
+
+	product, _ = products.GetById(1)
+	assert.Equal(t, 2, product.Quantity, "expect shipping to take the stock out")
+	assert.Equal(t, 0, product.Reserved)
+	movements, _ := products.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		movement := movements[len(movements)-1]
+		assert.Equal(t, MovementIssue, movement.Type)
+		assert.Equal(t, "sales order 1 shipped", movement.Reason)
+		assert.Equal(t, "SO-1", movement.Reference)
+	}
+
+	_, err = service.CancelSalesOrder(order.Id)
+	assert.ErrorAs(t, err, &te, "expect a shipped order not to be cancelled")
+	delivered, err := service.DeliverSalesOrder(order.Id)
+	assert.NoError(t, err, "deliver should succeed")
+	assert.Equal(t, SalesOrderDelivered, delivered.Status)
+}
+
+func TestSalesOrderServiceImpl_AllocateShort(t *testing.T) {
+	service, products, order := setupSalesOrders(t, SalesOrderLine{ProductId: 1, Quantity: 3}, SalesOrderLine{ProductId: 2, Quantity: 2})
+
+	_, err := service.AllocateSalesOrder(order.Id)
+	assert.ErrorIs(t, err, errInsufficientStock)
+
+	stored, _ := service.GetSalesOrderById(order.Id)
+	assert.Equal(t, SalesOrderCreated, stored.Status, "expect a short order to stay created")
+	assert.Zero(t, stored.Lines[0].ReservationId)
+	product, _ := products.GetById(1)
+	assert.Equal(t, 0, product.Reserved, "expect no line to be reserved")
+}
+
+func TestSalesOrderServiceImpl_Cancel(t *testing.T) {
+	service, products, order := setupSalesOrders(t, SalesOrderLine{ProductId: 1, Quantity: 3})
+
+	_, err := service.AllocateSalesOrder(order.Id)
+	assert.NoError(t, err, "allocate should succeed")
+	_, err = service.PickSalesOrder(order.Id)
+	assert.NoError(t, err, "pick should succeed")
+
+	cancelled, err := service.CancelSalesOrder(order.Id)
+	assert.NoError(t, err, "cancel should succeed")
+	assert.Equal(t, SalesOrderCancelled, cancelled.Status)
+
+	product, _ := products.GetById(1)
+	assert.Equal(t, 0, product.Reserved, "expect cancelling to give the stock back")
+	assert.Equal(t, 5, product.Quantity)
+	reservation, _ := products.GetReservationById(cancelled.Lines[0].ReservationId)
+	assert.Equal(t, ReservationReleased, reservation.Status)
+
+	_, err = service.AllocateSalesOrder(order.Id)
+	var te *transitionError
+	assert.ErrorAs(t, err, &te, "expect a cancelled order to stay cancelled")
+}
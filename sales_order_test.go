@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSalesOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		order        SalesOrder
+		wantFailures []string
+	}{
+		{
+			name:  "valid order",
+			order: SalesOrder{Customer: "Jane", Lines: []SalesOrderLine{{ProductId: 1, Quantity: 2, UnitPrice: 9.99}}},
+		},
+		{
+			name:         "empty order",
+			order:        SalesOrder{},
+			wantFailures: []string{"Customer should not be empty", "Lines should not be empty"},
+		},
+		{
+			name:  "invalid line",
+			order: SalesOrder{Customer: "Jane", Lines: []SalesOrderLine{{UnitPrice: -1}}},
+			wantFailures: []string{
+				"line 1: ProductId should be greater than 0",
+				"line 1: Quantity should be greater than 0",
+				"line 1: UnitPrice should not be less than 0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSalesOrder(tt.order)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestParseSalesOrderFilter(t *testing.T) {
+	filter, err := parseSalesOrderFilter(url.Values{"customer": {"Jane"}, "status": {SalesOrderPacked}})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, SalesOrderFilter{Customer: "Jane", Status: SalesOrderPacked}, filter)
+
+	_, err = parseSalesOrderFilter(url.Values{"status": {"lost"}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{"status should be one of created, allocated, picked, packed, shipped, delivered, cancelled"}, ve.failures)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var errSearchNotSupported = errors.New("search not supported")
+
+type SearchResult struct {
+	Product Product `json:"product"`
+	Rank    float64 `json:"rank"`
+}
+
+// SearchRepo is implemented by repos that can search products by text. It is
+// kept apart from Repo so a repo without a text index still satisfies Repo.
+type SearchRepo interface {
+	Search(query string, limit int) ([]SearchResult, error)
+}
+
+// tokenize lower cases text and splits it on everything that is not a letter
+// or a digit.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func searchableText(product Product) string {
+	return product.Brand + " " + product.Category
+}
+
+// invertedIndex maps every token of the searchable text of a product to the
+// products containing it.
+type invertedIndex struct {
+	postings map[string]map[int]int
+	tokens   map[int][]string
+}
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings: make(map[string]map[int]int),
+		tokens:   make(map[int][]string),
+	}
+}
+
+func (idx *invertedIndex) add(id int, text string) {
+	idx.remove(id)
+
+	tokens := tokenize(text)
+	for _, token := range tokens {
+		if idx.postings[token] == nil {
+			idx.postings[token] = make(map[int]int)
+		}
+		idx.postings[token][id]++
+	}
+	idx.tokens[id] = tokens
+}
+
+func (idx *invertedIndex) remove(id int) {
+	for _, token := range idx.tokens[id] {
+		delete(idx.postings[token], id)
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+	delete(idx.tokens, id)
+}
+
+// search returns the ids of the products matching every term together with
+// their rank. A term matches a token it is a prefix of; exact matches rank
+// higher than prefix matches.
+func (idx *invertedIndex) search(terms []string) map[int]float64 {
+	var ranks map[int]float64
+	for _, term := range terms {
+		termRanks := make(map[int]float64)
+		for token, postings := range idx.postings {
+			if !strings.HasPrefix(token, term) {
+				continue
+			}
+			weight := float64(len(term)) / float64(len(token))
+			for id, occurrences := range postings {
+				termRanks[id] += weight * float64(occurrences)
+			}
+		}
+
+		if ranks == nil {
+			ranks = termRanks
+			continue
+		}
+		for id := range ranks {
+			if termRank, ok := termRanks[id]; ok {
+				ranks[id] += termRank
+			} else {
+				delete(ranks, id)
+			}
+		}
+	}
+	return ranks
+}
+
+// sortSearchResults orders results by rank, best first, ties broken by id.
+func sortSearchResults(results []SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Product.Id < results[j].Product.Id
+	})
+}
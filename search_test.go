@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"coca", "cola", "500ml"}, tokenize("Coca-Cola 500ml"), "expect lower cased tokens")
+	assert.Empty(t, tokenize(" -- "), "expect no tokens without letters or digits")
+}
+
+func TestInMemoryRepo_Search(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Brand: "Samsung", Category: "Phones"},
+		{Id: 2, Brand: "Sam", Category: "Tablets"},
+		{Id: 3, Brand: "Apple", Category: "Phones"},
+		{Id: 4, Brand: "Samsonite", Category: "Luggage"},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		limit   int
+		wantIds []int
+	}{
+		{
+			name:    "exact match ranks above prefix matches",
+			query:   "sam",
+			limit:   10,
+			wantIds: []int{2, 1, 4},
+		},
+		{
+			name:    "every term has to match",
+			query:   "sams phone",
+			limit:   10,
+			wantIds: []int{1},
+		},
+		{
+			name:    "limit",
+			query:   "sam",
+			limit:   1,
+			wantIds: []int{2},
+		},
+		{
+			name:    "no match",
+			query:   "nokia",
+			limit:   10,
+			wantIds: []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+
+			results, err := repo.Search(tt.query, tt.limit)
+			assert.NoError(t, err, "expect no error")
+
+			gotIds := make([]int, 0)
+			for _, result := range results {
+				gotIds = append(gotIds, result.Product.Id)
+			}
+			assert.Equal(t, tt.wantIds, gotIds, "expect same products by rank")
+		})
+	}
+}
+
+func TestInMemoryRepo_SearchFollowsWrites(t *testing.T) {
+	repo := NewInMemoryRepo()
+	assert.NoError(t, repo.Create(Product{Id: 1, Brand: "Nokia", Category: "Phones"}), "create should succeed")
+	assert.NoError(t, repo.Update(Product{Id: 1, Brand: "Apple", Category: "Phones"}), "update should succeed")
+
+	results, err := repo.Search("nokia", 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, results, "expect old brand to be dropped from the index")
+
+	results, err = repo.Search("apple", 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, results, 1, "expect new brand to be indexed")
+
+	assert.NoError(t, repo.Delete(1, 0), "delete should succeed")
+	results, err = repo.Search("apple", 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, results, "expect deleted product to be dropped from the index")
+}
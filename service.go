@@ -1,113 +1,984 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
 type ProductService interface {
-	Create(Product) error
+	Create(Product) (Product, error)
 	Update(Product) error
+	Patch(id, version int, patch ProductPatch) (Product, error)
 	GetById(id int) (Product, error)
+	GetBySku(sku string) (Product, error)
 	GetAll() ([]Product, error)
-	Delete(id int) error
+	GetLowStock() ([]Product, error)
+	List(ProductQuery) (ProductPage, error)
+	Export(query ProductQuery, fn func(Product) error) error
+	Search(query string, limit int) ([]SearchResult, error)
+	Delete(id, version int) error
+	Batch(operations []BatchOperation, partial bool) ([]BatchResult, error)
+	Import(rows []ImportRow, options ImportOptions) (ImportSummary, error)
+	AddMovement(StockMovement) (StockMovement, error)
+	GetMovements(productId int) ([]StockMovement, error)
 	subscribe(Subscriber) error
+	resume(subscriber Subscriber, since int64) error
+	setFilter(subscriber Subscriber, name string, filter EventFilter) error
+	removeFilter(subscriber Subscriber, name string) error
 	unsubscribe(Subscriber) error
-	notify()
+	publish(eventType string, product Product)
 }
 
 type ProductServiceImpl struct {
-	repo        Repo
-	subscribers []Subscriber
+	repo       Repo
+	skuPattern string
+	events     EventLog
+	relay      Relay
+	publisher  PublisherOptions
+	// outbox holds the events of committed writes until RunOutbox
+	// dispatches them to the subscribers and handlers
+	outbox   Outbox
+	handlers []EventHandler
+	wake     chan struct{}
+	// dispatchMu keeps events fanned out in the order they were numbered,
+	// without holding mu while the event log is appended to
+	dispatchMu sync.Mutex
+	// mu guards subscriptions and keeps seq in step with what they were sent
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	seq           int64
+	// deferred collects the events of a service bound to a batch transaction,
+	// they are only published once the batch commits
+	deferred *[]Event
 }
 
 func NewProductServiceImpl(repo Repo) *ProductServiceImpl {
 	return &ProductServiceImpl{
-		repo: repo,
+		repo:          repo,
+		skuPattern:    defaultSkuPattern,
+		publisher:     PublisherOptions{QueueSize: defaultQueueSize, SlowConsumer: SlowConsumerDrop},
+		subscriptions: make(map[string]*subscription),
+		wake:          make(chan struct{}, 1),
 	}
 }
 
-func (s *ProductServiceImpl) Create(product Product) error {
+// SetRelay makes the service pass the events it publishes to the other
+// instances of the server. Events coming the other way have to be handed to
+// relayed, see Relay.Receive.
+func (s *ProductServiceImpl) SetRelay(relay Relay) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.relay = relay
+}
+
+// SetPublisherOptions changes how events are queued for subscribers that
+// subscribe from now on.
+func (s *ProductServiceImpl) SetPublisherOptions(options PublisherOptions) error {
+	if err := validatePublisherOptions(options); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publisher = options
+	return nil
+}
+
+// SetEventLog makes the service record what it publishes in events, so
+// subscribers can resume after a disconnect. Numbering carries on from the
+// last event in the log.
+func (s *ProductServiceImpl) SetEventLog(events EventLog) error {
+	seq, err := events.LastEventSeq()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = events
+	s.seq = seq
+	return nil
+}
+
+// SetOutbox makes writes add their events to outbox in their own transaction
+// instead of dispatching them once they commit. RunOutbox dispatches them from
+// there and hands them to handlers, so a committed change is announced even
+// when the process dies right after the commit. outbox has to store into the
+// same database as the repo and number into the event log.
+func (s *ProductServiceImpl) SetOutbox(outbox Outbox, handlers ...EventHandler) {
+	s.outbox = outbox
+	s.handlers = handlers
+}
+
+// SetSkuPattern changes the pattern SKUs are generated from for products
+// created without one, see generateSku.
+func (s *ProductServiceImpl) SetSkuPattern(pattern string) error {
+	if err := validateSkuPattern(pattern); err != nil {
+		return err
+	}
+	s.skuPattern = pattern
+	return nil
+}
+
+// Create stores a new product. Products without an id get the next free one
+// and products without a SKU get one generated from the SKU pattern.
+func (s *ProductServiceImpl) Create(product Product) (Product, error) {
+	if s.deferred == nil {
+		var created Product
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			created, err = tx.Create(product)
+			return err
+		})
+		return created, err
+	}
 
 	if err := validateProduct(product); err != nil {
-		return fmt.Errorf("create product: %w", err)
+		return Product{}, fmt.Errorf("create product: %w", err)
+	}
+
+	if product.Id == 0 {
+		id, err := s.repo.NextId()
+		if err != nil {
+			return Product{}, err
+		}
+		product.Id = id
+	}
+	if product.Sku == "" {
+		product.Sku = generateSku(s.skuPattern, product)
+		if !validSku.MatchString(product.Sku) {
+			failures := []string{fmt.Sprintf("generated Sku %q is too long, set a Sku or use a shorter sku pattern", product.Sku)}
+			return Product{}, fmt.Errorf("create product: %w", &validationError{failures: failures})
+		}
 	}
 
 	timeNow := time.Now()
 	product.CreatedAt = timeNow
 	product.UpdatedAt = timeNow
+	product.Version = 1
+	// stock is only reserved through Reserve and shipped through transfer
+	// orders
+	product.Reserved = 0
+	product.InTransit = 0
+
+	// the opening stock is booked as a receipt so the ledger always adds up to
+	// the product quantity
+	openingQuantity := product.Quantity
+	product.Quantity = 0
 
 	if err := s.repo.Create(product); err != nil {
-		return err
+		return Product{}, err
 	}
-	s.notify()
-	return nil
+
+	if openingQuantity > 0 {
+		movement := StockMovement{
+			ProductId: product.Id,
+			Type:      MovementReceipt,
+			Quantity:  openingQuantity,
+			Reason:    "opening stock",
+			CreatedAt: timeNow,
+		}
+		if _, err := s.repo.AddMovement(movement); err != nil {
+			return Product{}, err
+		}
+		product.Quantity = openingQuantity
+		product.Version++
+	}
+	s.publish(EventProductCreated, product)
+	return product, nil
 }
 
 func (s *ProductServiceImpl) Update(product Product) error {
+	if s.deferred == nil {
+		return s.write(func(tx *ProductServiceImpl) error {
+			return tx.Update(product)
+		})
+	}
+
 	if err := validateProduct(product); err != nil {
 		return fmt.Errorf("update product: %w", err)
 	}
 
+	current, err := s.repo.GetById(product.Id)
+	if err != nil {
+		return err
+	}
+	// pinning the version read above makes sure the quantity difference below
+	// is taken against what is actually overwritten
+	if product.Version == 0 {
+		product.Version = current.Version
+	}
+
 	product.UpdatedAt = time.Now()
 
 	if err := s.repo.Update(product); err != nil {
 		return err
 	}
-	s.notify()
+
+	// quantity is never overwritten, a changed quantity is booked as an
+	// adjustment against the stored one
+	if diff := product.Quantity - current.Quantity; diff != 0 {
+		movement := StockMovement{
+			ProductId: product.Id,
+			Type:      MovementAdjustment,
+			Quantity:  diff,
+			Reason:    "product update",
+			CreatedAt: product.UpdatedAt,
+		}
+		if _, err := s.bookMovement(movement, current); err != nil {
+			return err
+		}
+	}
+	s.publishStored(EventProductUpdated, current)
 	return nil
+}
+
+// Patch applies patch to the stored product and writes back only what it
+// changed. A patch that changes nothing leaves the product and its version
+// untouched.
+func (s *ProductServiceImpl) Patch(id, version int, patch ProductPatch) (Product, error) {
+	if s.deferred == nil {
+		var patched Product
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			patched, err = tx.Patch(id, version, patch)
+			return err
+		})
+		return patched, err
+	}
 
+	current, err := s.repo.GetById(id)
+	if err != nil {
+		return Product{}, err
+	}
+	if version != 0 && version != current.Version {
+		return Product{}, errVersionConflict
+	}
+
+	patched, err := patch.apply(current)
+	if err != nil {
+		return Product{}, fmt.Errorf("patch product: %w", err)
+	}
+	if err := validateProductPatch(current, patched); err != nil {
+		return Product{}, fmt.Errorf("patch product: %w", err)
+	}
+	if err := validateProduct(patched); err != nil {
+		return Product{}, fmt.Errorf("patch product: %w", err)
+	}
+
+	columns := changedProductColumns(current, patched)
+	diff := patched.Quantity - current.Quantity
+	if len(columns) == 0 && diff == 0 {
+		return current, nil
+	}
+
+	patched.UpdatedAt = time.Now()
+	if err := s.repo.UpdateColumns(patched, columns); err != nil {
+		return Product{}, err
+	}
+
+	if diff != 0 {
+		movement := StockMovement{
+			ProductId: id,
+			Type:      MovementAdjustment,
+			Quantity:  diff,
+			Reason:    "product patch",
+			CreatedAt: patched.UpdatedAt,
+		}
+		if _, err := s.bookMovement(movement, current); err != nil {
+			return Product{}, err
+		}
+	}
+	updated, err := s.repo.GetById(id)
+	if err != nil {
+		return Product{}, err
+	}
+	s.publish(EventProductUpdated, updated)
+	s.publishStockAlert(current, updated)
+	return updated, nil
 }
 
 func (s *ProductServiceImpl) GetAll() ([]Product, error) {
 	return s.repo.GetAll()
 }
 
+func (s *ProductServiceImpl) GetLowStock() ([]Product, error) {
+	return s.repo.GetLowStock()
+}
+
+func (s *ProductServiceImpl) List(query ProductQuery) (ProductPage, error) {
+	if err := validateProductQuery(query); err != nil {
+		return ProductPage{}, fmt.Errorf("list products: %w", err)
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultProductQueryLimit
+	}
+	return s.repo.List(query)
+}
+
+// Export validates query before reading anything, so an invalid query fails
+// before fn is called.
+func (s *ProductServiceImpl) Export(query ProductQuery, fn func(Product) error) error {
+	query.Limit, query.Offset = 0, 0
+	if err := validateProductQuery(query); err != nil {
+		return fmt.Errorf("export products: %w", err)
+	}
+	return s.repo.Export(query, fn)
+}
+
+func (s *ProductServiceImpl) Search(query string, limit int) ([]SearchResult, error) {
+	searchRepo, ok := s.repo.(SearchRepo)
+	if !ok {
+		return nil, errSearchNotSupported
+	}
+
+	failures := make([]string, 0)
+	if strings.TrimSpace(query) == "" {
+		failures = append(failures, "q should not be empty")
+	}
+	if limit < 0 || limit > maxSearchLimit {
+		failures = append(failures, fmt.Sprintf("limit should be between 0 and %d", maxSearchLimit))
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("search products: %w", &validationError{failures: failures})
+	}
+
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	return searchRepo.Search(query, limit)
+}
+
 func (s *ProductServiceImpl) GetById(id int) (Product, error) {
 	return s.repo.GetById(id)
 }
 
-func (s *ProductServiceImpl) Delete(id int) error {
-	if err := s.repo.Delete(id); err != nil {
+func (s *ProductServiceImpl) GetBySku(sku string) (Product, error) {
+	return s.repo.GetBySku(sku)
+}
+
+func (s *ProductServiceImpl) Delete(id, version int) error {
+	if s.deferred == nil {
+		return s.write(func(tx *ProductServiceImpl) error {
+			return tx.Delete(id, version)
+		})
+	}
+
+	product, err := s.repo.GetById(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id, version); err != nil {
 		return err
 	}
-	s.notify()
+	s.publish(EventProductDeleted, product)
 	return nil
 }
 
+// Batch runs operations in one transaction. Every operation is attempted so
+// all failures are reported; unless partial is set a single failure rolls
+// back the whole batch. Events are only published once the batch commits.
+func (s *ProductServiceImpl) Batch(operations []BatchOperation, partial bool) ([]BatchResult, error) {
+	if err := validateBatch(operations); err != nil {
+		return nil, fmt.Errorf("batch: %w", err)
+	}
+	return s.runBatch(operations, partial, false)
+}
+
+// runBatch runs operations as described for Batch. A dry run reports what
+// would happen and then rolls everything back.
+func (s *ProductServiceImpl) runBatch(operations []BatchOperation, partial, dryRun bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(operations))
+	events := make([]Event, 0, len(operations))
+	succeeded := 0
+	err := s.repo.InTx(func(repo Repo) error {
+		for index, operation := range operations {
+			result := BatchResult{Index: index, Op: operation.Op}
+			operationEvents := make([]Event, 0)
+			result.Err = repo.InTx(func(repo Repo) error {
+				product, err := s.bind(repo, &operationEvents).applyBatchOperation(operation)
+				result.Product = product
+				return err
+			})
+			if result.Err != nil {
+				result.Product = nil
+			} else {
+				events = append(events, operationEvents...)
+				succeeded++
+			}
+			results[index] = result
+		}
+
+		if succeeded < len(operations) && !partial {
+			return errBatchFailed
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return s.stage(repo, events)
+	})
+
+	if errors.Is(err, errBatchFailed) {
+		for index := range results {
+			if results[index].Err == nil {
+				results[index].Err = errBatchRolledBack
+				results[index].Product = nil
+			}
+		}
+		return results, nil
+	}
+	if errors.Is(err, errDryRun) {
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(events)
+	return results, nil
+}
+
+// Import creates the products of rows, or with ImportUpsert updates the
+// products matching their SKU or else their id. Either every row is applied
+// or none is, the failing rows are reported in an importError.
+func (s *ProductServiceImpl) Import(rows []ImportRow, options ImportOptions) (ImportSummary, error) {
+	if err := validateImportOptions(options); err != nil {
+		return ImportSummary{}, fmt.Errorf("import: %w", err)
+	}
+
+	operations := make([]BatchOperation, 0, len(rows))
+	rowErrors := make([]ImportRowError, 0)
+	for _, row := range rows {
+		operation := BatchOperation{Op: BatchCreate, Product: row.Product}
+		if options.Mode == ImportUpsert {
+			existing, err := s.findImportTarget(row.Product)
+			if err == nil {
+				operation = BatchOperation{
+					Op:      BatchUpdate,
+					Id:      existing.Id,
+					Version: existing.Version,
+					Product: mergeImportRow(existing, row),
+				}
+			} else if !errors.Is(err, errProductNotFound) {
+				return ImportSummary{}, err
+			}
+		}
+		if err := validateProduct(operation.Product); err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Line: row.Line, Err: err})
+		}
+		operations = append(operations, operation)
+	}
+	if len(rowErrors) > 0 {
+		return ImportSummary{}, &importError{rows: rowErrors}
+	}
+
+	results, err := s.runBatch(operations, false, options.DryRun)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+
+	summary := ImportSummary{DryRun: options.DryRun}
+	for index, result := range results {
+		switch {
+		case result.Err == nil && result.Op == BatchCreate:
+			summary.Created++
+		case result.Err == nil:
+			summary.Updated++
+		case !errors.Is(result.Err, errBatchRolledBack):
+			rowErrors = append(rowErrors, ImportRowError{Line: rows[index].Line, Err: result.Err})
+		}
+	}
+	if len(rowErrors) > 0 {
+		return ImportSummary{}, &importError{rows: rowErrors}
+	}
+	return summary, nil
+}
+
+func (s *ProductServiceImpl) findImportTarget(product Product) (Product, error) {
+	if product.Sku != "" {
+		return s.repo.GetBySku(product.Sku)
+	}
+	if product.Id != 0 {
+		return s.repo.GetById(product.Id)
+	}
+	return Product{}, errProductNotFound
+}
+
+func (s *ProductServiceImpl) AddMovement(movement StockMovement) (StockMovement, error) {
+	if s.deferred == nil {
+		var created StockMovement
+		err := s.write(func(tx *ProductServiceImpl) (err error) {
+			created, err = tx.AddMovement(movement)
+			return err
+		})
+		return created, err
+	}
+
+	// stock in transit has to match the transfer orders shipping it
+	switch movement.Type {
+	case MovementDispatch, MovementArrival, MovementLoss:
+		return StockMovement{}, fmt.Errorf("add movement: %w", &validationError{failures: []string{movementTypeFailure}})
+	}
+	return s.addMovement(movement)
+}
+
+// addMovement validates and books movement, the ones transfer orders book
+// included, and publishes the product. It has to run bound to a transaction.
+func (s *ProductServiceImpl) addMovement(movement StockMovement) (StockMovement, error) {
+	if err := validateMovement(movement); err != nil {
+		return StockMovement{}, fmt.Errorf("add movement: %w", err)
+	}
+
+	movement.CreatedAt = time.Now()
+
+	current, err := s.repo.GetById(movement.ProductId)
+	if err != nil {
+		return StockMovement{}, err
+	}
+	created, err := s.bookMovement(movement, current)
+	if err != nil {
+		return StockMovement{}, err
+	}
+	s.publishStored(EventProductUpdated, current)
+	return created, nil
+}
+
+func (s *ProductServiceImpl) GetMovements(productId int) ([]StockMovement, error) {
+	return s.repo.GetMovements(productId)
+}
+
+// subscribe sends subscriber a snapshot of the catalog and then every event
+// published after it. A subscriber subscribing again under the same id
+// replaces the earlier one.
 func (s *ProductServiceImpl) subscribe(subscriber Subscriber) error {
 	if subscriber.Id() == "" {
 		return errEmptyId
 	}
-	s.subscribers = append(s.subscribers, subscriber)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, err := s.snapshot(nil)
+	if err != nil {
+		return err
+	}
+	s.register(subscriber).push(snapshot)
+	return nil
+}
+
+// resume sends subscriber the events published after since and then every
+// later one. When the missed events are no longer in the log it gets a
+// snapshot instead, like a new subscriber.
+func (s *ProductServiceImpl) resume(subscriber Subscriber, since int64) error {
+	if subscriber.Id() == "" {
+		return errEmptyId
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.missedEvents(since)
+	if errors.Is(err, errEventLogTruncated) {
+		var snapshot Event
+		snapshot, err = s.snapshot(nil)
+		events = []Event{snapshot}
+	}
+	if err != nil {
+		return err
+	}
+	s.register(subscriber).push(events...)
+	return nil
+}
+
+// register starts a subscription for subscriber, s.mu must be held.
+func (s *ProductServiceImpl) register(subscriber Subscriber) *subscription {
+	if previous, ok := s.subscriptions[subscriber.Id()]; ok {
+		go previous.stop()
+	}
+	sub := newSubscription(subscriber, s.publisher.QueueSize, s.coalescedSnapshot)
+	s.subscriptions[subscriber.Id()] = sub
+	return sub
+}
+
+func (s *ProductServiceImpl) missedEvents(since int64) ([]Event, error) {
+	if s.events == nil {
+		return nil, errEventLogTruncated
+	}
+	return s.events.EventsSince(since)
+}
+
+// snapshot returns the catalog as of the last event, only the products
+// matching filter when there is one.
+func (s *ProductServiceImpl) snapshot(filter *subscriptionFilter) (Event, error) {
+	products, err := s.repo.GetAll()
+	if err != nil {
+		return Event{}, err
+	}
+	if filter != nil {
+		products = filter.snapshot(products)
+	}
+	return Event{Seq: s.seq, Type: EventSnapshot, Products: products, CreatedAt: time.Now()}, nil
+}
+
+// coalescedSnapshot is the snapshot sent to sub in place of the events that
+// did not fit its queue. It runs in the send goroutine of sub: the catalog is
+// read without holding s.mu, which is only taken to read seq before and to
+// filter the products after, as fanOut updates the filter. Events after seq
+// are queued for sub and applied on top of the snapshot.
+func (s *ProductServiceImpl) coalescedSnapshot(sub *subscription) (Event, error) {
+	s.mu.Lock()
+	seq := s.seq
+	s.mu.Unlock()
+
+	products, err := s.repo.GetAll()
+	if err != nil {
+		return Event{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub.filter != nil {
+		products = sub.filter.snapshot(products)
+	}
+	return Event{Seq: seq, Type: EventSnapshot, Products: products, CreatedAt: time.Now()}, nil
+}
+
+// setFilter adds or replaces the named filter of subscriber. From then on it
+// only gets events for products matching one of its filters, starting with a
+// snapshot of those.
+func (s *ProductServiceImpl) setFilter(subscriber Subscriber, name string, filter EventFilter) error {
+	if err := validateEventFilter(filter); err != nil {
+		return fmt.Errorf("set filter: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[subscriber.Id()]
+	if !ok {
+		return fmt.Errorf("subscriber with %s id not found", subscriber.Id())
+	}
+	if sub.filter == nil {
+		sub.filter = &subscriptionFilter{filters: make(map[string]EventFilter)}
+	}
+	sub.filter.filters[name] = filter
+	return s.resendSnapshot(sub)
+}
+
+// removeFilter drops the named filter of subscriber and sends a snapshot of
+// what its remaining filters match. A subscriber without filters left gets
+// no more events.
+func (s *ProductServiceImpl) removeFilter(subscriber Subscriber, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[subscriber.Id()]
+	if !ok || sub.filter == nil {
+		return fmt.Errorf("filter %q not found", name)
+	}
+	if _, ok := sub.filter.filters[name]; !ok {
+		return fmt.Errorf("filter %q not found", name)
+	}
+	delete(sub.filter.filters, name)
+	return s.resendSnapshot(sub)
+}
+
+func (s *ProductServiceImpl) resendSnapshot(sub *subscription) error {
+	snapshot, err := s.snapshot(sub.filter)
+	if err != nil {
+		return err
+	}
+	sub.push(snapshot)
 	return nil
 }
 
+// unsubscribe stops sending events to subscriber. Once it returns the
+// subscriber is not called anymore.
 func (s *ProductServiceImpl) unsubscribe(subscriber Subscriber) error {
 	if subscriber.Id() == "" {
 		return errEmptyId
 	}
 
-	subscriberId := subscriber.Id()
+	s.mu.Lock()
+	sub, ok := s.subscriptions[subscriber.Id()]
+	delete(s.subscriptions, subscriber.Id())
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("subscriber with %s id not found", subscriber.Id())
+	}
+	sub.stop()
+	return nil
+}
+
+// drain waits until every subscriber has been sent what is queued for it.
+func (s *ProductServiceImpl) drain() {
+	s.mu.Lock()
+	subscriptions := make([]*subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		subscriptions = append(subscriptions, sub)
+	}
+	s.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.drain()
+	}
+}
+
+// write runs fn against a service bound to a transaction, so a change and
+// the movements booked with it are kept or dropped together. The events fn
+// publishes are added to the outbox in that transaction when there is one,
+// and dispatched once it commits otherwise.
+func (s *ProductServiceImpl) write(fn func(tx *ProductServiceImpl) error) error {
+	events := make([]Event, 0)
+	err := s.repo.InTx(func(repo Repo) error {
+		if err := fn(s.bind(repo, &events)); err != nil {
+			return err
+		}
+		return s.stage(repo, events)
+	})
+	if err != nil {
+		return err
+	}
+	s.announce(events)
+	return nil
+}
+
+// bind returns a service writing to repo, which collects what it publishes
+// in events.
+func (s *ProductServiceImpl) bind(repo Repo, events *[]Event) *ProductServiceImpl {
+	return &ProductServiceImpl{repo: repo, skuPattern: s.skuPattern, deferred: events}
+}
+
+// stage adds events to the outbox through repo, the transaction they were
+// published in.
+func (s *ProductServiceImpl) stage(repo Repo, events []Event) error {
+	if s.outbox == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, event := range events {
+		event.CreatedAt = now
+		if err := repo.AddToOutbox(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// announce passes on the events of a committed transaction: they are
+// dispatched, or the outbox worker is woken up when they were staged.
+func (s *ProductServiceImpl) announce(events []Event) {
+	if s.outbox == nil {
+		for _, event := range events {
+			s.dispatch(event)
+		}
+		return
+	}
+	if len(events) > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
 
-	for index, sub := range s.subscribers {
-		if subscriberId == sub.Id() {
-			s.subscribers = append(s.subscribers[:index], s.subscribers[index+1:]...)
+// RunOutbox dispatches the outbox until ctx is done, waking up when a write
+// staged events and every outboxPollInterval for entries left behind by
+// failed attempts or other instances.
+func (s *ProductServiceImpl) RunOutbox(ctx context.Context) error {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.dispatchOutbox(time.Now()); err != nil {
+			log.Println("error while dispatching outbox:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchOutbox dispatches the entries that can be claimed at now in order.
+// It stops at the first entry that fails, which is tried again once its
+// claim ran out.
+func (s *ProductServiceImpl) dispatchOutbox(now time.Time) error {
+	for {
+		entries, err := s.outbox.ClaimOutbox(now, outboxLease, outboxClaimLimit)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
 			return nil
 		}
+
+		for _, entry := range entries {
+			if err := s.dispatchEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dispatchEntry numbers the event of entry, hands it to the handlers and the
+// subscribers and only then removes it, so a crash on the way leads to the
+// event being dispatched again under the same seq rather than being lost.
+func (s *ProductServiceImpl) dispatchEntry(entry OutboxEntry) error {
+	entry, err := s.outbox.NumberOutboxEntry(entry)
+	if err != nil {
+		return err
+	}
+	for _, handler := range s.handlers {
+		if err := handler.HandleEvent(entry.Event); err != nil {
+			return err
+		}
+	}
+
+	s.relayed(entry.Event)
+	s.mu.Lock()
+	relay := s.relay
+	s.mu.Unlock()
+	if relay != nil {
+		if err := relay.Send(entry.Event); err != nil {
+			log.Println("error while relaying event:", err)
+		}
 	}
+	return s.outbox.RemoveFromOutbox(entry.Id)
+}
 
-	return fmt.Errorf("subscriber with %s id not found", subscriberId)
+// publish announces a change to product, or holds it back until the
+// transaction the service is bound to commits.
+func (s *ProductServiceImpl) publish(eventType string, product Product) {
+	event := Event{Type: eventType, Product: &product}
+	if s.deferred != nil {
+		*s.deferred = append(*s.deferred, event)
+		return
+	}
+	s.dispatch(event)
 }
 
-func (s *ProductServiceImpl) notify() {
-	products, err := s.GetAll()
+// publishStored publishes the product as stored after a change to before,
+// along with the stock alert the change set off.
+func (s *ProductServiceImpl) publishStored(eventType string, before Product) {
+	product, err := s.repo.GetById(before.Id)
 	if err != nil {
-		log.Println("error while getting list of products:", err)
+		log.Println("error while getting product to publish:", err)
 		return
 	}
-	for _, sub := range s.subscribers {
-		sub.Update(products)
+	s.publish(eventType, product)
+	s.publishStockAlert(before, product)
+}
+
+// publishStockAlert publishes stock.low or stock.out when the change from
+// before to after crossed the threshold.
+func (s *ProductServiceImpl) publishStockAlert(before, after Product) {
+	if alert := stockAlert(before, after); alert != "" {
+		s.publish(alert, after)
+	}
+}
+
+// dispatch records event in the event log, numbers it, queues it for every
+// subscriber and relays it to the other instances.
+func (s *ProductServiceImpl) dispatch(event Event) {
+	s.dispatchMu.Lock()
+	event.CreatedAt = time.Now()
+	event = s.record(event)
+
+	s.mu.Lock()
+	event = s.number(event)
+	s.fanOut(event)
+	relay := s.relay
+	s.mu.Unlock()
+	s.dispatchMu.Unlock()
+
+	if relay != nil {
+		if err := relay.Send(event); err != nil {
+			log.Println("error while relaying event:", err)
+		}
+	}
+}
+
+// relayed queues an event that is already in the shared event log, published
+// by another instance or taken from the outbox, for the local subscribers.
+func (s *ProductServiceImpl) relayed(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fanOut(s.number(event))
+}
+
+// record appends event to the event log when there is one, which numbers it
+// so instances sharing the log share the numbering. Seq stays 0 without a
+// log or when appending failed. s.mu must not be held, the log can be a
+// round trip to the database.
+func (s *ProductServiceImpl) record(event Event) Event {
+	s.mu.Lock()
+	events := s.events
+	s.mu.Unlock()
+
+	if events == nil {
+		return event
+	}
+	logged, err := events.AppendEvent(event)
+	if err != nil {
+		log.Println("error while recording event:", err)
+		return event
+	}
+	return logged
+}
+
+// number gives event the next seq unless it has one already, and moves seq
+// up to it. s.mu must be held.
+func (s *ProductServiceImpl) number(event Event) Event {
+	if event.Seq == 0 {
+		s.seq++
+		event.Seq = s.seq
+	} else if event.Seq > s.seq {
+		s.seq = event.Seq
+	}
+	return event
+}
+
+// fanOut queues event for every subscriber, applying the slow consumer
+// policy to those that are full. s.mu must be held.
+func (s *ProductServiceImpl) fanOut(event Event) {
+	for id, sub := range s.subscriptions {
+		if sub.filter != nil && !sub.filter.admit(event) {
+			continue
+		}
+		if sub.enqueue(event) {
+			continue
+		}
+
+		switch s.publisher.SlowConsumer {
+		case SlowConsumerCoalesce:
+			sub.markStale()
+		case SlowConsumerDisconnect:
+			log.Println("disconnecting slow subscriber", id)
+			delete(s.subscriptions, id)
+			go disconnect(sub)
+		default:
+			log.Println("dropping event", event.Seq, "for slow subscriber", id)
+		}
+	}
+}
+
+// disconnect closes the subscriber of sub if it can be closed and then stops
+// sub. Closing comes first: stop waits for the Update in flight, which for a
+// subscriber that stopped reading only returns once its connection is gone.
+func disconnect(sub *subscription) {
+	if closer, ok := sub.subscriber.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("error while closing subscriber:", err)
+		}
 	}
+	sub.stop()
 }
@@ -1,21 +1,49 @@
 package main
 
 import (
+	"context"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// testSubscriber keeps a replica of the catalog by applying the events it
+// receives on top of the snapshot.
 type testSubscriber struct {
 	id       string
+	snapshot Event
 	products []Product
+	events   []Event
 	count    int
 }
 
-func (t *testSubscriber) Update(products []Product) {
+func (t *testSubscriber) Update(event Event) {
+	switch event.Type {
+	case EventSnapshot:
+		t.snapshot = event
+		t.products = event.Products
+		return
+	case EventProductCreated:
+		t.products = append(t.products, *event.Product)
+	case EventProductUpdated:
+		for index := range t.products {
+			if t.products[index].Id == event.Product.Id {
+				t.products[index] = *event.Product
+			}
+		}
+	case EventProductDeleted:
+		products := make([]Product, 0, len(t.products))
+		for _, product := range t.products {
+			if product.Id != event.Product.Id {
+				products = append(products, product)
+			}
+		}
+		t.products = products
+	}
 	t.count = t.count + 1
-	t.products = products
+	t.events = append(t.events, event)
 }
 
 func (t *testSubscriber) Id() string {
@@ -190,7 +218,8 @@ func TestProductServiceImpl_Create(t *testing.T) {
 			subscriberErr := svc.subscribe(subscriber)
 			assert.NoError(t, subscriberErr, "subscribe should succeed")
 
-			err := svc.Create(tt.args.product)
+			_, err := svc.Create(tt.args.product)
+			svc.drain()
 
 			end := time.Now()
 
@@ -209,7 +238,7 @@ func TestProductServiceImpl_Create(t *testing.T) {
 				assertProductsEqual(t, tt.wantProducts, subscriber.products, tt.args.product.Id, start, end)
 				assert.Equal(t, 1, subscriber.count, "expect 1 notification")
 			} else {
-				assert.Nil(t, subscriber.products, "expect no products for subscriber")
+				assert.Empty(t, subscriber.events, "expect no events for subscriber")
 				assert.Equal(t, 0, subscriber.count, "expect no notification")
 			}
 		})
@@ -370,6 +399,7 @@ func TestProductServiceImpl_Update(t *testing.T) {
 			assert.NoError(t, subscriberErr, "expect no error while subscribing")
 
 			err := svc.Update(tt.args.product)
+			svc.drain()
 
 			end := time.Now()
 
@@ -388,7 +418,7 @@ func TestProductServiceImpl_Update(t *testing.T) {
 				assertProductsEqualUpdate(t, tt.wantProducts, subscriber.products, tt.args.product.Id, start, end)
 				assert.Equal(t, 1, subscriber.count, "expect 1 notification")
 			} else {
-				assert.Nil(t, subscriber.products)
+				assert.Empty(t, subscriber.events, "expect no events for subscriber")
 				assert.Equal(t, 0, subscriber.count, "expect no notification")
 			}
 		})
@@ -651,7 +681,8 @@ func TestProductServiceImpl_Delete(t *testing.T) {
 			subscriberErr := svc.subscribe(subscriber)
 			assert.NoError(t, subscriberErr, "subscribe should succeed")
 
-			err := svc.Delete(tt.args.id)
+			err := svc.Delete(tt.args.id, 0)
+			svc.drain()
 
 			assert.ErrorIs(t, err, tt.wantError, "expect same error")
 			assert.Equal(t, tt.wantProducts, repo.products, "expect products after delete")
@@ -660,7 +691,7 @@ func TestProductServiceImpl_Delete(t *testing.T) {
 				assert.Equal(t, tt.wantProducts, subscriber.products, "expect same notification")
 				assert.Equal(t, 1, subscriber.count, "expect notification")
 			} else {
-				assert.Nil(t, subscriber.products, "expect no products for subscriber")
+				assert.Empty(t, subscriber.events, "expect no events for subscriber")
 				assert.Equal(t, 0, subscriber.count, "expect no notification")
 			}
 		})
@@ -668,14 +699,25 @@ func TestProductServiceImpl_Delete(t *testing.T) {
 }
 
 func TestProductServiceImpl_subscribe(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 10,
+			Price:    10,
+		},
+	}
+
 	type args struct {
-		subscriber Subscriber
+		subscriber *testSubscriber
 	}
 
 	tests := []struct {
 		name           string
 		args           args
-		wantSubsribers []Subscriber
+		wantSubsribers int
+		wantSnapshot   []Product
 		wantError      bool
 	}{
 		{
@@ -683,51 +725,198 @@ func TestProductServiceImpl_subscribe(t *testing.T) {
 			args: args{
 				subscriber: &testSubscriber{id: "A"},
 			},
-			wantSubsribers: []Subscriber{
-				&testSubscriber{id: "A"},
-			},
-			wantError: false,
+			wantSubsribers: 1,
+			wantSnapshot:   existing,
+			wantError:      false,
 		},
 		{
 			name: "subscriber with empty id",
 			args: args{
 				subscriber: &testSubscriber{id: ""},
 			},
-			wantSubsribers: nil,
+			wantSubsribers: 0,
 			wantError:      true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			svc := NewProductServiceImpl(nil)
+			svc := NewProductServiceImpl(setupInMemoryRepo(existing))
+			svc.seq = 7
 
 			err := svc.subscribe(tt.args.subscriber)
+			svc.drain()
 			if !tt.wantError {
 				assert.NoError(t, err, "expect no error")
 			} else {
 				assert.Error(t, err, "expect error")
 			}
-			assert.Equal(t, tt.wantSubsribers, svc.subscribers, "expect same subscriber")
+			assert.Len(t, svc.subscriptions, tt.wantSubsribers, "expect same subscribers")
+			assert.Equal(t, tt.wantSnapshot, tt.args.subscriber.products, "expect snapshot of the catalog")
+			if !tt.wantError {
+				assert.Equal(t, int64(7), tt.args.subscriber.snapshot.Seq, "expect snapshot as of the last event")
+			}
+			assert.Empty(t, tt.args.subscriber.events, "expect snapshot not to count as an event")
 		})
 	}
 }
 
-func TestProductImpl_unsubscribe(t *testing.T) {
-	existingSubscribers := []Subscriber{
-		&testSubscriber{
-			id: "A",
+func TestProductServiceImpl_resume(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 10,
+			Price:    10,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		since        int64
+		eventLog     bool
+		wantSnapshot bool
+		wantSeqs     []int64
+	}{
+		{
+			name:     "missed events replayed",
+			since:    1,
+			eventLog: true,
+			wantSeqs: []int64{2, 3},
+		},
+		{
+			name:     "nothing missed",
+			since:    3,
+			eventLog: true,
+		},
+		{
+			name:         "since ahead of the log",
+			since:        9,
+			eventLog:     true,
+			wantSnapshot: true,
+		},
+		{
+			name:         "no event log",
+			since:        1,
+			wantSnapshot: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			if tt.eventLog {
+				assert.NoError(t, svc.SetEventLog(repo), "expect event log to be set")
+			}
+			svc.publish(EventProductUpdated, existing[0])
+			svc.publish(EventProductUpdated, existing[0])
+			svc.publish(EventProductUpdated, existing[0])
+
+			subscriber := &testSubscriber{id: "A"}
+			assert.NoError(t, svc.resume(subscriber, tt.since), "resume should succeed")
+			svc.drain()
+
+			if tt.wantSnapshot {
+				assert.Equal(t, EventSnapshot, subscriber.snapshot.Type, "expect a snapshot")
+				assert.Equal(t, int64(3), subscriber.snapshot.Seq, "expect snapshot as of the last event")
+			} else {
+				assert.Empty(t, subscriber.snapshot.Type, "expect no snapshot")
+			}
+			var seqs []int64
+			for _, event := range subscriber.events {
+				seqs = append(seqs, event.Seq)
+			}
+			assert.Equal(t, tt.wantSeqs, seqs, "expect missed events in order")
+			assert.Len(t, svc.subscriptions, 1, "expect subscriber to get live events")
+		})
+	}
+}
+
+func TestProductServiceImpl_SetEventLog(t *testing.T) {
+	repo := setupInMemoryRepo(nil)
+	appendTestEvents(t, repo, 1, 4)
+
+	svc := NewProductServiceImpl(repo)
+	assert.NoError(t, svc.SetEventLog(repo), "expect event log to be set")
+	svc.publish(EventProductDeleted, Product{Id: 4})
+
+	last, err := repo.LastEventSeq()
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, int64(5), last, "expect numbering to carry on from the log")
+}
+
+func TestProductServiceImpl_setFilter(t *testing.T) {
+	existing := []Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "Phone",
+			Quantity: 10,
+			Price:    10,
 		},
-		&testSubscriber{
-			id: "B",
+		{
+			Id:       2,
+			Brand:    "B",
+			Category: "Laptop",
+			Quantity: 20,
+			Price:    20,
 		},
 	}
+
+	repo := setupInMemoryRepo(existing)
+	svc := NewProductServiceImpl(repo)
+	filtered := &testSubscriber{id: "A"}
+	everything := &testSubscriber{id: "B"}
+	assert.NoError(t, svc.subscribe(filtered), "subscribe should succeed")
+	assert.NoError(t, svc.subscribe(everything), "subscribe should succeed")
+
+	assert.NoError(t, svc.setFilter(filtered, "phones", EventFilter{Category: "Phone"}), "set filter should succeed")
+	svc.drain()
+	assert.Equal(t, existing[:1], filtered.products, "expect snapshot of matching products")
+
+	laptop, err := svc.Create(Product{Brand: "C", Category: "Laptop", Price: 30})
+	assert.NoError(t, err, "create should succeed")
+	phone, err := svc.Create(Product{Brand: "D", Category: "Phone", Price: 40})
+	assert.NoError(t, err, "create should succeed")
+	svc.drain()
+
+	assert.Equal(t, 1, filtered.count, "expect only the matching event")
+	assert.Equal(t, phone.Id, filtered.events[0].Product.Id, "expect the matching product")
+	assert.Equal(t, 2, everything.count, "expect subscriber without filters to get every event")
+
+	assert.NoError(t, svc.setFilter(filtered, "laptop", EventFilter{Ids: []int{laptop.Id}}), "add filter should succeed")
+	svc.drain()
+	assert.Equal(t, []int{1, laptop.Id, phone.Id}, productIds(filtered.products), "expect snapshot of both filters")
+
+	assert.NoError(t, svc.removeFilter(filtered, "phones"), "remove filter should succeed")
+	svc.drain()
+	assert.Equal(t, []int{laptop.Id}, productIds(filtered.products), "expect snapshot of the remaining filter")
+
+	var ve *validationError
+	assert.ErrorAs(t, svc.setFilter(filtered, "ids", EventFilter{Ids: []int{-1}}), &ve, "expect invalid filter to fail")
+	assert.Error(t, svc.removeFilter(filtered, "phones"), "expect unknown filter to fail")
+	assert.Error(t, svc.setFilter(&testSubscriber{id: "C"}, "phones", EventFilter{}), "expect unknown subscriber to fail")
+
+	assert.NoError(t, svc.unsubscribe(filtered), "unsubscribe should succeed")
+	assert.NotContains(t, svc.subscriptions, "A", "expect filters to go with the subscriber")
+}
+
+func productIds(products []Product) []int {
+	ids := make([]int, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.Id)
+	}
+	return ids
+}
+
+func TestProductImpl_unsubscribe(t *testing.T) {
 	type args struct {
 		subscriber Subscriber
 	}
 	tests := []struct {
 		name            string
 		args            args
-		wantSubscribers []Subscriber
+		wantSubscribers []string
 		wantError       bool
 	}{
 		{
@@ -735,25 +924,16 @@ func TestProductImpl_unsubscribe(t *testing.T) {
 			args: args{
 				subscriber: &testSubscriber{id: "A"},
 			},
-			wantSubscribers: []Subscriber{
-				&testSubscriber{id: "B"},
-			},
-			wantError: false,
+			wantSubscribers: []string{"B"},
+			wantError:       false,
 		},
 		{
 			name: "subscriber with empty id",
 			args: args{
 				subscriber: &testSubscriber{id: ""},
 			},
-			wantSubscribers: []Subscriber{
-				&testSubscriber{
-					id: "A",
-				},
-				&testSubscriber{
-					id: "B",
-				},
-			},
-			wantError: true,
+			wantSubscribers: []string{"A", "B"},
+			wantError:       true,
 		},
 		{
 			name: "subscriber not found",
@@ -762,21 +942,17 @@ func TestProductImpl_unsubscribe(t *testing.T) {
 					id: "D",
 				},
 			},
-			wantSubscribers: []Subscriber{
-				&testSubscriber{
-					id: "A",
-				},
-				&testSubscriber{
-					id: "B",
-				},
-			},
-			wantError: true,
+			wantSubscribers: []string{"A", "B"},
+			wantError:       true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			svc := NewProductServiceImpl(nil)
-			svc.subscribers = append(svc.subscribers, existingSubscribers...)
+			svc := NewProductServiceImpl(setupInMemoryRepo(nil))
+			existingSubscribers := []*testSubscriber{{id: "A"}, {id: "B"}}
+			for _, subscriber := range existingSubscribers {
+				assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+			}
 
 			err := svc.unsubscribe(tt.args.subscriber)
 
@@ -785,12 +961,27 @@ func TestProductImpl_unsubscribe(t *testing.T) {
 			} else {
 				assert.Error(t, err, "expect error")
 			}
-			assert.Equal(t, tt.wantSubscribers, svc.subscribers, "expect no subscriber")
+			ids := make([]string, 0)
+			for id := range svc.subscriptions {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			assert.Equal(t, tt.wantSubscribers, ids, "expect remaining subscribers")
+
+			svc.publish(EventProductCreated, Product{Id: 1})
+			svc.drain()
+			for _, subscriber := range existingSubscribers {
+				wantCount := 1
+				if subscriber.id == tt.args.subscriber.Id() {
+					wantCount = 0
+				}
+				assert.Equal(t, wantCount, subscriber.count, "expect events only for subscribers left")
+			}
 		})
 	}
 }
 
-func TestProductServiceImpl_notify(t *testing.T) {
+func TestProductServiceImpl_publish(t *testing.T) {
 	existing := []Product{
 		{
 			Id:       1,
@@ -799,49 +990,585 @@ func TestProductServiceImpl_notify(t *testing.T) {
 			Quantity: 10,
 			Price:    10,
 		},
+	}
+
+	repo := setupInMemoryRepo(existing)
+	svc := NewProductServiceImpl(repo)
+	subscribers := []*testSubscriber{{id: "A"}, {id: "B"}}
+	for _, subscriber := range subscribers {
+		assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+	}
+
+	created := Product{Id: 2, Brand: "B", Category: "B"}
+	svc.publish(EventProductCreated, created)
+	svc.publish(EventProductDeleted, existing[0])
+	svc.drain()
+
+	for _, subscriber := range subscribers {
+		if assert.Len(t, subscriber.events, 2, "expect every event") {
+			assert.Equal(t, int64(1), subscriber.events[0].Seq, "expect seq to start at 1")
+			assert.Equal(t, EventProductCreated, subscriber.events[0].Type)
+			assert.Equal(t, &created, subscriber.events[0].Product)
+			assert.Equal(t, int64(2), subscriber.events[1].Seq, "expect seq to increase by one")
+			assert.Equal(t, EventProductDeleted, subscriber.events[1].Type)
+		}
+		assert.Equal(t, []Product{created}, subscriber.products, "expect replica to follow the events")
+	}
+}
+
+type testRelay struct {
+	sent []Event
+}
+
+func (r *testRelay) Send(event Event) error {
+	r.sent = append(r.sent, event)
+	return nil
+}
+
+func (r *testRelay) Receive(ctx context.Context, fn func(Event)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestProductServiceImpl_relay(t *testing.T) {
+	repo := setupInMemoryRepo(nil)
+	svc := NewProductServiceImpl(repo)
+	relay := &testRelay{}
+	svc.SetRelay(relay)
+	subscriber := &testSubscriber{id: "A"}
+	assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+
+	created, err := svc.Create(Product{Brand: "A", Category: "A", Price: 10})
+	assert.NoError(t, err, "create should succeed")
+	if assert.Len(t, relay.sent, 1, "expect published events to be relayed") {
+		assert.Equal(t, int64(1), relay.sent[0].Seq)
+		assert.Equal(t, created.Id, relay.sent[0].Product.Id)
+	}
+
+	remote := Product{Id: 7, Brand: "B", Category: "B"}
+	svc.relayed(Event{Seq: 5, Type: EventProductCreated, Product: &remote})
+	svc.drain()
+
+	assert.Len(t, relay.sent, 1, "expect relayed events not to be sent back")
+	assert.Equal(t, []int{created.Id, remote.Id}, productIds(subscriber.products), "expect relayed events to reach subscribers")
+	assert.Equal(t, int64(5), svc.seq, "expect seq to follow the other instances")
+}
+
+func TestProductServiceImpl_AddMovement(t *testing.T) {
+	existing := []Product{
 		{
-			Id:       2,
-			Brand:    "B",
-			Category: "B",
-			Quantity: 20,
-			Price:    20,
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 5,
+			Price:    10,
+		},
+	}
+
+	type args struct {
+		movement StockMovement
+	}
+	tests := []struct {
+		name             string
+		args             args
+		wantQuantity     int
+		wantFailures     []string
+		wantNotification bool
+		wantErr          error
+	}{
+		{
+			name: "receipt booked",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: MovementReceipt, Quantity: 2, Reason: "delivery", Reference: "PO-1", Actor: "clerk"},
+			},
+			wantQuantity:     7,
+			wantNotification: true,
 		},
 		{
-			Id:       3,
-			Brand:    "C",
-			Category: "C",
-			Quantity: 30,
-			Price:    30,
+			name: "validation errors",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: "lost", Quantity: 2},
+			},
+			wantQuantity: 5,
+			wantFailures: []string{
+				"Type should be one of receipt, issue, adjustment, transfer",
+				"Reason should not be empty",
+			},
+		},
+		{
+			name: "transit booked by transfer orders only",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: MovementDispatch, Quantity: 2, LocationId: 1, Reason: "shipped"},
+			},
+			wantQuantity: 5,
+			wantFailures: []string{
+				"Type should be one of receipt, issue, adjustment, transfer",
+			},
+		},
+		{
+			name: "insufficient stock",
+			args: args{
+				movement: StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 6, Reason: "sold"},
+			},
+			wantQuantity: 5,
+			wantErr:      errInsufficientStock,
 		},
 	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+
+			subscriber := &testSubscriber{id: "A"}
+			assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+
+			start := time.Now()
+			movement, err := svc.AddMovement(tt.args.movement)
+			svc.drain()
+			end := time.Now()
+
+			if len(tt.wantFailures) > 0 {
+				var ve *validationError
+				assert.ErrorAs(t, err, &ve, "error should be of ValidationError type")
+				assert.Equal(t, tt.wantFailures, ve.failures, "expect failures to be same")
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr, "error should match")
+			}
+
+			if err == nil {
+				assert.Equal(t, 1, movement.Id, "expect movement id to be assigned")
+				assertTimestampBetween(t, start, end, movement.CreatedAt)
+			}
+			assert.Equal(t, tt.wantQuantity, repo.products[0].Quantity, "expect same quantity")
+
+			if tt.wantNotification {
+				assert.Equal(t, 1, subscriber.count, "expect 1 notification")
+			} else {
+				assert.Equal(t, 0, subscriber.count, "expect no notification")
+			}
+		})
+	}
+}
+
+func TestProductServiceImpl_QuantityLedger(t *testing.T) {
+	repo := setupInMemoryRepo(nil)
+	svc := NewProductServiceImpl(repo)
+
+	_, err := svc.Create(Product{Id: 1, Brand: "A", Category: "A", Quantity: 4, Price: 10})
+	assert.NoError(t, err, "create should succeed")
+
+	err = svc.Update(Product{Id: 1, Brand: "A", Category: "A", Quantity: 1, Price: 10})
+	assert.NoError(t, err, "update should succeed")
+
+	product, err := svc.GetById(1)
+	assert.NoError(t, err, "get should succeed")
+	assert.Equal(t, 1, product.Quantity, "expect quantity from update")
+
+	movements, err := svc.GetMovements(1)
+	assert.NoError(t, err, "get movements should succeed")
+	if assert.Len(t, movements, 2, "expect opening receipt and adjustment") {
+		assert.Equal(t, MovementReceipt, movements[0].Type, "expect opening receipt")
+		assert.Equal(t, 4, movements[0].Quantity, "expect opening quantity")
+		assert.Equal(t, MovementAdjustment, movements[1].Type, "expect adjustment")
+		assert.Equal(t, -3, movements[1].Quantity, "expect adjustment by the difference")
+	}
+
+	total := 0
+	for _, movement := range movements {
+		total += movement.delta()
+	}
+	assert.Equal(t, product.Quantity, total, "expect ledger to add up to quantity")
+}
+
+func TestProductServiceImpl_Patch(t *testing.T) {
+	existing := []Product{
+		{
+			Id:        1,
+			Sku:       "A-A-000001",
+			Brand:     "A",
+			Category:  "A",
+			Quantity:  5,
+			Price:     10,
+			Version:   2,
+			CreatedAt: time.Date(2023, 04, 26, 15, 00, 00, 00, time.UTC),
+			UpdatedAt: time.Date(2023, 04, 26, 16, 00, 00, 00, time.UTC),
+		},
+	}
+
+	type args struct {
+		id      int
+		version int
+		patch   ProductPatch
+	}
+
+	tests := []struct {
+		name             string
+		args             args
+		wantProduct      Product
+		wantMovements    int
+		wantFailures     []string
+		wantError        error
+		wantNotification bool
+	}{
+		{
+			name: "merge patch changes price only",
+			args: args{
+				id:      1,
+				version: 2,
+				patch:   ProductPatch{Type: mergePatchContentType, Document: []byte(`{"price": 12.5}`)},
+			},
+			wantProduct:      Product{Id: 1, Sku: "A-A-000001", Brand: "A", Category: "A", Quantity: 5, Price: 12.5, Version: 3},
+			wantNotification: true,
+		},
+		{
+			name: "json patch books quantity as adjustment",
+			args: args{
+				id:    1,
+				patch: ProductPatch{Type: jsonPatchContentType, Document: []byte(`[{"op": "replace", "path": "/quantity", "value": 3}]`)},
+			},
+			wantProduct:      Product{Id: 1, Sku: "A-A-000001", Brand: "A", Category: "A", Quantity: 3, Price: 10, Version: 4},
+			wantMovements:    1,
+			wantNotification: true,
+		},
+		{
+			name: "unchanged product keeps its version",
+			args: args{
+				id:    1,
+				patch: ProductPatch{Type: mergePatchContentType, Document: []byte(`{"brand": "A"}`)},
+			},
+			wantProduct: existing[0],
+		},
+		{
+			name: "patched product is validated",
+			args: args{
+				id:    1,
+				patch: ProductPatch{Type: mergePatchContentType, Document: []byte(`{"brand": null, "price": -1}`)},
+			},
+			wantFailures: []string{"Brand should not be empty", "Price should not be less than 0"},
+		},
+		{
+			name: "read only fields cannot be patched",
+			args: args{
+				id:    1,
+				patch: ProductPatch{Type: mergePatchContentType, Document: []byte(`{"id": 2, "version": 7}`)},
+			},
+			wantFailures: []string{"Id should not be changed", "Version should not be changed"},
+		},
+		{
+			name: "unknown field",
+			args: args{
+				id:    1,
+				patch: ProductPatch{Type: mergePatchContentType, Document: []byte(`{"colour": "red"}`)},
+			},
+			wantFailures: []string{`patched product is invalid: json: unknown field "colour"`},
+		},
+		{
+			name: "stale version",
+			args: args{
+				id:      1,
+				version: 1,
+				patch:   ProductPatch{Type: mergePatchContentType, Document: []byte(`{"price": 12}`)},
+			},
+			wantError: errVersionConflict,
+		},
+		{
+			name: "unsupported patch type",
+			args: args{
+				id:    1,
+				patch: ProductPatch{Type: "text/plain", Document: []byte(`price=12`)},
+			},
+			wantError: errUnsupportedPatch,
+		},
+		{
+			name: "product not found",
+			args: args{
+				id:    2,
+				patch: ProductPatch{Type: mergePatchContentType, Document: []byte(`{"price": 12}`)},
+			},
+			wantError: errProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			subscriber := &testSubscriber{id: "C"}
+			assert.NoError(t, svc.subscribe(subscriber), "expect no error while subscribing")
+
+			start := time.Now()
+			product, err := svc.Patch(tt.args.id, tt.args.version, tt.args.patch)
+			svc.drain()
+			end := time.Now()
+
+			if len(tt.wantFailures) > 0 {
+				var ve *validationError
+				if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+					assert.Equal(t, tt.wantFailures, ve.failures, "expect failures to be same")
+				}
+				assert.Equal(t, existing, repo.products, "expect products to be unchanged")
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantError, "error should match")
+			if tt.wantError != nil {
+				assert.Equal(t, existing, repo.products, "expect products to be unchanged")
+				return
+			}
+
+			if tt.wantNotification {
+				assertTimestampBetween(t, start, end, product.UpdatedAt)
+				product.UpdatedAt = time.Time{}
+				tt.wantProduct.CreatedAt = existing[0].CreatedAt
+				assert.Equal(t, 1, subscriber.count, "expect 1 notification")
+			} else {
+				assert.Equal(t, 0, subscriber.count, "expect no notification")
+			}
+			assert.Equal(t, tt.wantProduct, product, "expect patched product")
+
+			movements, err := svc.GetMovements(tt.args.id)
+			assert.NoError(t, err, "get movements should succeed")
+			assert.Len(t, movements, tt.wantMovements, "expect quantity changes in the ledger")
+		})
+	}
+}
+
+func TestProductServiceImpl_Batch(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Brand: "A", Category: "A", Quantity: 1, Price: 10, Version: 1},
+		{Id: 2, Brand: "B", Category: "B", Quantity: 2, Price: 20, Version: 1},
+	}
+	operations := []BatchOperation{
+		{Op: BatchCreate, Product: Product{Brand: "C", Category: "C", Quantity: 3, Price: 30}},
+		{Op: BatchUpdate, Id: 1, Version: 1, Product: Product{Brand: "A", Category: "A", Quantity: 1, Price: 11}},
+		{Op: BatchDelete, Id: 2, Version: 1},
+	}
+	failing := append(append([]BatchOperation(nil), operations...),
+		BatchOperation{Op: BatchUpdate, Id: 9, Product: Product{Brand: "Z", Category: "Z"}},
+		BatchOperation{Op: "upsert"},
+	)
+
+	type args struct {
+		operations []BatchOperation
+		partial    bool
+	}
+
+	tests := []struct {
+		name       string
+		args       args
+		wantErrors []error
+		wantIds    []int
+		wantEvents []string
+	}{
+		{
+			name:       "all operations succeed",
+			args:       args{operations: operations},
+			wantErrors: []error{nil, nil, nil},
+			wantIds:    []int{1, 3},
+			wantEvents: []string{EventProductCreated, EventProductUpdated, EventProductDeleted},
+		},
+		{
+			name: "a failure rolls back the batch",
+			args: args{operations: failing},
+			wantErrors: []error{
+				errBatchRolledBack,
+				errBatchRolledBack,
+				errBatchRolledBack,
+				errProductNotFound,
+				&validationError{failures: []string{"Op should be one of create, update, delete"}},
+			},
+			wantIds: []int{1, 2},
+		},
+		{
+			name: "partial batch keeps what succeeded",
+			args: args{operations: failing, partial: true},
+			wantErrors: []error{
+				nil,
+				nil,
+				nil,
+				errProductNotFound,
+				&validationError{failures: []string{"Op should be one of create, update, delete"}},
+			},
+			wantIds:    []int{1, 3},
+			wantEvents: []string{EventProductCreated, EventProductUpdated, EventProductDeleted},
+		},
+		{
+			name: "nothing succeeds",
+			args: args{operations: failing[3:], partial: true},
+			wantErrors: []error{
+				errProductNotFound,
+				&validationError{failures: []string{"Op should be one of create, update, delete"}},
+			},
+			wantIds: []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupInMemoryRepo(existing)
+			svc := NewProductServiceImpl(repo)
+			subscriber := &testSubscriber{id: "C"}
+			assert.NoError(t, svc.subscribe(subscriber), "expect no error while subscribing")
+
+			results, err := svc.Batch(tt.args.operations, tt.args.partial)
+			svc.drain()
+			assert.NoError(t, err, "batch should run")
+
+			if assert.Len(t, results, len(tt.wantErrors), "expect one result per operation") {
+				for index, result := range results {
+					assert.Equal(t, index, result.Index, "expect results in operation order")
+					if wantFailures, ok := tt.wantErrors[index].(*validationError); ok {
+						var ve *validationError
+						if assert.ErrorAs(t, result.Err, &ve, "error should be of ValidationError type") {
+							assert.Equal(t, wantFailures.failures, ve.failures, "expect failures to be same")
+						}
+						continue
+					}
+					assert.ErrorIs(t, result.Err, tt.wantErrors[index], "error should match")
+					if result.Err == nil && result.Op != BatchDelete {
+						assert.NotNil(t, result.Product, "expect stored product")
+					}
+				}
+			}
+
+			ids := make([]int, 0)
+			for _, product := range repo.products {
+				ids = append(ids, product.Id)
+			}
+			assert.Equal(t, tt.wantIds, ids, "expect same products after batch")
+			var eventTypes []string
+			for _, event := range subscriber.events {
+				eventTypes = append(eventTypes, event.Type)
+			}
+			assert.Equal(t, tt.wantEvents, eventTypes, "expect events only for what was committed")
+			assert.Equal(t, repo.products, subscriber.products, "expect replica to match the catalog")
+		})
+	}
+}
+
+func TestProductServiceImpl_BatchValidation(t *testing.T) {
+	svc := NewProductServiceImpl(setupInMemoryRepo(nil))
+
+	_, err := svc.Batch(nil, false)
+
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{"operations should not be empty"}, ve.failures, "expect failures to be same")
+	}
+}
+
+func TestProductServiceImpl_Import(t *testing.T) {
+	existing := []Product{
+		{Id: 1, Sku: "SAM-1", Brand: "Samsung", Category: "Phone", Quantity: 1, Price: 10, Version: 1},
+	}
+	allFields := map[string]bool{"sku": true, "brand": true, "category": true, "quantity": true, "price": true}
+	priceOnly := map[string]bool{"sku": true, "price": true}
+
+	type args struct {
+		rows    []ImportRow
+		options ImportOptions
+	}
+
 	tests := []struct {
 		name        string
-		subscribers []Subscriber
+		args        args
+		wantSummary ImportSummary
+		wantRowErrs []int
+		wantProduct Product
 		wantCount   int
 	}{
 		{
-			name: "product removed",
-			subscribers: []Subscriber{
-				&testSubscriber{id: "A"},
-				&testSubscriber{id: "B"},
+			name: "create",
+			args: args{
+				rows: []ImportRow{
+					{Line: 2, Product: Product{Brand: "Apple", Category: "Phone", Quantity: 2, Price: 20}, Fields: allFields},
+				},
+				options: ImportOptions{Mode: ImportCreate},
+			},
+			wantSummary: ImportSummary{Created: 1},
+			wantProduct: existing[0],
+			wantCount:   2,
+		},
+		{
+			name: "create rejects existing sku",
+			args: args{
+				rows: []ImportRow{
+					{Line: 2, Product: Product{Brand: "Apple", Category: "Phone", Price: 20}, Fields: allFields},
+					{Line: 3, Product: Product{Sku: "SAM-1", Brand: "Samsung", Category: "Phone", Price: 20}, Fields: allFields},
+				},
+				options: ImportOptions{Mode: ImportCreate},
+			},
+			wantRowErrs: []int{3},
+			wantProduct: existing[0],
+			wantCount:   1,
+		},
+		{
+			name: "upsert updates only the imported columns",
+			args: args{
+				rows: []ImportRow{
+					{Line: 2, Product: Product{Sku: "SAM-1", Price: 15}, Fields: priceOnly},
+					{Line: 3, Product: Product{Sku: "NEW-1", Price: 5}, Fields: priceOnly},
+				},
+				options: ImportOptions{Mode: ImportUpsert},
+			},
+			wantRowErrs: []int{3},
+			wantProduct: existing[0],
+			wantCount:   1,
+		},
+		{
+			name: "upsert",
+			args: args{
+				rows: []ImportRow{
+					{Line: 2, Product: Product{Sku: "SAM-1", Price: 15}, Fields: priceOnly},
+					{Line: 3, Product: Product{Sku: "APP-1", Brand: "Apple", Category: "Phone", Price: 5}, Fields: allFields},
+				},
+				options: ImportOptions{Mode: ImportUpsert},
 			},
-			wantCount: 1,
+			wantSummary: ImportSummary{Created: 1, Updated: 1},
+			wantProduct: Product{Id: 1, Sku: "SAM-1", Brand: "Samsung", Category: "Phone", Quantity: 1, Price: 15, Version: 2},
+			wantCount:   2,
+		},
+		{
+			name: "dry run writes nothing",
+			args: args{
+				rows: []ImportRow{
+					{Line: 2, Product: Product{Sku: "SAM-1", Price: 15}, Fields: priceOnly},
+					{Line: 3, Product: Product{Sku: "APP-1", Brand: "Apple", Category: "Phone", Price: 5}, Fields: allFields},
+				},
+				options: ImportOptions{Mode: ImportUpsert, DryRun: true},
+			},
+			wantSummary: ImportSummary{Created: 1, Updated: 1, DryRun: true},
+			wantProduct: existing[0],
+			wantCount:   1,
 		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			repo := setupInMemoryRepo(existing)
 			svc := NewProductServiceImpl(repo)
-			svc.subscribers = append(svc.subscribers, tt.subscribers...)
 
-			svc.notify()
+			summary, err := svc.Import(tt.args.rows, tt.args.options)
 
-			for _, subscriber := range svc.subscribers {
-				s := subscriber.(*testSubscriber)
-				assert.Equal(t, repo.products, s.products, "expect same products")
-				assert.Equal(t, tt.wantCount, s.count, "expect same no. of count")
+			if len(tt.wantRowErrs) > 0 {
+				var ie *importError
+				if assert.ErrorAs(t, err, &ie, "error should be an import error") {
+					lines := make([]int, 0)
+					for _, row := range ie.rows {
+						lines = append(lines, row.Line)
+					}
+					assert.Equal(t, tt.wantRowErrs, lines, "expect errors for the bad rows")
+				}
+			} else {
+				assert.NoError(t, err, "import should succeed")
+				assert.Equal(t, tt.wantSummary, summary)
 			}
+
+			product, err := repo.GetById(1)
+			assert.NoError(t, err, "get should succeed")
+			product.UpdatedAt = time.Time{}
+			assert.Equal(t, tt.wantProduct, product, "expect same existing product")
+			assert.Len(t, repo.products, tt.wantCount, "expect same number of products")
 		})
 	}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultSkuPattern renders e.g. "PHO-SAM-000042" for a Samsung phone with
+// id 42.
+const defaultSkuPattern = "{category:3}-{brand:3}-{id:6}"
+
+var (
+	skuPlaceholder = regexp.MustCompile(`\{(id|brand|category)(?::(\d+))?\}`)
+	validSku       = regexp.MustCompile(`^[A-Z0-9][A-Z0-9-]{0,63}$`)
+)
+
+// validateSkuPattern checks that a pattern only uses known placeholders,
+// includes {id}, which is what keeps generated SKUs unique, and renders to a
+// valid SKU. Long brands and categories can still push a generated SKU over
+// the length limit, Create checks those.
+func validateSkuPattern(pattern string) error {
+	if !strings.Contains(pattern, "{id") {
+		return fmt.Errorf("sku pattern %q should contain the {id} placeholder", pattern)
+	}
+	rest := skuPlaceholder.ReplaceAllString(pattern, "")
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("sku pattern %q has an unknown placeholder, use {id}, {brand} or {category} with an optional :width", pattern)
+	}
+	if sample := generateSku(pattern, Product{Id: 1, Brand: "A", Category: "A"}); !validSku.MatchString(sample) {
+		return fmt.Errorf("sku pattern %q renders %q, SKUs should only contain upper case letters, digits and dashes and be at most 64 characters long", pattern, sample)
+	}
+	return nil
+}
+
+// generateSku renders pattern for product. {id:N} zero pads the id to N
+// digits; {brand:N} and {category:N} keep the first N letters and digits of
+// the upper cased field.
+func generateSku(pattern string, product Product) string {
+	return skuPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		match := skuPlaceholder.FindStringSubmatch(placeholder)
+		width, _ := strconv.Atoi(match[2])
+
+		switch match[1] {
+		case "id":
+			return fmt.Sprintf("%0*d", width, product.Id)
+		case "brand":
+			return skuSegment(product.Brand, width)
+		default:
+			return skuSegment(product.Category, width)
+		}
+	})
+}
+
+func skuSegment(text string, width int) string {
+	var segment []rune
+	for _, r := range strings.ToUpper(text) {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			continue
+		}
+		segment = append(segment, r)
+		if width > 0 && len(segment) == width {
+			break
+		}
+	}
+	if len(segment) == 0 {
+		return "X"
+	}
+	return string(segment)
+}
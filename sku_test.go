@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSku(t *testing.T) {
+	product := Product{Id: 42, Brand: "Coca-Cola", Category: "Soft drinks"}
+
+	tests := []struct {
+		name    string
+		pattern string
+		wantSku string
+	}{
+		{
+			name:    "default pattern",
+			pattern: defaultSkuPattern,
+			wantSku: "SOF-COC-000042",
+		},
+		{
+			name:    "full fields",
+			pattern: "{brand}-{category}-{id}",
+			wantSku: "COCACOLA-SOFTDRINKS-42",
+		},
+		{
+			name:    "id only",
+			pattern: "SKU{id:8}",
+			wantSku: "SKU00000042",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, validateSkuPattern(tt.pattern), "expect valid pattern")
+			assert.Equal(t, tt.wantSku, generateSku(tt.pattern, product), "expect same sku")
+		})
+	}
+}
+
+func TestValidateSkuPattern(t *testing.T) {
+	assert.Error(t, validateSkuPattern("{brand}-{category}"), "expect pattern without id to be rejected")
+	assert.Error(t, validateSkuPattern("{id}-{colour}"), "expect unknown placeholder to be rejected")
+	assert.Error(t, validateSkuPattern("{brand}/{id}"), "expect pattern with a slash to be rejected")
+	assert.Error(t, validateSkuPattern("sku-{id}"), "expect lower case pattern to be rejected")
+	assert.Error(t, validateSkuPattern("{id:80}"), "expect pattern longer than a sku to be rejected")
+	assert.NoError(t, validateSkuPattern("P-{id:4}"), "expect pattern with id to be accepted")
+}
+
+func TestProductServiceImpl_CreateRejectsInvalidGeneratedSku(t *testing.T) {
+	svc := NewProductServiceImpl(setupInMemoryRepo(nil))
+	assert.NoError(t, svc.SetSkuPattern("{brand}-{id}"), "expect valid pattern")
+
+	_, err := svc.Create(Product{Brand: strings.Repeat("B", 70), Category: "A", Price: 10})
+
+	var ve *validationError
+	assert.ErrorAs(t, err, &ve, "expect a validation error")
+	products, err := svc.GetAll()
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, products, "expect nothing stored")
+}
+
+func TestInMemoryRepo_NextId(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{
+		{Id: 2, Brand: "A", Category: "A"},
+	})
+
+	first, err := repo.NextId()
+	assert.NoError(t, err, "expect no error")
+	second, err := repo.NextId()
+	assert.NoError(t, err, "expect no error")
+
+	assert.Equal(t, 1, first, "expect first free id")
+	assert.Equal(t, 3, second, "expect id picked by a client to be skipped")
+}
@@ -0,0 +1,28 @@
+package main
+
+// stockPosition is the stock product can still count on to sell: what is
+// available and what is on its way between warehouses. Reserved stock counts
+// as gone, it is already promised to an order.
+func stockPosition(product Product) int {
+	return product.available() + product.InTransit
+}
+
+// isLowStock tells if the stock position of product is at or below its
+// reorder point. A product without one is low once nothing is left.
+func isLowStock(product Product) bool {
+	return stockPosition(product) <= product.ReorderPoint
+}
+
+// stockAlert returns the alert a change from before to after sets off:
+// stock.out when it left nothing, stock.low when it brought the stock
+// position down to the reorder point, or "" when it crossed neither
+// threshold. Shipping stock between warehouses moves none of them.
+func stockAlert(before, after Product) string {
+	if stockPosition(after) <= 0 && stockPosition(before) > 0 {
+		return EventStockOut
+	}
+	if isLowStock(after) && !isLowStock(before) {
+		return EventStockLow
+	}
+	return ""
+}
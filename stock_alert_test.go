@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockAlert(t *testing.T) {
+	tests := []struct {
+		name   string
+		before Product
+		after  Product
+		want   string
+	}{
+		{name: "above reorder point", before: Product{Quantity: 10, ReorderPoint: 5}, after: Product{Quantity: 6, ReorderPoint: 5}, want: ""},
+		{name: "down to reorder point", before: Product{Quantity: 10, ReorderPoint: 5}, after: Product{Quantity: 5, ReorderPoint: 5}, want: EventStockLow},
+		{name: "already low", before: Product{Quantity: 5, ReorderPoint: 5}, after: Product{Quantity: 3, ReorderPoint: 5}, want: ""},
+		{name: "reorder point raised", before: Product{Quantity: 5, ReorderPoint: 2}, after: Product{Quantity: 5, ReorderPoint: 8}, want: EventStockLow},
+		{name: "ran out", before: Product{Quantity: 3, ReorderPoint: 5}, after: Product{Quantity: 0, ReorderPoint: 5}, want: EventStockOut},
+		{name: "ran out without reorder point", before: Product{Quantity: 3}, after: Product{Quantity: 0}, want: EventStockOut},
+		{name: "still out", before: Product{Quantity: 0}, after: Product{Quantity: 0, ReorderPoint: 5}, want: ""},
+		{name: "restocked", before: Product{Quantity: 0, ReorderPoint: 5}, after: Product{Quantity: 2, ReorderPoint: 5}, want: ""},
+		{name: "reserved down to reorder point", before: Product{Quantity: 10, Reserved: 2, ReorderPoint: 5}, after: Product{Quantity: 10, Reserved: 5, ReorderPoint: 5}, want: EventStockLow},
+		{name: "fully reserved", before: Product{Quantity: 3, Reserved: 1}, after: Product{Quantity: 3, Reserved: 3}, want: EventStockOut},
+		{name: "reservation released", before: Product{Quantity: 3, Reserved: 3}, after: Product{Quantity: 3, Reserved: 1}, want: ""},
+		{name: "reserved stock shipped", before: Product{Quantity: 3, Reserved: 3}, after: Product{Quantity: 0, Reserved: 0}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stockAlert(tt.before, tt.after))
+		})
+	}
+}
+
+func TestProductServiceImpl_stockAlerts(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{
+		{Id: 1, Brand: "A", Category: "A", Quantity: 10, Price: 10, ReorderPoint: 5, ReorderQuantity: 20, Version: 1},
+		{Id: 2, Brand: "B", Category: "B", Quantity: 2, Price: 10, Version: 1},
+	})
+	svc := NewProductServiceImpl(repo)
+	subscriber := &testSubscriber{id: "A"}
+	assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+
+	_, err := svc.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 6, Reason: "order"})
+	assert.NoError(t, err, "movement should succeed")
+	_, err = svc.AddMovement(StockMovement{ProductId: 1, Type: MovementIssue, Quantity: 1, Reason: "order"})
+	assert.NoError(t, err, "movement should succeed")
+	_, err = svc.Patch(2, 0, ProductPatch{Type: mergePatchContentType, Document: []byte(`{"quantity": 0}`)})
+	assert.NoError(t, err, "patch should succeed")
+	svc.drain()
+
+	types := make([]string, 0, len(subscriber.events))
+	for _, event := range subscriber.events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{
+		EventProductUpdated, EventStockLow,
+		EventProductUpdated,
+		EventProductUpdated, EventStockOut,
+	}, types, "expect alerts only when a threshold is crossed")
+	assert.Equal(t, 4, subscriber.events[1].Product.Quantity, "expect alert to carry the product")
+
+	lowStock, err := svc.GetLowStock()
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, []int{1, 2}, productIds(lowStock))
+}
+
+func TestProductServiceImpl_stockAlertsOnReserve(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{
+		{Id: 1, Brand: "A", Category: "A", Quantity: 3, Price: 10, Version: 1},
+	})
+	svc := NewProductServiceImpl(repo)
+	subscriber := &testSubscriber{id: "A"}
+	assert.NoError(t, svc.subscribe(subscriber), "subscribe should succeed")
+
+	_, err := svc.Reserve(Reservation{ProductId: 1, Quantity: 3, Reference: "order-1"})
+	assert.NoError(t, err, "reserve should succeed")
+	svc.drain()
+
+	types := make([]string, 0, len(subscriber.events))
+	for _, event := range subscriber.events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{EventProductUpdated, EventStockOut}, types, "expect a fully reserved product to be out of stock")
+
+	lowStock, err := svc.GetLowStock()
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, []int{1}, productIds(lowStock))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Statuses of a transfer order. Shipping takes the stock out of the source
+// warehouse, after which it is in transit until received at the destination.
+// Closing an order that did not arrive in full reports what is missing and
+// writes it off.
+const (
+	TransferOrderDraft             = "draft"
+	TransferOrderInTransit         = "in_transit"
+	TransferOrderPartiallyReceived = "partially_received"
+	TransferOrderClosed            = "closed"
+	TransferOrderCancelled         = "cancelled"
+)
+
+// TransferOrder moves stock from one warehouse to another.
+type TransferOrder struct {
+	Id              int                 `json:"id" bun:"id,pk,autoincrement"`
+	FromWarehouseId int                 `json:"fromWarehouseId"`
+	ToWarehouseId   int                 `json:"toWarehouseId"`
+	Reference       string              `json:"reference"`
+	Status          string              `json:"status"`
+	Lines           []TransferOrderLine `json:"lines" bun:"rel:has-many,join:id=transfer_order_id"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt"`
+	ShippedAt       time.Time           `json:"shippedAt,omitempty" bun:",nullzero"`
+	ClosedAt        time.Time           `json:"closedAt,omitempty" bun:",nullzero"`
+}
+
+// TransferOrderLine moves Quantity of a product out of FromLocationId. What
+// shipped and is neither Received nor Missing is in transit. Lots are the
+// lots the stock in transit was taken from, every receipt puts its share
+// back into them; once the order is closed they are the lots of what went
+// missing.
+type TransferOrderLine struct {
+	Id              int           `json:"id" bun:"id,pk,autoincrement"`
+	TransferOrderId int           `json:"-"`
+	ProductId       int           `json:"productId"`
+	FromLocationId  int           `json:"fromLocationId"`
+	Quantity        int           `json:"quantity"`
+	Received        int           `json:"received"`
+	Missing         int           `json:"missing,omitempty"`
+	Lots            []LotQuantity `json:"lots,omitempty" bun:",type:jsonb,nullzero"`
+}
+
+// TransferOrderFilter narrows down GetTransferOrders, zero fields match
+// everything.
+type TransferOrderFilter struct {
+	FromWarehouseId int
+	ToWarehouseId   int
+	Status          string
+}
+
+// TransferOrderReceipt books goods arriving at LocationId, a bin of the
+// destination warehouse.
+type TransferOrderReceipt struct {
+	LocationId int                        `json:"locationId"`
+	Actor      string                     `json:"actor"`
+	Lines      []TransferOrderReceiptLine `json:"lines"`
+}
+
+type TransferOrderReceiptLine struct {
+	LineId   int `json:"lineId"`
+	Quantity int `json:"quantity"`
+}
+
+// TransferDiscrepancy is a line of a closed transfer order that did not
+// arrive in full.
+type TransferDiscrepancy struct {
+	TransferOrderId int    `json:"transferOrderId"`
+	Reference       string `json:"reference"`
+	LineId          int    `json:"lineId"`
+	ProductId       int    `json:"productId"`
+	Shipped         int    `json:"shipped"`
+	Received        int    `json:"received"`
+	Missing         int    `json:"missing"`
+}
+
+func validateTransferOrder(order TransferOrder) error {
+	failures := make([]string, 0)
+
+	if order.FromWarehouseId <= 0 {
+		failures = append(failures, "FromWarehouseId should be greater than 0")
+	}
+	if order.ToWarehouseId <= 0 {
+		failures = append(failures, "ToWarehouseId should be greater than 0")
+	} else if order.ToWarehouseId == order.FromWarehouseId {
+		failures = append(failures, "ToWarehouseId should differ from FromWarehouseId")
+	}
+	if len(order.Lines) == 0 {
+		failures = append(failures, "Lines should not be empty")
+	}
+	for idx, line := range order.Lines {
+		if line.ProductId <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: ProductId should be greater than 0", idx+1))
+		}
+		if line.FromLocationId <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: FromLocationId should be greater than 0", idx+1))
+		}
+		if line.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should be greater than 0", idx+1))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// parseTransferOrderFilter reads the fromWarehouseId, toWarehouseId and
+// status query parameters.
+func parseTransferOrderFilter(values url.Values) (TransferOrderFilter, error) {
+	failures := make([]string, 0)
+	filter := TransferOrderFilter{Status: values.Get("status")}
+
+	if raw := values.Get("fromWarehouseId"); raw != "" {
+		fromWarehouseId, err := strconv.Atoi(raw)
+		if err != nil {
+			failures = append(failures, "fromWarehouseId should be an integer")
+		}
+		filter.FromWarehouseId = fromWarehouseId
+	}
+	if raw := values.Get("toWarehouseId"); raw != "" {
+		toWarehouseId, err := strconv.Atoi(raw)
+		if err != nil {
+			failures = append(failures, "toWarehouseId should be an integer")
+		}
+		filter.ToWarehouseId = toWarehouseId
+	}
+	switch filter.Status {
+	case "", TransferOrderDraft, TransferOrderInTransit, TransferOrderPartiallyReceived, TransferOrderClosed, TransferOrderCancelled:
+	default:
+		failures = append(failures, fmt.Sprintf("status should be one of %s, %s, %s, %s, %s", TransferOrderDraft, TransferOrderInTransit, TransferOrderPartiallyReceived, TransferOrderClosed, TransferOrderCancelled))
+	}
+
+	if len(failures) == 0 {
+		return filter, nil
+	}
+	return TransferOrderFilter{}, &validationError{failures: failures}
+}
+
+// validateTransferReceipt checks receipt against the lines of order, which
+// cannot receive more than is still in transit.
+func validateTransferReceipt(order TransferOrder, receipt TransferOrderReceipt) error {
+	failures := make([]string, 0)
+
+	if receipt.LocationId <= 0 {
+		failures = append(failures, "LocationId should be greater than 0")
+	}
+	if len(receipt.Lines) == 0 {
+		failures = append(failures, "Lines should not be empty")
+	}
+	inTransit := make(map[int]int, len(order.Lines))
+	for _, line := range order.Lines {
+		inTransit[line.Id] = line.inTransit()
+	}
+	for idx, line := range receipt.Lines {
+		left, ok := inTransit[line.LineId]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("line %d: LineId %d is not a line of the transfer order", idx+1, line.LineId))
+			continue
+		}
+		if line.Quantity <= 0 {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should be greater than 0", idx+1))
+			continue
+		}
+		if line.Quantity > left {
+			failures = append(failures, fmt.Sprintf("line %d: Quantity should not be more than the %d in transit", idx+1, left))
+			continue
+		}
+		inTransit[line.LineId] = left - line.Quantity
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+// inTransit is what shipped on line without arriving or being given up.
+func (line TransferOrderLine) inTransit() int {
+	return line.Quantity - line.Received - line.Missing
+}
+
+// received tells whether every line of order arrived in full.
+func (order TransferOrder) received() bool {
+	for _, line := range order.Lines {
+		if line.Received < line.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// discrepancies lists the lines of order that went missing.
+func (order TransferOrder) discrepancies() []TransferDiscrepancy {
+	discrepancies := make([]TransferDiscrepancy, 0)
+	for _, line := range order.Lines {
+		if line.Missing == 0 {
+			continue
+		}
+		discrepancies = append(discrepancies, TransferDiscrepancy{
+			TransferOrderId: order.Id,
+			Reference:       order.Reference,
+			LineId:          line.Id,
+			ProductId:       line.ProductId,
+			Shipped:         line.Quantity,
+			Received:        line.Received,
+			Missing:         line.Missing,
+		})
+	}
+	return discrepancies
+}
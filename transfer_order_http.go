@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type transferOrderTransport struct {
+	service TransferOrderService
+}
+
+func NewTransferOrderTransport(svc TransferOrderService) *transferOrderTransport {
+	return &transferOrderTransport{
+		service: svc,
+	}
+}
+
+func (t *transferOrderTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/transfer-orders", t.CreateTransferOrder).Methods("POST")
+	r.HandleFunc("/transfer-orders", t.GetTransferOrders).Methods("GET")
+	r.HandleFunc("/transfer-orders/discrepancies", t.GetTransferDiscrepancies).Methods("GET")
+	r.HandleFunc("/transfer-orders/{id}", t.GetTransferOrderById).Methods("GET")
+	r.HandleFunc("/transfer-orders/{id}/ship", t.ShipTransferOrder).Methods("POST")
+	r.HandleFunc("/transfer-orders/{id}/receive", t.ReceiveTransferOrder).Methods("POST")
+	r.HandleFunc("/transfer-orders/{id}/close", t.CloseTransferOrder).Methods("POST")
+	r.HandleFunc("/transfer-orders/{id}/cancel", t.CancelTransferOrder).Methods("POST")
+}
+
+func (t *transferOrderTransport) CreateTransferOrder(w http.ResponseWriter, r *http.Request) {
+	var order TransferOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreateTransferOrder(order)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *transferOrderTransport) GetTransferOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTransferOrderFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	orders, err := t.service.GetTransferOrders(filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+// GetTransferDiscrepancies takes the warehouse parameters of
+// GetTransferOrders, the status is always closed.
+func (t *transferOrderTransport) GetTransferDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTransferOrderFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	discrepancies, err := t.service.GetTransferDiscrepancies(filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, discrepancies)
+}
+
+func (t *transferOrderTransport) GetTransferOrderById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := t.service.GetTransferOrderById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (t *transferOrderTransport) ShipTransferOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.ShipTransferOrder)
+}
+
+func (t *transferOrderTransport) ReceiveTransferOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var receipt TransferOrderReceipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	order, err := t.service.ReceiveTransferOrder(id, receipt)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (t *transferOrderTransport) CloseTransferOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.CloseTransferOrder)
+}
+
+func (t *transferOrderTransport) CancelTransferOrder(w http.ResponseWriter, r *http.Request) {
+	t.transition(w, r, t.service.CancelTransferOrder)
+}
+
+// transition runs one step of the order named in the path.
+func (t *transferOrderTransport) transition(w http.ResponseWriter, r *http.Request, step func(id int) (TransferOrder, error)) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := step(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferOrderTransport(t *testing.T) {
+	service, products := setupTransferOrders(t)
+	handler := buildHttpHandler(
+		NewhttpTransport(products),
+		NewTransferOrderTransport(service),
+	)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "invalid transfer order",
+			method:         "POST",
+			url:            "/transfer-orders",
+			body:           `{"fromWarehouseId": 1, "toWarehouseId": 1, "lines": []}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["ToWarehouseId should differ from FromWarehouseId", "Lines should not be empty"]}`,
+		},
+		{
+			name:           "unknown warehouse",
+			method:         "POST",
+			url:            "/transfer-orders",
+			body:           `{"fromWarehouseId": 1, "toWarehouseId": 7, "lines": [{"productId": 1, "fromLocationId": 1, "quantity": 1}]}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["warehouse not found"]}`,
+		},
+		{
+			name:           "create transfer order",
+			method:         "POST",
+			url:            "/transfer-orders",
+			body:           `{"fromWarehouseId": 1, "toWarehouseId": 2, "reference": "TO-1", "lines": [{"productId": 1, "fromLocationId": 1, "quantity": 4}]}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "receive before shipping",
+			method:         "POST",
+			url:            "/transfer-orders/1/receive",
+			body:           `{"locationId": 2, "lines": [{"lineId": 1, "quantity": 1}]}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["cannot receive a transfer order that is draft"]}`,
+		},
+		{
+			name:           "ship",
+			method:         "POST",
+			url:            "/transfer-orders/1/ship",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "filter in transit",
+			method:         "GET",
+			url:            "/transfer-orders?fromWarehouseId=1&status=partially_received",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "receive more than shipped",
+			method:         "POST",
+			url:            "/transfer-orders/1/receive",
+			body:           `{"locationId": 2, "lines": [{"lineId": 1, "quantity": 5}]}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["line 1: Quantity should not be more than the 4 in transit"]}`,
+		},
+		{
+			name:           "receive part",
+			method:         "POST",
+			url:            "/transfer-orders/1/receive",
+			body:           `{"locationId": 2, "actor": "dock", "lines": [{"lineId": 1, "quantity": 3}]}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "close short",
+			method:         "POST",
+			url:            "/transfer-orders/1/close",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "discrepancies",
+			method:         "GET",
+			url:            "/transfer-orders/discrepancies?fromWarehouseId=1",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[{"transferOrderId": 1, "reference": "TO-1", "lineId": 1, "productId": 1, "shipped": 4, "received": 3, "missing": 1}]`,
+		},
+		{
+			name:           "unknown transfer order",
+			method:         "POST",
+			url:            "/transfer-orders/7/ship",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["transfer order not found"]}`,
+		},
+		{
+			name:           "invalid id",
+			method:         "POST",
+			url:            "/transfer-orders/abc/close",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid id"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/transfer-orders/1", nil))
+	var order TransferOrder
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&order), "expect transfer order response")
+	assert.Equal(t, TransferOrderClosed, order.Status)
+	assert.False(t, order.ClosedAt.IsZero(), "expect the closing time")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/products/1", nil))
+	var product Product
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&product), "expect product response")
+	assert.Equal(t, 5, product.Quantity, "expect the missing unit to stay gone")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+)
+
+var errTransferOrderNotFound = errors.New("transfer order not found")
+
+// TransferOrderRepo stores transfer orders with their lines, ordered by id.
+// The lines are fixed once the order is created, only what arrived of them
+// changes.
+type TransferOrderRepo interface {
+	// CreateTransferOrder fails with errProductNotFound when a line refers to
+	// a product that does not exist.
+	CreateTransferOrder(TransferOrder) (TransferOrder, error)
+	GetTransferOrderById(id int) (TransferOrder, error)
+	// LockTransferOrder gets the order like GetTransferOrderById and makes
+	// other transactions locking it wait until the calling one ends.
+	LockTransferOrder(id int) (TransferOrder, error)
+	GetTransferOrders(TransferOrderFilter) ([]TransferOrder, error)
+	// UpdateTransferOrder writes the status and timestamps of the order and
+	// the received and missing quantities of its lines.
+	UpdateTransferOrder(TransferOrder) error
+}
+
+func (r *InMemoryRepo) CreateTransferOrder(order TransferOrder) (TransferOrder, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for _, line := range order.Lines {
+		if _, err := r.GetById(line.ProductId); err != nil {
+			return TransferOrder{}, err
+		}
+	}
+
+	r.lastTransferOrderId++
+	order.Id = r.lastTransferOrderId
+	lines := make([]TransferOrderLine, len(order.Lines))
+	for idx, line := range order.Lines {
+		r.lastTransferOrderLineId++
+		line.Id = r.lastTransferOrderLineId
+		line.TransferOrderId = order.Id
+		lines[idx] = line
+	}
+	order.Lines = lines
+	r.transferOrders = append(r.transferOrders, order)
+	return copyTransferOrder(order), nil
+}
+
+func (r *InMemoryRepo) GetTransferOrderById(id int) (TransferOrder, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentOrder := range r.transferOrders {
+		if currentOrder.Id == id {
+			return copyTransferOrder(currentOrder), nil
+		}
+	}
+	return TransferOrder{}, errTransferOrderNotFound
+}
+
+// LockTransferOrder needs no lock of its own, InTx already runs one
+// transaction at a time.
+func (r *InMemoryRepo) LockTransferOrder(id int) (TransferOrder, error) {
+	return r.GetTransferOrderById(id)
+}
+
+func (r *InMemoryRepo) GetTransferOrders(filter TransferOrderFilter) ([]TransferOrder, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	orders := make([]TransferOrder, 0)
+	for _, currentOrder := range r.transferOrders {
+		if filter.FromWarehouseId != 0 && currentOrder.FromWarehouseId != filter.FromWarehouseId {
+			continue
+		}
+		if filter.ToWarehouseId != 0 && currentOrder.ToWarehouseId != filter.ToWarehouseId {
+			continue
+		}
+		if filter.Status != "" && currentOrder.Status != filter.Status {
+			continue
+		}
+		orders = append(orders, copyTransferOrder(currentOrder))
+	}
+	return orders, nil
+}
+
+func (r *InMemoryRepo) UpdateTransferOrder(order TransferOrder) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentOrder := range r.transferOrders {
+		if currentOrder.Id == order.Id {
+			updated := copyTransferOrder(currentOrder)
+			updated.Status = order.Status
+			updated.UpdatedAt = order.UpdatedAt
+			updated.ShippedAt = order.ShippedAt
+			updated.ClosedAt = order.ClosedAt
+			for lineIdx, line := range updated.Lines {
+				for _, changed := range order.Lines {
+					if changed.Id == line.Id {
+						updated.Lines[lineIdx].Received = changed.Received
+						updated.Lines[lineIdx].Missing = changed.Missing
+						updated.Lines[lineIdx].Lots = changed.Lots
+					}
+				}
+			}
+			r.transferOrders[idx] = updated
+			return nil
+		}
+	}
+	return errTransferOrderNotFound
+}
+
+// copyTransferOrder keeps callers from changing the stored lines.
+func copyTransferOrder(order TransferOrder) TransferOrder {
+	order.Lines = append([]TransferOrderLine{}, order.Lines...)
+	return order
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_TransferOrders(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A"}, {Id: 2, Brand: "B", Category: "B"}})
+
+	_, err := repo.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{{ProductId: 7, Quantity: 1}}})
+	assert.ErrorIs(t, err, errProductNotFound)
+
+	order, err := repo.CreateTransferOrder(TransferOrder{
+		FromWarehouseId: 1,
+		ToWarehouseId:   2,
+		Status:          TransferOrderDraft,
+		Lines:           []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 1}, {ProductId: 2, FromLocationId: 1, Quantity: 2}},
+	})
+	assert.NoError(t, err, "create transfer order should succeed")
+	assert.Equal(t, []int{1, 2}, []int{order.Lines[0].Id, order.Lines[1].Id}, "expect lines to be numbered")
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	order.Status = TransferOrderClosed
+	order.ClosedAt = now
+	order.ToWarehouseId = 3
+	order.Lines[1].Received = 1
+	order.Lines[1].Missing = 1
+	order.Lines[1].Quantity = 9
+	assert.NoError(t, repo.UpdateTransferOrder(order), "update transfer order should succeed")
+
+	stored, _ := repo.GetTransferOrderById(order.Id)
+	assert.Equal(t, TransferOrderClosed, stored.Status)
+	assert.Equal(t, now, stored.ClosedAt)
+	assert.Equal(t, 2, stored.ToWarehouseId, "expect only the status and timestamps to be written")
+	assert.Equal(t, 1, stored.Lines[1].Received)
+	assert.Equal(t, 1, stored.Lines[1].Missing)
+	assert.Equal(t, 2, stored.Lines[1].Quantity, "expect the lines to be fixed")
+	assert.ErrorIs(t, repo.UpdateTransferOrder(TransferOrder{Id: 7}), errTransferOrderNotFound)
+
+	orders, err := repo.GetTransferOrders(TransferOrderFilter{FromWarehouseId: 1, Status: TransferOrderDraft})
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, orders, "expect the status filter to apply")
+	orders, err = repo.GetTransferOrders(TransferOrderFilter{FromWarehouseId: 1, ToWarehouseId: 2})
+	assert.NoError(t, err, "expect no error")
+	assert.Len(t, orders, 1)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type TransferOrderService interface {
+	CreateTransferOrder(TransferOrder) (TransferOrder, error)
+	GetTransferOrderById(id int) (TransferOrder, error)
+	GetTransferOrders(TransferOrderFilter) ([]TransferOrder, error)
+	ShipTransferOrder(id int) (TransferOrder, error)
+	ReceiveTransferOrder(id int, receipt TransferOrderReceipt) (TransferOrder, error)
+	CloseTransferOrder(id int) (TransferOrder, error)
+	CancelTransferOrder(id int) (TransferOrder, error)
+	GetTransferDiscrepancies(TransferOrderFilter) ([]TransferDiscrepancy, error)
+}
+
+// TransferOrderServiceImpl moves stock between warehouses through the
+// product service, so both legs of a transfer are in the stock ledger and
+// published. In between the stock is on neither site but in transit, see
+// Product.InTransit.
+type TransferOrderServiceImpl struct {
+	repo     Repo
+	products *ProductServiceImpl
+}
+
+func NewTransferOrderServiceImpl(repo Repo, products *ProductServiceImpl) *TransferOrderServiceImpl {
+	return &TransferOrderServiceImpl{
+		repo:     repo,
+		products: products,
+	}
+}
+
+func (s *TransferOrderServiceImpl) CreateTransferOrder(order TransferOrder) (TransferOrder, error) {
+	if err := validateTransferOrder(order); err != nil {
+		return TransferOrder{}, fmt.Errorf("create transfer order: %w", err)
+	}
+
+	var created TransferOrder
+	err := s.repo.InTx(func(tx Repo) (err error) {
+		for _, warehouseId := range []int{order.FromWarehouseId, order.ToWarehouseId} {
+			if _, err := tx.GetWarehouseById(warehouseId); err != nil {
+				return err
+			}
+		}
+		failures := make([]string, 0)
+		for idx, line := range order.Lines {
+			location, err := tx.GetLocationById(line.FromLocationId)
+			if err != nil {
+				return err
+			}
+			if location.WarehouseId != order.FromWarehouseId {
+				failures = append(failures, fmt.Sprintf("line %d: FromLocationId %d is not in warehouse %d", idx+1, line.FromLocationId, order.FromWarehouseId))
+			}
+		}
+		if len(failures) > 0 {
+			return fmt.Errorf("create transfer order: %w", &validationError{failures: failures})
+		}
+
+		timeNow := time.Now()
+		order.Status = TransferOrderDraft
+		order.CreatedAt = timeNow
+		order.UpdatedAt = timeNow
+		order.ShippedAt = time.Time{}
+		order.ClosedAt = time.Time{}
+		lines := make([]TransferOrderLine, len(order.Lines))
+		for idx, line := range order.Lines {
+			lines[idx] = TransferOrderLine{ProductId: line.ProductId, FromLocationId: line.FromLocationId, Quantity: line.Quantity}
+		}
+		order.Lines = lines
+		created, err = tx.CreateTransferOrder(order)
+		return err
+	})
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return created, nil
+}
+
+func (s *TransferOrderServiceImpl) GetTransferOrderById(id int) (TransferOrder, error) {
+	return s.repo.GetTransferOrderById(id)
+}
+
+func (s *TransferOrderServiceImpl) GetTransferOrders(filter TransferOrderFilter) ([]TransferOrder, error) {
+	return s.repo.GetTransferOrders(filter)
+}
+
+// ShipTransferOrder takes the stock of every line out of its source bin into
+// transit with a dispatch movement, or of none when one of them is short. The
+// line keeps the lots the stock was taken from.
+func (s *TransferOrderServiceImpl) ShipTransferOrder(id int) (TransferOrder, error) {
+	var order TransferOrder
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		order, err = tx.repo.LockTransferOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("transfer order", "ship", order.Status, TransferOrderDraft); err != nil {
+			return err
+		}
+
+		for idx, line := range order.Lines {
+			dispatched, err := tx.addMovement(StockMovement{
+				ProductId:  line.ProductId,
+				Type:       MovementDispatch,
+				Quantity:   line.Quantity,
+				LocationId: line.FromLocationId,
+				Reason:     fmt.Sprintf("transfer order %d shipped", order.Id),
+				Reference:  order.Reference,
+			})
+			if err != nil {
+				return err
+			}
+			order.Lines[idx].Lots = dispatched.Lots
+		}
+
+		now := time.Now()
+		order.Status = TransferOrderInTransit
+		order.ShippedAt = now
+		order.UpdatedAt = now
+		return tx.repo.UpdateTransferOrder(order)
+	})
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return order, nil
+}
+
+// ReceiveTransferOrder books what arrived out of transit into a bin of the
+// destination warehouse with an arrival movement per line, which puts its
+// share of the lots in transit back. The order is closed once every line
+// arrived in full.
+func (s *TransferOrderServiceImpl) ReceiveTransferOrder(id int, receipt TransferOrderReceipt) (TransferOrder, error) {
+	var order TransferOrder
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		order, err = tx.repo.LockTransferOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("transfer order", "receive", order.Status, TransferOrderInTransit, TransferOrderPartiallyReceived); err != nil {
+			return err
+		}
+		if err := validateTransferReceipt(order, receipt); err != nil {
+			return fmt.Errorf("receive transfer order: %w", err)
+		}
+		location, err := tx.repo.GetLocationById(receipt.LocationId)
+		if err != nil {
+			return err
+		}
+		if location.WarehouseId != order.ToWarehouseId {
+			return fmt.Errorf("receive transfer order: %w", &validationError{failures: []string{
+				fmt.Sprintf("LocationId %d is not in warehouse %d", receipt.LocationId, order.ToWarehouseId),
+			}})
+		}
+
+		for _, received := range receipt.Lines {
+			for idx := range order.Lines {
+				line := &order.Lines[idx]
+				if line.Id != received.LineId {
+					continue
+				}
+				lots := prorateLots(line.Lots, line.inTransit(), received.Quantity)
+				line.Lots = subtractLots(line.Lots, lots)
+				line.Received += received.Quantity
+				_, err := tx.addMovement(StockMovement{
+					ProductId:  line.ProductId,
+					Type:       MovementArrival,
+					Quantity:   received.Quantity,
+					LocationId: receipt.LocationId,
+					Lots:       lots,
+					Reason:     fmt.Sprintf("transfer order %d received", order.Id),
+					Reference:  order.Reference,
+					Actor:      receipt.Actor,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		now := time.Now()
+		order.Status = TransferOrderPartiallyReceived
+		if order.received() {
+			order.Status = TransferOrderClosed
+			order.ClosedAt = now
+		}
+		order.UpdatedAt = now
+		return tx.repo.UpdateTransferOrder(order)
+	})
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return order, nil
+}
+
+// CloseTransferOrder gives up on what is still in transit, recording it as
+// missing on its line and writing it off with a loss movement. The missing
+// stock already left the source warehouse, so closing changes no stock on
+// hand.
+func (s *TransferOrderServiceImpl) CloseTransferOrder(id int) (TransferOrder, error) {
+	var order TransferOrder
+	err := s.products.write(func(tx *ProductServiceImpl) (err error) {
+		order, err = tx.repo.LockTransferOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("transfer order", "close", order.Status, TransferOrderInTransit, TransferOrderPartiallyReceived); err != nil {
+			return err
+		}
+
+		for idx, line := range order.Lines {
+			missing := line.inTransit()
+			if missing == 0 {
+				continue
+			}
+			order.Lines[idx].Missing += missing
+			_, err := tx.addMovement(StockMovement{
+				ProductId: line.ProductId,
+				Type:      MovementLoss,
+				Quantity:  missing,
+				Reason:    fmt.Sprintf("transfer order %d closed with stock missing", order.Id),
+				Reference: order.Reference,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		order.Status = TransferOrderClosed
+		order.ClosedAt = now
+		order.UpdatedAt = now
+		return tx.repo.UpdateTransferOrder(order)
+	})
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return order, nil
+}
+
+// CancelTransferOrder drops an order that has not shipped yet.
+func (s *TransferOrderServiceImpl) CancelTransferOrder(id int) (TransferOrder, error) {
+	return s.transition(id, "cancel", TransferOrderCancelled, []string{TransferOrderDraft})
+}
+
+// GetTransferDiscrepancies reports the lines of the closed orders matching
+// filter that did not arrive in full.
+func (s *TransferOrderServiceImpl) GetTransferDiscrepancies(filter TransferOrderFilter) ([]TransferDiscrepancy, error) {
+	filter.Status = TransferOrderClosed
+	orders, err := s.repo.GetTransferOrders(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := make([]TransferDiscrepancy, 0)
+	for _, order := range orders {
+		discrepancies = append(discrepancies, order.discrepancies()...)
+	}
+	return discrepancies, nil
+}
+
+// transition moves the order with id from one of from to status. It touches
+// no stock.
+func (s *TransferOrderServiceImpl) transition(id int, action, status string, from []string) (TransferOrder, error) {
+	var order TransferOrder
+	err := s.repo.InTx(func(tx Repo) (err error) {
+		order, err = tx.LockTransferOrder(id)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition("transfer order", action, order.Status, from...); err != nil {
+			return err
+		}
+
+		order.Status = status
+		order.UpdatedAt = time.Now()
+		return tx.UpdateTransferOrder(order)
+	})
+	if err != nil {
+		return TransferOrder{}, err
+	}
+	return order, nil
+}
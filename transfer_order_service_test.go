@@ -0,0 +1,184 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTransferOrders stocks 6 of product 1 in location 1 of warehouse 1,
+// next to warehouse 2 with location 2.
+func setupTransferOrders(t *testing.T) (*TransferOrderServiceImpl, *ProductServiceImpl) {
+	repo := setupInMemoryRepo([]Product{{Id: 1, Brand: "A", Category: "A", Version: 1}})
+	for _, code := range []string{"BLR", "DEL"} {
+		warehouse, err := repo.CreateWarehouse(Warehouse{Code: code, Name: code})
+		assert.NoError(t, err, "create warehouse should succeed")
+		_, err = repo.CreateLocation(Location{WarehouseId: warehouse.Id, Code: "A-01", Aisle: "A", Bin: "01"})
+		assert.NoError(t, err, "create location should succeed")
+	}
+	products := NewProductServiceImpl(repo)
+	_, err := products.AddMovement(StockMovement{ProductId: 1, Type: MovementReceipt, Quantity: 6, LocationId: 1, Reason: "opening stock"})
+	assert.NoError(t, err, "add movement should succeed")
+	return NewTransferOrderServiceImpl(repo, products), products
+}
+
+func TestTransferOrderServiceImpl_Create(t *testing.T) {
+	service, _ := setupTransferOrders(t)
+
+	_, err := service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 7, Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 1}}})
+	assert.ErrorIs(t, err, errWarehouseNotFound)
+
+	_, err = service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 2, Quantity: 1}}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "expect the source bin to be in the source warehouse") {
+		assert.Equal(t, []string{"line 1: FromLocationId 2 is not in warehouse 1"}, ve.failures)
+	}
+
+	order, err := service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 1, Received: 1}}})
+	assert.NoError(t, err, "create transfer order should succeed")
+	assert.Equal(t, TransferOrderDraft, order.Status)
+	assert.Zero(t, order.Lines[0].Received, "expect nothing to be received yet")
+
+	cancelled, err := service.CancelTransferOrder(order.Id)
+	assert.NoError(t, err, "cancel should succeed")
+	assert.Equal(t, TransferOrderCancelled, cancelled.Status)
+	_, err = service.ShipTransferOrder(order.Id)
+	var te *transitionError
+	if assert.ErrorAs(t, err, &te, "expect a cancelled order not to ship") {
+		assert.Equal(t, "cannot ship a transfer order that is cancelled", te.Error())
+	}
+}
+
+func TestTransferOrderServiceImpl_Transfer(t *testing.T) {
+	service, products := setupTransferOrders(t)
+	order, err := service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Reference: "TO-1", Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 5}}})
+	assert.NoError(t, err, "create transfer order should succeed")
+
+	shipped, err := service.ShipTransferOrder(order.Id)
+	assert.NoError(t, err, "ship should succeed")
+	assert.Equal(t, TransferOrderInTransit, shipped.Status)
+	assert.False(t, shipped.ShippedAt.IsZero(), "expect the shipping time")
+	product, _ := products.GetById(1)
+	assert.Equal(t, 1, product.Quantity, "expect shipping to take the stock out of the source")
+	assert.Equal(t, 5, product.InTransit, "expect the shipped stock to be in transit")
+	assert.Equal(t, 5, shipped.Lines[0].inTransit())
+
+	_, err = service.ReceiveTransferOrder(order.Id, TransferOrderReceipt{LocationId: 1, Lines: []TransferOrderReceiptLine{{LineId: order.Lines[0].Id, Quantity: 2}}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "expect goods to arrive at the destination") {
+		assert.Equal(t, []string{"LocationId 1 is not in warehouse 2"}, ve.failures)
+	}
+
+	partial, err := service.ReceiveTransferOrder(order.Id, TransferOrderReceipt{LocationId: 2, Actor: "dock", Lines: []TransferOrderReceiptLine{{LineId: order.Lines[0].Id, Quantity: 3}}})
+	assert.NoError(t, err, "receive should succeed")
+	assert.Equal(t, TransferOrderPartiallyReceived, partial.Status)
+	assert.Equal(t, 2, partial.Lines[0].inTransit())
+	levels, _ := products.repo.GetStockByLocation(2)
+	assert.Equal(t, []StockLevel{{ProductId: 1, LocationId: 2, Quantity: 3}}, levels)
+	movements, _ := products.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		movement := movements[len(movements)-1]
+		assert.Equal(t, MovementArrival, movement.Type)
+		assert.Equal(t, "transfer order 1 received", movement.Reason)
+		assert.Equal(t, "TO-1", movement.Reference)
+	}
+	product, _ = products.GetById(1)
+	assert.Equal(t, 4, product.Quantity, "expect the arrived stock to be on hand")
+	assert.Equal(t, 2, product.InTransit, "expect the rest to stay in transit")
+
+	closed, err := service.CloseTransferOrder(order.Id)
+	assert.NoError(t, err, "close should succeed")
+	assert.Equal(t, TransferOrderClosed, closed.Status)
+	assert.Equal(t, 2, closed.Lines[0].Missing, "expect what never arrived to be missing")
+	product, _ = products.GetById(1)
+	assert.Equal(t, 4, product.Quantity, "expect closing to change no stock on hand")
+	assert.Zero(t, product.InTransit, "expect the missing stock to be written off")
+	movements, _ = products.GetMovements(1)
+	if assert.NotEmpty(t, movements) {
+		movement := movements[len(movements)-1]
+		assert.Equal(t, MovementLoss, movement.Type)
+		assert.Equal(t, 2, movement.Quantity)
+	}
+
+	discrepancies, err := service.GetTransferDiscrepancies(TransferOrderFilter{ToWarehouseId: 2})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, []TransferDiscrepancy{{TransferOrderId: 1, Reference: "TO-1", LineId: 1, ProductId: 1, Shipped: 5, Received: 3, Missing: 2}}, discrepancies)
+}
+
+func TestTransferOrderServiceImpl_ShipShort(t *testing.T) {
+	service, products := setupTransferOrders(t)
+	order, err := service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{
+		{ProductId: 1, FromLocationId: 1, Quantity: 4},
+		{ProductId: 1, FromLocationId: 1, Quantity: 4},
+	}})
+	assert.NoError(t, err, "create transfer order should succeed")
+
+	_, err = service.ShipTransferOrder(order.Id)
+	assert.ErrorIs(t, err, errInsufficientStock)
+
+	stored, _ := service.GetTransferOrderById(order.Id)
+	assert.Equal(t, TransferOrderDraft, stored.Status, "expect a short order to stay a draft")
+	product, _ := products.GetById(1)
+	assert.Equal(t, 6, product.Quantity, "expect no line to leave the source")
+}
+
+func TestTransferOrderServiceImpl_ShipRaisesNoStockAlert(t *testing.T) {
+	service, products := setupTransferOrders(t)
+	product, _ := products.GetById(1)
+	product.ReorderPoint = 2
+	assert.NoError(t, products.Update(product), "update should succeed")
+	subscriber := &testSubscriber{id: "A"}
+	assert.NoError(t, products.subscribe(subscriber), "subscribe should succeed")
+
+	order, err := service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 6}}})
+	assert.NoError(t, err, "create transfer order should succeed")
+	_, err = service.ShipTransferOrder(order.Id)
+	assert.NoError(t, err, "ship should succeed")
+	lowStock, err := products.GetLowStock()
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, lowStock, "expect stock in transit not to be low")
+	_, err = service.ReceiveTransferOrder(order.Id, TransferOrderReceipt{LocationId: 2, Lines: []TransferOrderReceiptLine{{LineId: order.Lines[0].Id, Quantity: 6}}})
+	assert.NoError(t, err, "receive should succeed")
+	products.drain()
+
+	for _, event := range subscriber.events {
+		assert.NotContains(t, []string{EventStockLow, EventStockOut}, event.Type, "expect stock in transit not to alert")
+	}
+}
+
+func TestTransferOrderServiceImpl_TransferLots(t *testing.T) {
+	service, products := setupTransferOrders(t)
+	lot, err := products.CreateLot(Lot{ProductId: 1, Number: "L1", ExpiresAt: time.Now().AddDate(0, 0, 10)})
+	assert.NoError(t, err, "create lot should succeed")
+	_, err = products.AddMovement(StockMovement{ProductId: 1, Type: MovementReceipt, Quantity: 4, LocationId: 1, Lots: []LotQuantity{{LotId: lot.Id, Quantity: 4}}, Reason: "delivery"})
+	assert.NoError(t, err, "add movement should succeed")
+
+	order, err := service.CreateTransferOrder(TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 6}}})
+	assert.NoError(t, err, "create transfer order should succeed")
+	shipped, err := service.ShipTransferOrder(order.Id)
+	assert.NoError(t, err, "ship should succeed")
+	assert.Equal(t, []LotQuantity{{LotId: lot.Id, Quantity: 4}}, shipped.Lines[0].Lots, "expect the line to keep the lots it was taken from")
+	stored, _ := products.GetLotById(lot.Id)
+	assert.Zero(t, stored.Quantity, "expect the lot to be in transit")
+
+	lineId := order.Lines[0].Id
+	partial, err := service.ReceiveTransferOrder(order.Id, TransferOrderReceipt{LocationId: 2, Lines: []TransferOrderReceiptLine{{LineId: lineId, Quantity: 3}}})
+	assert.NoError(t, err, "receive should succeed")
+	assert.Equal(t, []LotQuantity{{LotId: lot.Id, Quantity: 2}}, partial.Lines[0].Lots, "expect the rest of the lot to stay in transit")
+	stored, _ = products.GetLotById(lot.Id)
+	assert.Equal(t, 2, stored.Quantity, "expect a partial receipt to bring back its share of the lot")
+
+	_, err = service.ReceiveTransferOrder(order.Id, TransferOrderReceipt{LocationId: 2, Lines: []TransferOrderReceiptLine{{LineId: lineId, Quantity: 3}}})
+	assert.NoError(t, err, "receive should succeed")
+	stored, _ = products.GetLotById(lot.Id)
+	assert.Equal(t, 4, stored.Quantity, "expect the lot to be back in full")
+	product, _ := products.GetById(1)
+	assert.Equal(t, 10, product.Quantity)
+
+	expiring, err := products.GetExpiringLots(30 * 24 * time.Hour)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, expiring, 1, "expect the received lot to be reported") {
+		assert.Equal(t, 4, expiring[0].Quantity)
+	}
+}
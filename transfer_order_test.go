@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTransferOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		order        TransferOrder
+		wantFailures []string
+	}{
+		{
+			name:  "valid order",
+			order: TransferOrder{FromWarehouseId: 1, ToWarehouseId: 2, Lines: []TransferOrderLine{{ProductId: 1, FromLocationId: 1, Quantity: 2}}},
+		},
+		{
+			name:         "empty order",
+			order:        TransferOrder{},
+			wantFailures: []string{"FromWarehouseId should be greater than 0", "ToWarehouseId should be greater than 0", "Lines should not be empty"},
+		},
+		{
+			name:  "same warehouse and invalid line",
+			order: TransferOrder{FromWarehouseId: 1, ToWarehouseId: 1, Lines: []TransferOrderLine{{}}},
+			wantFailures: []string{
+				"ToWarehouseId should differ from FromWarehouseId",
+				"line 1: ProductId should be greater than 0",
+				"line 1: FromLocationId should be greater than 0",
+				"line 1: Quantity should be greater than 0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTransferOrder(tt.order)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestValidateTransferReceipt(t *testing.T) {
+	order := TransferOrder{Lines: []TransferOrderLine{{Id: 1, Quantity: 5, Received: 2}, {Id: 2, Quantity: 1}}}
+
+	tests := []struct {
+		name         string
+		receipt      TransferOrderReceipt
+		wantFailures []string
+	}{
+		{
+			name:    "partial receipt",
+			receipt: TransferOrderReceipt{LocationId: 1, Lines: []TransferOrderReceiptLine{{LineId: 1, Quantity: 3}}},
+		},
+		{
+			name:         "empty receipt",
+			receipt:      TransferOrderReceipt{},
+			wantFailures: []string{"LocationId should be greater than 0", "Lines should not be empty"},
+		},
+		{
+			name: "invalid lines",
+			receipt: TransferOrderReceipt{LocationId: 1, Lines: []TransferOrderReceiptLine{
+				{LineId: 7, Quantity: 1},
+				{LineId: 2, Quantity: 0},
+				{LineId: 1, Quantity: 2},
+				{LineId: 1, Quantity: 2},
+			}},
+			wantFailures: []string{
+				"line 1: LineId 7 is not a line of the transfer order",
+				"line 2: Quantity should be greater than 0",
+				"line 4: Quantity should not be more than the 1 in transit",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTransferReceipt(order, tt.receipt)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestTransferOrder_Discrepancies(t *testing.T) {
+	order := TransferOrder{Id: 3, Reference: "TO-3", Lines: []TransferOrderLine{
+		{Id: 1, ProductId: 1, Quantity: 5, Received: 5},
+		{Id: 2, ProductId: 2, Quantity: 4, Received: 1, Missing: 3},
+	}}
+
+	assert.Equal(t, []TransferDiscrepancy{
+		{TransferOrderId: 3, Reference: "TO-3", LineId: 2, ProductId: 2, Shipped: 4, Received: 1, Missing: 3},
+	}, order.discrepancies())
+	assert.False(t, order.received())
+	assert.Equal(t, 0, order.Lines[1].inTransit())
+}
+
+func TestParseTransferOrderFilter(t *testing.T) {
+	filter, err := parseTransferOrderFilter(url.Values{"fromWarehouseId": {"1"}, "toWarehouseId": {"2"}, "status": {TransferOrderInTransit}})
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, TransferOrderFilter{FromWarehouseId: 1, ToWarehouseId: 2, Status: TransferOrderInTransit}, filter)
+
+	_, err = parseTransferOrderFilter(url.Values{"toWarehouseId": {"x"}, "status": {"lost"}})
+	var ve *validationError
+	if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+		assert.Equal(t, []string{
+			"toWarehouseId should be an integer",
+			"status should be one of draft, in_transit, partially_received, closed, cancelled",
+		}, ve.failures)
+	}
+}
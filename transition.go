@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+)
+
+// transitionError rejects an action the status of an order does not allow.
+type transitionError struct {
+	entity string
+	action string
+	status string
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("cannot %s a %s that is %s", e.action, e.entity, e.status)
+}
+
+// checkTransition fails with a transitionError unless status is one of
+// allowed.
+func checkTransition(entity, action, status string, allowed ...string) error {
+	for _, candidate := range allowed {
+		if candidate == status {
+			return nil
+		}
+	}
+	return &transitionError{entity: entity, action: action, status: status}
+}
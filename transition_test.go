@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTransition(t *testing.T) {
+	assert.NoError(t, checkTransition("purchase order", "send", PurchaseOrderDraft, PurchaseOrderDraft))
+
+	err := checkTransition("purchase order", "receive", PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived)
+	var te *transitionError
+	if assert.ErrorAs(t, err, &te, "error should be of transitionError type") {
+		assert.Equal(t, "cannot receive a purchase order that is draft", te.Error())
+	}
+}
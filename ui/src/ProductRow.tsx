@@ -20,6 +20,7 @@ const ProductRow: FunctionComponent<ProductRowProps> = ({
         <Td>{product.brand}</Td>
         <Td>{product.category}</Td>
         <Td>{product.quantity}</Td>
+        <Td>{product.available ?? product.quantity}</Td>
         <Td>{product.price}</Td>
         <Td>
           <Link
@@ -5,7 +5,7 @@ import { FunctionComponent, useEffect, useState } from "react";
 
 export interface ProductsTableProps {
   products: Product[];
-  handleDelete: (id: number) => void;
+  handleDelete: (id: number, version?: number) => void;
 }
 
 const ProductsTable: FunctionComponent<ProductsTableProps> = ({
@@ -27,6 +27,7 @@ const ProductsTable: FunctionComponent<ProductsTableProps> = ({
             <Th>Brand</Th>
             <Th>Category</Th>
             <Th>Quantity</Th>
+            <Th>Available</Th>
             <Th>Price</Th>
           </Tr>
         </Thead>
@@ -35,7 +36,7 @@ const ProductsTable: FunctionComponent<ProductsTableProps> = ({
             <ProductRow
               key={index}
               product={item}
-              onDelete={() => handleDelete(item.id)}
+              onDelete={() => handleDelete(item.id, item.version)}
             />
           ))}
         </Tbody>
@@ -1,9 +1,27 @@
 import axios from "axios";
 import { Product } from "product";
 
-export async function getProducts(): Promise<Product[]> {
-  const response = await axios.get("/products");
-  return response.data;
+// PAGE_SIZE is how many products the list asks for at a time; the server
+// caps pages anyway, so the whole catalog is never fetched in one request.
+const PAGE_SIZE = 100;
+
+export interface ProductPage {
+  products: Product[];
+  total: number;
+  nextCursor?: string;
+}
+
+// getProducts fetches the page starting at cursor, or the first page when
+// cursor is undefined.
+export async function getProducts(cursor?: string): Promise<ProductPage> {
+  const response = await axios.get("/products", {
+    params: { limit: PAGE_SIZE, cursor },
+  });
+  return {
+    products: response.data,
+    total: Number(response.headers["x-total-count"]),
+    nextCursor: response.headers["x-next-cursor"] || undefined,
+  };
 }
 
 export async function createProduct(product: Product): Promise<void> {
@@ -15,10 +33,25 @@ export async function getProductById(id: number): Promise<Product> {
 }
 
 export async function updateProduct(product: Product): Promise<void> {
-  await axios.put(`/products/${product.id}`, product);
+  await axios.put(`/products/${product.id}`, product, {
+    headers: { "If-Match": ifMatch(product.version) },
+  });
   console.log(product);
 }
 
-export async function deleteProduct(id: number): Promise<void> {
-  await axios.delete(`/products/${id}`);
+export async function deleteProduct(id: number, version?: number): Promise<void> {
+  await axios.delete(`/products/${id}`, {
+    headers: { "If-Match": ifMatch(version) },
+  });
+}
+
+// ifMatch makes a write conditional on the version it was read at, the server
+// answers 412 when the product changed in the meantime. Without a version the
+// write fails instead of falling back to "*", which would overwrite whatever
+// is stored.
+function ifMatch(version?: number): string {
+  if (version === undefined) {
+    throw new Error("The product version is unknown, reload the product and try again");
+  }
+  return `"${version}"`;
 }
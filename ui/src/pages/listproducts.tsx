@@ -1,18 +1,30 @@
-import { Spinner, useToast } from "@chakra-ui/react";
+import { Button, Spinner, useToast } from "@chakra-ui/react";
 import { deleteProduct, getProducts } from "api";
-import { Product } from "product";
+import {
+  applyProductEvent,
+  isStockAlert,
+  Product,
+  ProductEvent,
+} from "product";
 import ProductsTable from "ProductsTable";
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 
 export default function ProductsList() {
   const [products, setProducts] = useState<Product[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
   const [loading, setLoading] = useState(true);
   const toast = useToast();
 
-  function updateProductList() {
-    getProducts()
-      .then((products) => {
-        setProducts(products);
+  // loadProducts replaces the list with the first page, or appends the page at
+  // cursor to it.
+  function loadProducts(cursor?: string) {
+    setLoading(true);
+    getProducts(cursor)
+      .then((page) => {
+        setProducts((products) =>
+          cursor === undefined ? page.products : [...products, ...page.products]
+        );
+        setNextCursor(page.nextCursor);
       })
       .catch((error) => {
         toast({
@@ -27,32 +39,49 @@ export default function ProductsList() {
       });
   }
 
-  function handleDelete(id: number) {
-    deleteProduct(id)
+  function handleDelete(id: number, version?: number) {
+    deleteProduct(id, version)
       .then(() => {
-        updateProductList();
+        loadProducts();
       })
       .catch((err) => {
         console.log(err);
       });
   }
 
+  // seq of the last event applied, sent on reconnect to get what was missed
+  const lastSeq = useRef<number | undefined>(undefined);
+
   function connectWebsocket() {
-    let socket = new WebSocket("ws://127.0.0.1:5000/ws");
+    const since = lastSeq.current === undefined ? "" : `?since=${lastSeq.current}`;
+    let socket = new WebSocket(`ws://127.0.0.1:5000/ws${since}`);
     console.log("Attempting Connection...");
 
     socket.onopen = () => {
       console.log("Successfully Connected");
-      socket.send("Hi From the Client!");
     };
 
     socket.onmessage = (message) => {
       console.log("data: ", message.data);
-      var products = message.data;
       try {
-        var result = JSON.parse(products);
-        console.log(result);
-        setProducts(result);
+        var event: ProductEvent = JSON.parse(message.data);
+        if (event.seq === undefined) {
+          console.log("realtime error: ", message.data);
+          return;
+        }
+        lastSeq.current = event.seq;
+        if (isStockAlert(event) && event.product) {
+          toast({
+            title:
+              event.type === "stock.out"
+                ? `${event.product.brand} ${event.product.category} is out of stock`
+                : `${event.product.brand} ${event.product.category} is low on stock`,
+            isClosable: true,
+            status: "warning",
+          });
+          return;
+        }
+        setProducts((products) => applyProductEvent(products, event));
       } catch (err) {
         console.log("error while converting json to js object", err);
       }
@@ -60,7 +89,7 @@ export default function ProductsList() {
 
     socket.onclose = (event) => {
       console.log("Socket Closed Connection: ", event);
-      socket.send("Client Closed!");
+      setTimeout(connectWebsocket, 1000);
     };
 
     socket.onerror = (error) => {
@@ -70,7 +99,7 @@ export default function ProductsList() {
 
   useEffect(() => {
     connectWebsocket();
-    updateProductList();
+    loadProducts();
   }, []);
 
   return (
@@ -85,6 +114,9 @@ export default function ProductsList() {
         />
       )}
       <ProductsTable products={products} handleDelete={handleDelete} />
+      {nextCursor && !loading && (
+        <Button onClick={() => loadProducts(nextCursor)}>Load more</Button>
+      )}
     </>
   );
 }
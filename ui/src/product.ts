@@ -1,7 +1,59 @@
 export interface Product {
   id: number;
+  sku?: string;
   brand: string;
   category: string;
   quantity: number;
+  reserved?: number;
+  available?: number;
+  inTransit?: number;
   price: number;
+  reorderPoint?: number;
+  reorderQuantity?: number;
+  version?: number;
+}
+
+export interface ProductEvent {
+  seq: number;
+  type:
+    | "snapshot"
+    | "product.created"
+    | "product.updated"
+    | "product.deleted"
+    | "stock.low"
+    | "stock.out";
+  product?: Product;
+  products?: Product[];
+  createdAt: string;
+}
+
+// isStockAlert tells alerts apart from changes, an alert follows the
+// product.updated event that set it off and changes nothing itself.
+export function isStockAlert(event: ProductEvent): boolean {
+  return event.type === "stock.low" || event.type === "stock.out";
+}
+
+export function applyProductEvent(
+  products: Product[],
+  event: ProductEvent
+): Product[] {
+  const product = event.product;
+  switch (event.type) {
+    case "snapshot":
+      return event.products ?? [];
+    case "product.created":
+      if (!product) {
+        return products;
+      }
+      // a snapshot taken while the event was queued may already have it
+      return products.some((p) => p.id === product.id)
+        ? products.map((p) => (p.id === product.id ? product : p))
+        : [...products, product];
+    case "product.updated":
+      return products.map((p) => (product && p.id === product.id ? product : p));
+    case "product.deleted":
+      return products.filter((p) => !product || p.id !== product.id);
+    default:
+      return products;
+  }
 }
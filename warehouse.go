@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+)
+
+type Warehouse struct {
+	Id        int       `json:"id" bun:"id,pk,autoincrement"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Location is a bin inside a warehouse, addressed by aisle, rack and bin.
+type Location struct {
+	Id          int       `json:"id" bun:"id,pk,autoincrement"`
+	WarehouseId int       `json:"warehouseId"`
+	Code        string    `json:"code"`
+	Aisle       string    `json:"aisle"`
+	Rack        string    `json:"rack"`
+	Bin         string    `json:"bin"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// StockLevel is the quantity of a product held in a single location.
+type StockLevel struct {
+	ProductId  int `json:"productId" bun:",pk"`
+	LocationId int `json:"locationId" bun:",pk"`
+	Quantity   int `json:"quantity"`
+}
+
+func validateWarehouse(warehouse Warehouse) error {
+	failures := make([]string, 0)
+
+	if warehouse.Code == "" {
+		failures = append(failures, "Code should not be empty")
+	}
+	if warehouse.Name == "" {
+		failures = append(failures, "Name should not be empty")
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+func validateLocation(location Location) error {
+	failures := make([]string, 0)
+
+	if location.Code == "" {
+		failures = append(failures, "Code should not be empty")
+	}
+	if location.Aisle == "" {
+		failures = append(failures, "Aisle should not be empty")
+	}
+	if location.Bin == "" {
+		failures = append(failures, "Bin should not be empty")
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type warehouseTransport struct {
+	service WarehouseService
+}
+
+func NewWarehouseTransport(svc WarehouseService) *warehouseTransport {
+	return &warehouseTransport{
+		service: svc,
+	}
+}
+
+func (t *warehouseTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/warehouses", t.CreateWarehouse).Methods("POST")
+	r.HandleFunc("/warehouses", t.GetWarehouses).Methods("GET")
+	r.HandleFunc("/warehouses/{id}", t.GetWarehouseById).Methods("GET")
+	r.HandleFunc("/warehouses/{id}/locations", t.CreateLocation).Methods("POST")
+	r.HandleFunc("/warehouses/{id}/locations", t.GetLocations).Methods("GET")
+	r.HandleFunc("/warehouses/{id}/stock", t.GetStockByWarehouse).Methods("GET")
+	r.HandleFunc("/locations/{id}/stock", t.GetStockByLocation).Methods("GET")
+	r.HandleFunc("/products/{id}/stock", t.GetStockByProduct).Methods("GET")
+}
+
+func (t *warehouseTransport) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var warehouse Warehouse
+	if err := json.NewDecoder(r.Body).Decode(&warehouse); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreateWarehouse(warehouse)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *warehouseTransport) GetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := t.service.GetWarehouses()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, warehouses)
+}
+
+func (t *warehouseTransport) GetWarehouseById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	warehouse, err := t.service.GetWarehouseById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, warehouse)
+}
+
+func (t *warehouseTransport) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var location Location
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	location.WarehouseId = id
+	created, err := t.service.CreateLocation(location)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *warehouseTransport) GetLocations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	locations, err := t.service.GetLocations(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, locations)
+}
+
+func (t *warehouseTransport) GetStockByWarehouse(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	stockLevels, err := t.service.GetStockByWarehouse(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stockLevels)
+}
+
+func (t *warehouseTransport) GetStockByLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	stockLevels, err := t.service.GetStockByLocation(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stockLevels)
+}
+
+func (t *warehouseTransport) GetStockByProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	stockLevels, err := t.service.GetStockByProduct(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stockLevels)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarehouseTransport(t *testing.T) {
+	repo := setupInMemoryRepo([]Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 0,
+			Price:    10,
+		},
+	})
+	handler := buildHttpHandler(
+		NewhttpTransport(NewProductServiceImpl(repo)),
+		NewWarehouseTransport(NewWarehouseServiceImpl(repo)),
+	)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "create warehouse",
+			method:         "POST",
+			url:            "/warehouses",
+			body:           `{"code": "BLR", "name": "Bangalore"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "duplicate warehouse",
+			method:         "POST",
+			url:            "/warehouses",
+			body:           `{"code": "BLR", "name": "Bangalore 2"}`,
+			wantStatusCode: http.StatusConflict,
+			wantResponse:   `{"errors": ["code exists"]}`,
+		},
+		{
+			name:           "invalid location",
+			method:         "POST",
+			url:            "/warehouses/1/locations",
+			body:           `{"code": "A-01-01"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["Aisle should not be empty", "Bin should not be empty"]}`,
+		},
+		{
+			name:           "create location",
+			method:         "POST",
+			url:            "/warehouses/1/locations",
+			body:           `{"code": "A-01-01", "aisle": "A", "rack": "01", "bin": "01"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "location in unknown warehouse",
+			method:         "POST",
+			url:            "/warehouses/2/locations",
+			body:           `{"code": "A-01-01", "aisle": "A", "rack": "01", "bin": "01"}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["warehouse not found"]}`,
+		},
+		{
+			name:           "receive into bin",
+			method:         "POST",
+			url:            "/products/1/movements",
+			body:           `{"type": "receipt", "quantity": 6, "locationId": 1, "reason": "delivery"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "bin contents",
+			method:         "GET",
+			url:            "/locations/1/stock",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[{"productId": 1, "locationId": 1, "quantity": 6}]`,
+		},
+		{
+			name:           "warehouse stock",
+			method:         "GET",
+			url:            "/warehouses/1/stock",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[{"productId": 1, "locationId": 1, "quantity": 6}]`,
+		},
+		{
+			name:           "product stock",
+			method:         "GET",
+			url:            "/products/1/stock",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[{"productId": 1, "locationId": 1, "quantity": 6}]`,
+		},
+		{
+			name:           "unknown bin",
+			method:         "GET",
+			url:            "/locations/5/stock",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["location not found"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		var body *strings.Reader
+		if step.body != "" {
+			body = strings.NewReader(step.body)
+		} else {
+			body = strings.NewReader("")
+		}
+		r := httptest.NewRequest(step.method, step.url, body)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+}
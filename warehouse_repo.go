@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+)
+
+var (
+	errWarehouseNotFound = errors.New("warehouse not found")
+	errLocationNotFound  = errors.New("location not found")
+	errDuplicateCode     = errors.New("found duplicate code")
+)
+
+type WarehouseRepo interface {
+	CreateWarehouse(Warehouse) (Warehouse, error)
+	GetWarehouseById(id int) (Warehouse, error)
+	GetWarehouses() ([]Warehouse, error)
+	CreateLocation(Location) (Location, error)
+	GetLocationById(id int) (Location, error)
+	GetLocations(warehouseId int) ([]Location, error)
+	GetStockByWarehouse(warehouseId int) ([]StockLevel, error)
+	GetStockByLocation(locationId int) ([]StockLevel, error)
+	GetStockByProduct(productId int) ([]StockLevel, error)
+}
+
+func (r *InMemoryRepo) CreateWarehouse(warehouse Warehouse) (Warehouse, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	for _, currentWarehouse := range r.warehouses {
+		if currentWarehouse.Code == warehouse.Code {
+			return Warehouse{}, errDuplicateCode
+		}
+	}
+	r.lastWarehouseId++
+	warehouse.Id = r.lastWarehouseId
+	r.warehouses = append(r.warehouses, warehouse)
+	return warehouse, nil
+}
+
+func (r *InMemoryRepo) GetWarehouseById(id int) (Warehouse, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentWarehouse := range r.warehouses {
+		if currentWarehouse.Id == id {
+			return currentWarehouse, nil
+		}
+	}
+	return Warehouse{}, errWarehouseNotFound
+}
+
+func (r *InMemoryRepo) GetWarehouses() ([]Warehouse, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	warehouses := make([]Warehouse, len(r.warehouses))
+	copy(warehouses, r.warehouses)
+	return warehouses, nil
+}
+
+func (r *InMemoryRepo) CreateLocation(location Location) (Location, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	if _, err := r.GetWarehouseById(location.WarehouseId); err != nil {
+		return Location{}, err
+	}
+	for _, currentLocation := range r.locations {
+		if currentLocation.WarehouseId == location.WarehouseId && currentLocation.Code == location.Code {
+			return Location{}, errDuplicateCode
+		}
+	}
+	r.lastLocationId++
+	location.Id = r.lastLocationId
+	r.locations = append(r.locations, location)
+	return location, nil
+}
+
+func (r *InMemoryRepo) GetLocationById(id int) (Location, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentLocation := range r.locations {
+		if currentLocation.Id == id {
+			return currentLocation, nil
+		}
+	}
+	return Location{}, errLocationNotFound
+}
+
+func (r *InMemoryRepo) GetLocations(warehouseId int) ([]Location, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetWarehouseById(warehouseId); err != nil {
+		return nil, err
+	}
+
+	locations := make([]Location, 0)
+	for _, currentLocation := range r.locations {
+		if currentLocation.WarehouseId == warehouseId {
+			locations = append(locations, currentLocation)
+		}
+	}
+	return locations, nil
+}
+
+func (r *InMemoryRepo) GetStockByWarehouse(warehouseId int) ([]StockLevel, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	locations, err := r.GetLocations(warehouseId)
+	if err != nil {
+		return nil, err
+	}
+
+	stockLevels := make([]StockLevel, 0)
+	for _, location := range locations {
+		for _, stockLevel := range r.stockLevels {
+			if stockLevel.LocationId == location.Id {
+				stockLevels = append(stockLevels, stockLevel)
+			}
+		}
+	}
+	return stockLevels, nil
+}
+
+func (r *InMemoryRepo) GetStockByLocation(locationId int) ([]StockLevel, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetLocationById(locationId); err != nil {
+		return nil, err
+	}
+
+	stockLevels := make([]StockLevel, 0)
+	for _, stockLevel := range r.stockLevels {
+		if stockLevel.LocationId == locationId {
+			stockLevels = append(stockLevels, stockLevel)
+		}
+	}
+	return stockLevels, nil
+}
+
+func (r *InMemoryRepo) GetStockByProduct(productId int) ([]StockLevel, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetById(productId); err != nil {
+		return nil, err
+	}
+
+	stockLevels := make([]StockLevel, 0)
+	for _, stockLevel := range r.stockLevels {
+		if stockLevel.ProductId == productId {
+			stockLevels = append(stockLevels, stockLevel)
+		}
+	}
+	return stockLevels, nil
+}
+
+func (r *InMemoryRepo) stockLevel(productId, locationId int) int {
+	for _, stockLevel := range r.stockLevels {
+		if stockLevel.ProductId == productId && stockLevel.LocationId == locationId {
+			return stockLevel.Quantity
+		}
+	}
+	return 0
+}
+
+// adjustStockLevel applies delta to the bin, dropping levels that reach zero
+// so a bin only lists what physically sits in it.
+func (r *InMemoryRepo) adjustStockLevel(productId, locationId, delta int) {
+	for idx, stockLevel := range r.stockLevels {
+		if stockLevel.ProductId == productId && stockLevel.LocationId == locationId {
+			r.stockLevels[idx].Quantity += delta
+			if r.stockLevels[idx].Quantity == 0 {
+				r.stockLevels = append(r.stockLevels[:idx], r.stockLevels[idx+1:]...)
+			}
+			return
+		}
+	}
+	r.stockLevels = append(r.stockLevels, StockLevel{ProductId: productId, LocationId: locationId, Quantity: delta})
+}
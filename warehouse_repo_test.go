@@ -0,0 +1,168 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWarehouseRepo(t *testing.T) *InMemoryRepo {
+	repo := setupInMemoryRepo([]Product{
+		{
+			Id:       1,
+			Brand:    "A",
+			Category: "A",
+			Quantity: 10,
+			Price:    10,
+		},
+	})
+
+	warehouse, err := repo.CreateWarehouse(Warehouse{Code: "BLR", Name: "Bangalore"})
+	assert.NoError(t, err, "create warehouse should succeed")
+	for _, code := range []string{"A-01", "A-02"} {
+		_, err := repo.CreateLocation(Location{WarehouseId: warehouse.Id, Code: code, Aisle: "A", Bin: code})
+		assert.NoError(t, err, "create location should succeed")
+	}
+	return repo
+}
+
+func TestInMemoryRepo_CreateLocation(t *testing.T) {
+	tests := []struct {
+		name          string
+		location      Location
+		wantLocations []Location
+		wantErr       error
+	}{
+		{
+			name:     "location created",
+			location: Location{WarehouseId: 1, Code: "B-01", Aisle: "B", Bin: "01"},
+			wantLocations: []Location{
+				{Id: 1, WarehouseId: 1, Code: "A-01", Aisle: "A", Bin: "A-01"},
+				{Id: 2, WarehouseId: 1, Code: "A-02", Aisle: "A", Bin: "A-02"},
+				{Id: 3, WarehouseId: 1, Code: "B-01", Aisle: "B", Bin: "01"},
+			},
+			wantErr: nil,
+		},
+		{
+			name:     "duplicate code in warehouse",
+			location: Location{WarehouseId: 1, Code: "A-01", Aisle: "A", Bin: "01"},
+			wantLocations: []Location{
+				{Id: 1, WarehouseId: 1, Code: "A-01", Aisle: "A", Bin: "A-01"},
+				{Id: 2, WarehouseId: 1, Code: "A-02", Aisle: "A", Bin: "A-02"},
+			},
+			wantErr: errDuplicateCode,
+		},
+		{
+			name:     "warehouse not found",
+			location: Location{WarehouseId: 7, Code: "A-01", Aisle: "A", Bin: "01"},
+			wantLocations: []Location{
+				{Id: 1, WarehouseId: 1, Code: "A-01", Aisle: "A", Bin: "A-01"},
+				{Id: 2, WarehouseId: 1, Code: "A-02", Aisle: "A", Bin: "A-02"},
+			},
+			wantErr: errWarehouseNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupWarehouseRepo(t)
+
+			_, err := repo.CreateLocation(tt.location)
+
+			assert.ErrorIs(t, err, tt.wantErr, "error should match")
+			assert.Equal(t, tt.wantLocations, repo.locations, "expect same locations")
+		})
+	}
+}
+
+func TestInMemoryRepo_AddMovementWithLocation(t *testing.T) {
+	tests := []struct {
+		name            string
+		movements       []StockMovement
+		wantQuantity    int
+		wantStockLevels []StockLevel
+		wantErr         error
+	}{
+		{
+			name: "receipt into bin",
+			movements: []StockMovement{
+				{ProductId: 1, Type: MovementReceipt, Quantity: 4, LocationId: 1, Reason: "delivery"},
+			},
+			wantQuantity: 14,
+			wantStockLevels: []StockLevel{
+				{ProductId: 1, LocationId: 1, Quantity: 4},
+			},
+		},
+		{
+			name: "transfer between bins keeps total",
+			movements: []StockMovement{
+				{ProductId: 1, Type: MovementReceipt, Quantity: 4, LocationId: 1, Reason: "delivery"},
+				{ProductId: 1, Type: MovementTransfer, Quantity: 4, LocationId: 1, ToLocationId: 2, Reason: "replenish"},
+			},
+			wantQuantity: 14,
+			wantStockLevels: []StockLevel{
+				{ProductId: 1, LocationId: 2, Quantity: 4},
+			},
+		},
+		{
+			name: "issue more than the bin holds",
+			movements: []StockMovement{
+				{ProductId: 1, Type: MovementReceipt, Quantity: 1, LocationId: 1, Reason: "delivery"},
+				{ProductId: 1, Type: MovementIssue, Quantity: 2, LocationId: 1, Reason: "picked"},
+			},
+			wantQuantity: 11,
+			wantStockLevels: []StockLevel{
+				{ProductId: 1, LocationId: 1, Quantity: 1},
+			},
+			wantErr: errInsufficientStock,
+		},
+		{
+			name: "unknown location",
+			movements: []StockMovement{
+				{ProductId: 1, Type: MovementReceipt, Quantity: 1, LocationId: 9, Reason: "delivery"},
+			},
+			wantQuantity:    10,
+			wantStockLevels: []StockLevel{},
+			wantErr:         errLocationNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupWarehouseRepo(t)
+
+			var err error
+			for _, movement := range tt.movements {
+				_, err = repo.AddMovement(movement)
+			}
+
+			assert.ErrorIs(t, err, tt.wantErr, "error should match")
+			assert.Equal(t, tt.wantQuantity, repo.products[0].Quantity, "expect same total quantity")
+			assert.Equal(t, tt.wantStockLevels, repo.stockLevels, "expect same stock levels")
+		})
+	}
+}
+
+func TestInMemoryRepo_GetStockByWarehouse(t *testing.T) {
+	repo := setupWarehouseRepo(t)
+	other, err := repo.CreateWarehouse(Warehouse{Code: "DEL", Name: "Delhi"})
+	assert.NoError(t, err, "create warehouse should succeed")
+	otherLocation, err := repo.CreateLocation(Location{WarehouseId: other.Id, Code: "A-01", Aisle: "A", Bin: "01"})
+	assert.NoError(t, err, "create location should succeed")
+
+	repo.stockLevels = []StockLevel{
+		{ProductId: 1, LocationId: 1, Quantity: 2},
+		{ProductId: 1, LocationId: otherLocation.Id, Quantity: 5},
+		{ProductId: 1, LocationId: 2, Quantity: 3},
+	}
+
+	stockLevels, err := repo.GetStockByWarehouse(1)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, []StockLevel{
+		{ProductId: 1, LocationId: 1, Quantity: 2},
+		{ProductId: 1, LocationId: 2, Quantity: 3},
+	}, stockLevels, "expect only stock of warehouse 1")
+
+	_, err = repo.GetStockByWarehouse(9)
+	assert.ErrorIs(t, err, errWarehouseNotFound, "expect warehouse not found")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type WarehouseService interface {
+	CreateWarehouse(Warehouse) (Warehouse, error)
+	GetWarehouseById(id int) (Warehouse, error)
+	GetWarehouses() ([]Warehouse, error)
+	CreateLocation(Location) (Location, error)
+	GetLocations(warehouseId int) ([]Location, error)
+	GetStockByWarehouse(warehouseId int) ([]StockLevel, error)
+	GetStockByLocation(locationId int) ([]StockLevel, error)
+	GetStockByProduct(productId int) ([]StockLevel, error)
+}
+
+type WarehouseServiceImpl struct {
+	repo WarehouseRepo
+}
+
+func NewWarehouseServiceImpl(repo WarehouseRepo) *WarehouseServiceImpl {
+	return &WarehouseServiceImpl{
+		repo: repo,
+	}
+}
+
+func (s *WarehouseServiceImpl) CreateWarehouse(warehouse Warehouse) (Warehouse, error) {
+	if err := validateWarehouse(warehouse); err != nil {
+		return Warehouse{}, fmt.Errorf("create warehouse: %w", err)
+	}
+
+	warehouse.CreatedAt = time.Now()
+	return s.repo.CreateWarehouse(warehouse)
+}
+
+func (s *WarehouseServiceImpl) GetWarehouseById(id int) (Warehouse, error) {
+	return s.repo.GetWarehouseById(id)
+}
+
+func (s *WarehouseServiceImpl) GetWarehouses() ([]Warehouse, error) {
+	return s.repo.GetWarehouses()
+}
+
+func (s *WarehouseServiceImpl) CreateLocation(location Location) (Location, error) {
+	if err := validateLocation(location); err != nil {
+		return Location{}, fmt.Errorf("create location: %w", err)
+	}
+
+	location.CreatedAt = time.Now()
+	return s.repo.CreateLocation(location)
+}
+
+func (s *WarehouseServiceImpl) GetLocations(warehouseId int) ([]Location, error) {
+	return s.repo.GetLocations(warehouseId)
+}
+
+func (s *WarehouseServiceImpl) GetStockByWarehouse(warehouseId int) ([]StockLevel, error) {
+	return s.repo.GetStockByWarehouse(warehouseId)
+}
+
+func (s *WarehouseServiceImpl) GetStockByLocation(locationId int) ([]StockLevel, error) {
+	return s.repo.GetStockByLocation(locationId)
+}
+
+func (s *WarehouseServiceImpl) GetStockByProduct(productId int) ([]StockLevel, error) {
+	return s.repo.GetStockByProduct(productId)
+}
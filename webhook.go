@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Statuses of a webhook delivery. A delivery is retried while pending and
+// ends up dead, the dead-letter list, once it ran out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// webhookEventTypes are the events a webhook can ask for.
+var webhookEventTypes = []string{EventProductCreated, EventProductUpdated, EventProductDeleted, EventStockLow, EventStockOut}
+
+// Webhook is an endpoint product events are posted to. A webhook without
+// Events gets every event. Secret signs the payloads, it is only returned
+// when the webhook is created.
+type Webhook struct {
+	Id        int       `json:"id" bun:"id,pk,autoincrement"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events" bun:",array"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WebhookDelivery is one event to be posted to one webhook, along with how
+// the attempts so far went.
+type WebhookDelivery struct {
+	Id             int             `json:"id" bun:"id,pk,autoincrement"`
+	WebhookId      int             `json:"webhookId"`
+	EventSeq       int64           `json:"eventSeq"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload" bun:"type:jsonb"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty" bun:",nullzero"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    time.Time       `json:"deliveredAt,omitempty" bun:",nullzero"`
+}
+
+func validateWebhook(webhook Webhook) error {
+	failures := make([]string, 0)
+
+	if webhook.Url == "" {
+		failures = append(failures, "Url should not be empty")
+	} else if u, err := url.Parse(webhook.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		failures = append(failures, "Url should be an absolute http or https url")
+	}
+	for _, eventType := range webhook.Events {
+		if !containsString(webhookEventTypes, eventType) {
+			failures = append(failures, fmt.Sprintf("Events should only contain %v, got %q", webhookEventTypes, eventType))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{failures: failures}
+}
+
+func (w Webhook) wants(eventType string) bool {
+	return len(w.Events) == 0 || containsString(w.Events, eventType)
+}
+
+func containsString(values []string, value string) bool {
+	for _, current := range values {
+		if current == value {
+			return true
+		}
+	}
+	return false
+}
+
+// signWebhookPayload signs the timestamp and body of a delivery, receivers
+// recompute it from the X-Webhook-Timestamp header and the raw body.
+func signWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is how long to wait after the given number of failed
+// attempts: base doubled for every attempt after the first, up to max.
+func webhookBackoff(attempts int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
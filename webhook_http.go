@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type webhookTransport struct {
+	service WebhookService
+}
+
+func NewWebhookTransport(svc WebhookService) *webhookTransport {
+	return &webhookTransport{
+		service: svc,
+	}
+}
+
+func (t *webhookTransport) registerRoutes(r *mux.Router) {
+	r.HandleFunc("/webhooks", t.CreateWebhook).Methods("POST")
+	r.HandleFunc("/webhooks", t.GetWebhooks).Methods("GET")
+	r.HandleFunc("/webhooks/dead-letters", t.GetDeadDeliveries).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", t.GetWebhookById).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", t.UpdateWebhook).Methods("PUT")
+	r.HandleFunc("/webhooks/{id}", t.DeleteWebhook).Methods("DELETE")
+	r.HandleFunc("/webhooks/{id}/deliveries", t.GetDeliveries).Methods("GET")
+	r.HandleFunc("/webhooks/deliveries/{id}/retry", t.RetryDelivery).Methods("POST")
+}
+
+func (t *webhookTransport) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	created, err := t.service.CreateWebhook(webhook)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (t *webhookTransport) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := t.service.GetWebhooks()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+func (t *webhookTransport) GetWebhookById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	webhook, err := t.service.GetWebhookById(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+func (t *webhookTransport) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var webhook Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	webhook.Id = id
+	updated, err := t.service.UpdateWebhook(webhook)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (t *webhookTransport) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	if err := t.service.DeleteWebhook(id); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *webhookTransport) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	deliveries, err := t.service.GetDeliveries(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (t *webhookTransport) GetDeadDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := t.service.GetDeadDeliveries()
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (t *webhookTransport) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	delivery, err := t.service.RetryDelivery(id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, delivery)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookTransport(t *testing.T) {
+	repo := setupInMemoryRepo(nil)
+	handler := buildHttpHandler(
+		NewhttpTransport(NewProductServiceImpl(repo)),
+		NewWebhookTransport(NewWebhookServiceImpl(repo)),
+	)
+
+	steps := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		wantStatusCode int
+		wantResponse   string
+	}{
+		{
+			name:           "invalid webhook",
+			method:         "POST",
+			url:            "/webhooks",
+			body:           `{"url": "ftp://example.com", "events": ["product.moved"]}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse: `{"errors": [
+				"Url should be an absolute http or https url",
+				"Events should only contain [product.created product.updated product.deleted stock.low stock.out], got \"product.moved\""
+			]}`,
+		},
+		{
+			name:           "create webhook",
+			method:         "POST",
+			url:            "/webhooks",
+			body:           `{"url": "https://example.com/hooks", "secret": "secret"}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "update webhook",
+			method:         "PUT",
+			url:            "/webhooks/1",
+			body:           `{"url": "https://example.com/products", "events": ["product.deleted"]}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "update unknown webhook",
+			method:         "PUT",
+			url:            "/webhooks/7",
+			body:           `{"url": "https://example.com/products"}`,
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["webhook not found"]}`,
+		},
+		{
+			name:           "delivery log",
+			method:         "GET",
+			url:            "/webhooks/1/deliveries",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "dead letters",
+			method:         "GET",
+			url:            "/webhooks/dead-letters",
+			wantStatusCode: http.StatusOK,
+			wantResponse:   `[]`,
+		},
+		{
+			name:           "retry unknown delivery",
+			method:         "POST",
+			url:            "/webhooks/deliveries/1/retry",
+			wantStatusCode: http.StatusNotFound,
+			wantResponse:   `{"errors": ["delivery not found"]}`,
+		},
+		{
+			name:           "invalid id",
+			method:         "GET",
+			url:            "/webhooks/abc",
+			wantStatusCode: http.StatusBadRequest,
+			wantResponse:   `{"errors": ["invalid id"]}`,
+		},
+	}
+
+	for _, step := range steps {
+		r := httptest.NewRequest(step.method, step.url, strings.NewReader(step.body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, step.wantStatusCode, w.Code, "expect same status code for %s", step.name)
+		if step.wantResponse != "" {
+			assert.JSONEq(t, step.wantResponse, w.Body.String(), "expect same response for %s", step.name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks/1", nil))
+	var webhook Webhook
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&webhook), "expect webhook response")
+	assert.Equal(t, "https://example.com/products", webhook.Url)
+	assert.Equal(t, []string{EventProductDeleted}, webhook.Events)
+	assert.Empty(t, webhook.Secret, "expect secret not to be returned")
+	stored, _ := repo.GetWebhookById(1)
+	assert.Equal(t, "secret", stored.Secret, "expect update to keep the secret")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/webhooks/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "expect deleted webhook to be gone")
+}
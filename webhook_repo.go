@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	errWebhookNotFound   = errors.New("webhook not found")
+	errDeliveryNotFound  = errors.New("delivery not found")
+	errDuplicateDelivery = errors.New("event was already queued for the webhook")
+	errDeliveryNotDead   = errors.New("delivery is not dead")
+)
+
+// WebhookRepo stores webhooks and their deliveries. Deleting a webhook
+// deletes its deliveries.
+type WebhookRepo interface {
+	CreateWebhook(Webhook) (Webhook, error)
+	GetWebhookById(id int) (Webhook, error)
+	GetWebhooks() ([]Webhook, error)
+	UpdateWebhook(Webhook) error
+	DeleteWebhook(id int) error
+	// CreateDelivery rejects a second delivery of the same event to the
+	// same webhook with errDuplicateDelivery.
+	CreateDelivery(WebhookDelivery) (WebhookDelivery, error)
+	// ClaimDeliveries returns up to limit pending deliveries that are due at
+	// now, oldest first, and moves their next attempt lease past now so no
+	// other worker claims them while they are being sent.
+	ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	UpdateDelivery(WebhookDelivery) error
+	GetDeliveryById(id int) (WebhookDelivery, error)
+	GetDeliveries(webhookId int) ([]WebhookDelivery, error)
+	GetDeadDeliveries() ([]WebhookDelivery, error)
+}
+
+func (r *InMemoryRepo) CreateWebhook(webhook Webhook) (Webhook, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	r.lastWebhookId++
+	webhook.Id = r.lastWebhookId
+	r.webhooks = append(r.webhooks, webhook)
+	return webhook, nil
+}
+
+func (r *InMemoryRepo) GetWebhookById(id int) (Webhook, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentWebhook := range r.webhooks {
+		if currentWebhook.Id == id {
+			return currentWebhook, nil
+		}
+	}
+	return Webhook{}, errWebhookNotFound
+}
+
+func (r *InMemoryRepo) GetWebhooks() ([]Webhook, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	webhooks := make([]Webhook, len(r.webhooks))
+	copy(webhooks, r.webhooks)
+	return webhooks, nil
+}
+
+func (r *InMemoryRepo) UpdateWebhook(webhook Webhook) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentWebhook := range r.webhooks {
+		if currentWebhook.Id == webhook.Id {
+			r.webhooks[idx] = webhook
+			return nil
+		}
+	}
+	return errWebhookNotFound
+}
+
+func (r *InMemoryRepo) DeleteWebhook(id int) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentWebhook := range r.webhooks {
+		if currentWebhook.Id == id {
+			r.webhooks = append(r.webhooks[:idx], r.webhooks[idx+1:]...)
+			deliveries := make([]WebhookDelivery, 0, len(r.deliveries))
+			for _, delivery := range r.deliveries {
+				if delivery.WebhookId != id {
+					deliveries = append(deliveries, delivery)
+				}
+			}
+			r.deliveries = deliveries
+			return nil
+		}
+	}
+	return errWebhookNotFound
+}
+
+func (r *InMemoryRepo) CreateDelivery(delivery WebhookDelivery) (WebhookDelivery, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	if _, err := r.GetWebhookById(delivery.WebhookId); err != nil {
+		return WebhookDelivery{}, err
+	}
+	for _, currentDelivery := range r.deliveries {
+		if currentDelivery.WebhookId == delivery.WebhookId && currentDelivery.EventSeq == delivery.EventSeq {
+			return WebhookDelivery{}, errDuplicateDelivery
+		}
+	}
+	r.lastDeliveryId++
+	delivery.Id = r.lastDeliveryId
+	r.deliveries = append(r.deliveries, delivery)
+	return delivery, nil
+}
+
+func (r *InMemoryRepo) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error) {
+	r, unlock := r.write()
+	defer unlock()
+
+	due := make([]int, 0)
+	for idx, delivery := range r.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, idx)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return r.deliveries[due[i]].NextAttemptAt.Before(r.deliveries[due[j]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]WebhookDelivery, 0, len(due))
+	for _, idx := range due {
+		r.deliveries[idx].NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, r.deliveries[idx])
+	}
+	return claimed, nil
+}
+
+func (r *InMemoryRepo) UpdateDelivery(delivery WebhookDelivery) error {
+	r, unlock := r.write()
+	defer unlock()
+
+	for idx, currentDelivery := range r.deliveries {
+		if currentDelivery.Id == delivery.Id {
+			r.deliveries[idx] = delivery
+			return nil
+		}
+	}
+	return errDeliveryNotFound
+}
+
+func (r *InMemoryRepo) GetDeliveryById(id int) (WebhookDelivery, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	for _, currentDelivery := range r.deliveries {
+		if currentDelivery.Id == id {
+			return currentDelivery, nil
+		}
+	}
+	return WebhookDelivery{}, errDeliveryNotFound
+}
+
+func (r *InMemoryRepo) GetDeliveries(webhookId int) ([]WebhookDelivery, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	if _, err := r.GetWebhookById(webhookId); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]WebhookDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookId == webhookId {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *InMemoryRepo) GetDeadDeliveries() ([]WebhookDelivery, error) {
+	r, unlock := r.read()
+	defer unlock()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.Status == DeliveryDead {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryRepo_ClaimDeliveries(t *testing.T) {
+	repo := NewInMemoryRepo()
+	webhook, err := repo.CreateWebhook(Webhook{Url: "https://example.com"})
+	assert.NoError(t, err, "create webhook should succeed")
+
+	now := time.Date(2023, 11, 27, 12, 0, 0, 0, time.UTC)
+	for seq, at := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(time.Minute)} {
+		_, err := repo.CreateDelivery(WebhookDelivery{WebhookId: webhook.Id, EventSeq: int64(seq + 1), Status: DeliveryPending, NextAttemptAt: at})
+		assert.NoError(t, err, "create delivery should succeed")
+	}
+	_, err = repo.CreateDelivery(WebhookDelivery{WebhookId: webhook.Id, EventSeq: 1, Status: DeliveryPending})
+	assert.ErrorIs(t, err, errDuplicateDelivery, "expect an event to be queued once per webhook")
+	_, err = repo.CreateDelivery(WebhookDelivery{WebhookId: 7, EventSeq: 1})
+	assert.ErrorIs(t, err, errWebhookNotFound)
+
+	claimed, err := repo.ClaimDeliveries(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, claimed, 2, "expect only due deliveries") {
+		assert.Equal(t, 2, claimed[0].Id, "expect oldest due first")
+		assert.Equal(t, 1, claimed[1].Id)
+		assert.Equal(t, now.Add(time.Minute), claimed[0].NextAttemptAt, "expect claim to lease the delivery")
+	}
+
+	claimed, err = repo.ClaimDeliveries(now, time.Minute, 10)
+	assert.NoError(t, err, "expect no error")
+	assert.Empty(t, claimed, "expect leased deliveries not to be claimed again")
+
+	assert.NoError(t, repo.DeleteWebhook(webhook.Id), "delete webhook should succeed")
+	_, err = repo.GetDeliveryById(1)
+	assert.ErrorIs(t, err, errDeliveryNotFound, "expect deliveries to be deleted with the webhook")
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookBackoff     = 10 * time.Second
+	defaultWebhookMaxBackoff  = time.Hour
+	webhookTimeout            = 10 * time.Second
+	// webhookLease has to outlast the attempts at every delivery claimed
+	// with it, or another worker may claim a delivery while it is still
+	// waiting to be sent. So no more deliveries are claimed at once than can
+	// time out within the lease, with one attempt to spare for the lookups
+	// and updates around them.
+	webhookLease        = time.Minute
+	webhookPollInterval = 5 * time.Second
+	webhookClaimLimit   = int(webhookLease/webhookTimeout) - 1
+)
+
+type WebhookService interface {
+	CreateWebhook(Webhook) (Webhook, error)
+	GetWebhookById(id int) (Webhook, error)
+	GetWebhooks() ([]Webhook, error)
+	UpdateWebhook(Webhook) (Webhook, error)
+	DeleteWebhook(id int) error
+	GetDeliveries(webhookId int) ([]WebhookDelivery, error)
+	GetDeadDeliveries() ([]WebhookDelivery, error)
+	RetryDelivery(id int) (WebhookDelivery, error)
+}
+
+// WebhookServiceImpl subscribes to product events, queues a delivery for
+// every webhook that wants the event and, in Run, posts the due deliveries,
+// retrying failed ones with exponential backoff until maxAttempts.
+type WebhookServiceImpl struct {
+	repo        WebhookRepo
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	wake        chan struct{}
+	// now is read for every claim and every attempt, tests replace it
+	now func() time.Time
+}
+
+func NewWebhookServiceImpl(repo WebhookRepo) *WebhookServiceImpl {
+	return &WebhookServiceImpl{
+		repo:        repo,
+		client:      &http.Client{Timeout: webhookTimeout},
+		maxAttempts: defaultWebhookMaxAttempts,
+		backoff:     defaultWebhookBackoff,
+		maxBackoff:  defaultWebhookMaxBackoff,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// CreateWebhook generates a secret when none is given. The created webhook
+// is the only place the secret is returned.
+func (s *WebhookServiceImpl) CreateWebhook(webhook Webhook) (Webhook, error) {
+	if err := validateWebhook(webhook); err != nil {
+		return Webhook{}, fmt.Errorf("create webhook: %w", err)
+	}
+
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return Webhook{}, fmt.Errorf("create webhook: %w", err)
+		}
+		webhook.Secret = secret
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	return s.repo.CreateWebhook(webhook)
+}
+
+func (s *WebhookServiceImpl) GetWebhookById(id int) (Webhook, error) {
+	webhook, err := s.repo.GetWebhookById(id)
+	webhook.Secret = ""
+	return webhook, err
+}
+
+func (s *WebhookServiceImpl) GetWebhooks() ([]Webhook, error) {
+	webhooks, err := s.repo.GetWebhooks()
+	for idx := range webhooks {
+		webhooks[idx].Secret = ""
+	}
+	return webhooks, err
+}
+
+// UpdateWebhook keeps the stored secret unless a new one is given.
+func (s *WebhookServiceImpl) UpdateWebhook(webhook Webhook) (Webhook, error) {
+	if err := validateWebhook(webhook); err != nil {
+		return Webhook{}, fmt.Errorf("update webhook: %w", err)
+	}
+
+	stored, err := s.repo.GetWebhookById(webhook.Id)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = stored.Secret
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	webhook.CreatedAt = stored.CreatedAt
+	webhook.UpdatedAt = time.Now()
+	if err := s.repo.UpdateWebhook(webhook); err != nil {
+		return Webhook{}, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookServiceImpl) DeleteWebhook(id int) error {
+	return s.repo.DeleteWebhook(id)
+}
+
+func (s *WebhookServiceImpl) GetDeliveries(webhookId int) ([]WebhookDelivery, error) {
+	return s.repo.GetDeliveries(webhookId)
+}
+
+func (s *WebhookServiceImpl) GetDeadDeliveries() ([]WebhookDelivery, error) {
+	return s.repo.GetDeadDeliveries()
+}
+
+// RetryDelivery gives a dead delivery a fresh set of attempts, starting now.
+func (s *WebhookServiceImpl) RetryDelivery(id int) (WebhookDelivery, error) {
+	delivery, err := s.repo.GetDeliveryById(id)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if delivery.Status != DeliveryDead {
+		return WebhookDelivery{}, errDeliveryNotDead
+	}
+
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := s.repo.UpdateDelivery(delivery); err != nil {
+		return WebhookDelivery{}, err
+	}
+	s.notify()
+	return delivery, nil
+}
+
+func (s *WebhookServiceImpl) Id() string {
+	return "webhooks"
+}
+
+// Update queues the event for the webhooks that want it, see HandleEvent.
+func (s *WebhookServiceImpl) Update(event Event) {
+	if err := s.HandleEvent(event); err != nil {
+		log.Println("error while queueing webhook deliveries:", err)
+	}
+}
+
+// HandleEvent queues the event for the webhooks that want it. Snapshots are
+// not changes and are not delivered. An event handed over again is not
+// queued twice.
+func (s *WebhookServiceImpl) HandleEvent(event Event) error {
+	if event.Type == EventSnapshot {
+		return nil
+	}
+
+	if err := s.enqueue(event); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+func (s *WebhookServiceImpl) enqueue(event Event) error {
+	webhooks, err := s.repo.GetWebhooks()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		if !webhook.wants(event.Type) {
+			continue
+		}
+		delivery := WebhookDelivery{
+			WebhookId:     webhook.Id,
+			EventSeq:      event.Seq,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		// an event can come in more than once, through the relay or from the
+		// outbox, only the first time queues it
+		_, err := s.repo.CreateDelivery(delivery)
+		if err != nil && !errors.Is(err, errDuplicateDelivery) && !errors.Is(err, errWebhookNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *WebhookServiceImpl) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until ctx is done, waking up when an event is
+// queued and every webhookPollInterval for retries.
+func (s *WebhookServiceImpl) Run(ctx context.Context) error {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.deliverDue(); err != nil {
+			log.Println("error while delivering webhooks:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue sends the deliveries that are due, one after the other. Every
+// claim starts a lease of its own, so the lease of a delivery does not run
+// out while the ones claimed before it are sent.
+func (s *WebhookServiceImpl) deliverDue() error {
+	for {
+		deliveries, err := s.repo.ClaimDeliveries(s.now(), webhookLease, webhookClaimLimit)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		for _, delivery := range deliveries {
+			webhook, err := s.repo.GetWebhookById(delivery.WebhookId)
+			if errors.Is(err, errWebhookNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			delivery = s.attempt(webhook, delivery)
+			if err := s.repo.UpdateDelivery(delivery); err != nil && !errors.Is(err, errDeliveryNotFound) {
+				return err
+			}
+		}
+	}
+}
+
+// attempt posts the delivery and records the outcome: succeeded on a 2xx
+// response, otherwise pending again after a backoff, or dead once it ran
+// out of attempts. The payload is signed with the time it is sent at, so
+// receivers checking the timestamp do not reject deliveries sent late in a
+// batch.
+func (s *WebhookServiceImpl) attempt(webhook Webhook, delivery WebhookDelivery) WebhookDelivery {
+	now := s.now()
+	delivery.Attempts++
+	statusCode, err := s.post(webhook, delivery, now)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = now
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= s.maxAttempts {
+		delivery.Status = DeliveryDead
+		return delivery
+	}
+	delivery.Status = DeliveryPending
+	delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts, s.backoff, s.maxBackoff))
+	return delivery
+}
+
+func (s *WebhookServiceImpl) post(webhook Webhook, delivery WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.Itoa(delivery.Id))
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", signWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)); err != nil {
+		log.Println("error while reading webhook response:", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver records the requests posted to it and answers them with
+// statuses, in order, then with 200.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *webhookReceiver) received() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func setupWebhooks(t *testing.T, receiver http.Handler, events ...string) (*ProductServiceImpl, *WebhookServiceImpl, *InMemoryRepo) {
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	repo := setupInMemoryRepo(nil)
+	products := NewProductServiceImpl(repo)
+	webhooks := NewWebhookServiceImpl(repo)
+	webhooks.backoff = time.Second
+	webhooks.maxBackoff = time.Minute
+	webhooks.maxAttempts = 3
+	assert.NoError(t, products.subscribe(webhooks), "subscribe should succeed")
+
+	_, err := webhooks.CreateWebhook(Webhook{Url: server.URL, Secret: "secret", Events: events})
+	assert.NoError(t, err, "create webhook should succeed")
+	return products, webhooks, repo
+}
+
+// deliverDueAt runs deliverDue with the clock stopped at at.
+func deliverDueAt(webhooks *WebhookServiceImpl, at time.Time) error {
+	webhooks.now = func() time.Time { return at }
+	return webhooks.deliverDue()
+}
+
+func TestWebhookServiceImpl_deliver(t *testing.T) {
+	receiver := &webhookReceiver{}
+	products, webhooks, repo := setupWebhooks(t, receiver, EventProductCreated)
+
+	_, err := products.Create(Product{Brand: "A", Category: "A", Quantity: 1, Price: 10})
+	assert.NoError(t, err, "create should succeed")
+	_, err = products.AddMovement(StockMovement{ProductId: 1, Type: MovementReceipt, Quantity: 1, Reason: "restock"})
+	assert.NoError(t, err, "movement should succeed")
+	products.drain()
+
+	now := time.Now()
+	assert.NoError(t, deliverDueAt(webhooks, now), "expect no error")
+
+	if assert.Equal(t, 1, receiver.received(), "expect only the events the webhook wants") {
+		req, body := receiver.requests[0], receiver.bodies[0]
+		assert.Equal(t, EventProductCreated, req.Header.Get("X-Webhook-Event"))
+		assert.Equal(t, "1", req.Header.Get("X-Webhook-Id"))
+		assert.Equal(t, strconv.FormatInt(now.Unix(), 10), req.Header.Get("X-Webhook-Timestamp"))
+		assert.Equal(t, signWebhookPayload("secret", now.Unix(), body), req.Header.Get("X-Webhook-Signature"), "expect payload to be signed")
+
+		var event Event
+		assert.NoError(t, json.Unmarshal(body, &event), "expect event payload")
+		assert.Equal(t, EventProductCreated, event.Type)
+		assert.Equal(t, 1, event.Product.Id)
+	}
+
+	delivery, err := repo.GetDeliveryById(1)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+	assert.Equal(t, now, delivery.DeliveredAt)
+
+	assert.NoError(t, deliverDueAt(webhooks, now.Add(time.Hour)), "expect no error")
+	assert.Equal(t, 1, receiver.received(), "expect delivered events not to be sent again")
+}
+
+func TestWebhookServiceImpl_retry(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	products, webhooks, repo := setupWebhooks(t, receiver)
+
+	_, err := products.Create(Product{Brand: "A", Category: "A", Quantity: 1, Price: 10})
+	assert.NoError(t, err, "create should succeed")
+	products.drain()
+
+	now := time.Now()
+	assert.NoError(t, deliverDueAt(webhooks, now), "expect no error")
+	delivery, _ := repo.GetDeliveryById(1)
+	assert.Equal(t, DeliveryPending, delivery.Status)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.Equal(t, "unexpected status 500", delivery.LastError)
+	assert.Equal(t, now.Add(time.Second), delivery.NextAttemptAt, "expect first retry after the base backoff")
+
+	assert.NoError(t, deliverDueAt(webhooks, now.Add(500*time.Millisecond)), "expect no error")
+	assert.Equal(t, 1, receiver.received(), "expect no attempt before the backoff passed")
+
+	now = now.Add(time.Second)
+	assert.NoError(t, deliverDueAt(webhooks, now), "expect no error")
+	delivery, _ = repo.GetDeliveryById(1)
+	assert.Equal(t, now.Add(2*time.Second), delivery.NextAttemptAt, "expect backoff to double")
+
+	now = now.Add(2 * time.Second)
+	assert.NoError(t, deliverDueAt(webhooks, now), "expect no error")
+	delivery, _ = repo.GetDeliveryById(1)
+	assert.Equal(t, DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, "", delivery.LastError)
+	assert.Equal(t, 3, receiver.received())
+}
+
+func TestWebhookServiceImpl_deadLetter(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{500, 500, 500}}
+	products, webhooks, _ := setupWebhooks(t, receiver)
+
+	_, err := products.Create(Product{Brand: "A", Category: "A", Quantity: 1, Price: 10})
+	assert.NoError(t, err, "create should succeed")
+	products.drain()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, deliverDueAt(webhooks, now), "expect no error")
+		now = now.Add(time.Minute)
+	}
+
+	dead, err := webhooks.GetDeadDeliveries()
+	assert.NoError(t, err, "expect no error")
+	if assert.Len(t, dead, 1, "expect delivery to be dead after max attempts") {
+		assert.Equal(t, 3, dead[0].Attempts)
+	}
+	assert.NoError(t, deliverDueAt(webhooks, now.Add(time.Hour)), "expect no error")
+	assert.Equal(t, 3, receiver.received(), "expect dead deliveries not to be sent")
+
+	retried, err := webhooks.RetryDelivery(1)
+	assert.NoError(t, err, "expect no error")
+	assert.Equal(t, DeliveryPending, retried.Status)
+	assert.Equal(t, 0, retried.Attempts)
+	_, err = webhooks.RetryDelivery(1)
+	assert.ErrorIs(t, err, errDeliveryNotDead, "expect only dead deliveries to be retried")
+
+	assert.NoError(t, deliverDueAt(webhooks, time.Now()), "expect no error")
+	dead, _ = webhooks.GetDeadDeliveries()
+	assert.Empty(t, dead, "expect retried delivery to leave the dead letters")
+	assert.Equal(t, 4, receiver.received())
+}
+
+func TestWebhookServiceImpl_deliverSignsAtSendTime(t *testing.T) {
+	receiver := &webhookReceiver{}
+	products, webhooks, _ := setupWebhooks(t, receiver, EventProductCreated)
+
+	count := webhookClaimLimit + 2
+	for i := 0; i < count; i++ {
+		_, err := products.Create(Product{Brand: "A", Category: "A", Price: 10})
+		assert.NoError(t, err, "create should succeed")
+	}
+	products.drain()
+
+	// every send takes as long as it may, the clock moves on with each read
+	at := time.Now()
+	webhooks.now = func() time.Time {
+		at = at.Add(webhookTimeout)
+		return at
+	}
+	assert.NoError(t, webhooks.deliverDue(), "expect no error")
+
+	assert.Equal(t, count, receiver.received(), "expect every delivery to be sent once")
+	previous := int64(0)
+	for _, req := range receiver.requests {
+		timestamp, err := strconv.ParseInt(req.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		assert.NoError(t, err, "expect a timestamp")
+		assert.Greater(t, timestamp, previous, "expect each delivery to be signed when it is sent")
+		previous = timestamp
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name         string
+		webhook      Webhook
+		wantFailures []string
+	}{
+		{
+			name:    "valid webhook",
+			webhook: Webhook{Url: "https://example.com/hooks", Events: []string{EventProductCreated}},
+		},
+		{
+			name:         "empty url",
+			webhook:      Webhook{},
+			wantFailures: []string{"Url should not be empty"},
+		},
+		{
+			name:         "relative url",
+			webhook:      Webhook{Url: "/hooks"},
+			wantFailures: []string{"Url should be an absolute http or https url"},
+		},
+		{
+			name:         "unknown event",
+			webhook:      Webhook{Url: "http://example.com", Events: []string{EventSnapshot}},
+			wantFailures: []string{`Events should only contain [product.created product.updated product.deleted stock.low stock.out], got "snapshot"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWebhook(tt.webhook)
+			if tt.wantFailures == nil {
+				assert.NoError(t, err, "expect no error")
+				return
+			}
+			var ve *validationError
+			if assert.ErrorAs(t, err, &ve, "error should be of ValidationError type") {
+				assert.Equal(t, tt.wantFailures, ve.failures)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 6, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, webhookBackoff(tt.attempts, time.Second, 10*time.Second), "attempts %d", tt.attempts)
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	signature := signWebhookPayload("secret", 1700000000, []byte(`{"seq":1}`))
+
+	assert.Equal(t, "sha256=de44acd957813f3e827d17d95cf7d690ba2085a7f13db6d432fd9d5cff6fdf22", signature)
+	assert.NotEqual(t, signature, signWebhookPayload("other", 1700000000, []byte(`{"seq":1}`)), "expect the secret to change the signature")
+}
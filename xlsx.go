@@ -0,0 +1,311 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// The limits below bound what a workbook can make readXLSX allocate. A sheet
+// can hold no more rows than a header and maxImportRows records, no column
+// past XFD, the last one Excel has, and no more cells than xlsxMaxCells,
+// counting the empty ones that pad a row. Parts are uncompressed to at most
+// xlsxMaxPartSize bytes, so a small upload cannot expand without bound.
+const (
+	xlsxMaxRows     = maxImportRows + 1
+	xlsxMaxColumns  = 16384
+	xlsxMaxCells    = 1 << 21
+	xlsxMaxPartSize = 4 * maxImportSize
+)
+
+var errInvalidXLSX = errors.New("invalid xlsx file")
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Id string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	var sb strings.Builder
+	sb.WriteString(t.Text)
+	for _, run := range t.Runs {
+		sb.WriteString(run.Text)
+	}
+	return sb.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Number string `xml:"r,attr"`
+		Cells  []struct {
+			Ref       string   `xml:"r,attr"`
+			Type      string   `xml:"t,attr"`
+			Value     string   `xml:"v"`
+			InlineStr xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX returns the cell values of the first sheet of an xlsx workbook,
+// one slice per row with empty rows kept so row n is at index n-1. Only the
+// parts needed to read plain values are understood: shared strings, inline
+// strings, numbers and booleans.
+func readXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errInvalidXLSX
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("%w: no sheets", errInvalidXLSX)
+	}
+
+	var relationships xlsxRelationships
+	if err := readXLSXPart(files, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, relationship := range relationships.Relationships {
+		if relationship.Id == workbook.Sheets[0].Id {
+			sheetPath = relationship.Target
+		}
+	}
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(files, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxWorksheet
+	if err := readXLSXPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	cells := 0
+	for _, sheetRow := range sheet.Rows {
+		if number, err := strconv.Atoi(sheetRow.Number); err == nil {
+			if number > xlsxMaxRows {
+				return nil, &validationError{failures: []string{tooManyImportRows}}
+			}
+			for len(rows) < number-1 {
+				rows = append(rows, nil)
+			}
+		}
+		if len(rows) >= xlsxMaxRows {
+			return nil, &validationError{failures: []string{tooManyImportRows}}
+		}
+
+		row := make([]string, 0, len(sheetRow.Cells))
+		for _, cell := range sheetRow.Cells {
+			column := len(row)
+			if cell.Ref != "" {
+				if column, err = xlsxColumn(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if column >= xlsxMaxColumns {
+				return nil, fmt.Errorf("%w: cell %s is past column XFD", errInvalidXLSX, cell.Ref)
+			}
+			if cells += column + 1 - len(row); cells > xlsxMaxCells {
+				return nil, fmt.Errorf("%w: more than %d cells", errInvalidXLSX, xlsxMaxCells)
+			}
+			for len(row) <= column {
+				row = append(row, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, fmt.Errorf("%w: bad shared string in %s", errInvalidXLSX, cell.Ref)
+				}
+				row[column] = sharedStrings.Items[index].String()
+			case "inlineStr":
+				row[column] = cell.InlineStr.String()
+			case "b":
+				row[column] = strconv.FormatBool(cell.Value == "1")
+			default:
+				row[column] = cell.Value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readXLSXPart(files map[string]*zip.File, name string, v interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", errInvalidXLSX, name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: xlsxMaxPartSize + 1}
+	err = xml.NewDecoder(limited).Decode(v)
+	if limited.N <= 0 {
+		return fmt.Errorf("%w: %s is larger than %d bytes", errInvalidXLSX, name, xlsxMaxPartSize)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %s: %v", errInvalidXLSX, name, err)
+	}
+	return nil
+}
+
+// xlsxColumn turns the letters of a cell reference like "AB12" into a zero
+// based column index. References past XFD are rejected before they can
+// overflow.
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+		letters++
+		if column > xlsxMaxColumns {
+			return 0, fmt.Errorf("%w: cell %s is past column XFD", errInvalidXLSX, ref)
+		}
+	}
+	if letters == 0 {
+		return 0, fmt.Errorf("%w: bad cell reference %q", errInvalidXLSX, ref)
+	}
+	return column - 1, nil
+}
+
+// xlsxWriter streams a single sheet workbook. Cells are written as inline
+// strings and numbers, so no shared string table has to be kept in memory.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	row     int
+}
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{
+		name: "[Content_Types].xml",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`,
+	},
+	{
+		name: "_rels/.rels",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+	},
+	{
+		name: "xl/workbook.xml",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+	},
+	{
+		name: "xl/_rels/workbook.xml.rels",
+		content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+	},
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{archive: archive, sheet: sheet}, nil
+}
+
+// writeRow appends a row; ints and float64s become number cells, everything
+// else is written as text.
+func (x *xlsxWriter) writeRow(values ...interface{}) error {
+	x.row++
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<row r="%d">`, x.row)
+	for _, value := range values {
+		switch v := value.(type) {
+		case int:
+			fmt.Fprintf(&sb, `<c><v>%d</v></c>`, v)
+		case float64:
+			fmt.Fprintf(&sb, `<c><v>%s</v></c>`, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			sb.WriteString(`<c t="inlineStr"><is><t>`)
+			if err := xml.EscapeText(&sb, []byte(fmt.Sprint(v))); err != nil {
+				return err
+			}
+			sb.WriteString(`</t></is></c>`)
+		}
+	}
+	sb.WriteString(`</row>`)
+	_, err := io.WriteString(x.sheet, sb.String())
+	return err
+}
+
+func (x *xlsxWriter) close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.archive.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildXLSX zips the given parts into a workbook.
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatal("unable to create xlsx part:", err)
+		}
+		if _, err := part.Write([]byte(content)); err != nil {
+			t.Fatal("unable to write xlsx part:", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal("unable to close xlsx:", err)
+	}
+	return buf.Bytes()
+}
+
+var testWorkbookParts = map[string]string{
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <sheets><sheet name="Products" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`,
+	"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <si><t>brand</t></si>
+  <si><t>category</t></si>
+  <si><r><t>Sam</t></r><r><t>sung</t></r></si>
+</sst>`,
+	"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <sheetData>
+    <row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>price</t></is></c></row>
+    <row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><v>12.5</v></c></row>
+  </sheetData>
+</worksheet>`,
+}
+
+func TestReadXLSX(t *testing.T) {
+	rows, err := readXLSX(buildXLSX(t, testWorkbookParts))
+
+	assert.NoError(t, err, "read xlsx should succeed")
+	assert.Equal(t, [][]string{
+		{"brand", "category", "price"},
+		{"Samsung", "", "12.5"},
+	}, rows, "expect cell values by column")
+}
+
+func TestReadXLSX_Invalid(t *testing.T) {
+	_, err := readXLSX([]byte("brand,category"))
+	assert.ErrorIs(t, err, errInvalidXLSX, "expect a zip to be required")
+
+	_, err = readXLSX(buildXLSX(t, map[string]string{"xl/workbook.xml": "<workbook/>"}))
+	assert.ErrorIs(t, err, errInvalidXLSX, "expect a sheet to be required")
+}
+
+// sheetParts returns testWorkbookParts with sheet1.xml replaced by sheetData.
+func sheetParts(sheetData string) map[string]string {
+	parts := make(map[string]string, len(testWorkbookParts))
+	for name, content := range testWorkbookParts {
+		parts[name] = content
+	}
+	parts["xl/worksheets/sheet1.xml"] = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`
+	return parts
+}
+
+func TestReadXLSX_Limits(t *testing.T) {
+	_, err := readXLSX(buildXLSX(t, sheetParts(`<row r="1"><c r="XFE1"><v>1</v></c></row>`)))
+	assert.ErrorIs(t, err, errInvalidXLSX, "expect columns past XFD to be rejected")
+
+	_, err = readXLSX(buildXLSX(t, sheetParts(`<row r="1"><c r="AAAAAAAAAAAAAAAAAAAA1"><v>1</v></c></row>`)))
+	assert.ErrorIs(t, err, errInvalidXLSX, "expect a column reference that would overflow to be rejected")
+
+	_, err = readXLSX(buildXLSX(t, sheetParts(fmt.Sprintf(`<row r="%d"><c r="A1"><v>1</v></c></row>`, maxImportRows+2))))
+	var ve *validationError
+	assert.ErrorAs(t, err, &ve, "expect rows past the import limit to be rejected")
+
+	_, err = readXLSX(buildXLSX(t, sheetParts(strings.Repeat(`<row><c r="XFD1"><v>1</v></c></row>`, xlsxMaxCells/xlsxMaxColumns+1))))
+	assert.ErrorIs(t, err, errInvalidXLSX, "expect too many cells to be rejected")
+
+	_, err = readXLSX(buildXLSX(t, sheetParts(strings.Repeat(" ", xlsxMaxPartSize))))
+	assert.ErrorIs(t, err, errInvalidXLSX, "expect a part expanding past the size limit to be rejected")
+}
+
+func TestXlsxColumn(t *testing.T) {
+	tests := []struct {
+		ref        string
+		wantColumn int
+	}{
+		{ref: "A1", wantColumn: 0},
+		{ref: "Z9", wantColumn: 25},
+		{ref: "AA10", wantColumn: 26},
+		{ref: "AB3", wantColumn: 27},
+		{ref: "XFD1", wantColumn: 16383},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			column, err := xlsxColumn(tt.ref)
+			assert.NoError(t, err, "expect a valid reference")
+			assert.Equal(t, tt.wantColumn, column)
+		})
+	}
+}